@@ -3,13 +3,15 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	stdpath "path/filepath"
-	"runtime"
+	"sync"
 	"time"
 
+	"SmoothScreen/pkg/events"
 	"SmoothScreen/pkg/ffmpeg"
 	"SmoothScreen/pkg/hook"
 	"SmoothScreen/pkg/io"
@@ -23,8 +25,10 @@ import (
 // App struct
 type App struct {
 	ctx            context.Context
+	bus            *events.Bus // 事件总线（前端、WebSocket 等都是订阅者）
 	ffmpegManager  *ffmpeg.FFmpegManager
 	recorder       *recorder.Recorder
+	input          *hook.InputService // 唯一的全局输入钩子（鼠标、键盘和快捷键共用）
 	mouseHook      *hook.MouseHook
 	keyboardHook   *hook.KeyboardHook
 	audioRecorder  *recorder.AudioRecorder
@@ -34,14 +38,41 @@ type App struct {
 	fileServer     *server.FileServer
 	videoWriter    *recorder.VideoWriter
 	exporter       *recorder.Exporter
-	gpuExporter    *recorder.GPUExporter
+	exportJobs     *recorder.ExportJobManager
+	audioMix       recorder.AudioMixConfig      // 混音配置（录制合并、音视频封装共用）
+	captionStyle   string                       // 导出时烧录字幕的样式预设（空字符串表示不烧录）
+	transcriber    recorder.Transcriber         // 语音识别器（为空时按需创建 whisper.cpp 识别器）
+	webcamOverlay  recorder.WebcamOverlayConfig // 导出时的摄像头画中画配置
+	exportTrims    []recorder.TimeRange         // 导出时剪掉的范围（章节时间随之调整）
+	typingZoom     bool                         // 导出时打字自动放大（默认关闭）
+	dragZoom       bool                         // 导出时缩放到拖动和选择范围（默认关闭）
+	replayConfig   recorder.ReplayConfig        // 回放缓冲配置
+	streamListener *ffmpeg.Process              // 本地推流测试接收端
+	triggers       *recorder.TriggerManager     // 自动录制触发器（启用后非空）
+	hotkeys        *hook.HotkeyService          // 全局快捷键
+	lastRecording  completeRecordingOptions     // 最近一次完整录制的选项（快捷键开始录制时沿用）
+
+	// 界面、触发器、快捷键和磁盘保护可能同时开始或停止完整录制，recordingMu 让这些操作依次执行
+	recordingMu sync.Mutex
+	lastResult  map[string]string // 最近一次停止完整录制的文件（重复停止时返回）
+}
+
+// completeRecordingOptions 完整录制的选项
+type completeRecordingOptions struct {
+	recordAudio    bool
+	recordKeyboard bool
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{
-		fileWriter:  io.NewFileWriter(),
-		videoWriter: recorder.NewVideoWriter(),
+		bus:           events.NewBus(),
+		fileWriter:    io.NewFileWriter(),
+		videoWriter:   recorder.NewVideoWriter(),
+		audioMix:      recorder.DefaultAudioMixConfig(),
+		webcamOverlay: recorder.DefaultWebcamOverlayConfig(),
+		replayConfig:  recorder.DefaultReplayConfig(),
+		lastRecording: completeRecordingOptions{recordAudio: true, recordKeyboard: true},
 	}
 }
 
@@ -49,12 +80,23 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
+	// 把总线事件转发给前端：鼠标移动限制为 20 Hz，音频电平来不及处理时只保留最新值
+	a.bus.Subscribe(events.SubscribeOptions{
+		Limits: map[string]events.Limit{
+			hook.MouseMoveTopic.Name():      {Rate: 20, Coalesce: true},
+			recorder.AudioLevelTopic.Name(): {Coalesce: true},
+		},
+	}, a.emitToFrontend)
+
 	// 初始化 FFmpeg 管理器
 	a.ffmpegManager = ffmpeg.NewFFmpegManager(ctx)
+	a.ffmpegManager.SetProcessEventHandler(func(event ffmpeg.ProcessEvent) {
+		events.Publish(a.bus, recorder.FFmpegProcessTopic, event)
+	})
 
 	// 检查 FFmpeg 是否可用
 	if !a.ffmpegManager.CheckFFmpegAvailable() {
-		wailsruntime.EventsEmit(ctx, "ffmpeg-error", "未找到 FFmpeg 可执行文件，请确保 ffmpeg.exe 在正确位置")
+		events.Publish(a.bus, recorder.FFmpegErrorTopic, "未找到 FFmpeg 可执行文件，请在设置中指定路径、设置 SILKREC_FFMPEG 环境变量或安装到 PATH")
 		fmt.Println("警告: 未找到 FFmpeg 可执行文件")
 	} else {
 		// 获取 FFmpeg 版本
@@ -72,15 +114,56 @@ func (a *App) startup(ctx context.Context) {
 		}
 	}
 
+	// 初始化全局快捷键（设置保存在应用数据目录）
+	hotkeySettingsPath := ""
+	if dir, err := ffmpeg.AppDataDir(); err == nil {
+		hotkeySettingsPath = stdpath.Join(dir, "hotkeys.json")
+	}
+	a.hotkeys = hook.NewHotkeyService(hotkeySettingsPath)
+	a.hotkeys.SetEventHandler(a.handleHotkey)
+
+	// 初始化输入钩子，快捷键在应用运行期间一直生效
+	a.input = hook.NewInputService()
+	a.input.SetHotkeys(a.hotkeys)
+	a.input.Acquire()
+
 	// 初始化增强的鼠标钩子
-	a.mouseHook = hook.NewMouseHook(a.ctx)
+	a.mouseHook = hook.NewMouseHook(a.input, a.bus)
 	a.mouseHook.Start()
 
 	// 初始化录制管理器
 	a.recorder = recorder.NewRecorder(a.ffmpegManager, a.mouseHook, ctx)
+	a.recorder.SetCursorTracker(hook.NewCursorTracker(a.input, a.bus))
+	a.recorder.SetDiskEventHandler(func(event recorder.DiskSpaceEvent) {
+		events.Publish(a.bus, recorder.DiskSpaceTopic, event)
+	})
+	a.recorder.SetAutoStopHandler(func(event recorder.DiskSpaceEvent) {
+		// 同时停止音频和键盘录制，与手动停止完整录制一致
+		result, err := a.StopCompleteRecording()
+		payload := map[string]interface{}{
+			"reason":    event.Message,
+			"files":     result,
+			"timestamp": time.Now().Unix(),
+		}
+		if err != nil {
+			payload["error"] = err.Error()
+		}
+		events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{State: recorder.RecorderAutoStopped, Data: payload})
+	})
+
+	// 初始化导出任务管理器（同一时间只运行一个导出，其余排队）
+	a.exportJobs = recorder.NewExportJobManager(a.ffmpegManager, stdpath.Join("output", "export_jobs.json"), 1)
+	a.exportJobs.SetEventHandler(func(event recorder.ExportJobEvent) {
+		events.Publish(a.bus, recorder.ExportJobTopic, event)
+	})
 
 	// 初始化文件服务器（用于提供视频文件访问）
 	a.fileServer = server.NewFileServer("output", 8080)
+	a.fileServer.Handle("/events", events.NewWebSocketHandler(a.bus, events.SubscribeOptions{
+		Limits: map[string]events.Limit{
+			hook.MouseMoveTopic.Name(): {Rate: 30, Coalesce: true},
+		},
+	}))
 	if err := a.fileServer.Start(); err != nil {
 		fmt.Printf("启动文件服务器失败: %v\n", err)
 	}
@@ -88,18 +171,50 @@ func (a *App) startup(ctx context.Context) {
 	fmt.Println("SilkRec 应用已启动")
 }
 
+// emitToFrontend 把总线事件转发给前端（保持前端原有的事件名和参数）
+func (a *App) emitToFrontend(event events.Event) {
+	switch data := event.Data.(type) {
+	case hook.MouseEvent:
+		wailsruntime.EventsEmit(a.ctx, "mouse-position", data.X, data.Y, data.EventType, data.Button)
+	case recorder.RecorderStateEvent:
+		wailsruntime.EventsEmit(a.ctx, "recording-"+data.State, data.Data)
+	default:
+		wailsruntime.EventsEmit(a.ctx, event.Topic, event.Data)
+	}
+}
+
 // shutdown is called when app shuts down.
 func (a *App) shutdown(ctx context.Context) {
+	// 停用触发器，避免关闭过程中再次开始录制
+	if a.triggers != nil {
+		a.triggers.Disarm()
+	}
+
 	// 停止录制（如果正在录制）
 	if a.recorder != nil && a.recorder.IsRecording() {
 		a.recorder.StopRecording()
 	}
 
+	// 停止回放缓冲（丢弃未保存的内容）
+	if a.recorder != nil && a.recorder.IsReplaying() {
+		a.recorder.StopReplay()
+	}
+
+	// 停止本地推流接收端
+	if a.streamListener != nil {
+		a.streamListener.Stop()
+	}
+
 	// 停止 HTTP 管道服务器
 	if a.httpPipeServer != nil {
 		a.httpPipeServer.Stop()
 	}
 
+	// 取消所有未完成的导出任务
+	if a.exportJobs != nil {
+		a.exportJobs.CancelKind(recorder.ExportJobGPU, recorder.ExportJobGPUSegmented, recorder.ExportJobCustom)
+	}
+
 	// 停止文件服务器
 	if a.fileServer != nil {
 		a.fileServer.Stop()
@@ -113,6 +228,15 @@ func (a *App) shutdown(ctx context.Context) {
 	if a.mouseHook != nil {
 		a.mouseHook.Stop()
 	}
+	if a.keyboardHook != nil && a.keyboardHook.IsRecording() {
+		a.keyboardHook.StopRecording()
+	}
+	if a.input != nil {
+		a.input.Release()
+	}
+
+	// 取消所有事件订阅
+	a.bus.Close()
 
 	if a.fileWriter != nil && a.fileWriter.IsOpen() {
 		a.fileWriter.Close()
@@ -131,6 +255,41 @@ func (a *App) CheckFFmpegAvailable() bool {
 	return a.ffmpegManager.CheckFFmpegAvailable()
 }
 
+// GetFFmpegInfo 获取当前使用的 FFmpeg/ffprobe 路径及来源
+func (a *App) GetFFmpegInfo() (ffmpeg.FFmpegInfo, error) {
+	if a.ffmpegManager == nil {
+		return ffmpeg.FFmpegInfo{}, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	return a.ffmpegManager.GetFFmpegInfo()
+}
+
+// SetFFmpegPath 设置 FFmpeg 路径（空字符串表示恢复自动查找）
+func (a *App) SetFFmpegPath(path string) (ffmpeg.FFmpegInfo, error) {
+	if a.ffmpegManager == nil {
+		return ffmpeg.FFmpegInfo{}, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	if err := a.ffmpegManager.SetFFmpegPath(path); err != nil {
+		return ffmpeg.FFmpegInfo{}, err
+	}
+	return a.ffmpegManager.GetFFmpegInfo()
+}
+
+// GetFFmpegCapabilities 获取 FFmpeg 能力探测结果（编码器、硬件加速、滤镜等）
+func (a *App) GetFFmpegCapabilities() (*ffmpeg.Capabilities, error) {
+	if a.ffmpegManager == nil {
+		return nil, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	return a.ffmpegManager.Capabilities()
+}
+
+// InstallFFmpegArchive 校验并安装用户提供的 FFmpeg 压缩包
+func (a *App) InstallFFmpegArchive(archivePath string, sha256 string) (ffmpeg.FFmpegInfo, error) {
+	if a.ffmpegManager == nil {
+		return ffmpeg.FFmpegInfo{}, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	return a.ffmpegManager.InstallFromArchive(archivePath, sha256)
+}
+
 // StartScreenRecording 开始屏幕录制
 func (a *App) StartScreenRecording(videoPath string) error {
 	if a.recorder == nil {
@@ -142,9 +301,12 @@ func (a *App) StartScreenRecording(videoPath string) error {
 	}
 
 	// 发送录制开始事件
-	wailsruntime.EventsEmit(a.ctx, "recording-started", map[string]interface{}{
-		"videoPath": videoPath,
-		"timestamp": time.Now().Unix(),
+	events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{
+		State: recorder.RecorderStarted,
+		Data: map[string]interface{}{
+			"videoPath": videoPath,
+			"timestamp": time.Now().Unix(),
+		},
 	})
 
 	return nil
@@ -157,15 +319,32 @@ func (a *App) StopScreenRecording() (string, string, error) {
 	}
 
 	videoPath, mouseDataPath, err := a.recorder.StopRecording()
+	verification := a.recorder.GetVerification()
 	if err != nil {
-		return "", "", err
+		if verification != nil && !errors.Is(err, recorder.ErrNotRecording) {
+			// 输出校验失败时把报告发给前端
+			events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{
+				State: recorder.RecorderVerificationFailed,
+				Data: map[string]interface{}{
+					"videoPath":    videoPath,
+					"error":        err.Error(),
+					"verification": verification,
+				},
+			})
+		}
+		// 校验失败时录制文件仍然存在，路径随错误一起返回
+		return videoPath, mouseDataPath, err
 	}
 
 	// 发送录制停止事件
-	wailsruntime.EventsEmit(a.ctx, "recording-stopped", map[string]interface{}{
-		"videoPath":     videoPath,
-		"mouseDataPath": mouseDataPath,
-		"timestamp":     time.Now().Unix(),
+	events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{
+		State: recorder.RecorderStopped,
+		Data: map[string]interface{}{
+			"videoPath":     videoPath,
+			"mouseDataPath": mouseDataPath,
+			"verification":  verification,
+			"timestamp":     time.Now().Unix(),
+		},
 	})
 
 	return videoPath, mouseDataPath, nil
@@ -178,11 +357,30 @@ func (a *App) GetRecordingStatus() map[string]interface{} {
 	if a.recorder != nil {
 		recorderStatus := a.recorder.GetStatus()
 		status["isRecording"] = recorderStatus.IsRecording
+		status["isPaused"] = recorderStatus.IsPaused
 		status["outputPath"] = recorderStatus.OutputPath
 		status["mouseDataPath"] = recorderStatus.MouseDataPath
 		status["duration"] = recorderStatus.Duration
 		status["mouseEventCount"] = recorderStatus.MouseEventCount
 		status["ffmpegPID"] = recorderStatus.FFmpegPID
+		status["droppedFrames"] = recorderStatus.DroppedFrames
+		status["webcamRecording"] = recorderStatus.WebcamRecording
+		status["streams"] = recorderStatus.Streams
+		if recorderStatus.Replay != nil {
+			status["replay"] = recorderStatus.Replay
+		}
+		if recorderStatus.DiskSpace != nil {
+			status["diskSpace"] = recorderStatus.DiskSpace
+		}
+	}
+
+	if a.triggers != nil && a.triggers.IsArmed() {
+		status["triggers"] = a.triggers.GetStatus()
+	}
+
+	if a.audioRecorder != nil && a.audioRecorder.IsRecording() {
+		status["audioLevels"] = a.audioRecorder.GetLevels()
+		status["silentAudioTracks"] = a.audioRecorder.GetSilentTracks()
 	}
 
 	if a.ffmpegManager != nil {
@@ -472,41 +670,43 @@ func (a *App) FinalizeH264Export(h264Path string, audioPath string, outputPath s
 		return fmt.Errorf("FFmpeg 管理器未初始化")
 	}
 
-	ffmpegPath, err := a.ffmpegManager.GetFFmpegPath()
-	if err != nil {
-		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
-	}
-
 	// 构建 FFmpeg 命令
-	var args []string
+	command := ffmpeg.NewCommand().Overwrite()
+	video := command.Input(h264Path)
 
-	// 输入 H.264 文件
-	args = append(args, "-i", h264Path)
+	// 视频使用 copy 模式快速封装（无需重新编码）
+	output := command.Output(outputPath).Map(video.Video()).VideoCodec("copy")
 
-	// 如果有音频，添加音频输入
+	// 如果有音频，添加音频输入（WAV 等 PCM 音频不能直接封装进 MP4，需要编码为 AAC）
+	// 导出的视频与录制视频时间轴一致，按录制时记录的偏移对齐音频
+	audioTracks := 0
 	if audioPath != "" {
-		args = append(args, "-i", audioPath)
+		var err error
+		audioTracks, err = recorder.AddAudioTracks(command, output, recorder.FindAudioTracks(audioPath, "", a.audioMix), a.audioMix)
+		if err != nil {
+			return err
+		}
 	}
 
-	// 使用 copy 模式快速封装（无需重新编码）
-	args = append(args, "-c", "copy")
-
-	// 输出文件
-	args = append(args, outputPath)
+	args, err := command.Args()
+	if err != nil {
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
 
 	// 执行 FFmpeg 命令
-	cmd := exec.Command(ffmpegPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Printf("执行 FFmpeg 封装命令: %s %s\n", ffmpegPath, args)
-
-	if err := cmd.Run(); err != nil {
+	process, err := a.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: "H.264 封装"})
+	if err != nil {
+		return fmt.Errorf("创建 FFmpeg 进程失败: %w", err)
+	}
+	if err := process.Run(context.Background()); err != nil {
 		return fmt.Errorf("FFmpeg 封装失败: %w", err)
 	}
 
 	fmt.Printf("视频封装完成: %s\n", outputPath)
-	return nil
+
+	// 裸流没有时长信息，只校验流数量和可解码性
+	expect := ffmpeg.ProbeExpectation{VideoStreams: 1, AudioStreams: audioTracks}
+	return a.verifyOutput(h264Path, outputPath, expect)
 }
 
 // MuxH264ToMp4 将 H.264 裸流封装为 MP4
@@ -516,28 +716,30 @@ func (a *App) FinalizeH264Export(h264Path string, audioPath string, outputPath s
 //	outputPath: 最终的 .mp4 输出路径
 func (a *App) MuxH264ToMp4(inputPath string, outputPath string) error {
 	// 1. 确定 FFmpeg 可执行文件路径
-	ffmpegPath := "ffmpeg"
-
-	// 如果在 Windows 下，且项目中有 ffmpeg/ffmpeg.exe，可以使用相对路径查找
-	if runtime.GOOS == "windows" {
-		if _, err := os.Stat("ffmpeg/ffmpeg.exe"); err == nil {
-			absPath, _ := stdpath.Abs("ffmpeg/ffmpeg.exe")
-			ffmpegPath = absPath
-		}
+	if a.ffmpegManager == nil {
+		return fmt.Errorf("FFmpeg 管理器未初始化")
 	}
-
 	// 2. 构建命令
 	// -y: 覆盖输出文件
 	// -i inputPath: 输入文件
 	// -c copy: 核心参数！直接复制流，不重新编码（速度极快，无画质损失）
-	cmd := exec.Command(ffmpegPath, "-y", "-i", inputPath, "-c", "copy", outputPath)
+	command := ffmpeg.NewCommand().Overwrite()
+	command.Input(inputPath)
+	command.Output(outputPath).Codec("copy")
+	args, err := command.Args()
+	if err != nil {
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
 
 	// 3. 执行命令
 	fmt.Printf("执行 FFmpeg 封装: %s -> %s\n", inputPath, outputPath)
-	output, err := cmd.CombinedOutput()
+	process, err := a.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: "H.264 封装"})
 	if err != nil {
-		fmt.Printf("FFmpeg 执行失败: %s\nOutput: %s\n", err, string(output))
-		return fmt.Errorf("FFmpeg 封装失败: %v, 详细日志: %s", err, string(output))
+		return fmt.Errorf("创建 FFmpeg 进程失败: %w", err)
+	}
+	if err := process.Run(context.Background()); err != nil {
+		fmt.Printf("FFmpeg 执行失败: %v\nOutput: %s\n", err, process.Stderr())
+		return fmt.Errorf("FFmpeg 封装失败: %w", err)
 	}
 
 	fmt.Println("封装完成！")
@@ -557,88 +759,103 @@ func (a *App) DeleteFile(filePath string) error {
 
 // ExportWithGPU GPU 加速导出（推荐）
 // 使用 FFmpeg 硬件加速和滤镜链直接处理视频，无需前端渲染
-// 这是最高效的导出方法
+// 这是最高效的导出方法。导出作为任务排队执行，本方法等待任务结束
 func (a *App) ExportWithGPU(videoPath string, mouseDataPath string, outputPath string, screenWidth int, screenHeight int, fps int) error {
-	if a.ffmpegManager == nil {
-		return fmt.Errorf("FFmpeg 管理器未初始化")
-	}
-
-	// 创建 GPU 导出器
-	a.gpuExporter = recorder.NewGPUExporter(a.ffmpegManager)
-
-	// 配置
-	config := recorder.DefaultExportConfig()
-	config.VideoPath = videoPath
-	config.MouseDataPath = mouseDataPath
-	config.OutputPath = outputPath
-	config.ScreenWidth = screenWidth
-	config.ScreenHeight = screenHeight
-	config.FPS = fps
-
-	// 准备导出
-	if err := a.gpuExporter.PrepareExport(config); err != nil {
-		return fmt.Errorf("准备 GPU 导出失败: %w", err)
-	}
-
-	// 执行 GPU 加速导出
-	fmt.Println("开始 GPU 加速导出...")
-	if err := a.gpuExporter.ExportWithGPU(); err != nil {
-		return fmt.Errorf("GPU 导出失败: %w", err)
-	}
-
-	return nil
+	return a.runExportJob(recorder.ExportJobRequest{
+		Kind:   recorder.ExportJobGPU,
+		Config: newExportConfig(videoPath, mouseDataPath, outputPath, screenWidth, screenHeight, fps),
+	})
 }
 
 // ExportWithGPUSegmented GPU 加速分段导出
 // 使用分段处理获得更精确的相机控制
 // 适合长视频或需要精确相机运动的场景
 func (a *App) ExportWithGPUSegmented(videoPath string, mouseDataPath string, outputPath string, screenWidth int, screenHeight int, fps int) error {
-	if a.ffmpegManager == nil {
-		return fmt.Errorf("FFmpeg 管理器未初始化")
-	}
+	return a.runExportJob(recorder.ExportJobRequest{
+		Kind:   recorder.ExportJobGPUSegmented,
+		Config: newExportConfig(videoPath, mouseDataPath, outputPath, screenWidth, screenHeight, fps),
+	})
+}
 
-	// 创建 GPU 导出器
-	a.gpuExporter = recorder.NewGPUExporter(a.ffmpegManager)
+// StopGPUExport 停止 GPU 导出（取消所有未完成的 GPU 导出任务）
+func (a *App) StopGPUExport() error {
+	if a.exportJobs == nil {
+		return fmt.Errorf("导出任务管理器未初始化")
+	}
 
-	// 配置
-	config := recorder.DefaultExportConfig()
-	config.VideoPath = videoPath
-	config.MouseDataPath = mouseDataPath
-	config.OutputPath = outputPath
-	config.ScreenWidth = screenWidth
-	config.ScreenHeight = screenHeight
-	config.FPS = fps
+	a.exportJobs.CancelKind(recorder.ExportJobGPU, recorder.ExportJobGPUSegmented)
+	return nil
+}
 
-	// 准备导出
-	if err := a.gpuExporter.PrepareExport(config); err != nil {
-		return fmt.Errorf("准备 GPU 分段导出失败: %w", err)
+// GetGPUExportProgress 获取 GPU 导出进度（0-100）
+func (a *App) GetGPUExportProgress() float64 {
+	if a.exportJobs == nil {
+		return 0.0
 	}
 
-	// 执行分段导出
-	fmt.Println("开始 GPU 加速分段导出...")
-	if err := a.gpuExporter.ExportWithSegments(); err != nil {
-		return fmt.Errorf("GPU 分段导出失败: %w", err)
+	job, ok := a.exportJobs.LatestRunning(recorder.ExportJobGPU, recorder.ExportJobGPUSegmented)
+	if !ok {
+		return 0.0
 	}
+	return job.Progress
+}
 
-	return nil
+// ========== 导出任务 API ==========
+
+// ListExportJobs 列出所有导出任务（包括历史）
+func (a *App) ListExportJobs() []recorder.ExportJob {
+	if a.exportJobs == nil {
+		return []recorder.ExportJob{}
+	}
+	return a.exportJobs.List()
 }
 
-// StopGPUExport 停止 GPU 导出
-func (a *App) StopGPUExport() error {
-	if a.gpuExporter == nil {
-		return fmt.Errorf("GPU 导出器未初始化")
+// CancelExportJob 取消导出任务
+func (a *App) CancelExportJob(jobID string) error {
+	if a.exportJobs == nil {
+		return fmt.Errorf("导出任务管理器未初始化")
 	}
+	return a.exportJobs.Cancel(jobID)
+}
 
-	return a.gpuExporter.Stop()
+// RetryExportJob 以相同参数重试已结束的导出任务，返回新任务 ID
+func (a *App) RetryExportJob(jobID string) (string, error) {
+	if a.exportJobs == nil {
+		return "", fmt.Errorf("导出任务管理器未初始化")
+	}
+	return a.exportJobs.Retry(jobID)
 }
 
-// GetGPUExportProgress 获取 GPU 导出进度
-func (a *App) GetGPUExportProgress() float64 {
-	if a.gpuExporter == nil {
-		return 0.0
+// runExportJob 提交导出任务并等待完成
+func (a *App) runExportJob(request recorder.ExportJobRequest) error {
+	if a.exportJobs == nil {
+		return fmt.Errorf("导出任务管理器未初始化")
+	}
+
+	request.Config.CaptionStyle = a.captionStyle
+	request.Config.Webcam = a.webcamOverlay
+	request.Config.Trims = a.exportTrims
+	request.Config.EnableTypingZoom = a.typingZoom
+	request.Config.EnableDragZoom = a.dragZoom
+	jobID, err := a.exportJobs.Submit(request)
+	if err != nil {
+		return err
 	}
 
-	return a.gpuExporter.GetProgress()
+	fmt.Printf("导出任务已提交: %s (%s)\n", jobID, request.Kind)
+	return a.exportJobs.Wait(jobID)
+}
+
+// newExportConfig 根据前端参数创建导出配置
+func newExportConfig(videoPath string, mouseDataPath string, outputPath string, screenWidth int, screenHeight int, fps int) recorder.ExportConfig {
+	config := recorder.DefaultExportConfig()
+	config.VideoPath = videoPath
+	config.MouseDataPath = mouseDataPath
+	config.OutputPath = outputPath
+	config.ScreenWidth = screenWidth
+	config.ScreenHeight = screenHeight
+	config.FPS = fps
+	return config
 }
 
 // ========== 增强导出相关 API（带相机运动） ==========
@@ -657,6 +874,8 @@ func (a *App) PrepareExport(videoPath string, mouseDataPath string, outputPath s
 	config.ScreenWidth = screenWidth
 	config.ScreenHeight = screenHeight
 	config.FPS = fps
+	config.EnableTypingZoom = a.typingZoom
+	config.EnableDragZoom = a.dragZoom
 
 	// 创建导出器
 	a.exporter = recorder.NewExporter(a.ffmpegManager, config)
@@ -717,11 +936,18 @@ func (a *App) GetExportInfo() map[string]interface{} {
 
 // StartKeyboardRecording 开始录制键盘事件
 func (a *App) StartKeyboardRecording() error {
+	return a.getKeyboardHook().StartRecording()
+}
+
+// getKeyboardHook 获取键盘钩子（首次使用时创建）
+func (a *App) getKeyboardHook() *hook.KeyboardHook {
 	if a.keyboardHook == nil {
-		a.keyboardHook = hook.NewKeyboardHook()
+		a.keyboardHook = hook.NewKeyboardHook(a.input)
+		a.keyboardHook.SetEventHandler(func(event hook.KeyboardEvent) {
+			events.Publish(a.bus, hook.KeyboardTopic, event)
+		})
 	}
-
-	return a.keyboardHook.StartRecording()
+	return a.keyboardHook
 }
 
 // StopKeyboardRecording 停止录制键盘事件并返回文件路径
@@ -763,8 +989,14 @@ func (a *App) GetKeyboardEventCount() int {
 
 // ========== 音频录制 API ==========
 
-// StartAudioRecording 开始录制音频
+// StartAudioRecording 开始录制音频（使用默认设备）
 func (a *App) StartAudioRecording(recordSystem bool, recordMic bool) error {
+	return a.StartAudioRecordingWithDevices(recordSystem, recordMic, "", "")
+}
+
+// StartAudioRecordingWithDevices 使用指定设备开始录制音频
+// 设备 ID 来自 ListAudioDevices，空字符串表示默认设备
+func (a *App) StartAudioRecordingWithDevices(recordSystem bool, recordMic bool, systemDeviceID string, micDeviceID string) error {
 	if a.ffmpegManager == nil {
 		return fmt.Errorf("FFmpeg 管理器未初始化")
 	}
@@ -778,10 +1010,23 @@ func (a *App) StartAudioRecording(recordSystem bool, recordMic bool) error {
 	config := recorder.DefaultAudioConfig()
 	config.RecordSystemAudio = recordSystem
 	config.RecordMicrophone = recordMic
+	config.SystemDevice = systemDeviceID
+	config.MicDevice = micDeviceID
+	config.Mix = a.audioMix
 
 	// 创建音频录制器
 	a.audioRecorder = recorder.NewAudioRecorder(ffmpegPath, config)
 
+	// 实时电平（约 10 Hz）和无信号警告
+	a.audioRecorder.SetMeterHandler(func(level recorder.AudioLevel) {
+		events.Publish(a.bus, recorder.AudioLevelTopic, level)
+	}, func(event recorder.AudioSignalEvent) {
+		if event.Silent {
+			fmt.Printf("警告: %s 已 %.0f 秒没有信号\n", event.Device, event.Duration)
+		}
+		events.Publish(a.bus, recorder.AudioSignalTopic, event)
+	})
+
 	// 开始录制
 	return a.audioRecorder.StartRecording(config)
 }
@@ -805,13 +1050,27 @@ func (a *App) GetAudioRecordingStatus() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"isRecording": a.audioRecorder.IsRecording(),
-		"isPaused":    a.audioRecorder.IsPaused(),
+		"isRecording":  a.audioRecorder.IsRecording(),
+		"isPaused":     a.audioRecorder.IsPaused(),
+		"systemDevice": a.audioRecorder.GetSystemDevice(),
+		"micDevice":    a.audioRecorder.GetMicDevice(),
+		"levels":       a.audioRecorder.GetLevels(),
+		"silentTracks": a.audioRecorder.GetSilentTracks(),
 	}
 }
 
+// GetAudioMixConfig 获取混音配置
+func (a *App) GetAudioMixConfig() recorder.AudioMixConfig {
+	return a.audioMix
+}
+
+// SetAudioMixConfig 设置混音配置（对之后开始的录制和之后的合并、封装生效）
+func (a *App) SetAudioMixConfig(config recorder.AudioMixConfig) {
+	a.audioMix = config
+}
+
 // ListAudioDevices 列出可用的音频设备
-func (a *App) ListAudioDevices() ([]string, error) {
+func (a *App) ListAudioDevices() ([]recorder.AudioDevice, error) {
 	if a.ffmpegManager == nil {
 		return nil, fmt.Errorf("FFmpeg 管理器未初始化")
 	}
@@ -835,13 +1094,47 @@ func (a *App) MergeAudioWithVideo(videoPath string, audioPath string, outputPath
 		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
 	}
 
-	return recorder.MergeAudioWithVideo(ffmpegPath, videoPath, audioPath, outputPath)
+	audioTracks, err := recorder.MergeAudioWithVideo(ffmpegPath, videoPath, audioPath, outputPath, a.audioMix)
+	if err != nil {
+		return err
+	}
+
+	// 合并后的时长应不短于原视频
+	expect := ffmpeg.ProbeExpectation{VideoStreams: 1, AudioStreams: audioTracks}
+	if source, err := a.ffmpegManager.ProbeOutput(videoPath, ffmpeg.ProbeExpectation{SkipFrameCheck: true}); err == nil {
+		expect.Duration = source.Duration
+	}
+	return a.verifyOutput(videoPath, outputPath, expect)
+}
+
+// VerifyOutput 使用 ffprobe 校验媒体文件，返回校验报告
+func (a *App) VerifyOutput(path string) (*ffmpeg.ProbeReport, error) {
+	if a.ffmpegManager == nil {
+		return nil, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	return recorder.VerifyOutput(a.ffmpegManager, path, path, ffmpeg.ProbeExpectation{})
+}
+
+// verifyOutput 校验输出并写入会话，参数不符只打印警告
+func (a *App) verifyOutput(sessionVideoPath, outputPath string, expect ffmpeg.ProbeExpectation) error {
+	_, err := recorder.VerifyOutput(a.ffmpegManager, sessionVideoPath, outputPath, expect)
+	if errors.Is(err, ffmpeg.ErrOutputMismatch) {
+		fmt.Printf("警告: %v\n", err)
+		return nil
+	}
+	if err != nil && !errors.Is(err, ffmpeg.ErrProbeUnavailable) {
+		return fmt.Errorf("输出校验失败: %w", err)
+	}
+	return nil
 }
 
 // ========== 完整录制工作流（视频+音频+键盘）==========
 
 // StartCompleteRecording 开始完整录制（视频+音频+鼠标+键盘）
 func (a *App) StartCompleteRecording(videoPath string, recordAudio bool, recordKeyboard bool) error {
+	a.recordingMu.Lock()
+	defer a.recordingMu.Unlock()
+
 	// 1. 启动视频录制
 	if err := a.StartScreenRecording(videoPath); err != nil {
 		return fmt.Errorf("启动视频录制失败: %w", err)
@@ -863,46 +1156,604 @@ func (a *App) StartCompleteRecording(videoPath string, recordAudio bool, recordK
 		}
 	}
 
+	a.lastRecording = completeRecordingOptions{recordAudio: recordAudio, recordKeyboard: recordKeyboard}
+	a.lastResult = nil
 	fmt.Println("✓ 完整录制已启动")
 	return nil
 }
 
+// PauseCompleteRecording 暂停完整录制（视频、鼠标、键盘和音频对齐到同一暂停区间）
+func (a *App) PauseCompleteRecording() error {
+	a.recordingMu.Lock()
+	defer a.recordingMu.Unlock()
+
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	if err := a.recorder.PauseRecording(); err != nil {
+		return err
+	}
+
+	pauses := a.recorder.GetPauses()
+	pausedAt := pauses[len(pauses)-1].Start
+	if a.keyboardHook != nil && a.keyboardHook.IsRecording() {
+		a.keyboardHook.PauseRecordingAt(pausedAt)
+	}
+	if a.audioRecorder != nil && a.audioRecorder.IsRecording() {
+		a.audioRecorder.PauseRecording()
+	}
+
+	events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{
+		State: recorder.RecorderPaused,
+		Data: map[string]interface{}{
+			"timestamp": time.Now().Unix(),
+		},
+	})
+	return nil
+}
+
+// ResumeCompleteRecording 恢复完整录制
+func (a *App) ResumeCompleteRecording() error {
+	a.recordingMu.Lock()
+	defer a.recordingMu.Unlock()
+
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	if err := a.recorder.ResumeRecording(); err != nil {
+		return err
+	}
+
+	pauses := a.recorder.GetPauses()
+	resumedAt := pauses[len(pauses)-1].End
+	if a.keyboardHook != nil && a.keyboardHook.IsPaused() {
+		a.keyboardHook.ResumeRecordingAt(resumedAt)
+	}
+	if a.audioRecorder != nil && a.audioRecorder.IsPaused() {
+		a.audioRecorder.ResumeRecording()
+	}
+
+	events.Publish(a.bus, recorder.RecorderStateTopic, recorder.RecorderStateEvent{
+		State: recorder.RecorderResumed,
+		Data: map[string]interface{}{
+			"timestamp": time.Now().Unix(),
+		},
+	})
+	return nil
+}
+
 // StopCompleteRecording 停止完整录制并返回所有文件路径
+// 录制已被其他调用方停止（例如磁盘保护自动停止后用户再点停止）时返回同一结果
 func (a *App) StopCompleteRecording() (map[string]string, error) {
+	a.recordingMu.Lock()
+	defer a.recordingMu.Unlock()
+
 	result := make(map[string]string)
 
-	// 1. 停止视频录制
-	videoPath, mouseDataPath, err := a.StopScreenRecording()
-	if err != nil {
-		return nil, fmt.Errorf("停止视频录制失败: %w", err)
+	// 1. 停止视频录制（失败时仍继续停止音频和键盘录制）
+	videoPath, mouseDataPath, stopErr := a.StopScreenRecording()
+	if errors.Is(stopErr, recorder.ErrNotRecording) && a.lastResult != nil {
+		return a.lastResult, nil
+	}
+	if videoPath != "" {
+		result["video"] = videoPath
+	}
+	if mouseDataPath != "" {
+		result["mouseData"] = mouseDataPath
 	}
-	result["video"] = videoPath
-	result["mouseData"] = mouseDataPath
 
-	// 2. 停止音频录制
+	// 2. 停止音频录制（剪掉与视频一起暂停的区间）
 	if a.audioRecorder != nil && a.audioRecorder.IsRecording() {
+		a.audioRecorder.SetPauses(a.recorder.GetPauses())
 		audioPath, err := a.StopAudioRecording()
 		if err != nil {
 			fmt.Printf("警告: 停止音频录制失败: %v\n", err)
 		} else {
 			result["audio"] = audioPath
+			a.recordAVSync()
 		}
 	}
 
-	// 3. 停止键盘录制
+	// 3. 停止键盘录制（没有视频路径时只停止，不保存）
 	if a.keyboardHook != nil && a.keyboardHook.IsRecording() {
-		keyboardPath := videoPath[:len(videoPath)-4] + "_keyboard.json"
-		if err := a.StopKeyboardRecording(keyboardPath); err != nil {
-			fmt.Printf("警告: 停止键盘录制失败: %v\n", err)
+		if videoPath == "" {
+			if err := a.keyboardHook.StopRecording(); err != nil {
+				fmt.Printf("警告: 停止键盘录制失败: %v\n", err)
+			}
 		} else {
-			result["keyboard"] = keyboardPath
+			keyboardPath := videoPath[:len(videoPath)-4] + "_keyboard.json"
+			if err := a.StopKeyboardRecording(keyboardPath); err != nil {
+				fmt.Printf("警告: 停止键盘录制失败: %v\n", err)
+			} else {
+				result["keyboard"] = keyboardPath
+			}
+		}
+	}
+
+	if stopErr != nil {
+		if videoPath == "" {
+			return nil, fmt.Errorf("停止视频录制失败: %w", stopErr)
 		}
+		// 录制文件已保存（例如只是输出校验失败），结果随错误一起返回
+		a.lastResult = result
+		return result, fmt.Errorf("停止视频录制失败: %w", stopErr)
 	}
 
+	a.lastResult = result
 	fmt.Println("✓ 完整录制已停止")
 	return result, nil
 }
 
+// recordAVSync 记录本次录制音频相对视频的偏移，合并和封装时自动对齐
+func (a *App) recordAVSync() {
+	audioTimings := a.audioRecorder.GetStreamTimings()
+	if len(audioTimings) == 0 || a.recorder == nil {
+		return
+	}
+	if err := recorder.RecordAVSync(a.recorder.GetVideoTiming(), audioTimings...); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+}
+
+// ========== 字幕 API ==========
+
+// TranscribeRecording 识别录制的麦克风音频并保存字幕稿到会话
+// audioPath 为空时使用会话中与视频一起录制的麦克风音频；language 为空表示自动检测
+func (a *App) TranscribeRecording(videoPath string, audioPath string, language string) (*recorder.Transcript, error) {
+	transcriber, err := a.getTranscriber()
+	if err != nil {
+		return nil, err
+	}
+
+	track := recorder.AudioTrack{}
+	if audioPath != "" {
+		track.Path = audioPath
+		track.Offset, _ = recorder.FindAudioOffset(audioPath, videoPath)
+	}
+	return recorder.TranscribeRecording(a.ctx, transcriber, videoPath, track, recorder.TranscribeOptions{Language: language})
+}
+
+// GetTranscript 获取视频的字幕稿
+func (a *App) GetTranscript(videoPath string) (*recorder.Transcript, error) {
+	return recorder.LoadTranscript(videoPath)
+}
+
+// UpdateTranscript 保存编辑后的字幕分段
+func (a *App) UpdateTranscript(videoPath string, segments []recorder.TranscriptSegment) (*recorder.Transcript, error) {
+	return recorder.UpdateTranscript(videoPath, segments)
+}
+
+// ExportCaptions 把字幕稿导出为视频旁边的 SRT 和 WebVTT 文件
+func (a *App) ExportCaptions(videoPath string) (map[string]string, error) {
+	transcript, err := recorder.LoadTranscript(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	srtPath, vttPath, err := recorder.WriteCaptionFiles(transcript)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"srt": srtPath, "vtt": vttPath}, nil
+}
+
+// ListCaptionStyles 列出烧录字幕的样式预设
+func (a *App) ListCaptionStyles() []recorder.CaptionStyle {
+	styles := make([]recorder.CaptionStyle, 0, len(recorder.CaptionStyles))
+	for _, name := range []string{"default", "large", "boxed", "minimal"} {
+		styles = append(styles, recorder.CaptionStyles[name])
+	}
+	return styles
+}
+
+// SetExportCaptionStyle 设置导出时烧录字幕的样式（空字符串表示不烧录）
+func (a *App) SetExportCaptionStyle(style string) error {
+	if style != "" {
+		if _, err := recorder.GetCaptionStyle(style); err != nil {
+			return err
+		}
+	}
+	a.captionStyle = style
+	return nil
+}
+
+// SetExportTrims 设置导出时从源视频中剪掉的范围（秒，空列表表示不剪辑）
+func (a *App) SetExportTrims(trims []recorder.TimeRange) error {
+	for _, trim := range trims {
+		if trim.Start < 0 || trim.End <= trim.Start {
+			return fmt.Errorf("无效的剪辑范围: %.3f - %.3f", trim.Start, trim.End)
+		}
+	}
+	a.exportTrims = trims
+	return nil
+}
+
+// SetExportTypingZoom 设置导出时是否在连续打字时放大到光标位置（默认关闭）
+func (a *App) SetExportTypingZoom(enabled bool) {
+	a.typingZoom = enabled
+}
+
+// SetExportDragZoom 设置导出时是否缩放到拖动和选择的范围（默认关闭）
+func (a *App) SetExportDragZoom(enabled bool) {
+	a.dragZoom = enabled
+}
+
+// AddMarker 在当前录制时间添加章节标记（label 为空时按序号命名）
+func (a *App) AddMarker(label string) (recorder.Marker, error) {
+	if a.recorder == nil {
+		return recorder.Marker{}, fmt.Errorf("录制器未初始化")
+	}
+	marker, err := a.recorder.AddMarker(label)
+	if err != nil {
+		return marker, err
+	}
+
+	events.Publish(a.bus, recorder.MarkerAddedTopic, marker)
+	return marker, nil
+}
+
+// GetMarkers 获取视频会话中保存的章节标记
+func (a *App) GetMarkers(videoPath string) ([]recorder.Marker, error) {
+	return recorder.LoadMarkers(videoPath)
+}
+
+// UpdateMarkers 保存编辑后的章节标记
+func (a *App) UpdateMarkers(videoPath string, markers []recorder.Marker) error {
+	return recorder.SaveMarkers(videoPath, markers)
+}
+
+// GetChapterText 生成 YouTube 风格的章节列表（按当前导出剪辑调整时间）
+func (a *App) GetChapterText(videoPath string) (string, error) {
+	if a.ffmpegManager == nil {
+		return "", fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	chapters, err := recorder.ExportChapters(a.ffmpegManager, recorder.ExportConfig{VideoPath: videoPath, Trims: a.exportTrims})
+	if err != nil {
+		return "", err
+	}
+	return recorder.ChapterText(chapters), nil
+}
+
+// GetMouseGestures 识别鼠标数据中的双击、拖动、选择和连续滚动（供前端点击特效使用）
+func (a *App) GetMouseGestures(mouseDataPath string) ([]recorder.Gesture, error) {
+	return recorder.LoadGestures(mouseDataPath, recorder.DefaultGestureConfig())
+}
+
+// GetCursorTrack 获取录制的光标形状和图像（供前端预览绘制真实光标，没有数据时返回空）
+func (a *App) GetCursorTrack(videoPath string) (*hook.CursorTrack, error) {
+	path := recorder.CursorPathFor(videoPath)
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	return hook.LoadCursorTrack(path)
+}
+
+// getTranscriber 获取语音识别器，未设置时创建 whisper.cpp 识别器
+func (a *App) getTranscriber() (recorder.Transcriber, error) {
+	if a.transcriber != nil {
+		return a.transcriber, nil
+	}
+	if a.ffmpegManager == nil {
+		return nil, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+
+	ffmpegPath, err := a.ffmpegManager.GetFFmpegPath()
+	if err != nil {
+		return nil, fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+	}
+	transcriber, err := recorder.NewWhisperTranscriber(ffmpegPath, "", "")
+	if err != nil {
+		return nil, err
+	}
+	a.transcriber = transcriber
+	return transcriber, nil
+}
+
+// ========== 录制触发器 API ==========
+
+// ArmRecordingTriggers 启用自动录制触发器
+// 触发开始时在 outputDir 下按时间创建新的录制目录，使用完整录制（可选音频和键盘）
+func (a *App) ArmRecordingTriggers(triggers []recorder.TriggerConfig, outputDir string, recordAudio bool, recordKeyboard bool) error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	if a.triggers != nil {
+		a.triggers.Disarm()
+	}
+
+	manager := recorder.NewTriggerManager(a.recorder, recorder.TriggerActions{
+		Start: func() error {
+			videoPath := stdpath.Join(outputDir, time.Now().Format("20060102_150405"), "recording.mp4")
+			return a.StartCompleteRecording(videoPath, recordAudio, recordKeyboard)
+		},
+		Stop: func() error {
+			_, err := a.StopCompleteRecording()
+			return err
+		},
+	})
+	manager.SetEventHandler(func(event recorder.TriggerEvent) {
+		events.Publish(a.bus, recorder.TriggerStatusTopic, event)
+	})
+	if err := manager.Arm(triggers); err != nil {
+		return err
+	}
+	a.triggers = manager
+	return nil
+}
+
+// DisarmRecordingTriggers 停用自动录制触发器（不影响正在进行的录制）
+func (a *App) DisarmRecordingTriggers() {
+	if a.triggers != nil {
+		a.triggers.Disarm()
+	}
+}
+
+// GetTriggerStatus 获取触发器状态
+func (a *App) GetTriggerStatus() []recorder.TriggerStatus {
+	if a.triggers == nil {
+		return []recorder.TriggerStatus{}
+	}
+	return a.triggers.GetStatus()
+}
+
+// LoadSessionTriggers 读取录制会话中保存的触发器配置
+func (a *App) LoadSessionTriggers(videoPath string) ([]recorder.TriggerConfig, error) {
+	return recorder.LoadTriggers(videoPath)
+}
+
+// ========== 磁盘空间 API ==========
+
+// SetDiskGuardConfig 设置磁盘空间保护和码率上限（下次开始录制时生效）
+func (a *App) SetDiskGuardConfig(config recorder.DiskGuardConfig) error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	return a.recorder.SetDiskGuardConfig(config)
+}
+
+// GetDiskGuardConfig 获取磁盘空间保护配置
+func (a *App) GetDiskGuardConfig() recorder.DiskGuardConfig {
+	if a.recorder == nil {
+		return recorder.DefaultDiskGuardConfig()
+	}
+	return a.recorder.GetDiskGuardConfig()
+}
+
+// ========== 快捷键 API ==========
+
+// GetHotkeySettings 获取全局快捷键设置
+func (a *App) GetHotkeySettings() hook.HotkeySettings {
+	if a.hotkeys == nil {
+		return hook.DefaultHotkeySettings()
+	}
+	return a.hotkeys.GetSettings()
+}
+
+// SetHotkeySettings 设置并保存全局快捷键（组合键冲突或为系统保留时返回错误）
+func (a *App) SetHotkeySettings(settings hook.HotkeySettings) (hook.HotkeySettings, error) {
+	if a.hotkeys == nil {
+		return settings, fmt.Errorf("快捷键服务未初始化")
+	}
+	if err := a.hotkeys.SetSettings(settings); err != nil {
+		return settings, err
+	}
+	return a.hotkeys.GetSettings(), nil
+}
+
+// handleHotkey 执行快捷键动作，结果通过 hotkey 事件通知前端
+// 快捷键开始的录制和保存的回放按时间保存在 output 目录下
+func (a *App) handleHotkey(event hook.HotkeyEvent) {
+	if a.recorder == nil {
+		return
+	}
+
+	var err error
+	switch event.Action {
+	case hook.HotkeyToggleRecording:
+		if a.recorder.IsRecording() {
+			_, err = a.StopCompleteRecording()
+		} else {
+			a.recordingMu.Lock()
+			options := a.lastRecording
+			a.recordingMu.Unlock()
+			videoPath := stdpath.Join("output", time.Now().Format("20060102_150405"), "recording.mp4")
+			err = a.StartCompleteRecording(videoPath, options.recordAudio, options.recordKeyboard)
+		}
+	case hook.HotkeyTogglePause:
+		if a.recorder.IsPaused() {
+			err = a.ResumeCompleteRecording()
+		} else {
+			err = a.PauseCompleteRecording()
+		}
+	case hook.HotkeySaveReplay:
+		videoPath := stdpath.Join("output", "replay_"+time.Now().Format("20060102_150405"), "recording.mp4")
+		_, err = a.SaveReplay(videoPath)
+	case hook.HotkeyMarker:
+		_, err = a.AddMarker("")
+	}
+
+	payload := recorder.HotkeyActionEvent{Action: event.Action, Keys: event.Keys}
+	if err != nil {
+		fmt.Printf("✗ 快捷键 %s 执行失败: %v\n", event.Keys, err)
+		payload.Error = err.Error()
+	}
+	events.Publish(a.bus, recorder.HotkeyTopic, payload)
+}
+
+// ========== 推流 API ==========
+
+// SetStreamOutputs 设置录制时同时推流的输出（下次开始录制时生效）
+func (a *App) SetStreamOutputs(outputs []recorder.StreamOutputConfig) error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	return a.recorder.SetStreamOutputs(outputs)
+}
+
+// GetStreamOutputs 获取推流输出配置
+func (a *App) GetStreamOutputs() []recorder.StreamOutputConfig {
+	if a.recorder == nil {
+		return nil
+	}
+	return a.recorder.GetStreamOutputs()
+}
+
+// StartStreamTestListener 启动本地 FFmpeg 接收端，用于测试推流（如 rtmp://127.0.0.1:1935/live/test）
+func (a *App) StartStreamTestListener(listenURL string, outputPath string) error {
+	if a.ffmpegManager == nil {
+		return fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	if a.streamListener != nil && a.streamListener.Running() {
+		return fmt.Errorf("推流接收端已在运行")
+	}
+
+	process, err := recorder.StartStreamListener(a.ffmpegManager, listenURL, outputPath)
+	if err != nil {
+		return err
+	}
+	a.streamListener = process
+	return nil
+}
+
+// StopStreamTestListener 停止本地推流接收端
+func (a *App) StopStreamTestListener() error {
+	if a.streamListener == nil {
+		return fmt.Errorf("推流接收端未运行")
+	}
+	process := a.streamListener
+	a.streamListener = nil
+	process.Stop()
+	return process.Wait()
+}
+
+// ========== 回放缓冲 API ==========
+
+// GetReplayConfig 获取回放缓冲配置
+func (a *App) GetReplayConfig() recorder.ReplayConfig {
+	return a.replayConfig
+}
+
+// SetReplayConfig 设置回放缓冲配置（下次启动回放缓冲时生效）
+func (a *App) SetReplayConfig(config recorder.ReplayConfig) error {
+	if config.Duration <= 0 || config.SegmentSeconds <= 0 {
+		return fmt.Errorf("回放时长和分段时长必须大于 0")
+	}
+	if config.SegmentSeconds > config.Duration {
+		return fmt.Errorf("分段时长不能超过回放时长")
+	}
+	if config.MaxDiskMB <= 0 || config.MaxEvents <= 0 {
+		return fmt.Errorf("磁盘上限和事件上限必须大于 0")
+	}
+	a.replayConfig = config
+	return nil
+}
+
+// StartReplayBuffer 启动回放缓冲，持续保留最近一段时间的屏幕、鼠标（和键盘）数据
+func (a *App) StartReplayBuffer(recordKeyboard bool) error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+
+	var keyboardHook *hook.KeyboardHook
+	if recordKeyboard {
+		keyboardHook = a.getKeyboardHook()
+	}
+	if err := a.recorder.StartReplay(a.replayConfig, keyboardHook); err != nil {
+		return err
+	}
+
+	events.Publish(a.bus, recorder.ReplayStartedTopic, a.replayConfig)
+	return nil
+}
+
+// StopReplayBuffer 停止回放缓冲并丢弃缓冲的内容
+func (a *App) StopReplayBuffer() error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	if err := a.recorder.StopReplay(); err != nil {
+		return err
+	}
+
+	events.Publish(a.bus, recorder.ReplayStoppedTopic, a.replayConfig)
+	return nil
+}
+
+// SaveReplay 把回放缓冲的最近窗口保存为录制会话（视频、鼠标数据、键盘数据和会话清单）
+func (a *App) SaveReplay(videoPath string) (*recorder.ReplayBundle, error) {
+	if a.recorder == nil {
+		return nil, fmt.Errorf("录制器未初始化")
+	}
+
+	bundle, err := a.recorder.SaveReplay(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	events.Publish(a.bus, recorder.ReplaySavedTopic, *bundle)
+	return bundle, nil
+}
+
+// ========== 摄像头 API ==========
+
+// ListWebcams 列出可用的摄像头
+func (a *App) ListWebcams() ([]recorder.WebcamDevice, error) {
+	if a.ffmpegManager == nil {
+		return nil, fmt.Errorf("FFmpeg 管理器未初始化")
+	}
+	ffmpegPath, err := a.ffmpegManager.GetFFmpegPath()
+	if err != nil {
+		return nil, fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+	}
+	return recorder.ListWebcams(ffmpegPath)
+}
+
+// SetWebcamConfig 设置摄像头录制配置（下次开始录制时生效）
+func (a *App) SetWebcamConfig(config recorder.WebcamConfig) error {
+	if a.recorder == nil {
+		return fmt.Errorf("录制器未初始化")
+	}
+	a.recorder.SetWebcamConfig(config)
+	return nil
+}
+
+// GetWebcamConfig 获取摄像头录制配置
+func (a *App) GetWebcamConfig() recorder.WebcamConfig {
+	if a.recorder == nil {
+		return recorder.DefaultWebcamConfig()
+	}
+	return a.recorder.GetWebcamConfig()
+}
+
+// SetWebcamOverlay 设置导出时的摄像头画中画配置
+func (a *App) SetWebcamOverlay(config recorder.WebcamOverlayConfig) error {
+	switch config.Shape {
+	case recorder.WebcamShapeCircle, recorder.WebcamShapeRounded:
+	default:
+		return fmt.Errorf("未知的摄像头形状: %s", config.Shape)
+	}
+	switch config.Corner {
+	case recorder.WebcamTopLeft, recorder.WebcamTopRight, recorder.WebcamBottomLeft, recorder.WebcamBottomRight:
+	default:
+		return fmt.Errorf("未知的摄像头位置: %s", config.Corner)
+	}
+	if config.Size <= 0 || config.Size > 1 {
+		return fmt.Errorf("摄像头尺寸必须在 0 到 1 之间")
+	}
+	for _, r := range config.Ranges {
+		if r.Mode != recorder.WebcamModeFullscreen && r.Mode != recorder.WebcamModeHidden {
+			return fmt.Errorf("未知的摄像头时间段模式: %s", r.Mode)
+		}
+	}
+	a.webcamOverlay = config
+	return nil
+}
+
+// GetWebcamOverlay 获取导出时的摄像头画中画配置
+func (a *App) GetWebcamOverlay() recorder.WebcamOverlayConfig {
+	return a.webcamOverlay
+}
+
 // ========== 自定义参数导出 API ==========
 
 // ExportWithCustomParams 使用自定义参数导出视频
@@ -920,34 +1771,13 @@ func (a *App) ExportWithCustomParams(
 	bgParamsJSON string,
 	cursorImage string,
 ) error {
-	if a.ffmpegManager == nil {
-		return fmt.Errorf("FFmpeg 管理器未初始化")
-	}
-
-	// 创建自定义导出器
-	customExporter := recorder.NewCustomExporter(a.ffmpegManager)
-
-	// 创建导出配置
-	config := recorder.DefaultExportConfig()
-	config.VideoPath = videoPath
-	config.MouseDataPath = mouseDataPath
-	config.OutputPath = outputPath
-	config.ScreenWidth = screenWidth
-	config.ScreenHeight = screenHeight
-	config.FPS = fps
-
-	// 准备导出
-	if err := customExporter.PrepareCustomExport(config, customParamsJSON, bgParamsJSON, cursorImage); err != nil {
-		return fmt.Errorf("准备自定义导出失败: %w", err)
-	}
-
-	// 执行导出
-	fmt.Println("开始自定义参数导出...")
-	if err := customExporter.ExportWithCustomParams(); err != nil {
-		return fmt.Errorf("自定义导出失败: %w", err)
-	}
-
-	return nil
+	return a.runExportJob(recorder.ExportJobRequest{
+		Kind:             recorder.ExportJobCustom,
+		Config:           newExportConfig(videoPath, mouseDataPath, outputPath, screenWidth, screenHeight, fps),
+		CustomParamsJSON: customParamsJSON,
+		BgParamsJSON:     bgParamsJSON,
+		CursorImage:      cursorImage,
+	})
 }
 
 // ExportWithCustomParamsGPU 使用自定义参数和 GPU 加速导出
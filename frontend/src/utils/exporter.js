@@ -261,7 +261,7 @@ export class CameraRenderer {
 
     // 绘制光标
     if (showCursor) {
-      this.drawCursor(cameraFrame.MouseX, cameraFrame.MouseY, cameraFrame.EventType);
+      this.drawCursor(cameraFrame.MouseX, cameraFrame.MouseY, cameraFrame.Gesture, cameraFrame.ClickCount);
     }
 
     // 返回 Base64 数据（移除 data:image/png;base64, 前缀）
@@ -272,18 +272,33 @@ export class CameraRenderer {
    * 绘制光标
    * @param {number} x - 光标 X 坐标
    * @param {number} y - 光标 Y 坐标
-   * @param {string} eventType - 事件类型
+   * @param {string} gesture - 点击特效对应的手势（click、double_click、triple_click、drag、selection，空表示无）
+   * @param {number} clickCount - 连击次数
    */
-  drawCursor(x, y, eventType) {
+  drawCursor(x, y, gesture, clickCount) {
     const ctx = this.ctx;
     
-    // 根据事件类型选择光标样式
-    if (eventType && eventType.includes('down')) {
-      // 点击状态 - 红色高亮
+    // 根据手势选择点击特效
+    if (gesture === 'drag' || gesture === 'selection') {
+      // 拖动和选择 - 蓝色高亮
+      ctx.fillStyle = 'rgba(0, 120, 255, 0.25)';
+      ctx.beginPath();
+      ctx.arc(x, y, 24, 0, Math.PI * 2);
+      ctx.fill();
+    } else if (gesture) {
+      // 点击状态 - 红色高亮，双击、三击每次多一圈
       ctx.fillStyle = 'rgba(255, 0, 0, 0.3)';
       ctx.beginPath();
       ctx.arc(x, y, 30, 0, Math.PI * 2);
       ctx.fill();
+
+      ctx.strokeStyle = 'rgba(255, 0, 0, 0.6)';
+      ctx.lineWidth = 3;
+      for (let ring = 1; ring < (clickCount || 1); ring++) {
+        ctx.beginPath();
+        ctx.arc(x, y, 30 + ring * 10, 0, Math.PI * 2);
+        ctx.stroke();
+      }
     }
     
     // 绘制箭头光标
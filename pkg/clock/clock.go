@@ -0,0 +1,38 @@
+package clock
+
+import "time"
+
+// Clock 共享单调时钟
+// 视频、音频等采集流的开始时间都相对同一个起点计算。
+// time.Time 自带单调时钟读数，相减不受系统时间调整影响
+type Clock struct {
+	origin time.Time
+}
+
+// Default 进程内共享的时钟，所有录制器默认使用它
+var Default = New()
+
+// New 创建以当前时刻为起点的时钟
+func New() *Clock {
+	return &Clock{origin: time.Now()}
+}
+
+// Origin 返回时钟起点
+func (c *Clock) Origin() time.Time {
+	return c.origin
+}
+
+// Now 返回当前时刻相对起点的时长
+func (c *Clock) Now() time.Duration {
+	return time.Since(c.origin)
+}
+
+// Offset 返回 t 相对起点的时长（t 必须来自 time.Now 才能使用单调读数）
+func (c *Clock) Offset(t time.Time) time.Duration {
+	return t.Sub(c.origin)
+}
+
+// Seconds 返回 t 相对起点的秒数，用于持久化
+func (c *Clock) Seconds(t time.Time) float64 {
+	return c.Offset(t).Seconds()
+}
@@ -0,0 +1,323 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultBuffer 订阅者待投递队列的默认长度
+const defaultBuffer = 256
+
+// Event 总线上的事件
+type Event struct {
+	Topic string    `json:"topic"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data"`
+}
+
+// Topic 带负载类型的主题，发布和订阅时由编译器检查负载类型
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic 创建主题
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name 返回主题名称
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Limit 订阅者对某个主题的限速
+type Limit struct {
+	Rate     float64 `json:"rate"`     // 每秒最多投递次数（0 表示不限制）
+	Coalesce bool    `json:"coalesce"` // 超出速率或来不及处理时只保留最新的一个（否则丢弃超出速率的事件）
+}
+
+// lossy 是否允许丢弃该主题的事件（限速或合并的主题）
+func (l Limit) lossy() bool {
+	return l.Rate > 0 || l.Coalesce
+}
+
+// SubscribeOptions 订阅选项
+type SubscribeOptions struct {
+	Topics []string         // 只接收这些主题（为空表示全部）
+	Limits map[string]Limit // 按主题限速，"*" 为其余主题的默认值
+	Buffer int              // 待投递队列长度（0 使用默认值，满时丢弃最旧的限速或合并主题的事件）
+}
+
+// limit 返回主题的限速
+func (o SubscribeOptions) limit(topic string) Limit {
+	if limit, ok := o.Limits[topic]; ok {
+		return limit
+	}
+	return o.Limits["*"]
+}
+
+// Bus 进程内的发布/订阅总线
+// 每个订阅者有自己的队列和投递协程，慢订阅者不会阻塞发布者和其他订阅者
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish 发布事件（bus 为 nil 时忽略，便于无界面和测试环境使用）
+func (b *Bus) Publish(topic string, data any) {
+	if b == nil {
+		return
+	}
+	event := Event{Topic: topic, Time: time.Now(), Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if sub.accepts(topic) {
+			sub.offer(event)
+		}
+	}
+}
+
+// Publish 发布带类型的事件
+func Publish[T any](b *Bus, topic Topic[T], data T) {
+	b.Publish(topic.name, data)
+}
+
+// Subscribe 订阅事件，handler 在订阅者自己的协程中依次调用
+func (b *Bus) Subscribe(opts SubscribeOptions, handler func(Event)) *Subscription {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBuffer
+	}
+	sub := &Subscription{
+		bus:     b,
+		opts:    opts,
+		handler: handler,
+		states:  make(map[string]*topicState),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	if len(opts.Topics) > 0 {
+		sub.topics = make(map[string]bool, len(opts.Topics))
+		for _, topic := range opts.Topics {
+			sub.topics[topic] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.run()
+	return sub
+}
+
+// Subscribe 订阅单个带类型的主题
+func Subscribe[T any](b *Bus, topic Topic[T], limit Limit, handler func(T)) *Subscription {
+	opts := SubscribeOptions{
+		Topics: []string{topic.name},
+		Limits: map[string]Limit{topic.name: limit},
+	}
+	return b.Subscribe(opts, func(event Event) {
+		if data, ok := event.Data.(T); ok {
+			handler(data)
+		}
+	})
+}
+
+// Close 取消所有订阅
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := make([]*Subscription, 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
+
+// remove 移除订阅者
+func (b *Bus) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+}
+
+// topicState 订阅者在单个主题上的限速状态
+type topicState struct {
+	last    time.Time   // 最近一次入队的时间
+	pending *Event      // 等待速率窗口结束后投递的最新事件
+	timer   *time.Timer // 投递 pending 的定时器
+}
+
+// Subscription 订阅者
+type Subscription struct {
+	bus     *Bus
+	opts    SubscribeOptions
+	topics  map[string]bool // 为 nil 表示接收全部主题
+	handler func(Event)
+
+	mu      sync.Mutex
+	queue   []Event
+	states  map[string]*topicState
+	dropped uint64
+	closed  bool
+
+	wake      chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// accepts 是否接收该主题
+func (s *Subscription) accepts(topic string) bool {
+	return s.topics == nil || s.topics[topic]
+}
+
+// offer 按限速规则把事件放入队列
+func (s *Subscription) offer(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	limit := s.opts.limit(event.Topic)
+	if limit.Rate > 0 {
+		state := s.states[event.Topic]
+		if state == nil {
+			state = &topicState{}
+			s.states[event.Topic] = state
+		}
+
+		now := time.Now()
+		interval := time.Duration(float64(time.Second) / limit.Rate)
+		if wait := state.last.Add(interval).Sub(now); wait > 0 {
+			if !limit.Coalesce {
+				s.dropped++
+				return
+			}
+			// 窗口内只保留最新的一个，窗口结束时投递
+			if state.pending != nil {
+				s.dropped++
+			}
+			state.pending = &event
+			if state.timer == nil {
+				topic := event.Topic
+				state.timer = time.AfterFunc(wait, func() { s.flush(topic) })
+			}
+			return
+		}
+		state.last = now
+	}
+	s.enqueueLocked(event, limit)
+}
+
+// flush 投递速率窗口内合并的事件
+func (s *Subscription) flush(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.states[topic]
+	if s.closed || state == nil || state.pending == nil {
+		return
+	}
+	event := *state.pending
+	state.pending = nil
+	state.timer = nil
+	state.last = time.Now()
+	s.enqueueLocked(event, s.opts.limit(topic))
+}
+
+// enqueueLocked 放入队列；合并时替换队列中尚未投递的同主题事件
+// 队列满时丢弃最旧的限速或合并主题的事件。没有限速的主题（录制状态、导出完成等）从不丢弃，
+// 队列中没有可丢弃的事件时暂时超过队列长度，鼠标、音频电平等高频事件不会挤掉它们
+func (s *Subscription) enqueueLocked(event Event, limit Limit) {
+	if limit.Coalesce {
+		for i := len(s.queue) - 1; i >= 0; i-- {
+			if s.queue[i].Topic == event.Topic {
+				s.queue[i] = event
+				s.dropped++
+				return
+			}
+		}
+	}
+	if len(s.queue) >= s.opts.Buffer && !s.evictLocked() && limit.lossy() {
+		s.dropped++
+		return
+	}
+	s.queue = append(s.queue, event)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// evictLocked 丢弃队列中最旧的限速或合并主题的事件，没有可丢弃的事件时返回 false
+func (s *Subscription) evictLocked() bool {
+	for i, queued := range s.queue {
+		if s.opts.limit(queued.Topic).lossy() {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.dropped++
+			return true
+		}
+	}
+	return false
+}
+
+// run 依次投递队列中的事件
+func (s *Subscription) run() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+
+		for {
+			s.mu.Lock()
+			if s.closed || len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			s.handler(event)
+		}
+	}
+}
+
+// Dropped 返回因限速、合并或队列已满而未投递的事件数
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close 取消订阅（可以在 handler 中调用），未投递的事件被丢弃
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.bus.remove(s)
+
+		s.mu.Lock()
+		s.closed = true
+		s.queue = nil
+		for _, state := range s.states {
+			if state.timer != nil {
+				state.timer.Stop()
+			}
+		}
+		s.mu.Unlock()
+
+		close(s.stop)
+	})
+}
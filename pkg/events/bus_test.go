@@ -0,0 +1,202 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder 记录 handler 收到的事件
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) add(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// data 返回指定主题（为空表示全部）收到的负载
+func (r *recorder) data(topic string) []any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var data []any
+	for _, event := range r.events {
+		if topic == "" || event.Topic == topic {
+			data = append(data, event.Data)
+		}
+	}
+	return data
+}
+
+// waitFor 轮询直到条件满足或超时
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBusRateLimit(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	var got recorder
+	sub := bus.Subscribe(SubscribeOptions{Limits: map[string]Limit{"mouse": {Rate: 5}}}, got.add)
+
+	// 速率窗口（200ms）内只投递第一个，其余丢弃
+	for i := 1; i <= 5; i++ {
+		bus.Publish("mouse", i)
+	}
+	bus.Publish("state", "started") // 其他主题不受限速影响
+	waitFor(t, "投递限速主题的第一个事件", func() bool { return len(got.data("")) == 2 })
+
+	time.Sleep(250 * time.Millisecond)
+	bus.Publish("mouse", 6)
+	waitFor(t, "窗口结束后投递新事件", func() bool { return len(got.data("mouse")) == 2 })
+
+	if data := got.data("mouse"); !reflect.DeepEqual(data, []any{1, 6}) {
+		t.Errorf("mouse = %v, want [1 6]", data)
+	}
+	if dropped := sub.Dropped(); dropped != 4 {
+		t.Errorf("Dropped = %d, want 4", dropped)
+	}
+}
+
+func TestBusCoalesce(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	var got recorder
+	sub := bus.Subscribe(SubscribeOptions{Limits: map[string]Limit{"mouse": {Rate: 5, Coalesce: true}}}, got.add)
+
+	// 第一个立即投递，窗口内的其余事件只保留最新的一个，在窗口结束时投递
+	for i := 1; i <= 5; i++ {
+		bus.Publish("mouse", i)
+	}
+	waitFor(t, "投递合并后的事件", func() bool { return len(got.data("mouse")) == 2 })
+	time.Sleep(250 * time.Millisecond)
+
+	if data := got.data("mouse"); !reflect.DeepEqual(data, []any{1, 5}) {
+		t.Errorf("mouse = %v, want [1 5]", data)
+	}
+	if dropped := sub.Dropped(); dropped != 3 {
+		t.Errorf("Dropped = %d, want 3", dropped)
+	}
+}
+
+func TestBusCoalesceQueued(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	// handler 阻塞期间不限速但合并的主题只保留队列中最新的一个
+	var got recorder
+	started, gate := make(chan struct{}), make(chan struct{})
+	bus.Subscribe(SubscribeOptions{Limits: map[string]Limit{"level": {Coalesce: true}}}, func(event Event) {
+		if event.Data == 0 {
+			close(started)
+			<-gate
+		}
+		got.add(event)
+	})
+
+	bus.Publish("level", 0)
+	<-started
+	for i := 1; i <= 100; i++ {
+		bus.Publish("level", i)
+	}
+	close(gate)
+	waitFor(t, "投递合并后的事件", func() bool { return len(got.data("level")) == 2 })
+
+	if data := got.data("level"); !reflect.DeepEqual(data, []any{0, 100}) {
+		t.Errorf("level = %v, want [0 100]", data)
+	}
+}
+
+func TestBusKeepsLifecycleEventsWhenFull(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	var got recorder
+	started, gate := make(chan struct{}), make(chan struct{})
+	sub := bus.Subscribe(SubscribeOptions{
+		Limits: map[string]Limit{"*": {Coalesce: true}, "state": {}},
+		Buffer: 4,
+	}, func(event Event) {
+		if event.Data == "blocked" {
+			close(started)
+			<-gate
+		}
+		got.add(event)
+	})
+
+	bus.Publish("state", "blocked")
+	<-started
+
+	// 队列满后高频事件只挤掉高频事件，状态事件全部按顺序投递
+	var want []any
+	for i := range 10 {
+		for j := range 20 {
+			bus.Publish(fmt.Sprintf("mouse-%d", j), i)
+		}
+		bus.Publish("state", i)
+		want = append(want, i)
+	}
+	close(gate)
+	waitFor(t, "投递全部状态事件", func() bool { return len(got.data("state")) == len(want)+1 })
+
+	if data := got.data("state"); !reflect.DeepEqual(data[1:], want) {
+		t.Errorf("state = %v, want %v", data[1:], want)
+	}
+	if sub.Dropped() == 0 {
+		t.Error("队列满时应丢弃高频事件")
+	}
+}
+
+func TestBusCloseInsideHandler(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	var got recorder
+	var sub *Subscription
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	sub = bus.Subscribe(SubscribeOptions{}, func(event Event) {
+		<-ready
+		got.add(event)
+		sub.Close() // 在 handler 中取消订阅不能死锁
+		close(done)
+	})
+	close(ready)
+
+	for i := range 5 {
+		bus.Publish("state", i)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("在 handler 中调用 Close 死锁")
+	}
+
+	// 取消后不再投递，继续发布也不受影响
+	bus.Publish("state", 5)
+	time.Sleep(50 * time.Millisecond)
+	if data := got.data(""); !reflect.DeepEqual(data, []any{0}) {
+		t.Errorf("收到 %v, want [0]", data)
+	}
+	bus.mu.RLock()
+	subscribers := len(bus.subscribers)
+	bus.mu.RUnlock()
+	if subscribers != 0 {
+		t.Errorf("取消后总线仍有 %d 个订阅者", subscribers)
+	}
+	sub.Close() // 重复取消无影响
+}
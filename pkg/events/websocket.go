@@ -0,0 +1,266 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// websocketGUID 计算 Sec-WebSocket-Accept 使用的固定 GUID（RFC 6455）
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocketWriteTimeout 单帧写入超时，超时的客户端会被断开
+	websocketWriteTimeout = 5 * time.Second
+	// websocketMaxClientFrame 客户端帧的最大长度（客户端只需要发送控制帧）
+	websocketMaxClientFrame = 64 * 1024
+)
+
+// WebSocket 帧类型
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// WebSocketHandler 把总线事件以 JSON 文本帧推送给 WebSocket 客户端
+// 查询参数 topics=a,b 只接收指定主题；rate=N 把每个主题限制为每秒 N 条（合并为最新值）
+// 事件中包含键盘输入，只接受本机连接，浏览器连接只接受本机页面和应用自身的来源
+type WebSocketHandler struct {
+	bus      *Bus
+	defaults SubscribeOptions
+}
+
+// NewWebSocketHandler 创建 WebSocket 端点，defaults 为客户端未指定参数时的订阅选项
+func NewWebSocketHandler(bus *Bus, defaults SubscribeOptions) *WebSocketHandler {
+	return &WebSocketHandler{bus: bus, defaults: defaults}
+}
+
+// ServeHTTP 完成握手并推送事件，直到客户端断开
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r.RemoteAddr) || !allowedOrigin(r.Header.Get("Origin")) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "Bad WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	opts, err := h.options(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	client := &wsConn{conn: conn}
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if err := client.writeRaw([]byte(handshake)); err != nil {
+		return
+	}
+
+	sub := h.bus.Subscribe(opts, func(event Event) {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		if err := client.writeFrame(opText, payload); err != nil {
+			conn.Close()
+		}
+	})
+	defer sub.Close()
+
+	// 读取客户端帧：响应 ping，收到 close 或连接断开时结束
+	client.readLoop(rw.Reader)
+}
+
+// options 根据查询参数生成订阅选项
+func (h *WebSocketHandler) options(query url.Values) (SubscribeOptions, error) {
+	opts := h.defaults
+	if topics := query.Get("topics"); topics != "" {
+		opts.Topics = nil
+		for _, topic := range strings.Split(topics, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				opts.Topics = append(opts.Topics, topic)
+			}
+		}
+	}
+	if rate := query.Get("rate"); rate != "" {
+		value, err := strconv.ParseFloat(rate, 64)
+		if err != nil || value < 0 {
+			return opts, fmt.Errorf("无效的 rate 参数: %s", rate)
+		}
+		opts.Limits = map[string]Limit{"*": {Rate: value, Coalesce: true}}
+	}
+	return opts, nil
+}
+
+// wsConn 服务端 WebSocket 连接（写入加锁，事件和控制帧可能并发写入）
+type wsConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// writeRaw 写入原始数据
+func (c *wsConn) writeRaw(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// writeFrame 写入一个完整的帧（服务端帧不加掩码）
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	return c.writeRaw(append(header, payload...))
+}
+
+// readLoop 读取客户端帧直到连接关闭
+func (c *wsConn) readLoop(reader *bufio.Reader) {
+	for {
+		opcode, payload, err := readClientFrame(reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readClientFrame 读取一个客户端帧（客户端帧必须加掩码）
+func readClientFrame(reader *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("客户端帧未加掩码")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > websocketMaxClientFrame {
+		return 0, nil, fmt.Errorf("客户端帧过大: %d 字节", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// acceptKey 计算握手响应的 Sec-WebSocket-Accept
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains 检查逗号分隔的请求头是否包含某个值（不区分大小写）
+func headerContains(header http.Header, name, value string) bool {
+	for _, line := range header.Values(name) {
+		for _, item := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLoopback 检查远端地址是否为本机
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// allowedOrigin 检查浏览器来源：非浏览器客户端没有 Origin，应用自身为 wails:// 或 wails.localhost
+func allowedOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "wails" {
+		return true
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1", "wails.localhost":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSONLines 把事件逐行写为 JSON（命令行和日志使用），写入失败后不再写入
+func WriteJSONLines(bus *Bus, w io.Writer, opts SubscribeOptions) *Subscription {
+	encoder := json.NewEncoder(w)
+	failed := false
+	return bus.Subscribe(opts, func(event Event) {
+		if failed {
+			return
+		}
+		failed = encoder.Encode(event) != nil
+	})
+}
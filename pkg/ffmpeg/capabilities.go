@@ -0,0 +1,394 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// capabilitiesFileName 能力探测缓存文件名（位于应用数据目录）
+const capabilitiesFileName = "ffmpeg_capabilities.json"
+
+// hardwareTestTimeout 硬件编码器试编码的超时时间
+const hardwareTestTimeout = 10 * time.Second
+
+// vaapiDevice VAAPI 默认渲染设备
+const vaapiDevice = "/dev/dri/renderD128"
+
+// EncoderRanking 视频编码器优先级（从高到低）
+// 硬件编码器只有在试编码成功后才会被选中
+var EncoderRanking = []string{
+	"h264_vaapi",
+	"h264_qsv",
+	"h264_amf",
+	"h264_videotoolbox",
+	"h264_nvenc",
+	"libx264",
+	"libx265",
+	"libsvtav1",
+	"libvpx-vp9",
+}
+
+// CodecInfo 编解码器信息
+type CodecInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // video, audio, subtitle
+	Description string `json:"description"`
+}
+
+// Capabilities FFmpeg 能力探测结果
+type Capabilities struct {
+	BinaryPath string    `json:"binaryPath"`
+	ModTime    time.Time `json:"modTime"`
+	Size       int64     `json:"size"`
+	Version    string    `json:"version"`
+
+	Encoders []CodecInfo `json:"encoders"`
+	Decoders []CodecInfo `json:"decoders"`
+	HWAccels []string    `json:"hwaccels"`
+	Filters  []string    `json:"filters"`
+	Muxers   []string    `json:"muxers"`
+
+	// 硬件编码器试编码结果：编码器名 -> 是否可用
+	HardwareTests map[string]bool `json:"hardwareTests"`
+
+	ProbedAt time.Time `json:"probedAt"`
+}
+
+// HasEncoder 检查编码器是否存在
+func (c *Capabilities) HasEncoder(name string) bool {
+	return hasCodec(c.Encoders, name)
+}
+
+// HasDecoder 检查解码器是否存在
+func (c *Capabilities) HasDecoder(name string) bool {
+	return hasCodec(c.Decoders, name)
+}
+
+// HasHWAccel 检查硬件加速方法是否存在
+func (c *Capabilities) HasHWAccel(name string) bool {
+	return contains(c.HWAccels, name)
+}
+
+// HasFilter 检查滤镜是否存在
+func (c *Capabilities) HasFilter(name string) bool {
+	return contains(c.Filters, name)
+}
+
+// HasMuxer 检查封装格式是否存在
+func (c *Capabilities) HasMuxer(name string) bool {
+	return contains(c.Muxers, name)
+}
+
+// EncoderUsable 检查编码器是否可用（硬件编码器需要试编码成功）
+func (c *Capabilities) EncoderUsable(name string) bool {
+	if !c.HasEncoder(name) {
+		return false
+	}
+	if IsHardwareEncoder(name) {
+		return c.HardwareTests[name]
+	}
+	return true
+}
+
+// UsableEncoders 按优先级返回所有可用的编码器
+func (c *Capabilities) UsableEncoders() []string {
+	encoders := []string{}
+	for _, name := range EncoderRanking {
+		if c.EncoderUsable(name) {
+			encoders = append(encoders, name)
+		}
+	}
+	return encoders
+}
+
+// IsHardwareEncoder 判断是否为硬件编码器
+func IsHardwareEncoder(name string) bool {
+	for _, suffix := range []string{"_nvenc", "_qsv", "_amf", "_vaapi", "_videotoolbox"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HWDeviceArgs 返回编码器需要的硬件设备全局选项
+func HWDeviceArgs(encoder string) []Option {
+	if strings.HasSuffix(encoder, "_vaapi") {
+		return []Option{Opt("vaapi_device", vaapiDevice)}
+	}
+	return nil
+}
+
+// HWUploadFilters 返回把软件帧上传到硬件编码器所需的滤镜（不需要时为空）
+func HWUploadFilters(encoder string) []*Filter {
+	if strings.HasSuffix(encoder, "_vaapi") {
+		return []*Filter{NewFilter("format", "nv12"), NewFilter("hwupload")}
+	}
+	return nil
+}
+
+// Capabilities 获取 FFmpeg 能力探测结果
+// 结果按二进制路径和修改时间缓存到磁盘，FFmpeg 未更换时不会重复探测
+func (m *FFmpegManager) Capabilities() (*Capabilities, error) {
+	m.capsMu.Lock()
+	defer m.capsMu.Unlock()
+
+	path, err := m.GetFFmpegPath()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	caps := m.caps
+	m.mu.Unlock()
+	if caps != nil && caps.BinaryPath == path {
+		return caps, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := loadCapabilitiesCache()
+	if cached, ok := cache[path]; ok && cached.ModTime.Equal(info.ModTime()) && cached.Size == info.Size() {
+		caps = cached
+	} else {
+		fmt.Println("正在探测 FFmpeg 能力...")
+		caps, err = probeCapabilities(path)
+		if err != nil {
+			return nil, err
+		}
+		caps.ModTime = info.ModTime()
+		caps.Size = info.Size()
+		cache[path] = caps
+		saveCapabilitiesCache(cache)
+		fmt.Printf("✓ FFmpeg 能力探测完成: %d 个编码器, 可用视频编码器: %v\n", len(caps.Encoders), caps.UsableEncoders())
+	}
+
+	m.mu.Lock()
+	m.caps = caps
+	m.mu.Unlock()
+	return caps, nil
+}
+
+// probeCapabilities 运行 FFmpeg 探测能力
+func probeCapabilities(path string) (*Capabilities, error) {
+	caps := &Capabilities{
+		BinaryPath:    path,
+		HardwareTests: make(map[string]bool),
+		ProbedAt:      time.Now(),
+	}
+
+	version, err := runInfo(path, "-version")
+	if err != nil {
+		return nil, fmt.Errorf("获取 FFmpeg 版本失败: %w", err)
+	}
+	caps.Version = strings.TrimSpace(strings.SplitN(version, "\n", 2)[0])
+
+	sections := []struct {
+		flag  string
+		parse func(string)
+	}{
+		{"-encoders", func(out string) { caps.Encoders = parseCodecList(out) }},
+		{"-decoders", func(out string) { caps.Decoders = parseCodecList(out) }},
+		{"-hwaccels", func(out string) { caps.HWAccels = parseHWAccels(out) }},
+		{"-filters", func(out string) { caps.Filters = parseFilters(out) }},
+		{"-muxers", func(out string) { caps.Muxers = parseMuxers(out) }},
+	}
+	for _, section := range sections {
+		output, err := runInfo(path, section.flag)
+		if err != nil {
+			return nil, fmt.Errorf("执行 ffmpeg %s 失败: %w", section.flag, err)
+		}
+		section.parse(output)
+	}
+
+	// 硬件编码器列出来不代表能用（缺少驱动或设备），逐个试编码确认
+	for _, name := range EncoderRanking {
+		if IsHardwareEncoder(name) && caps.HasEncoder(name) {
+			err := testEncode(path, name)
+			caps.HardwareTests[name] = err == nil
+			if err != nil {
+				fmt.Printf("硬件编码器 %s 不可用: %v\n", name, err)
+			} else {
+				fmt.Printf("✓ 硬件编码器 %s 可用\n", name)
+			}
+		}
+	}
+
+	return caps, nil
+}
+
+// runInfo 运行不需要输入的 FFmpeg 信息命令
+func runInfo(path, flag string) (string, error) {
+	output, err := RunOutput(context.Background(), path, []string{"-hide_banner", flag}, "FFmpeg")
+	return string(output), err
+}
+
+// testEncode 使用测试源编码几帧，确认编码器可以工作
+func testEncode(path, encoder string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hardwareTestTimeout)
+	defer cancel()
+
+	cmd := NewCommand().Global(Flag("hide_banner"), Opt("v", "error"))
+	cmd.Global(HWDeviceArgs(encoder)...)
+	source := NewFilter("color").Set("c", "black").Set("s", "256x256").Set("r", 30)
+	input := cmd.Input(source.String()).Format("lavfi")
+
+	video := input.Video()
+	if filters := HWUploadFilters(encoder); len(filters) > 0 {
+		video = cmd.FilterGraph().Chain([]Pad{video}, filters...)
+	}
+	cmd.Output("-").Map(video).VideoCodec(encoder).With(Opt("frames:v", 5)).Format("null")
+
+	args, err := cmd.Args()
+	if err != nil {
+		return err
+	}
+	_, err = RunOutput(ctx, path, args, encoder+" 试编码")
+	return err
+}
+
+// parseCodecList 解析 -encoders / -decoders 输出
+// 格式: " V....D libx264              libx264 H.264 / AVC ..."
+func parseCodecList(output string) []CodecInfo {
+	codecs := []CodecInfo{}
+	started := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "------") {
+			started = true
+			continue
+		}
+		if !started {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields[0]) != 6 {
+			continue
+		}
+
+		codec := CodecInfo{Name: fields[1], Description: strings.Join(fields[2:], " ")}
+		switch fields[0][0] {
+		case 'V':
+			codec.Type = "video"
+		case 'A':
+			codec.Type = "audio"
+		case 'S':
+			codec.Type = "subtitle"
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs
+}
+
+// parseHWAccels 解析 -hwaccels 输出
+func parseHWAccels(output string) []string {
+	accels := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		accels = append(accels, line)
+	}
+	return accels
+}
+
+// parseFilters 解析 -filters 输出
+// 格式: " ... zoompan           V->V       Apply Zoom & Pan effect."
+func parseFilters(output string) []string {
+	filters := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.Contains(fields[2], "->") {
+			continue
+		}
+		filters = append(filters, fields[1])
+	}
+	return filters
+}
+
+// parseMuxers 解析 -muxers 输出
+// 格式: "  E mp4             MP4 (MPEG-4 Part 14)"
+func parseMuxers(output string) []string {
+	muxers := []string{}
+	started := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "--" {
+			started = true
+			continue
+		}
+		fields := strings.Fields(line)
+		if !started || len(fields) < 2 || !strings.Contains(fields[0], "E") {
+			continue
+		}
+		muxers = append(muxers, strings.Split(fields[1], ",")...)
+	}
+	return muxers
+}
+
+// hasCodec 检查编解码器列表中是否有指定名称
+func hasCodec(codecs []CodecInfo, name string) bool {
+	for _, codec := range codecs {
+		if codec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// contains 检查字符串列表中是否有指定值
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCapabilitiesCache 加载磁盘缓存（按二进制路径索引）
+func loadCapabilitiesCache() map[string]*Capabilities {
+	cache := make(map[string]*Capabilities)
+
+	dir, err := AppDataDir()
+	if err != nil {
+		return cache
+	}
+	data, err := os.ReadFile(filepath.Join(dir, capabilitiesFileName))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		fmt.Printf("警告: 解析 FFmpeg 能力缓存失败: %v\n", err)
+		return make(map[string]*Capabilities)
+	}
+	return cache
+}
+
+// saveCapabilitiesCache 保存磁盘缓存
+func saveCapabilitiesCache(cache map[string]*Capabilities) {
+	dir, err := AppDataDir()
+	if err != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		fmt.Printf("警告: 序列化 FFmpeg 能力缓存失败: %v\n", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("警告: 创建应用数据目录失败: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, capabilitiesFileName), data, 0644); err != nil {
+		fmt.Printf("警告: 保存 FFmpeg 能力缓存失败: %v\n", err)
+	}
+}
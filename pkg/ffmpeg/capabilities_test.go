@@ -0,0 +1,142 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// encodersFull 完整构建的 ffmpeg -hide_banner -encoders 输出（节选）
+const encodersFull = `Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ .F.... = Frame-level multithreading
+ ..S... = Slice-level multithreading
+ ...X.. = Codec is experimental
+ ....B. = Supports draw_horiz_band
+ .....D = Supports direct rendering method 1
+ ------
+ V....D a64multi             Multicolor charset for Commodore 64 (codec a64_multi)
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ V....D h264_vaapi           H.264/AVC (VAAPI) (codec h264)
+ V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)
+ A....D aac                  AAC (Advanced Audio Coding)
+ A....D libopus              libopus Opus (codec opus)
+ S..... srt                  SubRip subtitle
+`
+
+// encodersLGPL 不带 GPL 组件的构建：没有 libx264，也没有硬件编码器
+const encodersLGPL = `Encoders:
+ V..... = Video
+ A..... = Audio
+ S..... = Subtitle
+ ------
+ V....D mpeg4                MPEG-4 part 2
+ V....D libvpx-vp9           libvpx VP9 (codec vp9)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+
+// filtersOutput ffmpeg -hide_banner -filters 输出（节选）
+const filtersOutput = `Filters:
+  T.. = Timeline support
+  .S. = Slice threading
+  ..C = Command support
+  A = Audio input/output
+  V = Video input/output
+  N = Dynamic number and/or type of input/output
+  | = Source or sink filter
+ ... abuffersink       A->|       Buffer audio frames, and make them available to the end of the filter graph.
+ ..C amix              N->A       Audio mixing.
+ T.C crop              V->V       Crop the input video.
+ ... movie             |->N       Read from a movie source.
+ ... sendcmd           V->V       Send commands to filters.
+ TSC scale             V->V       Scale the input video size and/or convert the image format.
+ ... zoompan           V->V       Apply Zoom & Pan effect.
+`
+
+// hwaccelsOutput ffmpeg -hide_banner -hwaccels 输出（Windows 构建使用 CRLF）
+const hwaccelsOutput = "Hardware acceleration methods:\r\ncuda\r\ndxva2\r\nqsv\r\nd3d11va\r\n\r\n"
+
+func TestParseCodecList(t *testing.T) {
+	codecs := parseCodecList(encodersFull)
+	var names []string
+	for _, codec := range codecs {
+		names = append(names, codec.Name)
+	}
+	// 分隔线之前的图例不是编码器
+	want := []string{"a64multi", "libx264", "h264_nvenc", "h264_vaapi", "libsvtav1", "aac", "libopus", "srt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("编码器 = %v, want %v", names, want)
+	}
+
+	want264 := CodecInfo{Name: "libx264", Type: "video", Description: "libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)"}
+	if codecs[1] != want264 {
+		t.Errorf("libx264 = %+v, want %+v", codecs[1], want264)
+	}
+	if codecs[5].Type != "audio" || codecs[7].Type != "subtitle" {
+		t.Errorf("类型 = %s, %s", codecs[5].Type, codecs[7].Type)
+	}
+
+	// 没有分隔线（非预期的输出）时不解析出任何编码器
+	if codecs := parseCodecList("Encoders:\n V....D libx264 libx264\n"); len(codecs) != 0 {
+		t.Errorf("没有分隔线时 = %+v", codecs)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	want := []string{"abuffersink", "amix", "crop", "movie", "sendcmd", "scale", "zoompan"}
+	if got := parseFilters(filtersOutput); !reflect.DeepEqual(got, want) {
+		t.Errorf("滤镜 = %v, want %v", got, want)
+	}
+}
+
+func TestParseHWAccels(t *testing.T) {
+	want := []string{"cuda", "dxva2", "qsv", "d3d11va"}
+	if got := parseHWAccels(hwaccelsOutput); !reflect.DeepEqual(got, want) {
+		t.Errorf("硬件加速 = %q, want %q", got, want)
+	}
+	// 没有硬件加速方法的构建只有标题行
+	if got := parseHWAccels("Hardware acceleration methods:\n\n"); len(got) != 0 {
+		t.Errorf("没有硬件加速时 = %q", got)
+	}
+}
+
+func TestCapabilitiesUsableEncoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoders string
+		hardware map[string]bool
+		want     []string
+	}{
+		{
+			// 硬件编码器试编码成功才可用，按 EncoderRanking 排序
+			"完整构建", encodersFull,
+			map[string]bool{"h264_nvenc": true, "h264_vaapi": false},
+			[]string{"h264_nvenc", "libx264", "libsvtav1"},
+		},
+		{"完整构建未试编码", encodersFull, nil, []string{"libx264", "libsvtav1"}},
+		{"缺少 libx264 的构建", encodersLGPL, nil, []string{"libvpx-vp9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := &Capabilities{
+				Encoders:      parseCodecList(tt.encoders),
+				Filters:       parseFilters(filtersOutput),
+				HardwareTests: tt.hardware,
+			}
+			if got := caps.UsableEncoders(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UsableEncoders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	lgpl := &Capabilities{Encoders: parseCodecList(encodersLGPL), Filters: parseFilters(filtersOutput)}
+	if lgpl.HasEncoder("libx264") || lgpl.EncoderUsable("libx264") {
+		t.Error("缺少 libx264 的构建不应报告 libx264 可用")
+	}
+	if !lgpl.HasFilter("zoompan") || lgpl.HasFilter("zoom") {
+		t.Error("HasFilter 应按完整名称匹配")
+	}
+}
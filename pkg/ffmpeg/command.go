@@ -0,0 +1,254 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Option 命令行选项
+// 用 Opt 创建带值的选项，用 Flag 创建不带值的开关（如 -an）
+type Option struct {
+	Name  string
+	Value string
+	flag  bool
+}
+
+// Opt 创建带值的选项，name 不含前导 "-"
+// 值为空时不输出该选项（如编码器没有预设），避免渲染成开关吞掉下一个参数
+func Opt(name string, value interface{}) Option {
+	return Option{Name: name, Value: fmt.Sprint(value)}
+}
+
+// Flag 创建不带值的选项
+func Flag(name string) Option {
+	return Option{Name: name, flag: true}
+}
+
+// args 渲染为命令行参数
+func (o Option) args() []string {
+	if o.flag {
+		return []string{"-" + o.Name}
+	}
+	if o.Value == "" {
+		return nil
+	}
+	return []string{"-" + o.Name, o.Value}
+}
+
+// OptionArgs 把选项列表渲染为命令行参数
+func OptionArgs(opts []Option) []string {
+	args := []string{}
+	for _, opt := range opts {
+		args = append(args, opt.args()...)
+	}
+	return args
+}
+
+// Command FFmpeg 命令构建器
+// 按 全局选项 -> 输入 -> 滤镜图 -> 输出 的顺序渲染参数，渲染前校验滤镜图和流映射
+type Command struct {
+	global  []Option
+	inputs  []*Input
+	graph   *FilterGraph
+	outputs []*Output
+}
+
+// NewCommand 创建命令构建器
+func NewCommand() *Command {
+	return &Command{}
+}
+
+// Global 添加全局选项（如 -hide_banner、-vaapi_device）
+func (c *Command) Global(opts ...Option) *Command {
+	c.global = append(c.global, opts...)
+	return c
+}
+
+// Overwrite 覆盖已存在的输出文件（-y）
+func (c *Command) Overwrite() *Command {
+	return c.Global(Flag("y"))
+}
+
+// Input 添加输入（文件路径、"-" 表示 stdin，或设备名）
+func (c *Command) Input(source string, opts ...Option) *Input {
+	input := &Input{index: len(c.inputs), source: source, options: opts}
+	c.inputs = append(c.inputs, input)
+	return input
+}
+
+// FilterGraph 返回命令的滤镜图（-filter_complex），首次调用时创建
+func (c *Command) FilterGraph() *FilterGraph {
+	if c.graph == nil {
+		c.graph = NewFilterGraph()
+	}
+	return c.graph
+}
+
+// Output 添加输出
+func (c *Command) Output(target string, opts ...Option) *Output {
+	output := &Output{target: target, options: opts}
+	c.outputs = append(c.outputs, output)
+	return output
+}
+
+// Args 校验并渲染为 argv（不含 ffmpeg 本身）
+func (c *Command) Args() ([]string, error) {
+	if len(c.inputs) == 0 {
+		return nil, errors.New("FFmpeg 命令没有输入")
+	}
+	if len(c.outputs) == 0 {
+		return nil, errors.New("FFmpeg 命令没有输出")
+	}
+
+	// 滤镜图中的每个输出都必须被下一个滤镜或某个输出映射恰好使用一次
+	mapped := make(map[string]int)
+	for _, output := range c.outputs {
+		for _, pad := range output.maps {
+			if err := c.checkPad(pad); err != nil {
+				return nil, err
+			}
+			if pad.label != "" {
+				mapped[pad.label]++
+			}
+		}
+	}
+
+	args := OptionArgs(c.global)
+
+	for _, input := range c.inputs {
+		if input.source == "" {
+			return nil, fmt.Errorf("输入 #%d 没有来源", input.index)
+		}
+		args = append(args, OptionArgs(input.options)...)
+		args = append(args, "-i", input.source)
+	}
+
+	if c.graph != nil && !c.graph.empty() {
+		for _, pad := range c.graph.streamInputs() {
+			if err := c.checkPad(pad); err != nil {
+				return nil, err
+			}
+		}
+		graph, err := c.graph.render(mapped)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-filter_complex", graph)
+	} else if len(mapped) > 0 {
+		return nil, errors.New("输出映射了滤镜输出，但命令没有滤镜图")
+	}
+
+	for _, output := range c.outputs {
+		if output.target == "" {
+			return nil, errors.New("输出没有目标")
+		}
+		for _, pad := range output.maps {
+			args = append(args, "-map", pad.mapArg())
+		}
+		args = append(args, OptionArgs(output.options)...)
+		args = append(args, output.target)
+	}
+
+	return args, nil
+}
+
+// String 渲染为便于日志阅读的命令行（校验失败时包含错误信息）
+func (c *Command) String() string {
+	args, err := c.Args()
+	if err != nil {
+		return fmt.Sprintf("<无效命令: %v>", err)
+	}
+	return strings.Join(args, " ")
+}
+
+// checkPad 检查流引用属于本命令
+func (c *Command) checkPad(pad Pad) error {
+	if pad.IsZero() {
+		return errors.New("流映射为空引用")
+	}
+	if pad.graph != nil && pad.graph != c.graph {
+		return fmt.Errorf("滤镜输出 [%s] 不属于本命令的滤镜图", pad.label)
+	}
+	if pad.label == "" && (pad.input < 0 || pad.input >= len(c.inputs)) {
+		return fmt.Errorf("流引用 [%s] 指向不存在的输入", pad.String())
+	}
+	return nil
+}
+
+// Input 命令输入
+type Input struct {
+	index   int
+	source  string
+	options []Option
+}
+
+// Index 返回输入序号
+func (i *Input) Index() int {
+	return i.index
+}
+
+// With 添加输入选项（放在 -i 之前）
+func (i *Input) With(opts ...Option) *Input {
+	i.options = append(i.options, opts...)
+	return i
+}
+
+// Format 指定输入格式（-f）
+func (i *Input) Format(format string) *Input {
+	return i.With(Opt("f", format))
+}
+
+// Video 引用输入的视频流（如 [0:v]）
+func (i *Input) Video() Pad {
+	return i.Stream("v")
+}
+
+// Audio 引用输入的音频流（如 [0:a]）
+func (i *Input) Audio() Pad {
+	return i.Stream("a")
+}
+
+// Stream 使用流说明符引用输入的流（如 "v:0"）
+func (i *Input) Stream(specifier string) Pad {
+	return Pad{input: i.index, specifier: specifier}
+}
+
+// Output 命令输出
+type Output struct {
+	target  string
+	maps    []Pad
+	options []Option
+}
+
+// Map 映射流到输出（-map），可以是输入流或滤镜图的输出
+func (o *Output) Map(pads ...Pad) *Output {
+	o.maps = append(o.maps, pads...)
+	return o
+}
+
+// With 添加输出选项（放在输出路径之前）
+func (o *Output) With(opts ...Option) *Output {
+	o.options = append(o.options, opts...)
+	return o
+}
+
+// Format 指定输出格式（-f）
+func (o *Output) Format(format string) *Output {
+	return o.With(Opt("f", format))
+}
+
+// VideoCodec 指定视频编码器（-c:v）
+func (o *Output) VideoCodec(codec string) *Output {
+	return o.With(Opt("c:v", codec))
+}
+
+// AudioCodec 指定音频编码器（-c:a）
+func (o *Output) AudioCodec(codec string) *Output {
+	return o.With(Opt("c:a", codec))
+}
+
+// Codec 为所有流指定编码器（-c），如 "copy"
+func (o *Output) Codec(codec string) *Output {
+	return o.With(Opt("c", codec))
+}
@@ -0,0 +1,102 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptionArgs(t *testing.T) {
+	args := OptionArgs([]Option{
+		Opt("c:v", "libx264"),
+		Opt("preset", ""), // 编码器没有预设时不输出
+		Flag("an"),
+		Opt("crf", 23),
+	})
+	want := []string{"-c:v", "libx264", "-an", "-crf", "23"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("OptionArgs = %q, want %q", args, want)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	cmd := NewCommand().Overwrite().Global(Flag("hide_banner"))
+	video := cmd.Input("in.mp4", Opt("hwaccel", "cuda"))
+	logo := cmd.Input("logo.png", Opt("loop", 1))
+
+	graph := cmd.FilterGraph()
+	scaled := graph.Apply(NewFilter("scale", 1280, 720), video.Video())
+	overlaid := graph.Apply(NewFilter("overlay@logo").Set("x", 10).Set("y", 10).Set("shortest", 1), scaled, logo.Video())
+
+	cmd.Output("out.mp4").
+		Map(overlaid, video.Stream("a?")).
+		VideoCodec("h264_nvenc").
+		AudioCodec("copy").
+		With(Opt("preset", ""), Opt("movflags", "+faststart"))
+
+	args, err := cmd.Args()
+	if err != nil {
+		t.Fatalf("Args: %v", err)
+	}
+	want := []string{
+		"-y", "-hide_banner",
+		"-hwaccel", "cuda", "-i", "in.mp4",
+		"-loop", "1", "-i", "logo.png",
+		"-filter_complex", "[0:v]scale=1280:720[scale0];[scale0][1:v]overlay@logo=x=10:y=10:shortest=1[overlay0]",
+		"-map", "[overlay0]", "-map", "0:a?",
+		"-c:v", "h264_nvenc", "-c:a", "copy", "-movflags", "+faststart",
+		"out.mp4",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+	}
+}
+
+func TestCommandArgsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *Command
+	}{
+		{"没有输入", func() *Command {
+			cmd := NewCommand()
+			cmd.Output("out.mp4")
+			return cmd
+		}},
+		{"没有输出", func() *Command {
+			cmd := NewCommand()
+			cmd.Input("in.mp4")
+			return cmd
+		}},
+		{"输出没有目标", func() *Command {
+			cmd := NewCommand()
+			cmd.Input("in.mp4")
+			cmd.Output("")
+			return cmd
+		}},
+		{"滤镜输出未使用", func() *Command {
+			cmd := NewCommand()
+			input := cmd.Input("in.mp4")
+			cmd.FilterGraph().Apply(NewFilter("scale", 1280, 720), input.Video())
+			cmd.Output("out.mp4").Map(input.Video())
+			return cmd
+		}},
+		{"映射其他命令的滤镜输出", func() *Command {
+			other := NewCommand()
+			otherInput := other.Input("other.mp4")
+			pad := other.FilterGraph().Apply(NewFilter("null"), otherInput.Video())
+
+			cmd := NewCommand()
+			cmd.Input("in.mp4")
+			cmd.Output("out.mp4").Map(pad)
+			return cmd
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if args, err := tt.build().Args(); err == nil {
+				t.Errorf("Args() = %q, 应返回错误", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+)
+
+// EnvFFmpegPath 指定 FFmpeg 路径的环境变量
+const EnvFFmpegPath = "SILKREC_FFMPEG"
+
+// appName 应用数据目录名
+const appName = "SilkRec"
+
+// settingsFileName FFmpeg 设置文件名（位于应用数据目录）
+const settingsFileName = "ffmpeg.json"
+
+// DiscoverySource FFmpeg 的来源
+type DiscoverySource string
+
+const (
+	SourceSetting DiscoverySource = "setting" // 用户设置
+	SourceEnv     DiscoverySource = "env"     // SILKREC_FFMPEG 环境变量
+	SourceBundled DiscoverySource = "bundled" // 随应用分发
+	SourceManaged DiscoverySource = "managed" // 安装到应用数据目录
+	SourcePath    DiscoverySource = "path"    // 系统 PATH
+)
+
+// FFmpegInfo FFmpeg 查找结果
+type FFmpegInfo struct {
+	FFmpegPath  string          `json:"ffmpegPath"`
+	FFprobePath string          `json:"ffprobePath"` // 未找到时为空
+	Source      DiscoverySource `json:"source"`
+}
+
+// ffmpegCandidate 待检查的 FFmpeg 位置
+type ffmpegCandidate struct {
+	source DiscoverySource
+	path   string
+}
+
+// ffmpegSettings 持久化的 FFmpeg 设置
+type ffmpegSettings struct {
+	FFmpegPath string `json:"ffmpegPath,omitempty"`
+}
+
+// GetFFmpegPath 获取 FFmpeg 可执行文件路径
+// 查找顺序: 用户设置 > SILKREC_FFMPEG > 随应用分发 > 应用数据目录 > PATH
+func (m *FFmpegManager) GetFFmpegPath() (string, error) {
+	info, err := m.discover()
+	if err != nil {
+		return "", err
+	}
+	return info.FFmpegPath, nil
+}
+
+// GetFFprobePath 获取与当前 FFmpeg 同目录的 ffprobe 可执行文件路径
+func (m *FFmpegManager) GetFFprobePath() (string, error) {
+	info, err := m.discover()
+	if err != nil {
+		return "", err
+	}
+	if info.FFprobePath == "" {
+		return "", fmt.Errorf("%w: %s 同目录中没有 ffprobe 可执行文件", ErrProbeUnavailable, info.FFmpegPath)
+	}
+	return info.FFprobePath, nil
+}
+
+// GetFFmpegInfo 获取 FFmpeg 查找结果
+func (m *FFmpegManager) GetFFmpegInfo() (FFmpegInfo, error) {
+	return m.discover()
+}
+
+// SetFFmpegPath 设置 FFmpeg 路径并保存到设置文件（空字符串表示恢复自动查找）
+func (m *FFmpegManager) SetFFmpegPath(path string) error {
+	if path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("解析 FFmpeg 路径失败: %w", err)
+		}
+		if !isExecutable(absPath) {
+			return fmt.Errorf("不是可执行文件: %s", absPath)
+		}
+		path = absPath
+	}
+
+	m.mu.Lock()
+	m.explicitPath = path
+	m.resetLocked()
+	m.mu.Unlock()
+
+	return m.saveSettings()
+}
+
+// Refresh 清除缓存的查找结果，下次调用时重新查找
+func (m *FFmpegManager) Refresh() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resetLocked()
+}
+
+// resetLocked 清除缓存的查找结果
+func (m *FFmpegManager) resetLocked() {
+	m.ffmpegPath = ""
+	m.ffprobePath = ""
+	m.source = ""
+	m.caps = nil
+}
+
+// discover 严格按优先级选择第一个可用的 FFmpeg，再为它查找 ffprobe
+// ffprobe 是否存在不影响 FFmpeg 的选择，找不到时只是输出校验等功能不可用
+func (m *FFmpegManager) discover() (FFmpegInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 如果已经找到路径，直接返回
+	if m.ffmpegPath != "" {
+		return FFmpegInfo{FFmpegPath: m.ffmpegPath, FFprobePath: m.ffprobePath, Source: m.source}, nil
+	}
+
+	for _, candidate := range m.candidatesLocked() {
+		if candidate.path == "" || !isExecutable(candidate.path) {
+			continue
+		}
+
+		info := FFmpegInfo{FFmpegPath: candidate.path, Source: candidate.source}
+		info.FFprobePath = findProbe(candidate.path)
+		if info.FFprobePath == "" {
+			fmt.Printf("警告: 未找到 %s 对应的 ffprobe，输出校验等功能不可用\n", candidate.path)
+		}
+
+		m.useLocked(info)
+		return info, nil
+	}
+
+	return FFmpegInfo{}, errors.New("未找到 FFmpeg 可执行文件")
+}
+
+// useLocked 缓存查找结果
+func (m *FFmpegManager) useLocked(info FFmpegInfo) {
+	m.ffmpegPath = info.FFmpegPath
+	m.ffprobePath = info.FFprobePath
+	m.source = info.Source
+	fmt.Printf("找到 FFmpeg: %s (来源: %s)\n", info.FFmpegPath, info.Source)
+}
+
+// candidatesLocked 按优先级列出候选位置
+func (m *FFmpegManager) candidatesLocked() []ffmpegCandidate {
+	candidates := []ffmpegCandidate{
+		{SourceSetting, m.explicitPath},
+		{SourceEnv, os.Getenv(EnvFFmpegPath)},
+	}
+
+	// 随应用分发：项目根目录的 ffmpeg/（开发环境）和可执行文件同级目录（生产环境）
+	if absPath, err := filepath.Abs(filepath.Join(".", "ffmpeg", binaryName("ffmpeg"))); err == nil {
+		candidates = append(candidates, ffmpegCandidate{SourceBundled, absPath})
+	}
+	if m.ctx != nil {
+		if exePath, err := os.Executable(); err == nil {
+			exeDir := filepath.Dir(exePath)
+			candidates = append(candidates,
+				ffmpegCandidate{SourceBundled, filepath.Join(exeDir, binaryName("ffmpeg"))},
+				ffmpegCandidate{SourceBundled, filepath.Join(exeDir, "ffmpeg", binaryName("ffmpeg"))},
+			)
+		}
+	}
+
+	// 应用数据目录：InstallFromArchive 安装的位置，以及旧版本手动放置的位置
+	if dir, err := AppDataDir(); err == nil {
+		candidates = append(candidates,
+			ffmpegCandidate{SourceManaged, filepath.Join(ManagedInstallDir(dir), binaryName("ffmpeg"))},
+			ffmpegCandidate{SourceManaged, filepath.Join(dir, binaryName("ffmpeg"))},
+		)
+	}
+
+	// 系统 PATH
+	if path, err := exec.LookPath("ffmpeg"); err == nil {
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+		candidates = append(candidates, ffmpegCandidate{SourcePath, path})
+	}
+
+	return candidates
+}
+
+// findProbe 查找与 ffmpeg 同目录的 ffprobe
+// 不退回系统 PATH：其他位置的 ffprobe 可能来自不同版本的构建，校验结果与实际使用的 FFmpeg 不符
+func findProbe(ffmpegPath string) string {
+	probePath := filepath.Join(filepath.Dir(ffmpegPath), "ffprobe"+filepath.Ext(ffmpegPath))
+	if isExecutable(probePath) {
+		return probePath
+	}
+	return ""
+}
+
+// binaryName 返回当前系统下的可执行文件名
+func binaryName(name string) string {
+	if goruntime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// isExecutable 检查路径是否为可执行的常规文件
+// Windows 下检查 .exe 扩展名，Linux/macOS 下检查执行权限位
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if goruntime.GOOS == "windows" {
+		return filepath.Ext(path) == ".exe"
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// AppDataDir 返回应用数据目录
+// Windows: %LOCALAPPDATA%\SilkRec；Linux: ~/.config/SilkRec；macOS: ~/Library/Application Support/SilkRec
+func AppDataDir() (string, error) {
+	if goruntime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, appName), nil
+		}
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("获取应用数据目录失败: %w", err)
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+// ManagedInstallDir 返回应用数据目录中 FFmpeg 的安装目录
+func ManagedInstallDir(appDataDir string) string {
+	return filepath.Join(appDataDir, "ffmpeg")
+}
+
+// loadSettings 加载 FFmpeg 设置
+func (m *FFmpegManager) loadSettings() error {
+	dir, err := AppDataDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, settingsFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var settings ffmpegSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.explicitPath = settings.FFmpegPath
+	m.mu.Unlock()
+	return nil
+}
+
+// saveSettings 保存 FFmpeg 设置
+func (m *FFmpegManager) saveSettings() error {
+	dir, err := AppDataDir()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	settings := ffmpegSettings{FFmpegPath: m.explicitPath}
+	m.mu.Unlock()
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建应用数据目录失败: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, settingsFileName), data, 0644)
+}
@@ -0,0 +1,155 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// FFmpeg 进程失败的分类，可用 errors.Is 判断
+var (
+	ErrEncoderUnavailable = errors.New("编码器不可用")
+	ErrDeviceBusy         = errors.New("捕获设备被占用或不可用")
+	ErrDiskFull           = errors.New("磁盘空间不足")
+	ErrInvalidFilterGraph = errors.New("滤镜图无效")
+	ErrInputNotFound      = errors.New("输入不存在")
+	ErrProcessKilled      = errors.New("进程被强制终止")
+)
+
+// failurePatterns stderr 特征与失败分类（按顺序匹配，先匹配到的优先）
+// 磁盘满时编码器和封装器也会报错，因此放在最前面
+var failurePatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrDiskFull, []string{
+		"no space left on device",
+		"not enough space on the disk",
+		"disk quota exceeded",
+	}},
+	{ErrInvalidFilterGraph, []string{
+		"error initializing complex filters",
+		"error initializing filter",
+		"error parsing filtergraph",
+		"error configuring filter",
+		"error reinitializing filters",
+		"failed to configure output pad",
+		"no such filter",
+		"has an unconnected output",
+		"cannot find a matching stream for unlabeled input pad",
+		"invalid stream specifier",
+	}},
+	{ErrEncoderUnavailable, []string{
+		"unknown encoder",
+		"encoder not found",
+		"error while opening encoder",
+		"no nvenc capable devices found",
+		"cannot load nvcuda",
+		"cannot load libcuda",
+		"openencodesessionex failed",
+		"failed to initialise vaapi",
+		"no va display found",
+		"device creation failed",
+		"error creating a mfx session",
+		"amf failed",
+	}},
+	{ErrDeviceBusy, []string{
+		"device or resource busy",
+		"resource busy",
+		"could not duplicate output",
+		"failed to capture image",
+		"could not run graph",
+		"could not find audio only device",
+		"could not find video device",
+		"cannot open display",
+	}},
+	{ErrInputNotFound, []string{
+		"no such file or directory",
+		"error opening input",
+		"does not contain any stream",
+	}},
+}
+
+// ClassifyOutput 根据 FFmpeg 的 stderr 输出判断失败原因，无法判断时返回 nil
+func ClassifyOutput(output string) error {
+	lower := strings.ToLower(output)
+	for _, group := range failurePatterns {
+		for _, pattern := range group.patterns {
+			if strings.Contains(lower, pattern) {
+				return group.kind
+			}
+		}
+	}
+	return nil
+}
+
+// ExitError FFmpeg 进程异常退出
+// Kind 为失败分类（ErrDiskFull 等），Err 为原始错误，errors.Is/As 对两者都生效
+type ExitError struct {
+	Name     string // 进程名称
+	ExitCode int    // 退出码（被信号终止时为 -1）
+	Kind     error  // 失败分类，无法判断时为 nil
+	Tail     string // stderr 末尾几行
+	Err      error  // 原始错误
+}
+
+// Error 实现 error 接口
+func (e *ExitError) Error() string {
+	reason := "执行失败"
+	if e.Kind != nil {
+		reason = e.Kind.Error()
+	}
+
+	msg := fmt.Sprintf("%s %s (退出码 %d)", e.Name, reason, e.ExitCode)
+	if e.Tail != "" {
+		msg += ": " + e.Tail
+	}
+	return msg
+}
+
+// Unwrap 支持 errors.Is(err, ErrDiskFull) 等判断
+func (e *ExitError) Unwrap() []error {
+	errs := []error{}
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// newExitError 根据进程退出结果和 stderr 构建 ExitError
+func newExitError(name string, err error, stderr string, killed bool) *ExitError {
+	exitErr := &ExitError{
+		Name:     name,
+		ExitCode: -1,
+		Kind:     ClassifyOutput(stderr),
+		Tail:     lastLines(stderr, 3),
+		Err:      err,
+	}
+
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		exitErr.ExitCode = ee.ExitCode()
+	}
+	if killed && exitErr.Kind == nil {
+		exitErr.Kind = ErrProcessKilled
+	}
+	return exitErr
+}
+
+// lastLines 返回文本的最后 n 个非空行（以 " | " 连接，便于单行显示）
+func lastLines(text string, n int) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
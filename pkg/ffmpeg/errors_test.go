@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   error
+	}{
+		{"磁盘满", "[mp4 @ 0x55] Error writing trailer: No space left on device", ErrDiskFull},
+		{"Windows 磁盘满", "av_interleaved_write_frame(): There is not enough space on the disk.", ErrDiskFull},
+		{
+			// 磁盘满时编码器也会报错，磁盘满优先
+			"磁盘满导致编码器报错",
+			"Error while opening encoder for output stream #0:0\nNo space left on device",
+			ErrDiskFull,
+		},
+		{"未知滤镜", "[AVFilterGraph @ 0x1] No such filter: 'zoompann'", ErrInvalidFilterGraph},
+		{"滤镜图未连接", "Filter scale has an unconnected output", ErrInvalidFilterGraph},
+		{"NVENC 不可用", "[h264_nvenc @ 0x1] Cannot load libcuda.so.1\nError while opening encoder", ErrEncoderUnavailable},
+		{"VAAPI 不可用", "[AVHWDeviceContext @ 0x1] Failed to initialise VAAPI connection: -1 (unknown libva error).", ErrEncoderUnavailable},
+		{"捕获设备被占用", "[video4linux2,v4l2 @ 0x1] ioctl(VIDIOC_STREAMON): Device or resource busy", ErrDeviceBusy},
+		{"没有显示", "[x11grab @ 0x1] Cannot open display :1, error 1.", ErrDeviceBusy},
+		{"输入不存在", "missing.mp4: No such file or directory", ErrInputNotFound},
+		{"大小写不敏感", "NO SPACE LEFT ON DEVICE", ErrDiskFull},
+		{"无法判断", "Conversion failed!", nil},
+		{"空输出", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyOutput(tt.output); got != tt.want {
+				t.Errorf("ClassifyOutput = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	stderr := "line 1\n\nline 2\n  line 3  \nNo space left on device\n"
+	err := error(newExitError("录制", cause, stderr, false))
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("errors.As(*ExitError) = false")
+	}
+	// 分类和原始错误都能用 errors.Is 判断
+	if !errors.Is(err, ErrDiskFull) || !errors.Is(err, cause) {
+		t.Errorf("errors.Is: ErrDiskFull=%v, cause=%v", errors.Is(err, ErrDiskFull), errors.Is(err, cause))
+	}
+	if exitErr.ExitCode != -1 {
+		t.Errorf("不是 *exec.ExitError 时退出码 = %d, want -1", exitErr.ExitCode)
+	}
+	// 只保留最后 3 个非空行
+	if exitErr.Tail != "line 2 | line 3 | No space left on device" {
+		t.Errorf("Tail = %q", exitErr.Tail)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "录制 磁盘空间不足 (退出码 -1): line 2") {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	// 强制终止且无法从输出判断原因时归类为 ErrProcessKilled
+	killed := newExitError("录制", &exec.ExitError{}, "", true)
+	if !errors.Is(killed, ErrProcessKilled) || killed.Tail != "" {
+		t.Errorf("强制终止 = %+v", killed)
+	}
+	if killed.Error() != "录制 进程被强制终止 (退出码 -1)" {
+		t.Errorf("Error() = %q", killed.Error())
+	}
+	// 强制终止但输出能判断原因时保留原因
+	if full := newExitError("录制", cause, "No space left on device", true); !errors.Is(full, ErrDiskFull) || errors.Is(full, ErrProcessKilled) {
+		t.Errorf("强制终止前磁盘已满 = %+v", full)
+	}
+}
@@ -4,83 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	goruntime "runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // FFmpegManager 管理 FFmpeg 可执行文件路径
 type FFmpegManager struct {
-	ctx        context.Context
-	ffmpegPath string
-}
-
-// NewFFmpegManager 创建 FFmpeg 管理器
-func NewFFmpegManager(ctx context.Context) *FFmpegManager {
-	return &FFmpegManager{
-		ctx: ctx,
-	}
-}
+	ctx context.Context
 
-// GetFFmpegPath 获取 FFmpeg 可执行文件路径
-func (m *FFmpegManager) GetFFmpegPath() (string, error) {
-	// 如果已经找到路径，直接返回
-	if m.ffmpegPath != "" {
-		return m.ffmpegPath, nil
-	}
-
-	// 按优先级查找 FFmpeg
-	paths := []string{
-		m.getDevPath(),     // 开发环境路径
-		m.getProdPath(),    // 生产环境路径
-		m.getAppDataPath(), // 应用数据目录（可选）
-	}
+	mu           sync.Mutex
+	explicitPath string // 用户设置的 FFmpeg 路径（最高优先级）
+	ffmpegPath   string
+	ffprobePath  string
+	source       DiscoverySource
 
-	for _, path := range paths {
-		if path == "" {
-			continue
-		}
-		if _, err := os.Stat(path); err == nil {
-			m.ffmpegPath = path
-			fmt.Printf("找到 FFmpeg: %s\n", path)
-			return path, nil
-		}
-	}
+	capsMu sync.Mutex    // 串行化能力探测
+	caps   *Capabilities // 当前 FFmpeg 的能力探测结果
 
-	return "", errors.New("未找到 FFmpeg 可执行文件")
+	processHandler func(ProcessEvent) // 进程生命周期事件处理器
 }
 
-// getDevPath 获取开发环境路径
-func (m *FFmpegManager) getDevPath() string {
-	// 项目根目录下的 ffmpeg/ffmpeg.exe - 使用绝对路径
-	if absPath, err := filepath.Abs(filepath.Join(".", "ffmpeg", "ffmpeg.exe")); err == nil {
-		return absPath
+// NewFFmpegManager 创建 FFmpeg 管理器
+func NewFFmpegManager(ctx context.Context) *FFmpegManager {
+	m := &FFmpegManager{
+		ctx: ctx,
 	}
-	return filepath.Join(".", "ffmpeg", "ffmpeg.exe")
-}
-
-// getProdPath 获取生产环境路径
-func (m *FFmpegManager) getProdPath() string {
-	// 可执行文件同级目录的 ffmpeg.exe
-	if m.ctx != nil {
-		exePath, err := os.Executable()
-		if err == nil {
-			return filepath.Join(filepath.Dir(exePath), "ffmpeg.exe")
-		}
+	if err := m.loadSettings(); err != nil {
+		fmt.Printf("警告: 加载 FFmpeg 设置失败: %v\n", err)
 	}
-	return ""
-}
-
-// getAppDataPath 获取应用数据目录路径
-func (m *FFmpegManager) getAppDataPath() string {
-	// 使用环境变量获取 AppData 目录
-	appDataDir := os.Getenv("LOCALAPPDATA")
-	if appDataDir != "" {
-		return filepath.Join(appDataDir, "SilkRec", "ffmpeg.exe")
-	}
-	return ""
+	return m
 }
 
 // CheckFFmpegAvailable 检查 FFmpeg 是否可用
@@ -89,20 +43,7 @@ func (m *FFmpegManager) CheckFFmpegAvailable() bool {
 	if err != nil {
 		return false
 	}
-
-	// 检查文件是否存在且可执行
-	info, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
-
-	// Windows 下检查 .exe 扩展名
-	if goruntime.GOOS == "windows" && filepath.Ext(path) != ".exe" {
-		return false
-	}
-
-	// 检查是否为常规文件
-	return !info.IsDir()
+	return isExecutable(path)
 }
 
 // GetFFmpegVersion 获取 FFmpeg 版本
@@ -112,8 +53,7 @@ func (m *FFmpegManager) GetFFmpegVersion() (string, error) {
 		return "", err
 	}
 
-	cmd := exec.Command(path, "-version")
-	output, err := cmd.Output()
+	output, err := RunOutput(context.Background(), path, []string{"-version"}, "FFmpeg")
 	if err != nil {
 		return "", err
 	}
@@ -128,47 +68,84 @@ func (m *FFmpegManager) GetFFmpegVersion() (string, error) {
 }
 
 // CheckEncoderAvailable 检查指定的编码器是否可用
+// 硬件编码器需要试编码成功才算可用
 func (m *FFmpegManager) CheckEncoderAvailable(encoder string) bool {
-	path, err := m.GetFFmpegPath()
+	caps, err := m.Capabilities()
 	if err != nil {
 		return false
 	}
-
-	cmd := exec.Command(path, "-encoders")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-
-	return strings.Contains(string(output), encoder)
+	return caps.EncoderUsable(encoder)
 }
 
 // GetBestEncoder 获取最佳可用编码器
-// 优先级: h264_nvenc > libx264
+// 优先级见 EncoderRanking: 硬件编码器 (VAAPI/QSV/AMF/VideoToolbox/NVENC) > libx264 > libx265 > SVT-AV1 > libvpx-vp9
 func (m *FFmpegManager) GetBestEncoder() (string, error) {
-	// 1. 尝试检测 h264_nvenc (NVIDIA GPU 加速)
-	if m.CheckEncoderAvailable("h264_nvenc") {
-		return "h264_nvenc", nil
+	caps, err := m.Capabilities()
+	if err != nil {
+		return "", err
 	}
 
-	// 2. 回退到 libx264 (CPU 编码)
-	if m.CheckEncoderAvailable("libx264") {
-		return "libx264", nil
+	if encoders := caps.UsableEncoders(); len(encoders) > 0 {
+		return encoders[0], nil
 	}
 
-	return "", errors.New("未找到可用的视频编码器 (需要 h264_nvenc 或 libx264)")
+	return "", errors.New("未找到可用的视频编码器")
 }
 
-// GetBestPreset 获取编码器的最佳预设
+// GetBestPreset 获取编码器的最佳预设（编码器不支持 -preset 时返回空字符串）
 func (m *FFmpegManager) GetBestPreset(encoder string) string {
 	switch encoder {
 	case "h264_nvenc":
 		// NVIDIA 编码器预设: p1 (fastest) - p7 (slowest, best quality)
 		return "p4" // 平衡速度和质量
-	case "libx264":
-		// libx264 预设: ultrafast - veryslow
+	case "h264_qsv":
+		// Intel QSV 预设: veryfast - veryslow
+		return "veryfast"
+	case "libx264", "libx265":
+		// libx264/libx265 预设: ultrafast - veryslow
 		return "ultrafast" // 快速编码
+	case "libsvtav1":
+		// SVT-AV1 预设: 0 (slowest) - 13 (fastest)
+		return "10"
 	default:
+		// VAAPI、AMF、VideoToolbox、libvpx-vp9 不使用 -preset
 		return ""
 	}
 }
+
+// GetKeyframeTimes 获取视频第一条视频流中所有关键帧的时间（秒，升序）
+// 只读取数据包标志，不解码画面，速度很快
+func (m *FFmpegManager) GetKeyframeTimes(videoPath string) ([]float64, error) {
+	probePath, err := m.GetFFprobePath()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := RunOutput(context.Background(), probePath, []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		videoPath,
+	}, "ffprobe")
+	if err != nil {
+		return nil, fmt.Errorf("读取关键帧失败: %w", err)
+	}
+
+	// 每行格式: pts_time,flags 例如 "1.233000,K__"
+	times := []float64{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "K") {
+			continue
+		}
+		t, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+
+	sort.Float64s(times)
+	return times, nil
+}
@@ -0,0 +1,236 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Pad 滤镜图中的流：输入文件的流（如 [0:v]）或滤镜输出（带标签）
+type Pad struct {
+	graph     *FilterGraph
+	label     string // 滤镜输出标签
+	input     int    // 输入序号（仅输入流）
+	specifier string // 流说明符（仅输入流），如 "v"、"a:0"
+}
+
+// IsZero 检查是否为空引用
+func (p Pad) IsZero() bool {
+	return p.label == "" && p.specifier == ""
+}
+
+// String 渲染为滤镜图中的引用形式，如 "[0:v]"、"[scale1]"
+func (p Pad) String() string {
+	if p.label != "" {
+		return "[" + p.label + "]"
+	}
+	return fmt.Sprintf("[%d:%s]", p.input, p.specifier)
+}
+
+// mapArg 渲染为 -map 的参数：滤镜输出为 "[label]"，输入流为 "0:v"
+func (p Pad) mapArg() string {
+	if p.label != "" {
+		return "[" + p.label + "]"
+	}
+	return fmt.Sprintf("%d:%s", p.input, p.specifier)
+}
+
+// Filter 单个滤镜及其参数
+type Filter struct {
+	name string
+	args []filterArg
+}
+
+// filterArg 滤镜参数（key 为空表示位置参数）
+type filterArg struct {
+	key   string
+	value string
+}
+
+// NewFilter 创建滤镜，positional 为按顺序的位置参数
+// name 可以带实例名（如 overlay@cursor0），sendcmd 等按实例名向滤镜发送命令
+func NewFilter(name string, positional ...interface{}) *Filter {
+	f := &Filter{name: name}
+	for _, value := range positional {
+		f.args = append(f.args, filterArg{value: fmt.Sprint(value)})
+	}
+	return f
+}
+
+// Set 设置命名参数（按调用顺序渲染），值会自动转义
+func (f *Filter) Set(key string, value interface{}) *Filter {
+	f.args = append(f.args, filterArg{key: key, value: fmt.Sprint(value)})
+	return f
+}
+
+// String 渲染滤镜，如 "scale=w=1280:h=720"
+func (f *Filter) String() string {
+	if len(f.args) == 0 {
+		return f.name
+	}
+
+	parts := make([]string, len(f.args))
+	for i, arg := range f.args {
+		if arg.key == "" {
+			parts[i] = EscapeFilterValue(arg.value)
+		} else {
+			parts[i] = arg.key + "=" + EscapeFilterValue(arg.value)
+		}
+	}
+	return f.name + "=" + strings.Join(parts, ":")
+}
+
+// EscapeFilterValue 转义滤镜参数值
+// FFmpeg 对滤镜图做两级解析：先按 [],; 切分滤镜图，再按 : 切分滤镜参数，
+// 所以先转义参数级的特殊字符（\ ' :），再转义滤镜图级的特殊字符（\ ' [ ] , ;）。
+// 例如 Windows 路径 C:\bg.png 渲染为 C\\:\\\\bg.png
+func EscapeFilterValue(value string) string {
+	return escapeChars(escapeChars(value, `\':`), `\'[],;`)
+}
+
+// escapeChars 在指定字符前加反斜杠
+func escapeChars(value, special string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// filterChain 一条线性滤镜链: [in...]f1,f2,...[out...]
+type filterChain struct {
+	inputs  []Pad
+	filters []*Filter
+	outputs []string
+}
+
+// FilterGraph 带标签的滤镜图（-filter_complex）
+// 每条链的输出自动生成唯一标签；渲染时校验每个标签恰好被使用一次
+type FilterGraph struct {
+	chains []*filterChain
+	labels map[string]int // 标签前缀 -> 已使用次数
+}
+
+// NewFilterGraph 创建空的滤镜图
+func NewFilterGraph() *FilterGraph {
+	return &FilterGraph{labels: make(map[string]int)}
+}
+
+// Chain 添加一条滤镜链，返回其唯一输出
+func (g *FilterGraph) Chain(inputs []Pad, filters ...*Filter) Pad {
+	return g.ChainN(1, inputs, filters...)[0]
+}
+
+// ChainN 添加一条有 n 个输出的滤镜链（如 split），返回所有输出
+func (g *FilterGraph) ChainN(n int, inputs []Pad, filters ...*Filter) []Pad {
+	chain := &filterChain{inputs: inputs, filters: filters}
+	g.chains = append(g.chains, chain)
+
+	prefix := "out"
+	if len(filters) > 0 {
+		name, _, _ := strings.Cut(filters[len(filters)-1].name, "@") // 标签不包含实例名
+		prefix = strings.ReplaceAll(name, "_", "")
+	}
+
+	pads := make([]Pad, n)
+	for i := range pads {
+		label := fmt.Sprintf("%s%d", prefix, g.labels[prefix])
+		g.labels[prefix]++
+		chain.outputs = append(chain.outputs, label)
+		pads[i] = Pad{graph: g, label: label}
+	}
+	return pads
+}
+
+// Apply 对输入应用单个滤镜，返回其输出
+func (g *FilterGraph) Apply(filter *Filter, inputs ...Pad) Pad {
+	return g.Chain(inputs, filter)
+}
+
+// Source 添加没有输入的源滤镜链（如 color、movie），返回其输出
+func (g *FilterGraph) Source(filters ...*Filter) Pad {
+	return g.Chain(nil, filters...)
+}
+
+// empty 检查滤镜图是否为空
+func (g *FilterGraph) empty() bool {
+	return len(g.chains) == 0
+}
+
+// streamInputs 返回滤镜图引用的所有输入文件流
+func (g *FilterGraph) streamInputs() []Pad {
+	pads := []Pad{}
+	for _, chain := range g.chains {
+		for _, pad := range chain.inputs {
+			if pad.label == "" {
+				pads = append(pads, pad)
+			}
+		}
+	}
+	return pads
+}
+
+// render 校验并渲染滤镜图
+// mapped 为输出映射使用各标签的次数
+func (g *FilterGraph) render(mapped map[string]int) (string, error) {
+	produced := make(map[string]bool)
+	for _, chain := range g.chains {
+		for _, label := range chain.outputs {
+			produced[label] = true
+		}
+	}
+
+	used := make(map[string]int)
+	for label, count := range mapped {
+		used[label] += count
+	}
+
+	parts := make([]string, 0, len(g.chains))
+	for _, chain := range g.chains {
+		if len(chain.filters) == 0 {
+			return "", errors.New("滤镜链中没有滤镜")
+		}
+
+		var b strings.Builder
+		for _, pad := range chain.inputs {
+			if pad.IsZero() {
+				return "", errors.New("滤镜链的输入为空引用")
+			}
+			if pad.label != "" {
+				if pad.graph != g || !produced[pad.label] {
+					return "", fmt.Errorf("滤镜输入 [%s] 不属于本滤镜图", pad.label)
+				}
+				used[pad.label]++
+			}
+			b.WriteString(pad.String())
+		}
+
+		names := make([]string, len(chain.filters))
+		for i, filter := range chain.filters {
+			names[i] = filter.String()
+		}
+		b.WriteString(strings.Join(names, ","))
+
+		for _, label := range chain.outputs {
+			b.WriteString("[" + label + "]")
+		}
+		parts = append(parts, b.String())
+	}
+
+	for _, chain := range g.chains {
+		for _, label := range chain.outputs {
+			switch used[label] {
+			case 0:
+				return "", fmt.Errorf("滤镜输出 [%s] 没有连接到任何滤镜或输出", label)
+			case 1:
+			default:
+				return "", fmt.Errorf("滤镜输出 [%s] 被使用了 %d 次（需要 split 复制）", label, used[label])
+			}
+		}
+	}
+
+	return strings.Join(parts, ";"), nil
+}
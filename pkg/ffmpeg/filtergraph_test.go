@@ -0,0 +1,129 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// filterPaths 需要转义的路径：盘符冒号、单引号、反斜杠、逗号、方括号、分号、空格和中文
+var filterPaths = []struct {
+	name string
+	path string
+	want string // EscapeFilterValue 的结果
+}{
+	{"Windows 路径", `C:\Users\张三\Videos\bg image.png`, `C\\:\\\\Users\\\\张三\\\\Videos\\\\bg image.png`},
+	{"引号和滤镜图分隔符", `/home/me/it's [draft], v2; final.png`, `/home/me/it\\\'s \[draft\]\, v2\; final.png`},
+	{"空格和中文", `/tmp/录屏 2024/cursor.txt`, `/tmp/录屏 2024/cursor.txt`},
+	{"Windows 路径中的逗号和引号", `D:\录制\a,b'c.camera.txt`, `D\\:\\\\录制\\\\a\,b\\\'c.camera.txt`},
+}
+
+// getToken 按 FFmpeg 的 av_get_token 规则读取一个值：
+// 跳过开头空白，反斜杠转义下一个字符，单引号内原样保留，遇到 term 中的字符结束，去掉末尾未转义的空白
+func getToken(s, term string) (token, rest string) {
+	s = strings.TrimLeft(s, " \n\t\r")
+	var b []byte
+	end := 0
+	i := 0
+	for ; i < len(s) && !strings.ContainsRune(term, rune(s[i])); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b = append(b, s[i])
+			end = len(b)
+		case c == '\'':
+			for i++; i < len(s) && s[i] != '\''; i++ {
+				b = append(b, s[i])
+			}
+			end = len(b)
+		default:
+			b = append(b, c)
+			if !strings.ContainsRune(" \n\t\r", rune(c)) {
+				end = len(b)
+			}
+		}
+	}
+	return string(b[:end]), s[i:]
+}
+
+// parseFilter 按 FFmpeg 的两级解析还原滤镜名和参数：
+// 先从滤镜图中取出滤镜参数（以 [],; 结束），再按 : 切分 key=value
+func parseFilter(t *testing.T, graph string) (string, map[string]string) {
+	t.Helper()
+	name, rest := getToken(graph, "=,;[")
+	if !strings.HasPrefix(rest, "=") {
+		return name, nil
+	}
+	args, rest := getToken(rest[1:], "[],;")
+	if rest != "" {
+		t.Fatalf("滤镜 %s 的参数没有读完: %q", name, rest)
+	}
+
+	options := map[string]string{}
+	for args != "" {
+		key, value, ok := strings.Cut(args, "=")
+		if !ok {
+			t.Fatalf("参数缺少 key: %q", args)
+		}
+		value, args = getToken(value, ":")
+		options[key] = value
+		args = strings.TrimPrefix(args, ":")
+	}
+	return name, options
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+	for _, tt := range filterPaths {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeFilterValue(tt.path); got != tt.want {
+				t.Errorf("EscapeFilterValue(%q) = %s, want %s", tt.path, got, tt.want)
+			}
+
+			// FFmpeg 两级解析后还原为原始路径
+			for _, filter := range []*Filter{
+				NewFilter("movie").Set("filename", tt.path).Set("loop", 0),
+				NewFilter("sendcmd").Set("f", tt.path),
+			} {
+				name, options := parseFilter(t, filter.String())
+				want := map[string]string{"filename": tt.path, "loop": "0"}
+				if name == "sendcmd" {
+					want = map[string]string{"f": tt.path}
+				}
+				if !reflect.DeepEqual(options, want) {
+					t.Errorf("%s 解析结果 = %q, want %q", filter, options, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPathArgs(t *testing.T) {
+	background := `C:\Users\张三\Videos\bg image.png`
+	commands := `D:\录制\a,b'c.camera.txt`
+
+	cmd := NewCommand().Overwrite()
+	video := cmd.Input(`D:\录制\屏幕 录制.mp4`)
+	graph := cmd.FilterGraph()
+	bg := graph.Source(NewFilter("movie").Set("filename", background).Set("loop", 0))
+	moved := graph.Apply(NewFilter("sendcmd").Set("f", commands), video.Video())
+	overlaid := graph.Apply(NewFilter("overlay", 0, 0).Set("shortest", 1), bg, moved)
+	cmd.Output(`D:\导出\最终 版本.mp4`).Map(overlaid)
+
+	args, err := cmd.Args()
+	if err != nil {
+		t.Fatalf("Args: %v", err)
+	}
+	// 输入输出路径是独立的命令行参数，不需要转义；滤镜图中的路径按两级规则转义
+	want := []string{
+		"-y",
+		"-i", `D:\录制\屏幕 录制.mp4`,
+		"-filter_complex", `movie=filename=C\\:\\\\Users\\\\张三\\\\Videos\\\\bg image.png:loop=0[movie0];` +
+			`[0:v]sendcmd=f=D\\:\\\\录制\\\\a\,b\\\'c.camera.txt[sendcmd0];` +
+			`[movie0][sendcmd0]overlay=0:0:shortest=1[overlay0]`,
+		"-map", "[overlay0]",
+		`D:\导出\最终 版本.mp4`,
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("参数不符\n got: %q\nwant: %q", args, want)
+	}
+}
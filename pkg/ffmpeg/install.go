@@ -0,0 +1,181 @@
+package ffmpeg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// InstallFromArchive 从用户提供的 FFmpeg 压缩包安装到应用数据目录
+// 压缩包必须与 expectedSHA256 匹配；支持 .zip、.tar.gz/.tgz 和 .tar。
+// 只解压 ffmpeg 和 ffprobe 两个可执行文件，安装完成后设为当前使用的 FFmpeg。
+func (m *FFmpegManager) InstallFromArchive(archivePath, expectedSHA256 string) (FFmpegInfo, error) {
+	expectedSHA256 = strings.ToLower(strings.TrimSpace(expectedSHA256))
+	if expectedSHA256 == "" {
+		return FFmpegInfo{}, fmt.Errorf("必须提供压缩包的 SHA-256 校验值")
+	}
+
+	// 1. 校验压缩包
+	checksum, err := FileChecksum(archivePath)
+	if err != nil {
+		return FFmpegInfo{}, fmt.Errorf("读取压缩包失败: %w", err)
+	}
+	if checksum != expectedSHA256 {
+		return FFmpegInfo{}, fmt.Errorf("压缩包校验失败: SHA-256 为 %s，预期 %s", checksum, expectedSHA256)
+	}
+	fmt.Printf("✓ 压缩包校验通过: %s\n", archivePath)
+
+	// 2. 解压到临时目录
+	appDataDir, err := AppDataDir()
+	if err != nil {
+		return FFmpegInfo{}, err
+	}
+	if err := os.MkdirAll(appDataDir, 0755); err != nil {
+		return FFmpegInfo{}, fmt.Errorf("创建应用数据目录失败: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(appDataDir, "ffmpeg-install-")
+	if err != nil {
+		return FFmpegInfo{}, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wanted := map[string]bool{binaryName("ffmpeg"): true, binaryName("ffprobe"): true}
+	if err := extractBinaries(archivePath, tmpDir, wanted); err != nil {
+		return FFmpegInfo{}, err
+	}
+	for name := range wanted {
+		if !isExecutable(filepath.Join(tmpDir, name)) {
+			return FFmpegInfo{}, fmt.Errorf("压缩包中没有 %s", name)
+		}
+	}
+
+	// 3. 替换旧的安装
+	installDir := ManagedInstallDir(appDataDir)
+	if err := os.RemoveAll(installDir); err != nil {
+		return FFmpegInfo{}, fmt.Errorf("删除旧的 FFmpeg 失败: %w", err)
+	}
+	if err := os.Rename(tmpDir, installDir); err != nil {
+		return FFmpegInfo{}, fmt.Errorf("安装 FFmpeg 失败: %w", err)
+	}
+	fmt.Printf("✓ FFmpeg 已安装到: %s\n", installDir)
+
+	// 4. 设为当前使用的 FFmpeg
+	if err := m.SetFFmpegPath(filepath.Join(installDir, binaryName("ffmpeg"))); err != nil {
+		return FFmpegInfo{}, err
+	}
+	return m.GetFFmpegInfo()
+}
+
+// extractBinaries 从压缩包中按文件名解压需要的可执行文件到 destDir
+// 只使用条目的文件名部分，忽略目录结构，避免路径穿越
+func extractBinaries(archivePath, destDir string, wanted map[string]bool) error {
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return extractZip(archivePath, destDir, wanted)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压 gzip 失败: %w", err)
+		}
+		defer gz.Close()
+		return extractTar(gz, destDir, wanted)
+	case strings.HasSuffix(lower, ".tar"):
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return extractTar(file, destDir, wanted)
+	default:
+		return fmt.Errorf("不支持的压缩包格式: %s", filepath.Base(archivePath))
+	}
+}
+
+// extractZip 解压 zip 中需要的文件
+func extractZip(archivePath, destDir string, wanted map[string]bool) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开 zip 失败: %w", err)
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || !wanted[name] {
+			continue
+		}
+		src, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = writeExecutable(filepath.Join(destDir, name), src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTar 解压 tar 中需要的文件
+func extractTar(r io.Reader, destDir string, wanted map[string]bool) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 失败: %w", err)
+		}
+		name := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || !wanted[name] {
+			continue
+		}
+		if err := writeExecutable(filepath.Join(destDir, name), reader); err != nil {
+			return err
+		}
+	}
+}
+
+// writeExecutable 写入可执行文件
+func writeExecutable(dest string, src io.Reader) error {
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, src); err != nil {
+		file.Close()
+		return fmt.Errorf("解压 %s 失败: %w", filepath.Base(dest), err)
+	}
+	return file.Close()
+}
+
+// FileChecksum 计算文件的 SHA-256（安装包校验和导出段检查点共用）
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,194 @@
+package ffmpeg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveEntries 测试压缩包的内容：发行版通常带版本目录和文档
+var archiveEntries = map[string]string{
+	"ffmpeg-7.0/bin/" + binaryName("ffmpeg"):  "ffmpeg binary",
+	"ffmpeg-7.0/bin/" + binaryName("ffprobe"): "ffprobe binary",
+	"ffmpeg-7.0/bin/" + binaryName("ffplay"):  "ffplay binary",
+	"ffmpeg-7.0/doc/README.txt":               "readme",
+}
+
+// writeTarGz 写入 tar.gz 压缩包
+func writeTarGz(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeZip 写入 zip 压缩包
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newInstallTestManager 创建使用临时应用数据目录的管理器
+func newInstallTestManager(t *testing.T) *FFmpegManager {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv(EnvFFmpegPath, "")
+	return NewFFmpegManager(context.Background())
+}
+
+func TestFileChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// echo -n abc | sha256sum
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; checksum != want {
+		t.Errorf("FileChecksum = %s, want %s", checksum, want)
+	}
+	if _, err := FileChecksum(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestInstallFromArchive(t *testing.T) {
+	for _, format := range []string{".tar.gz", ".zip"} {
+		t.Run(format, func(t *testing.T) {
+			m := newInstallTestManager(t)
+			archive := filepath.Join(t.TempDir(), "ffmpeg-release"+format)
+			if format == ".zip" {
+				writeZip(t, archive, archiveEntries)
+			} else {
+				writeTarGz(t, archive, archiveEntries)
+			}
+			checksum, err := FileChecksum(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 校验值不匹配时拒绝安装
+			if _, err := m.InstallFromArchive(archive, strings.Repeat("0", 64)); err == nil || !strings.Contains(err.Error(), "校验失败") {
+				t.Fatalf("校验值不匹配: err = %v", err)
+			}
+			if _, err := m.GetFFmpegPath(); err == nil {
+				t.Fatal("校验失败后不应安装 FFmpeg")
+			}
+
+			// 校验值大小写和首尾空白不影响匹配
+			info, err := m.InstallFromArchive(archive, " "+strings.ToUpper(checksum)+"\n")
+			if err != nil {
+				t.Fatalf("InstallFromArchive: %v", err)
+			}
+			appData, _ := AppDataDir()
+			installDir := ManagedInstallDir(appData)
+			if info.FFmpegPath != filepath.Join(installDir, binaryName("ffmpeg")) ||
+				info.FFprobePath != filepath.Join(installDir, binaryName("ffprobe")) {
+				t.Errorf("安装结果 = %+v", info)
+			}
+
+			// 只解压 ffmpeg 和 ffprobe，忽略目录结构和其他文件
+			entries, err := os.ReadDir(installDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if len(names) != 2 {
+				t.Errorf("安装目录内容 = %v", names)
+			}
+			data, err := os.ReadFile(info.FFmpegPath)
+			if err != nil || string(data) != "ffmpeg binary" {
+				t.Errorf("ffmpeg 内容 = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestInstallFromArchiveMissingProbe(t *testing.T) {
+	m := newInstallTestManager(t)
+	archive := filepath.Join(t.TempDir(), "ffmpeg.tar.gz")
+	writeTarGz(t, archive, map[string]string{"bin/" + binaryName("ffmpeg"): "ffmpeg binary"})
+	checksum, err := FileChecksum(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.InstallFromArchive(archive, checksum); err == nil || !strings.Contains(err.Error(), "ffprobe") {
+		t.Errorf("缺少 ffprobe 的压缩包: err = %v", err)
+	}
+	if _, err := m.InstallFromArchive(archive+".rar", checksum); err == nil {
+		t.Error("不存在或不支持的压缩包应返回错误")
+	}
+}
+
+func TestFindProbeIgnoresPath(t *testing.T) {
+	ffmpegDir, pathDir := t.TempDir(), t.TempDir()
+	ffmpegPath := filepath.Join(ffmpegDir, binaryName("ffmpeg"))
+	for _, path := range []string{ffmpegPath, filepath.Join(pathDir, binaryName("ffprobe"))} {
+		if err := os.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", pathDir)
+
+	// PATH 中的 ffprobe 可能来自其他构建，只接受同目录的 ffprobe
+	if probe := findProbe(ffmpegPath); probe != "" {
+		t.Errorf("findProbe = %s, want 空", probe)
+	}
+	sibling := filepath.Join(ffmpegDir, binaryName("ffprobe"))
+	if err := os.WriteFile(sibling, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if probe := findProbe(ffmpegPath); probe != sibling {
+		t.Errorf("findProbe = %s, want %s", probe, sibling)
+	}
+}
@@ -0,0 +1,251 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 输出校验错误类型，可用 errors.Is 判断
+var (
+	ErrOutputMissing     = errors.New("输出文件不存在")
+	ErrOutputEmpty       = errors.New("输出文件为空或没有媒体流")
+	ErrOutputTruncated   = errors.New("输出文件被截断")
+	ErrOutputUndecodable = errors.New("输出文件无法解码")
+	ErrOutputMismatch    = errors.New("输出文件与预期不符")
+	// ErrProbeUnavailable 没有可用的 ffprobe，无法校验（文件本身可能完好）
+	ErrProbeUnavailable = errors.New("ffprobe 不可用")
+)
+
+// ProbeExpectation 输出校验的预期值（零值表示不检查该项）
+type ProbeExpectation struct {
+	Duration          float64 // 预期时长（秒）
+	DurationTolerance float64 // 时长容差（秒），默认 max(1s, 5%)
+	VideoStreams      int     // 预期视频流数量
+	AudioStreams      int     // 预期音频流数量
+	Width             int     // 预期宽度
+	Height            int     // 预期高度
+	FPS               float64 // 预期帧率
+	SkipFrameCheck    bool    // 跳过首尾帧解码检查
+}
+
+// StreamInfo 媒体流信息
+type StreamInfo struct {
+	Index     int     `json:"index"`
+	CodecType string  `json:"codecType"` // video, audio, subtitle...
+	CodecName string  `json:"codecName"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	FPS       float64 `json:"fps,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+}
+
+// ProbeReport 输出校验报告
+type ProbeReport struct {
+	Path         string       `json:"path"`
+	Size         int64        `json:"size"`
+	FormatName   string       `json:"formatName"`
+	Duration     float64      `json:"duration"` // 秒
+	VideoStreams int          `json:"videoStreams"`
+	AudioStreams int          `json:"audioStreams"`
+	Width        int          `json:"width"`
+	Height       int          `json:"height"`
+	FPS          float64      `json:"fps"`
+	Streams      []StreamInfo `json:"streams"`
+	FirstFrameOK bool         `json:"firstFrameOK"`
+	LastFrameOK  bool         `json:"lastFrameOK"`
+	Problems     []string     `json:"problems,omitempty"`
+	OK           bool         `json:"ok"`
+	CheckedAt    time.Time    `json:"checkedAt"`
+}
+
+// ffprobeOutput ffprobe -print_format json 的输出
+type ffprobeOutput struct {
+	Streams []struct {
+		Index        int    `json:"index"`
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Duration     string `json:"duration"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+	} `json:"format"`
+}
+
+// ProbeOutput 使用 ffprobe 校验输出文件
+// 总是尽可能返回报告；文件缺失、为空、截断、无法解码或与预期不符时同时返回对应的错误
+func (m *FFmpegManager) ProbeOutput(path string, expect ProbeExpectation) (*ProbeReport, error) {
+	report := &ProbeReport{Path: path, CheckedAt: time.Now()}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return report.fail(ErrOutputMissing, "%s", path)
+	}
+	report.Size = info.Size()
+	if report.Size == 0 {
+		return report.fail(ErrOutputEmpty, "文件大小为 0")
+	}
+
+	probePath, err := m.GetFFprobePath()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := RunOutput(context.Background(), probePath, []string{
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	}, "ffprobe")
+	if err != nil {
+		// 常见于 MP4 缺少 moov atom（录制进程被强制终止）
+		return report.fail(ErrOutputTruncated, "ffprobe 无法读取: %v", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("解析 ffprobe 输出失败: %w", err)
+	}
+
+	report.FormatName = probe.Format.FormatName
+	report.Duration = parseFloat(probe.Format.Duration)
+	for _, s := range probe.Streams {
+		stream := StreamInfo{
+			Index:     s.Index,
+			CodecType: s.CodecType,
+			CodecName: s.CodecName,
+			Width:     s.Width,
+			Height:    s.Height,
+			Duration:  parseFloat(s.Duration),
+		}
+		switch s.CodecType {
+		case "video":
+			stream.FPS = parseFrameRate(s.AvgFrameRate)
+			if stream.FPS == 0 {
+				stream.FPS = parseFrameRate(s.RFrameRate)
+			}
+			if report.VideoStreams == 0 {
+				report.Width = s.Width
+				report.Height = s.Height
+				report.FPS = stream.FPS
+			}
+			report.VideoStreams++
+		case "audio":
+			report.AudioStreams++
+		}
+		report.Streams = append(report.Streams, stream)
+	}
+
+	if len(report.Streams) == 0 {
+		return report.fail(ErrOutputEmpty, "没有媒体流")
+	}
+
+	// 时长：明显短于预期视为截断
+	if expect.Duration > 0 {
+		tolerance := expect.DurationTolerance
+		if tolerance <= 0 {
+			tolerance = math.Max(1.0, expect.Duration*0.05)
+		}
+		if report.Duration < expect.Duration-tolerance {
+			return report.fail(ErrOutputTruncated, "时长 %.2fs，预期 %.2fs", report.Duration, expect.Duration)
+		}
+		if report.Duration > expect.Duration+tolerance {
+			report.addProblem("时长 %.2fs 超出预期 %.2fs", report.Duration, expect.Duration)
+		}
+	}
+
+	if expect.VideoStreams > 0 && report.VideoStreams != expect.VideoStreams {
+		report.addProblem("视频流数量 %d，预期 %d", report.VideoStreams, expect.VideoStreams)
+	}
+	if expect.AudioStreams > 0 && report.AudioStreams != expect.AudioStreams {
+		report.addProblem("音频流数量 %d，预期 %d", report.AudioStreams, expect.AudioStreams)
+	}
+	if expect.Width > 0 && expect.Height > 0 && (report.Width != expect.Width || report.Height != expect.Height) {
+		report.addProblem("分辨率 %dx%d，预期 %dx%d", report.Width, report.Height, expect.Width, expect.Height)
+	}
+	if expect.FPS > 0 && math.Abs(report.FPS-expect.FPS) > 0.5 {
+		report.addProblem("帧率 %.2f，预期 %.2f", report.FPS, expect.FPS)
+	}
+
+	// 首尾帧解码检查
+	if report.VideoStreams > 0 && !expect.SkipFrameCheck {
+		ffmpegPath, err := m.GetFFmpegPath()
+		if err != nil {
+			return nil, err
+		}
+		report.FirstFrameOK = decodeFrame(ffmpegPath, path, false)
+		report.LastFrameOK = decodeFrame(ffmpegPath, path, true)
+		if !report.FirstFrameOK || !report.LastFrameOK {
+			return report.fail(ErrOutputUndecodable, "首帧可解码: %v, 尾帧可解码: %v", report.FirstFrameOK, report.LastFrameOK)
+		}
+	}
+
+	if len(report.Problems) > 0 {
+		return report, fmt.Errorf("%w: %s", ErrOutputMismatch, strings.Join(report.Problems, "; "))
+	}
+
+	report.OK = true
+	return report, nil
+}
+
+// fail 记录问题并返回带类型的错误
+func (r *ProbeReport) fail(kind error, format string, args ...interface{}) (*ProbeReport, error) {
+	detail := fmt.Sprintf(format, args...)
+	r.Problems = append(r.Problems, fmt.Sprintf("%s: %s", kind.Error(), detail))
+	r.OK = false
+	return r, fmt.Errorf("%w: %s", kind, detail)
+}
+
+// addProblem 记录非致命问题
+func (r *ProbeReport) addProblem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// decodeFrame 尝试解码第一帧或最后一秒内的帧
+func decodeFrame(ffmpegPath, path string, last bool) bool {
+	args := []string{"-v", "error", "-xerror"}
+	if last {
+		args = append(args, "-sseof", "-1")
+	}
+	args = append(args, "-i", path, "-map", "0:v:0", "-frames:v", "1", "-f", "null", "-")
+
+	// -v error 时解码出错会输出到 stderr，即使退出码为 0 也视为失败
+	process := NewProcess(ffmpegPath, args, ProcessOptions{Name: "FFmpeg 解码检查", Quiet: true})
+	err := process.Run(context.Background())
+	return err == nil && process.Stderr() == ""
+}
+
+// parseFloat 解析浮点数（失败返回 0）
+func parseFloat(value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// parseFrameRate 解析 "30000/1001" 形式的帧率
+func parseFrameRate(value string) float64 {
+	num, den, ok := strings.Cut(value, "/")
+	if !ok {
+		return parseFloat(value)
+	}
+	n, d := parseFloat(num), parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
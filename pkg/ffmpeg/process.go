@@ -0,0 +1,693 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrProcessRunning 进程已在运行
+var ErrProcessRunning = errors.New("FFmpeg 进程已在运行")
+
+// defaultStderrLines stderr 环形缓冲默认保留的行数
+const defaultStderrLines = 200
+
+// defaultStopTimeout 停止时每个阶段（q、信号）的默认等待时间
+const defaultStopTimeout = 5 * time.Second
+
+// ProcessState 进程生命周期状态
+type ProcessState string
+
+const (
+	StateIdle     ProcessState = "idle"     // 未启动
+	StateRunning  ProcessState = "running"  // 运行中
+	StateStopping ProcessState = "stopping" // 正在停止
+	StateExited   ProcessState = "exited"   // 正常退出
+	StateFailed   ProcessState = "failed"   // 异常退出
+	StateKilled   ProcessState = "killed"   // 被强制终止
+)
+
+// ProcessEvent 进程生命周期事件
+type ProcessEvent struct {
+	Name  string       `json:"name"`
+	State ProcessState `json:"state"`
+	PID   int          `json:"pid"`
+	Error string       `json:"error,omitempty"`
+	Time  time.Time    `json:"time"`
+}
+
+// Progress FFmpeg 进度（来自 -progress 输出或 stderr 状态行）
+type Progress struct {
+	Frame     int           `json:"frame"`
+	FPS       float64       `json:"fps"`
+	OutTime   time.Duration `json:"outTime"`   // 已输出的媒体时长
+	TotalSize int64         `json:"totalSize"` // 已输出的字节数
+	Speed     float64       `json:"speed"`     // 相对实时的倍速
+	Bitrate   float64       `json:"bitrate"`   // 输出码率（kbit/s）
+	Dropped   int           `json:"dropped"`   // 丢弃的帧数
+	Duplicate int           `json:"duplicate"` // 重复的帧数
+	Done      bool          `json:"done"`      // -progress 报告 progress=end
+}
+
+// ProcessOptions 进程选项
+type ProcessOptions struct {
+	Name        string             // 日志和事件中的进程名称（默认 "FFmpeg"）
+	PipeStdin   bool               // 调用方通过 Write 写入数据；停止时关闭 stdin 而不是发送 q
+	Stdout      io.Writer          // 接收 stdout 原始数据（如 ffprobe 的 JSON），同时仍会解析 -progress 输出
+	RawStdout   bool               // stdout 是二进制数据（如 PCM），只交给 Stdout，不解析 -progress 输出
+	Log         io.Writer          // 接收 stderr 的每一行（不含状态行）
+	StderrLines int                // stderr 环形缓冲保留的行数（默认 200）
+	StopTimeout time.Duration      // 停止时每个阶段的等待时间（默认 5 秒）
+	OnProgress  func(Progress)     // 进度回调
+	OnEvent     func(ProcessEvent) // 生命周期事件回调
+	Quiet       bool               // 不打印生命周期日志（用于探测等短命令）
+}
+
+// Process 受监管的 FFmpeg 进程
+// 负责启动、优雅停止（q -> 中断信号 -> 强制终止）、线程安全的 stderr 捕获、进度解析和失败分类。
+// 进程只由内部 goroutine 调用一次 cmd.Wait，其他调用方通过 Wait/Done 等待
+type Process struct {
+	path string
+	args []string
+	opts ProcessOptions
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	state    ProcessState
+	progress Progress
+	piped    bool // 收到过 -progress 输出，此后 stderr 状态行不再更新进度
+	stopping bool // 调用过 Stop，q/信号导致的非零退出码不算失败
+	killed   bool // 调用过 Kill
+	err      error
+	ctx      context.Context
+
+	startedAt  time.Time // 进程启动时刻
+	mediaStart time.Time // 根据首个输出时间戳推算的媒体起点
+
+	stderr     *lineRing
+	progressMu sync.Mutex // 串行化 OnProgress 回调（stdout 和 stderr 在不同 goroutine 中解析）
+	done       chan struct{}
+}
+
+// NewProcess 创建进程（不启动）
+func NewProcess(path string, args []string, opts ProcessOptions) *Process {
+	if opts.Name == "" {
+		opts.Name = "FFmpeg"
+	}
+	if opts.StderrLines <= 0 {
+		opts.StderrLines = defaultStderrLines
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = defaultStopTimeout
+	}
+
+	return &Process{
+		path:   path,
+		args:   args,
+		opts:   opts,
+		state:  StateIdle,
+		stderr: newLineRing(opts.StderrLines),
+		done:   make(chan struct{}),
+	}
+}
+
+// NewProcess 使用当前 FFmpeg 创建进程，未设置 OnEvent 时使用管理器的事件处理器
+func (m *FFmpegManager) NewProcess(args []string, opts ProcessOptions) (*Process, error) {
+	path, err := m.GetFFmpegPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.OnEvent == nil {
+		m.mu.Lock()
+		opts.OnEvent = m.processHandler
+		m.mu.Unlock()
+	}
+	return NewProcess(path, args, opts), nil
+}
+
+// SetProcessEventHandler 设置通过管理器创建的进程的生命周期事件处理器
+func (m *FFmpegManager) SetProcessEventHandler(handler func(ProcessEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processHandler = handler
+}
+
+// Start 启动进程
+// ctx 取消时按 Stop 的流程优雅停止，Wait 返回 ctx.Err()
+func (p *Process) Start(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p.mu.Lock()
+	if p.state != StateIdle {
+		p.mu.Unlock()
+		return ErrProcessRunning
+	}
+
+	p.cmd = exec.Command(p.path, p.args...)
+	p.ctx = ctx
+
+	// stdin 始终使用管道：用于发送 q 命令，或由调用方写入数据
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("获取 stdin 管道失败: %w", err)
+	}
+	p.stdin = stdin
+
+	// stdout/stderr 由 exec 的拷贝 goroutine 写入，cmd.Wait 会等待拷贝完成
+	progressWriter := newLineWriter(p.handleProgressLine)
+	switch {
+	case p.opts.Stdout != nil && p.opts.RawStdout:
+		p.cmd.Stdout = p.opts.Stdout
+	case p.opts.Stdout != nil:
+		p.cmd.Stdout = io.MultiWriter(p.opts.Stdout, progressWriter)
+	default:
+		p.cmd.Stdout = progressWriter
+	}
+	stderrWriter := newLineWriter(p.handleStderrLine)
+	p.cmd.Stderr = stderrWriter
+
+	if err := p.cmd.Start(); err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("启动 %s 进程失败: %w", p.opts.Name, err)
+	}
+	p.state = StateRunning
+	p.startedAt = time.Now()
+	pid := p.cmd.Process.Pid
+	p.mu.Unlock()
+
+	if !p.opts.Quiet {
+		fmt.Printf("✓ %s 进程已启动 (PID %d): %s %s\n", p.opts.Name, pid, p.path, strings.Join(p.args, " "))
+	}
+	p.emit(StateRunning, nil)
+
+	go p.wait(progressWriter, stderrWriter)
+
+	// 上下文取消时停止进程
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Stop()
+		case <-p.done:
+		}
+	}()
+
+	return nil
+}
+
+// Run 启动进程并等待结束
+func (p *Process) Run(ctx context.Context) error {
+	if err := p.Start(ctx); err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+// wait 等待进程退出并分类结果（唯一调用 cmd.Wait 的地方）
+func (p *Process) wait(stdout, stderr *lineWriter) {
+	waitErr := p.cmd.Wait()
+	stdout.Flush()
+	stderr.Flush()
+
+	p.mu.Lock()
+	switch {
+	case p.stopping && p.ctx.Err() != nil:
+		// 上下文取消导致的停止
+		p.err = p.ctx.Err()
+		p.state = StateExited
+		if p.killed {
+			p.state = StateKilled
+		}
+	case p.killed:
+		p.err = newExitError(p.opts.Name, waitErr, p.stderr.String(), true)
+		p.state = StateKilled
+	case waitErr != nil && !p.stopping:
+		p.err = newExitError(p.opts.Name, waitErr, p.stderr.String(), false)
+		p.state = StateFailed
+	default:
+		// 正常退出，或者 Stop 发送 q/信号后退出（FFmpeg 收到信号时退出码非零，但输出文件已完整写入）
+		p.state = StateExited
+	}
+	state, err := p.state, p.err
+	p.stdin = nil
+	p.mu.Unlock()
+
+	if !p.opts.Quiet {
+		if err != nil {
+			fmt.Printf("✗ %s 进程已结束: %v\n", p.opts.Name, err)
+		} else {
+			fmt.Printf("%s 进程已结束\n", p.opts.Name)
+		}
+	}
+	// 先发送事件再关闭 done，Wait 返回时调用方已经收到退出事件
+	p.emit(state, err)
+	close(p.done)
+}
+
+// Wait 等待进程结束，返回分类后的错误（*ExitError、ctx.Err() 或 nil）
+func (p *Process) Wait() error {
+	p.mu.Lock()
+	if p.state == StateIdle {
+		p.mu.Unlock()
+		return errors.New("进程未启动")
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	return p.Err()
+}
+
+// Done 返回进程结束时关闭的通道
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err 返回进程结束的结果（运行中为 nil）
+func (p *Process) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Stop 优雅停止进程
+// 1. 发送 q（PipeStdin 时关闭 stdin，让 FFmpeg 读到 EOF 后收尾）
+// 2. 超时后发送中断信号（Windows 不支持，直接进入下一步）
+// 3. 仍未退出则强制终止
+// 只有强制终止失败时返回错误；进程的退出结果通过 Wait/Err 获取
+func (p *Process) Stop() error {
+	p.mu.Lock()
+	if p.state != StateRunning {
+		p.mu.Unlock()
+		return nil
+	}
+	p.state = StateStopping
+	p.stopping = true
+	stdin := p.stdin
+	p.stdin = nil
+	p.mu.Unlock()
+
+	p.emit(StateStopping, nil)
+
+	// 方法 1: 发送 'q' 命令到 FFmpeg 的 stdin（最优雅的方式）
+	if stdin != nil {
+		if !p.opts.PipeStdin {
+			stdin.Write([]byte("q\n"))
+		}
+		stdin.Close()
+	}
+	if p.waitTimeout(p.opts.StopTimeout) {
+		return nil
+	}
+
+	// 方法 2: 发送中断信号，FFmpeg 会写完文件尾后退出
+	fmt.Printf("%s 进程未响应，尝试发送中断信号...\n", p.opts.Name)
+	if err := p.cmd.Process.Signal(os.Interrupt); err == nil {
+		if p.waitTimeout(p.opts.StopTimeout) {
+			return nil
+		}
+	}
+
+	// 方法 3: 强制终止
+	fmt.Printf("%s 进程仍未响应，强制终止...\n", p.opts.Name)
+	return p.Kill()
+}
+
+// Kill 立即强制终止进程
+func (p *Process) Kill() error {
+	p.mu.Lock()
+	if p.cmd == nil || p.cmd.Process == nil || p.state == StateIdle {
+		p.mu.Unlock()
+		return nil
+	}
+	p.killed = true
+	p.mu.Unlock()
+
+	if err := p.cmd.Process.Kill(); err != nil {
+		select {
+		case <-p.done:
+			return nil // 已经退出
+		default:
+			return fmt.Errorf("强制终止 %s 进程失败: %w", p.opts.Name, err)
+		}
+	}
+	<-p.done
+	return nil
+}
+
+// waitTimeout 等待进程退出，超时返回 false
+func (p *Process) waitTimeout(timeout time.Duration) bool {
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Write 写入 stdin（PipeStdin 时使用），stdin 已关闭时返回错误
+func (p *Process) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.mu.Unlock()
+
+	if stdin == nil {
+		return 0, fmt.Errorf("%s 进程的 stdin 已关闭", p.opts.Name)
+	}
+	return stdin.Write(data)
+}
+
+// CloseStdin 关闭 stdin，让读取 stdin 的 FFmpeg 收尾退出
+func (p *Process) CloseStdin() error {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.stdin = nil
+	p.mu.Unlock()
+
+	if stdin == nil {
+		return nil
+	}
+	return stdin.Close()
+}
+
+// State 返回进程状态
+func (p *Process) State() ProcessState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Running 检查进程是否在运行（包括正在停止）
+func (p *Process) Running() bool {
+	state := p.State()
+	return state == StateRunning || state == StateStopping
+}
+
+// PID 返回进程 ID（未启动时为 0）
+func (p *Process) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil && p.cmd.Process != nil {
+		return p.cmd.Process.Pid
+	}
+	return 0
+}
+
+// Stderr 返回 stderr 环形缓冲中的内容
+func (p *Process) Stderr() string {
+	return p.stderr.String()
+}
+
+// Progress 返回最近一次进度
+func (p *Process) Progress() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress
+}
+
+// StartedAt 返回进程启动时刻（未启动时为零值）
+func (p *Process) StartedAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.startedAt
+}
+
+// MediaStart 返回输出中时间戳 0 对应的时刻
+// 根据首个带输出时间的进度推算（收到进度的时刻减去输出时间），
+// 可以排除设备打开、编码器初始化等启动延迟。尚未收到进度时退回到进程启动时刻，第二个返回值为 false
+func (p *Process) MediaStart() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.mediaStart.IsZero() {
+		return p.mediaStart, true
+	}
+	return p.startedAt, false
+}
+
+// noteMediaStartLocked 在首次收到输出时间时记录媒体起点（需持有 p.mu）
+func (p *Process) noteMediaStartLocked() {
+	if !p.mediaStart.IsZero() || p.progress.OutTime <= 0 {
+		return
+	}
+	p.mediaStart = time.Now().Add(-p.progress.OutTime)
+	// 推算值不会早于进程启动
+	if p.mediaStart.Before(p.startedAt) {
+		p.mediaStart = p.startedAt
+	}
+}
+
+// emit 发送生命周期事件
+func (p *Process) emit(state ProcessState, err error) {
+	if p.opts.OnEvent == nil {
+		return
+	}
+	event := ProcessEvent{Name: p.opts.Name, State: state, PID: p.PID(), Time: time.Now()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	p.opts.OnEvent(event)
+}
+
+// handleProgressLine 解析 -progress 输出（每行 key=value，每组以 progress=continue/end 结束）
+func (p *Process) handleProgressLine(line string) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return
+	}
+	value = strings.TrimSpace(value)
+
+	p.mu.Lock()
+	p.piped = true
+	if key != "progress" {
+		applyProgressField(&p.progress, key, value)
+		p.mu.Unlock()
+		return
+	}
+	p.progress.Done = value == "end"
+	p.noteMediaStartLocked()
+	progress := p.progress
+	p.mu.Unlock()
+
+	p.reportProgress(progress)
+}
+
+// reportProgress 调用进度回调
+func (p *Process) reportProgress(progress Progress) {
+	if p.opts.OnProgress == nil {
+		return
+	}
+	p.progressMu.Lock()
+	defer p.progressMu.Unlock()
+	p.opts.OnProgress(progress)
+}
+
+// statusFieldPattern 匹配 stderr 状态行中的 "key= value"
+var statusFieldPattern = regexp.MustCompile(`(\w+)=\s*(\S+)`)
+
+// handleStderrLine 处理 stderr 的一行：状态行解析为进度，其余写入环形缓冲和日志
+// stdout 和 stderr 在不同 goroutine 中解析，顺序无法保证；有 -progress 输出时以它为准，
+// 否则晚到的状态行会让 progress=end 之后的帧数和时间倒退
+func (p *Process) handleStderrLine(line string) {
+	// 状态行: frame=  120 fps= 60 q=23.0 size=    512kB time=00:00:02.00 bitrate=... speed=1.00x
+	if strings.HasPrefix(line, "frame=") || strings.HasPrefix(line, "size=") {
+		p.mu.Lock()
+		if p.piped {
+			p.mu.Unlock()
+			return
+		}
+		for _, match := range statusFieldPattern.FindAllStringSubmatch(line, -1) {
+			key := match[1]
+			switch key {
+			case "size":
+				key = "total_size"
+			case "time":
+				key = "out_time"
+			case "drop":
+				key = "drop_frames"
+			case "dup":
+				key = "dup_frames"
+			}
+			applyProgressField(&p.progress, key, match[2])
+		}
+		p.noteMediaStartLocked()
+		progress := p.progress
+		p.mu.Unlock()
+
+		p.reportProgress(progress)
+		return
+	}
+
+	p.stderr.Add(line)
+	if p.opts.Log != nil {
+		fmt.Fprintf(p.opts.Log, "%s\n", line)
+	}
+}
+
+// applyProgressField 把一个进度字段写入 progress
+func applyProgressField(progress *Progress, key, value string) {
+	switch key {
+	case "frame":
+		if n, err := strconv.Atoi(value); err == nil {
+			progress.Frame = n
+		}
+	case "fps":
+		progress.FPS = parseFloat(value)
+	case "out_time":
+		if d, ok := parseClock(value); ok {
+			progress.OutTime = d
+		}
+	case "out_time_us":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			progress.OutTime = time.Duration(n) * time.Microsecond
+		}
+	case "total_size":
+		progress.TotalSize = parseSize(value)
+	case "speed":
+		progress.Speed = parseFloat(strings.TrimSuffix(value, "x"))
+	case "bitrate":
+		progress.Bitrate = parseFloat(strings.TrimSuffix(value, "kbits/s"))
+	case "drop_frames":
+		if n, err := strconv.Atoi(value); err == nil {
+			progress.Dropped = n
+		}
+	case "dup_frames":
+		if n, err := strconv.Atoi(value); err == nil {
+			progress.Duplicate = n
+		}
+	}
+}
+
+// parseClock 解析 "00:01:02.50" 形式的时间
+func parseClock(value string) (time.Duration, bool) {
+	parts := strings.Split(strings.TrimPrefix(value, "-"), ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	total := float64(hours*3600+minutes*60) + seconds
+	return time.Duration(total * float64(time.Second)), true
+}
+
+// parseSize 解析字节数（-progress 为纯数字，状态行为 "512kB"、"1MiB" 等）
+func parseSize(value string) int64 {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"KiB", 1024}, {"MiB", 1024 * 1024}, {"GiB", 1024 * 1024 * 1024},
+		{"kB", 1024}, {"MB", 1024 * 1024}, {"GB", 1024 * 1024 * 1024},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			return int64(parseFloat(strings.TrimSuffix(value, unit.suffix)) * float64(unit.scale))
+		}
+	}
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// RunOutput 运行短命令并返回 stdout（如 -version、-encoders、ffprobe）
+// 失败时返回分类后的 *ExitError
+func RunOutput(ctx context.Context, path string, args []string, name string) ([]byte, error) {
+	var stdout bytes.Buffer
+	process := NewProcess(path, args, ProcessOptions{Name: name, Stdout: &stdout, Quiet: true})
+	if err := process.Run(ctx); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// lineRing 线程安全的环形行缓冲，只保留最后 max 行
+type lineRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// newLineRing 创建环形行缓冲
+func newLineRing(max int) *lineRing {
+	return &lineRing{lines: make([]string, max)}
+}
+
+// Add 添加一行，缓冲满时覆盖最旧的行
+func (r *lineRing) Add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// String 按时间顺序返回所有行
+func (r *lineRing) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []string
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	return strings.Join(lines, "\n")
+}
+
+// lineWriter 把写入的数据按 \n 或 \r 切分成行（FFmpeg 状态行以 \r 结尾）
+type lineWriter struct {
+	buf    []byte
+	handle func(line string)
+}
+
+// newLineWriter 创建按行回调的 io.Writer
+func newLineWriter(handle func(line string)) *lineWriter {
+	return &lineWriter{handle: handle}
+}
+
+// Write 实现 io.Writer
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush 处理最后一行不完整的数据
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+// emit 回调非空行
+func (w *lineWriter) emit(line []byte) {
+	if text := strings.TrimSpace(string(line)); text != "" {
+		w.handle(text)
+	}
+}
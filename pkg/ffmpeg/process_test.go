@@ -0,0 +1,359 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// helperModeEnv 测试二进制作为假 FFmpeg 运行时的模式
+const helperModeEnv = "FFMPEG_TEST_HELPER_MODE"
+
+// TestHelperProcess 不是真正的测试：设置 helperModeEnv 时作为假的 FFmpeg 运行
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperModeEnv)
+	if mode == "" {
+		return
+	}
+
+	switch mode {
+	case "progress":
+		// -progress 输出在 stdout，状态行以 \r 结尾写在 stderr（最后一行状态晚于 progress=end）
+		fmt.Print("frame=30\nfps=30.0\nout_time_us=1000000\ntotal_size=1024\nspeed=1.0x\nprogress=continue\n")
+		fmt.Fprint(os.Stderr, "Input #0, lavfi\r\nframe=   60 fps= 30 q=23.0 size=     512kB time=00:00:02.00 bitrate=2097.2kbits/s dup=1 drop=2 speed=1.00x\r")
+		fmt.Print("frame=90\nout_time=00:00:03.000000\nprogress=end\n")
+		os.Stdout.Sync()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(os.Stderr, "frame=   60 fps= 30 q=-1.0 Lsize=     512kB time=00:00:02.00 bitrate=2097.2kbits/s speed=1.00x\r\n")
+		fmt.Fprint(os.Stderr, "video:512kB audio:0kB")
+		os.Exit(0)
+	case "status":
+		// 没有 -progress 输出时只能从 stderr 状态行获取进度
+		fmt.Fprint(os.Stderr, "Input #0, lavfi\r\nframe=   60 fps= 30 q=23.0 size=     512kB time=00:00:02.00 bitrate=2097.2kbits/s dup=1 drop=2 speed=1.00x\r")
+		fmt.Fprint(os.Stderr, "video:512kB audio:0kB")
+		os.Exit(0)
+	case "fail":
+		fmt.Fprintln(os.Stderr, "Output #0, mp4\n[mp4 @ 0x1] Error writing trailer: No space left on device")
+		os.Exit(1)
+	case "quit":
+		// 像 FFmpeg 一样读到 q 后退出
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if scanner.Text() == "q" {
+				os.Exit(0)
+			}
+		}
+		os.Exit(0)
+	case "interrupt":
+		// 忽略 q 和 stdin 关闭，收到中断信号后退出（FFmpeg 此时退出码为 255）
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		<-interrupts
+		os.Exit(255)
+	case "hang":
+		signal.Ignore(os.Interrupt)
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
+
+// newHelperProcess 创建以测试二进制作为 FFmpeg 的进程
+func newHelperProcess(t *testing.T, mode string, opts ProcessOptions) *Process {
+	t.Helper()
+	t.Setenv(helperModeEnv, mode)
+	opts.Quiet = true
+	return NewProcess(os.Args[0], []string{"-test.run=^TestHelperProcess$"}, opts)
+}
+
+// stateRecorder 记录生命周期事件
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []ProcessState
+}
+
+func (r *stateRecorder) add(event ProcessEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, event.State)
+}
+
+func (r *stateRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprint(r.states)
+}
+
+// progressReports 收集进度回调
+type progressReports struct {
+	mu      sync.Mutex
+	reports []Progress
+}
+
+func (r *progressReports) add(progress Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, progress)
+}
+
+func (r *progressReports) list() []Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Progress(nil), r.reports...)
+}
+
+func TestProcessProgress(t *testing.T) {
+	reports := &progressReports{}
+	var log strings.Builder
+	process := newHelperProcess(t, "progress", ProcessOptions{Log: &log, OnProgress: reports.add})
+	if err := process.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// 两组 -progress 各回调一次；stderr 状态行与 stdout 的先后不确定，不计入
+	list := reports.list()
+	if len(list) < 2 {
+		t.Fatalf("进度回调 %d 次, want >= 2: %+v", len(list), list)
+	}
+	// 最后一组 -progress 以 progress=end 结束，之后到达的状态行不会让进度倒退
+	want := Progress{Frame: 90, OutTime: 3 * time.Second, Done: true}
+	for _, progress := range []Progress{process.Progress(), list[len(list)-1]} {
+		if progress.Frame != want.Frame || progress.OutTime != want.OutTime || !progress.Done {
+			t.Errorf("最终进度 = %+v", progress)
+		}
+	}
+	// 状态行不写入 stderr 缓冲和日志
+	if stderr := process.Stderr(); stderr != "Input #0, lavfi\nvideo:512kB audio:0kB" {
+		t.Errorf("Stderr = %q", stderr)
+	}
+	if log.String() != "Input #0, lavfi\nvideo:512kB audio:0kB\n" {
+		t.Errorf("Log = %q", log.String())
+	}
+}
+
+func TestProcessStatusLineProgress(t *testing.T) {
+	reports := &progressReports{}
+	var log strings.Builder
+	process := newHelperProcess(t, "status", ProcessOptions{Log: &log, OnProgress: reports.add})
+	if err := process.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// 没有 -progress 输出时 stderr 状态行解析为进度
+	list := reports.list()
+	if len(list) != 1 {
+		t.Fatalf("进度回调 %d 次, want 1: %+v", len(list), list)
+	}
+	report := list[0]
+	if report.Frame != 60 || report.OutTime != 2*time.Second || report.TotalSize != 512*1024 ||
+		report.Dropped != 2 || report.Duplicate != 1 || report.Bitrate != 2097.2 || report.Done {
+		t.Errorf("状态行进度 = %+v", report)
+	}
+	if log.String() != "Input #0, lavfi\nvideo:512kB audio:0kB\n" {
+		t.Errorf("Log = %q", log.String())
+	}
+}
+
+func TestProcessFailureClassified(t *testing.T) {
+	events := &stateRecorder{}
+	process := newHelperProcess(t, "fail", ProcessOptions{Name: "导出", OnEvent: events.add})
+	err := process.Run(context.Background())
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("Run = %v, want ErrDiskFull", err)
+	}
+	if exitErr.ExitCode != 1 || exitErr.Name != "导出" {
+		t.Errorf("ExitError = %+v", exitErr)
+	}
+	if process.State() != StateFailed || events.String() != "[running failed]" {
+		t.Errorf("状态 = %s, 事件 = %s", process.State(), events)
+	}
+	if err := process.Start(context.Background()); !errors.Is(err, ErrProcessRunning) {
+		t.Errorf("重复启动 = %v, want ErrProcessRunning", err)
+	}
+}
+
+func TestProcessStopEscalation(t *testing.T) {
+	tests := []struct {
+		mode      string
+		state     ProcessState
+		events    string
+		killed    bool
+		minStages int // 至少经过的超时阶段数
+	}{
+		{"quit", StateExited, "[running stopping exited]", false, 0},
+		{"interrupt", StateExited, "[running stopping exited]", false, 1},
+		{"hang", StateKilled, "[running stopping killed]", true, 2},
+	}
+
+	const stopTimeout = 300 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tt.mode == "interrupt" {
+				t.Skip("Windows 不支持发送中断信号")
+			}
+			events := &stateRecorder{}
+			process := newHelperProcess(t, tt.mode, ProcessOptions{StopTimeout: stopTimeout, OnEvent: events.add})
+			if err := process.Start(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(200 * time.Millisecond) // 等假 FFmpeg 注册信号处理
+
+			started := time.Now()
+			if err := process.Stop(); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			elapsed := time.Since(started)
+
+			// q、中断信号、强制终止依次升级，前一步成功时不进入下一步
+			if elapsed < time.Duration(tt.minStages)*stopTimeout || elapsed > time.Duration(tt.minStages+1)*stopTimeout+2*time.Second {
+				t.Errorf("停止耗时 %v, 应经过 %d 个超时阶段", elapsed, tt.minStages)
+			}
+			if state := process.State(); state != tt.state {
+				t.Errorf("状态 = %s, want %s", state, tt.state)
+			}
+			if events.String() != tt.events {
+				t.Errorf("事件 = %s, want %s", events, tt.events)
+			}
+			// Stop 导致的非零退出码不算失败；强制终止归类为 ErrProcessKilled
+			err := process.Wait()
+			if tt.killed != errors.Is(err, ErrProcessKilled) || (!tt.killed && err != nil) {
+				t.Errorf("Wait = %v", err)
+			}
+			if err := process.Stop(); err != nil {
+				t.Errorf("重复 Stop = %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	process := newHelperProcess(t, "quit", ProcessOptions{})
+	if err := process.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := process.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+func TestLineRing(t *testing.T) {
+	ring := newLineRing(3)
+	if ring.String() != "" {
+		t.Errorf("空缓冲 = %q", ring.String())
+	}
+	for i, want := range []string{"1", "1\n2", "1\n2\n3", "2\n3\n4", "3\n4\n5", "4\n5\n6", "5\n6\n7"} {
+		ring.Add(fmt.Sprint(i + 1))
+		if got := ring.String(); got != want {
+			t.Errorf("添加 %d 行后 = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := newLineWriter(func(line string) { lines = append(lines, line) })
+
+	// 行可能跨多次写入；\r、\n、\r\n 都是行尾；空行和首尾空白被去掉
+	for _, chunk := range []string{"frame=  1", "0 fps=1\rframe=  2", "0\r\nInput #0\n\n  Stream #0:0  \nlast"} {
+		w.Write([]byte(chunk))
+	}
+	want := []string{"frame=  10 fps=1", "frame=  20", "Input #0", "Stream #0:0"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("行 = %q, want %q", lines, want)
+	}
+	w.Flush()
+	if last := lines[len(lines)-1]; last != "last" || len(lines) != len(want)+1 {
+		t.Errorf("Flush 后 = %q", lines)
+	}
+}
+
+func TestProgressParsing(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"00:00:02.50", 2500 * time.Millisecond, true},
+		{"01:02:03.000000", time.Hour + 2*time.Minute + 3*time.Second, true},
+		{"-00:00:00.04", 40 * time.Millisecond, true}, // 开头的负号被忽略
+		{"N/A", 0, false},
+		{"02.50", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseClock(tt.value); got != tt.want || ok != tt.ok {
+			t.Errorf("parseClock(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	for value, want := range map[string]int64{
+		"1048576": 1048576, "512kB": 512 * 1024, "512KiB": 512 * 1024,
+		"1.5MiB": 1572864, "2GB": 2 << 30, "N/A": 0,
+	} {
+		if got := parseSize(value); got != want {
+			t.Errorf("parseSize(%q) = %d, want %d", value, got, want)
+		}
+	}
+
+	var progress Progress
+	for key, value := range map[string]string{
+		"frame": "120", "fps": "59.94", "out_time_us": "2000000", "total_size": "4096",
+		"speed": "1.02x", "bitrate": "1234.5kbits/s", "drop_frames": "3", "dup_frames": "4", "unknown": "x",
+	} {
+		applyProgressField(&progress, key, value)
+	}
+	want := Progress{Frame: 120, FPS: 59.94, OutTime: 2 * time.Second, TotalSize: 4096, Speed: 1.02, Bitrate: 1234.5, Dropped: 3, Duplicate: 4}
+	if progress != want {
+		t.Errorf("进度 = %+v, want %+v", progress, want)
+	}
+	// 无法解析的值不覆盖已有值
+	applyProgressField(&progress, "out_time", "N/A")
+	applyProgressField(&progress, "frame", "N/A")
+	if progress.OutTime != 2*time.Second || progress.Frame != 120 {
+		t.Errorf("无效值覆盖了进度: %+v", progress)
+	}
+}
+
+func TestMediaStartEstimate(t *testing.T) {
+	process := NewProcess("ffmpeg", nil, ProcessOptions{Quiet: true})
+	startedAt := time.Now().Add(-3 * time.Second)
+	process.startedAt = startedAt
+
+	// 尚未收到输出时间时退回到进程启动时刻
+	if start, ok := process.MediaStart(); ok || !start.Equal(startedAt) {
+		t.Errorf("MediaStart = %v, %v, want 启动时刻", start, ok)
+	}
+
+	// 启动 3 秒后才输出 1 秒的媒体：媒体起点约在 1 秒前（扣除启动延迟）
+	process.handleProgressLine("out_time_us=1000000")
+	process.handleProgressLine("progress=continue")
+	start, ok := process.MediaStart()
+	if expected := time.Now().Add(-time.Second); !ok || start.Sub(expected).Abs() > 100*time.Millisecond {
+		t.Errorf("MediaStart = %v, %v, want 约 %v", start, ok, expected)
+	}
+
+	// 只在首次记录，之后的进度不改变起点
+	process.handleStderrLine("frame=  10 time=00:00:09.00 speed=1x")
+	if again, _ := process.MediaStart(); !again.Equal(start) {
+		t.Errorf("MediaStart 被后续进度改变: %v -> %v", start, again)
+	}
+
+	// 推算值不早于进程启动
+	late := NewProcess("ffmpeg", nil, ProcessOptions{Quiet: true})
+	late.startedAt = time.Now().Add(-time.Second)
+	late.handleStderrLine("frame=  10 time=00:00:05.00 speed=1x")
+	if start, _ := late.MediaStart(); !start.Equal(late.startedAt) {
+		t.Errorf("MediaStart = %v, 不应早于启动时刻 %v", start, late.startedAt)
+	}
+}
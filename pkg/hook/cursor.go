@@ -1,14 +1,14 @@
 package hook
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
+	"SmoothScreen/pkg/events"
+
 	hook "github.com/robotn/gohook"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // MouseEvent 表示一个鼠标事件
@@ -22,80 +22,80 @@ type MouseEvent struct {
 	Button    string `json:"button"`   // 按钮标识: left, right, middle
 }
 
+// 事件总线上的鼠标主题
+var (
+	// MouseMoveTopic 鼠标移动（不录制时也发布，用于实时显示；订阅者应限速合并）
+	MouseMoveTopic = events.NewTopic[MouseEvent]("mouse-move")
+	// MouseButtonTopic 录制中的按键、长按和滚动（不应丢弃）
+	MouseButtonTopic = events.NewTopic[MouseEvent]("mouse-button")
+)
+
 // MouseHook 管理鼠标钩子
 type MouseHook struct {
-	ctx           context.Context
-	eventChan     chan hook.Event
+	input         *InputService // 共享的输入捕获服务
+	clock         *SessionClock // 与键盘录制共用的会话时钟
+	bus           *events.Bus   // 实时事件发布到总线（为 nil 时不发布）
+	unlisten      func()
 	mouseData     []MouseEvent
 	mouseDataMu   sync.Mutex
 	isRecording   bool
+	isPaused      bool
 	lastX         int16
 	lastY         int16
 	mouseDownTime map[string]time.Time // 记录鼠标按下时间
 	mouseDownMu   sync.Mutex
-	ticker        *time.Ticker
-	immediateChan chan MouseEvent // 用于立即发送关键时刻事件
-	startTime     time.Time       // 录制开始时间
+	retention     time.Duration // 只保留最近这段时间的事件（0 表示全部保留）
+	maxEvents     int           // 最多保留的事件数（0 表示不限制）
 }
 
-// NewMouseHook 创建新的鼠标钩子
-func NewMouseHook(ctx context.Context) *MouseHook {
+// NewMouseHook 创建新的鼠标钩子，实时位置和点击发布到 bus
+func NewMouseHook(input *InputService, bus *events.Bus) *MouseHook {
 	return &MouseHook{
-		ctx:           ctx,
-		eventChan:     hook.Start(),
+		input:         input,
+		clock:         input.Clock(),
+		bus:           bus,
 		mouseData:     make([]MouseEvent, 0),
 		mouseDownTime: make(map[string]time.Time),
-		ticker:        time.NewTicker(50 * time.Millisecond), // 20Hz = 50ms
-		immediateChan: make(chan MouseEvent, 100),            // 用于立即发送关键时刻事件
 	}
 }
 
 // Start 开始监听鼠标事件
 func (m *MouseHook) Start() {
+	if m.unlisten != nil {
+		return
+	}
 	fmt.Println("启动增强鼠标监听...")
-
-	go m.processEvents()
-	go m.emitImmediateEvents() // 处理立即发送的事件
-	go m.emitData()
+	m.unlisten = m.input.AddListener(m.handleEvent)
+	m.input.Acquire()
 }
 
-// Stop 停止监听
+// Stop 停止监听（其他使用者仍持有引用时全局钩子继续运行）
 func (m *MouseHook) Stop() {
-	if m.ticker != nil {
-		m.ticker.Stop()
-	}
-	hook.End()
-}
-
-// processEvents 处理原始鼠标事件
-func (m *MouseHook) processEvents() {
-	for {
-		select {
-		case ev := <-m.eventChan:
-			m.handleEvent(ev)
-
-		case <-m.ctx.Done():
-			return
-		}
+	if m.unlisten == nil {
+		return
 	}
+	m.unlisten()
+	m.unlisten = nil
+	m.input.Release()
 }
 
 // handleEvent 处理单个事件
-func (m *MouseHook) handleEvent(ev hook.Event) {
+func (m *MouseHook) handleEvent(ev InputEvent) {
 	switch ev.Kind {
-	case hook.MouseMove:
+	case hook.MouseMove, hook.MouseDrag:
+		// 按住按键移动时 libuiohook 只发送 MouseDrag，同样记录为移动
 		m.lastX = ev.X
 		m.lastY = ev.Y
-		if m.isRecording {
-			m.addMouseEvent(MouseEvent{
-				Timestamp: m.getRelativeTimestamp(),
-				X:         ev.X,
-				Y:         ev.Y,
-				EventType: "move",
-			})
+		event := MouseEvent{X: ev.X, Y: ev.Y, EventType: "move"}
+		if m.capturing() {
+			event.Timestamp = m.timestamp(ev.Time)
+			m.addMouseEvent(event)
 		}
+		events.Publish(m.bus, MouseMoveTopic, event)
 
-	case hook.MouseDown, hook.MouseUp:
+	// gohook 的常量名与 libuiohook 不对应：MouseHold 是按键松开（MOUSE_RELEASED），每次松开都会发送；
+	// MouseUp 是 MOUSE_CLICKED，只在按下和松开位置相同时跟在松开之后发送，拖动后没有，因此忽略
+	case hook.MouseDown, hook.MouseHold:
 		// 根据鼠标按键判断是左键、右键还是中键
 		button := "left"
 		if ev.Button == 2 {
@@ -107,36 +107,32 @@ func (m *MouseHook) handleEvent(ev hook.Event) {
 		if ev.Kind == hook.MouseDown {
 			// 记录按下时间
 			m.mouseDownMu.Lock()
-			m.mouseDownTime[button] = time.Now()
+			m.mouseDownTime[button] = ev.Time
 			m.mouseDownMu.Unlock()
 
-			if m.isRecording {
+			if m.capturing() {
 				event := MouseEvent{
-					Timestamp: m.getRelativeTimestamp(),
+					Timestamp: m.timestamp(ev.Time),
 					X:         ev.X,
 					Y:         ev.Y,
 					EventType: getMouseDownType(button),
 					Button:    button,
 				}
 				m.addMouseEvent(event)
-				// 立即发送给前端
-				select {
-				case m.immediateChan <- event:
-				default:
-				}
+				events.Publish(m.bus, MouseButtonTopic, event)
 			}
-		} else if ev.Kind == hook.MouseUp {
+		} else {
 			// 计算持续时间
 			m.mouseDownMu.Lock()
 			if downTime, exists := m.mouseDownTime[button]; exists {
-				duration := int(time.Since(downTime).Milliseconds())
+				duration := int(ev.Time.Sub(downTime).Milliseconds())
 				delete(m.mouseDownTime, button)
 
-				if m.isRecording {
+				if m.capturing() {
 					// 如果持续时间较长，记录为hold事件
 					if duration > 200 {
 						event := MouseEvent{
-							Timestamp: m.getRelativeTimestamp(),
+							Timestamp: m.timestamp(ev.Time),
 							X:         ev.X,
 							Y:         ev.Y,
 							EventType: "hold",
@@ -144,16 +140,12 @@ func (m *MouseHook) handleEvent(ev hook.Event) {
 							Button:    button,
 						}
 						m.addMouseEvent(event)
-						// 立即发送给前端
-						select {
-						case m.immediateChan <- event:
-						default:
-						}
+						events.Publish(m.bus, MouseButtonTopic, event)
 					}
 
 					// 记录点击事件
 					event := MouseEvent{
-						Timestamp: m.getRelativeTimestamp(),
+						Timestamp: m.timestamp(ev.Time),
 						X:         ev.X,
 						Y:         ev.Y,
 						EventType: getMouseUpType(button),
@@ -161,31 +153,23 @@ func (m *MouseHook) handleEvent(ev hook.Event) {
 						Button:    button,
 					}
 					m.addMouseEvent(event)
-					// 立即发送给前端
-					select {
-					case m.immediateChan <- event:
-					default:
-					}
+					events.Publish(m.bus, MouseButtonTopic, event)
 				}
 			}
 			m.mouseDownMu.Unlock()
 		}
 
 	case hook.MouseWheel:
-		if m.isRecording {
+		if m.capturing() {
 			event := MouseEvent{
-				Timestamp: m.getRelativeTimestamp(),
+				Timestamp: m.timestamp(ev.Time),
 				X:         m.lastX,
 				Y:         m.lastY,
 				EventType: "scroll",
 				Delta:     int(ev.Amount),
 			}
 			m.addMouseEvent(event)
-			// 立即发送给前端
-			select {
-			case m.immediateChan <- event:
-			default:
-			}
+			events.Publish(m.bus, MouseButtonTopic, event)
 		}
 	}
 }
@@ -195,46 +179,19 @@ func (m *MouseHook) addMouseEvent(event MouseEvent) {
 	m.mouseDataMu.Lock()
 	defer m.mouseDataMu.Unlock()
 	m.mouseData = append(m.mouseData, event)
+	m.mouseData = trimEvents(m.mouseData, m.retention, m.maxEvents, func(e MouseEvent) int64 { return e.Timestamp })
 }
 
-// emitImmediateEvents 立即发送关键时刻事件到前端
-func (m *MouseHook) emitImmediateEvents() {
-	for {
-		select {
-		case event := <-m.immediateChan:
-			// 立即发送关键时刻事件
-			if m.ctx != nil {
-				runtime.EventsEmit(m.ctx, "mouse-position", event.X, event.Y, event.EventType, event.Button)
-			}
-		case <-m.ctx.Done():
-			return
-		}
-	}
-}
-
-// emitData 定时发送数据到前端
-func (m *MouseHook) emitData() {
-	for {
-		select {
-		case <-m.ticker.C:
-			// 发送当前鼠标位置（用于实时显示）
-			// 默认发送move事件类型
-			if m.ctx != nil {
-				runtime.EventsEmit(m.ctx, "mouse-position", m.lastX, m.lastY, "move", "")
-			}
-
-		case <-m.ctx.Done():
-			return
-		}
-	}
-}
-
-// StartRecording 开始录制
+// StartRecording 开始录制（加入会话时钟，键盘已在录制时沿用其起点）
 func (m *MouseHook) StartRecording() {
 	m.mouseDataMu.Lock()
+	if m.isRecording {
+		m.clock.Leave()
+	}
+	m.clock.Join(time.Now())
 	m.mouseData = make([]MouseEvent, 0)
-	m.startTime = time.Now()
 	m.isRecording = true
+	m.isPaused = false
 	m.mouseDataMu.Unlock()
 	fmt.Println("开始录制鼠标数据...")
 }
@@ -242,11 +199,62 @@ func (m *MouseHook) StartRecording() {
 // StopRecording 停止录制
 func (m *MouseHook) StopRecording() {
 	m.mouseDataMu.Lock()
+	if m.isRecording {
+		m.clock.Leave()
+	}
 	m.isRecording = false
 	m.mouseDataMu.Unlock()
 	fmt.Printf("录制结束，共捕获 %d 个鼠标事件\n", len(m.mouseData))
 }
 
+// PauseRecordingAt 从指定时刻起暂停录制（与视频暂停的时刻对齐）
+func (m *MouseHook) PauseRecordingAt(at time.Time) {
+	m.mouseDataMu.Lock()
+	defer m.mouseDataMu.Unlock()
+	if !m.isRecording || m.isPaused {
+		return
+	}
+	m.isPaused = true
+	m.clock.Pause(at)
+}
+
+// ResumeRecordingAt 恢复录制，暂停时长计算到指定时刻（与视频恢复的时刻对齐）
+func (m *MouseHook) ResumeRecordingAt(at time.Time) {
+	m.mouseDataMu.Lock()
+	defer m.mouseDataMu.Unlock()
+	if !m.isPaused {
+		return
+	}
+	m.isPaused = false
+	m.clock.Resume(at)
+}
+
+// capturing 是否正在记录事件（录制中且未暂停）
+func (m *MouseHook) capturing() bool {
+	m.mouseDataMu.Lock()
+	defer m.mouseDataMu.Unlock()
+	return m.isRecording && !m.isPaused
+}
+
+// SetRetention 设置事件保留窗口（回放缓冲使用），window 和 maxEvents 为 0 表示不限制
+func (m *MouseHook) SetRetention(window time.Duration, maxEvents int) {
+	m.mouseDataMu.Lock()
+	defer m.mouseDataMu.Unlock()
+	m.retention = window
+	m.maxEvents = maxEvents
+	m.mouseData = trimEvents(m.mouseData, m.retention, m.maxEvents, func(e MouseEvent) int64 { return e.Timestamp })
+}
+
+// GetStartTime 获取录制开始时间（事件时间戳 0 对应的时刻，已扣除暂停时长）
+func (m *MouseHook) GetStartTime() time.Time {
+	return m.clock.Origin()
+}
+
+// LastInputTime 获取最近一次鼠标或键盘输入的时间（没有输入时为零值）
+func (m *MouseHook) LastInputTime() time.Time {
+	return m.input.LastInputTime()
+}
+
 // GetMouseData 获取录制的鼠标数据
 func (m *MouseHook) GetMouseData() []MouseEvent {
 	m.mouseDataMu.Lock()
@@ -271,9 +279,9 @@ func (m *MouseHook) ClearMouseData() {
 	m.mouseDataMu.Unlock()
 }
 
-// getRelativeTimestamp 获取相对于录制开始的时间戳（毫秒）
-func (m *MouseHook) getRelativeTimestamp() int64 {
-	return time.Since(m.startTime).Milliseconds()
+// timestamp 获取 at 时刻相对于录制开始的时间戳（毫秒）
+func (m *MouseHook) timestamp(at time.Time) int64 {
+	return m.clock.Elapsed(at).Milliseconds()
 }
 
 // getMouseDownType 获取鼠标按下事件类型
@@ -0,0 +1,309 @@
+package hook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"SmoothScreen/pkg/events"
+)
+
+// CursorShapeEvent 光标形状或可见性的变化
+// 屏幕捕获使用 draw_mouse=0，真实的光标形状（I 形、手形、调整大小箭头）和隐藏光标的时刻只记录在这里
+type CursorShapeEvent struct {
+	Timestamp int64  `json:"t"`                // 相对时间戳（毫秒，与鼠标事件同一时间轴）
+	Visible   bool   `json:"visible"`          // 光标是否可见
+	Sprite    string `json:"sprite,omitempty"` // 光标图像的哈希（见 CursorTrack.Sprites），隐藏时为空
+}
+
+// CursorSprite 按哈希去重的光标图像
+type CursorSprite struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	HotspotX int    `json:"hotspotX"` // 热点（光标指向的位置）相对图像左上角的偏移
+	HotspotY int    `json:"hotspotY"`
+	PNG      []byte `json:"png"` // PNG 编码的图像（JSON 中为 base64）
+}
+
+// CursorTrack 录制的光标数据：形状变化事件和它们引用的图像
+type CursorTrack struct {
+	Events  []CursorShapeEvent      `json:"events"`
+	Sprites map[string]CursorSprite `json:"sprites"`
+}
+
+// CursorShapeTopic 事件总线上的光标形状主题（录制中形状或可见性变化时发布）
+var CursorShapeTopic = events.NewTopic[CursorShapeEvent]("cursor-shape")
+
+// cursorSnapshot 平台返回的当前光标
+type cursorSnapshot struct {
+	visible  bool         // 光标是否可见（Windows 由系统报告，Linux 由光标图像的 alpha 通道判断）
+	serial   uint64       // 光标的平台标识（Windows 为 HCURSOR，X11 为 XFixes cursor_serial）
+	image    *image.NRGBA // 光标图像（标识已知时为 nil）
+	hotspotX int
+	hotspotY int
+}
+
+// cursorSource 平台的光标查询
+// Windows 使用 GetCursorInfo，Linux 使用 X11 XFixes。
+// 只由采样 goroutine 创建、使用和关闭，该 goroutine 锁定在同一个系统线程上（见 CursorTracker.run）
+type cursorSource interface {
+	// snapshot 查询当前光标；known 返回 true 的标识不再读取图像
+	snapshot(known func(serial uint64) bool) (cursorSnapshot, error)
+	close()
+}
+
+// defaultCursorInterval 默认的光标查询间隔
+const defaultCursorInterval = 50 * time.Millisecond
+
+// CursorTracker 光标形状捕获
+// 录制期间定时查询系统光标，形状或可见性变化时记录事件；
+// 同一光标的图像只保存一次（按像素和热点的哈希去重）
+type CursorTracker struct {
+	clock    *SessionClock // 与鼠标、键盘录制共用的会话时钟
+	bus      *events.Bus
+	interval time.Duration
+
+	mu      sync.Mutex
+	track   CursorTrack
+	hashes  map[uint64]string // 平台标识 -> 图像哈希（全透明的光标为空字符串）
+	last    *CursorShapeEvent
+	failed  bool // 已打印过查询失败的警告
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewCursorTracker 创建光标形状捕获，形状变化发布到 bus
+func NewCursorTracker(input *InputService, bus *events.Bus) *CursorTracker {
+	return &CursorTracker{
+		clock:    input.Clock(),
+		bus:      bus,
+		interval: defaultCursorInterval,
+	}
+}
+
+// SetInterval 设置光标查询间隔（对之后开始的录制生效）
+func (t *CursorTracker) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCursorInterval
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = interval
+}
+
+// StartRecording 开始记录光标形状（加入会话时钟）
+// 平台不支持时返回错误，调用方可以忽略它继续录制
+func (t *CursorTracker) StartRecording() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		return nil
+	}
+
+	t.clock.Join(time.Now())
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	ready := make(chan error, 1)
+	go t.run(t.interval, ready, stop, stopped)
+	if err := <-ready; err != nil {
+		t.clock.Leave()
+		return fmt.Errorf("光标形状捕获不可用: %w", err)
+	}
+
+	t.track = CursorTrack{Events: make([]CursorShapeEvent, 0), Sprites: make(map[string]CursorSprite)}
+	t.hashes = make(map[uint64]string)
+	t.last = nil
+	t.failed = false
+	t.stop = stop
+	t.stopped = stopped
+
+	fmt.Println("✓ 光标形状捕获已启动")
+	return nil
+}
+
+// StopRecording 停止记录
+func (t *CursorTracker) StopRecording() {
+	t.mu.Lock()
+	if t.stop == nil {
+		t.mu.Unlock()
+		return
+	}
+	close(t.stop)
+	stopped := t.stopped
+	t.stop = nil
+	t.mu.Unlock()
+
+	<-stopped
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock.Leave()
+	fmt.Printf("光标形状捕获结束，共 %d 次变化、%d 个光标图像\n", len(t.track.Events), len(t.track.Sprites))
+}
+
+// GetTrack 获取录制的光标数据
+func (t *CursorTracker) GetTrack() CursorTrack {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	track := CursorTrack{
+		Events:  append([]CursorShapeEvent(nil), t.track.Events...),
+		Sprites: make(map[string]CursorSprite, len(t.track.Sprites)),
+	}
+	for hash, sprite := range t.track.Sprites {
+		track.Sprites[hash] = sprite
+	}
+	return track
+}
+
+// run 打开平台的光标查询，定时查询光标直到停止，退出前关闭
+// Xlib 的 Display 没有调用 XInitThreads 时不能跨线程使用：查询只在这个 goroutine 中进行，
+// 并锁定系统线程，打开、查询和关闭都在同一个线程上。打开的结果通过 ready 返回
+func (t *CursorTracker) run(interval time.Duration, ready chan<- error, stop, stopped chan struct{}) {
+	defer close(stopped)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	source, err := newCursorSource()
+	ready <- err
+	if err != nil {
+		return
+	}
+	defer source.close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.sample(source)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.sample(source)
+		}
+	}
+}
+
+// sample 查询一次光标，形状或可见性变化时记录事件
+func (t *CursorTracker) sample(source cursorSource) {
+	at := time.Now()
+	if t.clock.Paused() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot, err := source.snapshot(func(serial uint64) bool {
+		_, ok := t.hashes[serial]
+		return ok
+	})
+	if err != nil {
+		if !t.failed {
+			fmt.Printf("✗ 查询光标失败: %v\n", err)
+			t.failed = true
+		}
+		return
+	}
+
+	event := CursorShapeEvent{Visible: snapshot.visible}
+	if snapshot.visible {
+		hash, ok := t.hashes[snapshot.serial]
+		if !ok {
+			if snapshot.image == nil {
+				return
+			}
+			sprite, spriteHash, err := newCursorSprite(snapshot)
+			if err != nil {
+				fmt.Printf("✗ 编码光标图像失败: %v\n", err)
+				return
+			}
+			hash = spriteHash
+			t.hashes[snapshot.serial] = hash
+			if hash != "" {
+				t.track.Sprites[hash] = sprite
+			}
+		}
+		// 全透明的光标（应用隐藏光标的常见做法）视为隐藏
+		event.Visible = hash != ""
+		event.Sprite = hash
+	}
+
+	if t.last != nil && t.last.Visible == event.Visible && t.last.Sprite == event.Sprite {
+		return
+	}
+	event.Timestamp = t.clock.Elapsed(at).Milliseconds()
+	t.track.Events = append(t.track.Events, event)
+	t.last = &event
+	events.Publish(t.bus, CursorShapeTopic, event)
+}
+
+// newCursorSprite 编码光标图像并计算哈希（全透明的图像返回空哈希）
+func newCursorSprite(snapshot cursorSnapshot) (CursorSprite, string, error) {
+	img := snapshot.image
+	if !hasOpaquePixel(img) {
+		return CursorSprite{}, "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return CursorSprite{}, "", err
+	}
+
+	sprite := CursorSprite{
+		Width:    img.Rect.Dx(),
+		Height:   img.Rect.Dy(),
+		HotspotX: snapshot.hotspotX,
+		HotspotY: snapshot.hotspotY,
+		PNG:      buf.Bytes(),
+	}
+
+	// 哈希包含尺寸和热点：同样的图像热点不同时绘制位置也不同
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, [4]int32{int32(sprite.Width), int32(sprite.Height), int32(sprite.HotspotX), int32(sprite.HotspotY)})
+	h.Write(img.Pix)
+	return sprite, hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
+// hasOpaquePixel 图像是否有不透明的像素
+func hasOpaquePixel(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveCursorTrack 保存光标数据
+func SaveCursorTrack(path string, track CursorTrack) error {
+	data, err := json.Marshal(track)
+	if err != nil {
+		return fmt.Errorf("序列化光标数据失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入光标数据失败: %w", err)
+	}
+	return nil
+}
+
+// LoadCursorTrack 读取光标数据
+func LoadCursorTrack(path string) (*CursorTrack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取光标数据失败: %w", err)
+	}
+	var track CursorTrack
+	if err := json.Unmarshal(data, &track); err != nil {
+		return nil, fmt.Errorf("解析光标数据失败: %w", err)
+	}
+	return &track, nil
+}
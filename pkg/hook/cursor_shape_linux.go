@@ -0,0 +1,99 @@
+//go:build linux && cgo
+
+package hook
+
+/*
+#cgo LDFLAGS: -lX11 -lXfixes
+#include <X11/Xlib.h>
+#include <X11/extensions/Xfixes.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"unsafe"
+)
+
+// xfixesCursorSource 通过 X11 XFixes 扩展读取光标图像
+// 没有调用 XInitThreads（界面库可能已经先用了 Xlib），Display 只能由打开它的线程使用：
+// CursorTracker 在锁定系统线程的采样 goroutine 中打开、查询和关闭
+// XFixes 无法查询应用是否隐藏了光标；隐藏光标的应用通常设置全透明的光标，由 alpha 通道判断
+type xfixesCursorSource struct {
+	display *C.Display
+}
+
+// newCursorSource 连接 X 服务器并检查 XFixes 扩展
+func newCursorSource() (cursorSource, error) {
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return nil, errors.New("无法连接 X11 显示（Wayland 会话需要 XWayland）")
+	}
+
+	var eventBase, errorBase C.int
+	if C.XFixesQueryExtension(display, &eventBase, &errorBase) == 0 {
+		C.XCloseDisplay(display)
+		return nil, errors.New("X 服务器不支持 XFixes 扩展")
+	}
+	return &xfixesCursorSource{display: display}, nil
+}
+
+// snapshot 读取当前光标
+func (s *xfixesCursorSource) snapshot(known func(serial uint64) bool) (cursorSnapshot, error) {
+	cursor := C.XFixesGetCursorImage(s.display)
+	if cursor == nil {
+		return cursorSnapshot{}, errors.New("XFixesGetCursorImage 失败")
+	}
+	defer C.XFree(unsafe.Pointer(cursor))
+
+	// 每个像素是一个 unsigned long，低 32 位为预乘 alpha 的 ARGB
+	width, height := int(cursor.width), int(cursor.height)
+	var pixels []C.ulong
+	if width > 0 && height > 0 {
+		pixels = unsafe.Slice(cursor.pixels, width*height)
+	}
+
+	snapshot := cursorSnapshot{
+		visible:  hasOpaqueARGB(pixels),
+		serial:   uint64(cursor.cursor_serial),
+		hotspotX: int(cursor.xhot),
+		hotspotY: int(cursor.yhot),
+	}
+	if !snapshot.visible || known(snapshot.serial) {
+		return snapshot, nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, pixel := range pixels {
+		argb := uint32(pixel)
+		setUnpremultiplied(img.Pix[i*4:i*4+4], uint8(argb>>16), uint8(argb>>8), uint8(argb), uint8(argb>>24))
+	}
+	snapshot.image = img
+	return snapshot, nil
+}
+
+// hasOpaqueARGB 光标像素中是否有不透明的像素（alpha 在高 8 位）
+func hasOpaqueARGB(pixels []C.ulong) bool {
+	for _, pixel := range pixels {
+		if uint32(pixel)>>24 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// close 断开 X 服务器连接
+func (s *xfixesCursorSource) close() {
+	C.XCloseDisplay(s.display)
+}
+
+// setUnpremultiplied 把预乘 alpha 的颜色写为非预乘的 RGBA
+func setUnpremultiplied(pix []uint8, r, g, b, a uint8) {
+	if a == 0 {
+		return
+	}
+	pix[0] = uint8(min(uint32(r)*255/uint32(a), 255))
+	pix[1] = uint8(min(uint32(g)*255/uint32(a), 255))
+	pix[2] = uint8(min(uint32(b)*255/uint32(a), 255))
+	pix[3] = a
+}
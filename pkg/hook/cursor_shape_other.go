@@ -0,0 +1,10 @@
+//go:build !windows && !(linux && cgo)
+
+package hook
+
+import "errors"
+
+// newCursorSource 当前平台不支持读取光标图像
+func newCursorSource() (cursorSource, error) {
+	return nil, errors.New("当前平台不支持光标形状捕获")
+}
@@ -0,0 +1,219 @@
+//go:build windows
+
+package hook
+
+import (
+	"errors"
+	"image"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	cursorUser32           = syscall.NewLazyDLL("user32.dll")
+	cursorGdi32            = syscall.NewLazyDLL("gdi32.dll")
+	procGetCursorInfo      = cursorUser32.NewProc("GetCursorInfo")
+	procGetIconInfo        = cursorUser32.NewProc("GetIconInfo")
+	procGetObjectW         = cursorGdi32.NewProc("GetObjectW")
+	procGetDIBits          = cursorGdi32.NewProc("GetDIBits")
+	procCreateCompatibleDC = cursorGdi32.NewProc("CreateCompatibleDC")
+	procDeleteDC           = cursorGdi32.NewProc("DeleteDC")
+	procDeleteObject       = cursorGdi32.NewProc("DeleteObject")
+)
+
+// Windows API 常量
+const (
+	cursorShowing  = 0x00000001 // CURSOR_SHOWING
+	dibRGBColors   = 0          // DIB_RGB_COLORS
+	biRGB          = 0          // BI_RGB
+	bitmapInfoSize = 40         // sizeof(BITMAPINFOHEADER)
+)
+
+// cursorInfo CURSORINFO
+type cursorInfo struct {
+	cbSize  uint32
+	flags   uint32
+	hCursor uintptr
+	x, y    int32
+}
+
+// iconInfo ICONINFO
+type iconInfo struct {
+	fIcon    int32
+	xHotspot uint32
+	yHotspot uint32
+	hbmMask  uintptr
+	hbmColor uintptr
+}
+
+// bitmap BITMAP
+type bitmap struct {
+	bmType       int32
+	bmWidth      int32
+	bmHeight     int32
+	bmWidthBytes int32
+	bmPlanes     uint16
+	bmBitsPixel  uint16
+	bmBits       uintptr
+}
+
+// bitmapInfo BITMAPINFO（32 位 BI_RGB 不使用颜色表，留出空间以防驱动写入）
+type bitmapInfo struct {
+	biSize          uint32
+	biWidth         int32
+	biHeight        int32
+	biPlanes        uint16
+	biBitCount      uint16
+	biCompression   uint32
+	biSizeImage     uint32
+	biXPelsPerMeter int32
+	biYPelsPerMeter int32
+	biClrUsed       uint32
+	biClrImportant  uint32
+	colors          [256]uint32
+}
+
+// win32CursorSource 通过 GetCursorInfo 读取光标
+type win32CursorSource struct{}
+
+// newCursorSource 创建 Windows 光标查询
+func newCursorSource() (cursorSource, error) {
+	if err := procGetCursorInfo.Find(); err != nil {
+		return nil, err
+	}
+	return win32CursorSource{}, nil
+}
+
+// snapshot 读取当前光标
+func (win32CursorSource) snapshot(known func(serial uint64) bool) (cursorSnapshot, error) {
+	info := cursorInfo{cbSize: uint32(unsafe.Sizeof(cursorInfo{}))}
+	ret, _, err := procGetCursorInfo.Call(uintptr(unsafe.Pointer(&info)))
+	if ret == 0 {
+		return cursorSnapshot{}, err
+	}
+
+	// 应用调用 ShowCursor(FALSE) 或设置空光标时隐藏
+	if info.flags&cursorShowing == 0 || info.hCursor == 0 {
+		return cursorSnapshot{}, nil
+	}
+
+	snapshot := cursorSnapshot{visible: true, serial: uint64(info.hCursor)}
+	if known(snapshot.serial) {
+		return snapshot, nil
+	}
+
+	var icon iconInfo
+	ret, _, err = procGetIconInfo.Call(info.hCursor, uintptr(unsafe.Pointer(&icon)))
+	if ret == 0 {
+		return cursorSnapshot{}, err
+	}
+	// GetIconInfo 返回的位图是副本，需要释放
+	defer deleteObject(icon.hbmMask)
+	defer deleteObject(icon.hbmColor)
+
+	img, err := cursorBitmap(icon)
+	if err != nil {
+		return cursorSnapshot{}, err
+	}
+	snapshot.image = img
+	snapshot.hotspotX = int(icon.xHotspot)
+	snapshot.hotspotY = int(icon.yHotspot)
+	return snapshot, nil
+}
+
+// close 无需释放资源
+func (win32CursorSource) close() {}
+
+// cursorBitmap 把光标位图转换为 RGBA 图像
+// 彩色光标使用 32 位颜色（没有 alpha 时用掩码决定透明）；
+// 单色光标的掩码高度为两倍：上半是 AND 掩码，下半是 XOR 掩码
+func cursorBitmap(icon iconInfo) (*image.NRGBA, error) {
+	mask, width, maskHeight, err := readBitmap(icon.hbmMask)
+	if err != nil {
+		return nil, err
+	}
+
+	if icon.hbmColor == 0 {
+		height := maskHeight / 2
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			and := mask[i*4] != 0
+			xor := mask[(i+width*height)*4] != 0
+			switch {
+			case !and && !xor:
+				img.Pix[i*4+3] = 255 // 黑色
+			case !and && xor:
+				copy(img.Pix[i*4:], []uint8{255, 255, 255, 255}) // 白色
+			case and && xor:
+				// 反色像素无法用图像表示，画成黑色（如 I 形光标）
+				img.Pix[i*4+3] = 255
+			}
+		}
+		return img, nil
+	}
+
+	color, width, height, err := readBitmap(icon.hbmColor)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for i := 3; i < len(color); i += 4 {
+		if color[i] != 0 {
+			hasAlpha = true
+			break
+		}
+	}
+	for i := 0; i < width*height; i++ {
+		b, g, r, a := color[i*4], color[i*4+1], color[i*4+2], color[i*4+3]
+		if !hasAlpha {
+			a = 0
+			if i*4 < len(mask) && mask[i*4] == 0 {
+				a = 255
+			}
+		}
+		copy(img.Pix[i*4:], []uint8{r, g, b, a})
+	}
+	return img, nil
+}
+
+// readBitmap 以 32 位自上而下的 BGRA 读取位图像素
+func readBitmap(hbm uintptr) ([]uint8, int, int, error) {
+	var bm bitmap
+	if ret, _, _ := procGetObjectW.Call(hbm, unsafe.Sizeof(bm), uintptr(unsafe.Pointer(&bm))); ret == 0 {
+		return nil, 0, 0, errors.New("GetObject 失败")
+	}
+	width, height := int(bm.bmWidth), int(bm.bmHeight)
+	if width <= 0 || height <= 0 {
+		return nil, 0, 0, errors.New("光标位图尺寸无效")
+	}
+
+	hdc, _, _ := procCreateCompatibleDC.Call(0)
+	if hdc == 0 {
+		return nil, 0, 0, errors.New("CreateCompatibleDC 失败")
+	}
+	defer procDeleteDC.Call(hdc)
+
+	info := bitmapInfo{
+		biSize:        bitmapInfoSize,
+		biWidth:       int32(width),
+		biHeight:      -int32(height), // 负数表示自上而下
+		biPlanes:      1,
+		biBitCount:    32,
+		biCompression: biRGB,
+	}
+	pixels := make([]uint8, width*height*4)
+	ret, _, _ := procGetDIBits.Call(hdc, hbm, 0, uintptr(height),
+		uintptr(unsafe.Pointer(&pixels[0])), uintptr(unsafe.Pointer(&info)), dibRGBColors)
+	if ret == 0 {
+		return nil, 0, 0, errors.New("GetDIBits 失败")
+	}
+	return pixels, width, height, nil
+}
+
+// deleteObject 释放 GDI 对象
+func deleteObject(handle uintptr) {
+	if handle != 0 {
+		procDeleteObject.Call(handle)
+	}
+}
@@ -0,0 +1,94 @@
+package hook
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	hook "github.com/robotn/gohook"
+)
+
+// mouseEvent 构造 gohook 鼠标事件（button 1 左键、2 右键、3 中键）
+func mouseEvent(kind uint8, button uint16, x, y int16) hook.Event {
+	return hook.Event{Kind: kind, Button: button, X: x, Y: y}
+}
+
+// recordMouse 不启动全局钩子，把事件依次交给鼠标钩子并返回记录的事件（类型@坐标）
+func recordMouse(t *testing.T, sequence []hook.Event) []string {
+	t.Helper()
+	input := NewInputService()
+	m := NewMouseHook(input, nil)
+	m.unlisten = input.AddListener(m.handleEvent)
+	defer m.unlisten()
+
+	m.StartRecording()
+	for _, ev := range sequence {
+		input.dispatch(ev)
+	}
+	m.StopRecording()
+
+	var got []string
+	var last int64
+	for _, event := range m.GetMouseData() {
+		if event.Timestamp < last {
+			t.Errorf("时间戳倒退: %+v", event)
+		}
+		last = event.Timestamp
+		got = append(got, fmt.Sprintf("%s@%d,%d", event.EventType, event.X, event.Y))
+	}
+	return got
+}
+
+func TestMouseHookRecordsDrag(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence []hook.Event
+		want     []string
+	}{
+		{
+			// libuiohook 按住按键时只发送 MouseDrag，松开后没有 MouseUp（MOUSE_CLICKED）
+			name: "左键拖动",
+			sequence: []hook.Event{
+				mouseEvent(hook.MouseMove, 0, 90, 100),
+				mouseEvent(hook.MouseDown, 1, 100, 100),
+				mouseEvent(hook.MouseDrag, 0, 150, 120),
+				mouseEvent(hook.MouseDrag, 0, 300, 200),
+				mouseEvent(hook.MouseDrag, 0, 400, 300),
+				mouseEvent(hook.MouseHold, 1, 400, 300),
+				mouseEvent(hook.MouseMove, 0, 410, 300),
+			},
+			want: []string{
+				"move@90,100", "l_down@100,100",
+				"move@150,120", "move@300,200", "move@400,300",
+				"l_up@400,300", "move@410,300",
+			},
+		},
+		{
+			// 原地点击时松开后紧跟 MouseUp（MOUSE_CLICKED），不重复记录松开
+			name: "右键点击",
+			sequence: []hook.Event{
+				mouseEvent(hook.MouseDown, 2, 50, 60),
+				mouseEvent(hook.MouseHold, 2, 50, 60),
+				mouseEvent(hook.MouseUp, 2, 50, 60),
+			},
+			want: []string{"r_down@50,60", "r_up@50,60"},
+		},
+		{
+			name: "滚动使用最近的位置",
+			sequence: []hook.Event{
+				mouseEvent(hook.MouseDown, 3, 10, 10),
+				mouseEvent(hook.MouseDrag, 0, 20, 30),
+				mouseEvent(hook.MouseHold, 3, 20, 30),
+				{Kind: hook.MouseWheel, Amount: 3},
+			},
+			want: []string{"m_down@10,10", "move@20,30", "m_up@20,30", "scroll@20,30"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recordMouse(t, tt.sequence); !slices.Equal(got, tt.want) {
+				t.Errorf("鼠标记录 = %v\n期望 %v", got, tt.want)
+			}
+		})
+	}
+}
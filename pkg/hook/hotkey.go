@@ -0,0 +1,383 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	hook "github.com/robotn/gohook"
+)
+
+// 快捷键动作
+const (
+	HotkeyToggleRecording = "toggle_recording" // 开始/停止录制
+	HotkeyTogglePause     = "toggle_pause"     // 暂停/恢复录制
+	HotkeyMarker          = "marker"           // 添加章节标记
+	HotkeySaveReplay      = "save_replay"      // 保存回放缓冲
+)
+
+// hotkeyActions 支持的快捷键动作
+var hotkeyActions = []string{HotkeyToggleRecording, HotkeyTogglePause, HotkeyMarker, HotkeySaveReplay}
+
+// reservedHotkeys 系统保留的组合键（无法可靠拦截，或会与系统操作冲突）
+var reservedHotkeys = []string{"Ctrl+Alt+Delete", "Alt+Tab", "Alt+F4", "Ctrl+Esc", "Win+L", "Win+D", "Win+Tab"}
+
+// modifierOrder 修饰键的规范顺序（与 eventModifiers 一致）
+var modifierOrder = []string{"Ctrl", "Shift", "Alt", "Win"}
+
+// modifierAliases 修饰键的别名
+var modifierAliases = map[string]string{
+	"ctrl":    "Ctrl",
+	"control": "Ctrl",
+	"shift":   "Shift",
+	"alt":     "Alt",
+	"option":  "Alt",
+	"win":     "Win",
+	"cmd":     "Win",
+	"meta":    "Win",
+	"super":   "Win",
+}
+
+// HotkeySettings 全局快捷键设置
+type HotkeySettings struct {
+	Enabled  bool              `json:"enabled"`
+	Bindings map[string]string `json:"bindings"` // 动作 -> 组合键（如 "Ctrl+Shift+F9"），空字符串表示不绑定
+}
+
+// DefaultHotkeySettings 返回默认的快捷键设置
+func DefaultHotkeySettings() HotkeySettings {
+	return HotkeySettings{
+		Enabled: true,
+		Bindings: map[string]string{
+			HotkeyToggleRecording: "Ctrl+Shift+F9",
+			HotkeyTogglePause:     "Ctrl+Shift+F10",
+			HotkeyMarker:          "Ctrl+Shift+F11",
+			HotkeySaveReplay:      "Ctrl+Shift+F12",
+		},
+	}
+}
+
+// Hotkey 解析后的组合键
+type Hotkey struct {
+	Modifiers []string // 按规范顺序排列
+	Key       string
+	Keycode   uint16 // libuiohook 虚拟键码（VC_*）
+}
+
+// ParseHotkey 解析组合键（如 "Ctrl+Shift+F9"，不区分大小写）
+func ParseHotkey(text string) (Hotkey, error) {
+	var hotkey Hotkey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(text, "+") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return Hotkey{}, fmt.Errorf("无效的组合键: %q", text)
+		}
+		if modifier, ok := modifierAliases[strings.ToLower(part)]; ok {
+			seen[modifier] = true
+			continue
+		}
+		if hotkey.Key != "" {
+			return Hotkey{}, fmt.Errorf("组合键只能包含一个非修饰键: %q", text)
+		}
+		keycode, name, ok := lookupKey(part)
+		if !ok {
+			return Hotkey{}, fmt.Errorf("未知按键 %q", part)
+		}
+		hotkey.Key, hotkey.Keycode = name, keycode
+	}
+	if hotkey.Key == "" {
+		return Hotkey{}, fmt.Errorf("组合键缺少非修饰键: %q", text)
+	}
+	for _, modifier := range modifierOrder {
+		if seen[modifier] {
+			hotkey.Modifiers = append(hotkey.Modifiers, modifier)
+		}
+	}
+	return hotkey, nil
+}
+
+// String 返回规范格式的组合键
+func (h Hotkey) String() string {
+	return strings.Join(append(slices.Clone(h.Modifiers), h.Key), "+")
+}
+
+// matches 检查事件是否为该组合键（修饰键必须完全一致）
+// 按 libuiohook 的虚拟键码匹配：Rawcode 随平台不同（Windows 虚拟键码、X11 keysym、macOS 键码），
+// X11 的 keysym 还随 Shift 变化，而 Keycode 由 libuiohook 按平台统一映射
+func (h Hotkey) matches(ev hook.Event) bool {
+	return ev.Keycode == h.Keycode && slices.Equal(eventModifiers(ev), h.Modifiers)
+}
+
+// hotkeyKeys 可用于快捷键的按键名称和 libuiohook 虚拟键码（VC_*）
+var hotkeyKeys = map[string]uint16{
+	"A": 0x001E, "B": 0x0030, "C": 0x002E, "D": 0x0020, "E": 0x0012, "F": 0x0021, "G": 0x0022,
+	"H": 0x0023, "I": 0x0017, "J": 0x0024, "K": 0x0025, "L": 0x0026, "M": 0x0032, "N": 0x0031,
+	"O": 0x0018, "P": 0x0019, "Q": 0x0010, "R": 0x0013, "S": 0x001F, "T": 0x0014, "U": 0x0016,
+	"V": 0x002F, "W": 0x0011, "X": 0x002D, "Y": 0x0015, "Z": 0x002C,
+
+	"1": 0x0002, "2": 0x0003, "3": 0x0004, "4": 0x0005, "5": 0x0006,
+	"6": 0x0007, "7": 0x0008, "8": 0x0009, "9": 0x000A, "0": 0x000B,
+
+	"F1": 0x003B, "F2": 0x003C, "F3": 0x003D, "F4": 0x003E, "F5": 0x003F, "F6": 0x0040,
+	"F7": 0x0041, "F8": 0x0042, "F9": 0x0043, "F10": 0x0044, "F11": 0x0057, "F12": 0x0058,
+	"F13": 0x005B, "F14": 0x005C, "F15": 0x005D, "F16": 0x0063, "F17": 0x0064, "F18": 0x0065,
+	"F19": 0x0066, "F20": 0x0067, "F21": 0x0068, "F22": 0x0069, "F23": 0x006A, "F24": 0x006B,
+
+	"Esc":         0x0001,
+	"Backspace":   0x000E,
+	"Tab":         0x000F,
+	"Enter":       0x001C,
+	"Space":       0x0039,
+	"Insert":      0x0E52,
+	"Delete":      0x0E53,
+	"Home":        0x0E47,
+	"End":         0x0E4F,
+	"PageUp":      0x0E49,
+	"PageDown":    0x0E51,
+	"Up":          0xE048,
+	"Left":        0xE04B,
+	"Right":       0xE04D,
+	"Down":        0xE050,
+	"Pause":       0x0E45,
+	"PrintScreen": 0x0E37,
+	"ScrollLock":  0x0046,
+}
+
+// lookupKey 按名称查找按键的虚拟键码
+func lookupKey(name string) (uint16, string, bool) {
+	for keyName, keycode := range hotkeyKeys {
+		if strings.EqualFold(keyName, name) {
+			return keycode, keyName, true
+		}
+	}
+	return 0, "", false
+}
+
+// isModifierKey 检查 libuiohook 虚拟键码是否为修饰键（左右 Shift、Ctrl、Alt、Win）
+func isModifierKey(keycode uint16) bool {
+	switch keycode {
+	case 0x002A, 0x0036, 0x001D, 0x0E1D, 0x0038, 0x0E38, 0x0E5B, 0x0E5C:
+		return true
+	}
+	return false
+}
+
+// standaloneKey 可以不带修饰键使用的按键（F13-F24 等平时很少输入，不会误触发）
+func standaloneKey(hotkey Hotkey) bool {
+	switch hotkey.Key {
+	case "F13", "F14", "F15", "F16", "F17", "F18", "F19", "F20", "F21", "F22", "F23", "F24", "Pause", "ScrollLock":
+		return true
+	}
+	return false
+}
+
+// HotkeyEvent 快捷键触发事件
+type HotkeyEvent struct {
+	Action string    `json:"action"`
+	Keys   string    `json:"keys"`
+	Time   time.Time `json:"time"`
+}
+
+// HotkeyService 全局快捷键服务
+// 由输入捕获服务（InputService）在分发按键事件前调用 HandleEvent；匹配的按键不会写入键盘记录
+type HotkeyService struct {
+	mu           sync.Mutex
+	path         string // 设置文件路径（为空时不保存）
+	settings     HotkeySettings
+	hotkeys      map[string]Hotkey // 动作 -> 组合键
+	pressed      map[uint16]bool   // 已触发、尚未松开的按键虚拟键码（忽略按住时的自动重复）
+	eventHandler func(HotkeyEvent)
+}
+
+// NewHotkeyService 创建快捷键服务并加载设置（文件不存在或无效时使用默认设置）
+func NewHotkeyService(settingsPath string) *HotkeyService {
+	s := &HotkeyService{
+		path:    settingsPath,
+		pressed: make(map[uint16]bool),
+	}
+
+	settings := DefaultHotkeySettings()
+	if loaded, err := s.load(); err != nil {
+		fmt.Printf("警告: 加载快捷键设置失败，使用默认设置: %v\n", err)
+	} else if loaded != nil {
+		settings = *loaded
+	}
+	hotkeys, err := parseHotkeySettings(settings)
+	if err != nil {
+		fmt.Printf("警告: 快捷键设置无效，使用默认设置: %v\n", err)
+		settings = DefaultHotkeySettings()
+		hotkeys, _ = parseHotkeySettings(settings)
+	}
+	s.settings, s.hotkeys = settings, hotkeys
+	return s
+}
+
+// SetEventHandler 设置快捷键触发回调（在新的 goroutine 中调用，不阻塞钩子）
+func (s *HotkeyService) SetEventHandler(handler func(HotkeyEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventHandler = handler
+}
+
+// GetSettings 获取快捷键设置（组合键为规范格式）
+func (s *HotkeyService) GetSettings() HotkeySettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneHotkeySettings(s.settings)
+}
+
+// SetSettings 校验并保存快捷键设置
+func (s *HotkeyService) SetSettings(settings HotkeySettings) error {
+	hotkeys, err := parseHotkeySettings(settings)
+	if err != nil {
+		return err
+	}
+	settings = cloneHotkeySettings(settings)
+	for action, hotkey := range hotkeys {
+		settings.Bindings[action] = hotkey.String()
+	}
+
+	s.mu.Lock()
+	s.settings, s.hotkeys = settings, hotkeys
+	s.pressed = make(map[uint16]bool)
+	s.mu.Unlock()
+
+	return s.save(settings)
+}
+
+// HandleEvent 处理按键事件，返回事件是否属于快捷键（调用方不应再记录该事件）
+// 按下时触发一次，按住时的自动重复和松开只吞掉不触发
+func (s *HotkeyService) HandleEvent(ev hook.Event) bool {
+	if ev.Kind != hook.KeyDown && ev.Kind != hook.KeyUp {
+		return false
+	}
+
+	s.mu.Lock()
+	if !s.settings.Enabled {
+		s.mu.Unlock()
+		return false
+	}
+	if ev.Kind == hook.KeyUp {
+		// 修饰键可能先于按键松开，只按按键判断
+		pressed := s.pressed[ev.Keycode]
+		delete(s.pressed, ev.Keycode)
+		s.mu.Unlock()
+		return pressed
+	}
+
+	for _, action := range hotkeyActions {
+		hotkey, ok := s.hotkeys[action]
+		if !ok || !hotkey.matches(ev) {
+			continue
+		}
+		repeat := s.pressed[ev.Keycode]
+		s.pressed[ev.Keycode] = true
+		handler := s.eventHandler
+		s.mu.Unlock()
+
+		if !repeat && handler != nil {
+			fmt.Printf("快捷键 %s: %s\n", hotkey, action)
+			go handler(HotkeyEvent{Action: action, Keys: hotkey.String(), Time: time.Now()})
+		}
+		return true
+	}
+	s.mu.Unlock()
+	return false
+}
+
+// load 读取设置文件（不存在时返回 nil）
+func (s *HotkeyService) load() (*HotkeySettings, error) {
+	if s.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var settings HotkeySettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	// 新增的动作使用默认绑定
+	for action, keys := range DefaultHotkeySettings().Bindings {
+		if _, ok := settings.Bindings[action]; !ok {
+			if settings.Bindings == nil {
+				settings.Bindings = make(map[string]string)
+			}
+			settings.Bindings[action] = keys
+		}
+	}
+	return &settings, nil
+}
+
+// save 保存设置文件
+func (s *HotkeyService) save(settings HotkeySettings) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建设置目录失败: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("保存快捷键设置失败: %w", err)
+	}
+	return nil
+}
+
+// parseHotkeySettings 解析所有绑定并检查冲突
+func parseHotkeySettings(settings HotkeySettings) (map[string]Hotkey, error) {
+	hotkeys := make(map[string]Hotkey)
+	owners := make(map[string]string) // 规范组合键 -> 动作
+	for action, keys := range settings.Bindings {
+		if !slices.Contains(hotkeyActions, action) {
+			return nil, fmt.Errorf("未知的快捷键动作: %s", action)
+		}
+		if strings.TrimSpace(keys) == "" {
+			continue
+		}
+
+		hotkey, err := ParseHotkey(keys)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+		combo := hotkey.String()
+		if len(hotkey.Modifiers) == 0 && !standaloneKey(hotkey) {
+			return nil, fmt.Errorf("%s: 组合键 %s 至少需要一个修饰键，否则会拦截正常输入", action, combo)
+		}
+		for _, reserved := range reservedHotkeys {
+			if parsed, _ := ParseHotkey(reserved); parsed.String() == combo {
+				return nil, fmt.Errorf("%s: 组合键 %s 为系统保留", action, combo)
+			}
+		}
+		if owner, ok := owners[combo]; ok {
+			return nil, fmt.Errorf("组合键 %s 同时绑定到 %s 和 %s", combo, owner, action)
+		}
+		owners[combo] = action
+		hotkeys[action] = hotkey
+	}
+	return hotkeys, nil
+}
+
+// cloneHotkeySettings 复制设置（避免共享 map）
+func cloneHotkeySettings(settings HotkeySettings) HotkeySettings {
+	bindings := make(map[string]string, len(settings.Bindings))
+	for action, keys := range settings.Bindings {
+		bindings[action] = keys
+	}
+	settings.Bindings = bindings
+	return settings
+}
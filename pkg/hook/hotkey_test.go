@@ -0,0 +1,213 @@
+package hook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	hook "github.com/robotn/gohook"
+)
+
+// libuiohook 虚拟键码
+const (
+	vcCtrl  = 0x001D
+	vcShift = 0x002A
+	vcA     = 0x001E
+	vcC     = 0x002E
+	vcF9    = 0x0043
+)
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		keycode uint16
+		wantErr string
+	}{
+		{text: "Ctrl+Shift+F9", want: "Ctrl+Shift+F9", keycode: vcF9},
+		{text: "shift + control + f9", want: "Ctrl+Shift+F9", keycode: vcF9},
+		{text: "Cmd+Option+a", want: "Alt+Win+A", keycode: vcA},
+		{text: "F13", want: "F13", keycode: 0x005B},
+		{text: "Ctrl+PrintScreen", want: "Ctrl+PrintScreen", keycode: 0x0E37},
+		{text: "Ctrl+Shift", wantErr: "缺少非修饰键"},
+		{text: "Ctrl+A+B", wantErr: "只能包含一个非修饰键"},
+		{text: "Ctrl++A", wantErr: "无效的组合键"},
+		{text: "Ctrl+Hyper", wantErr: "未知按键"},
+	}
+	for _, tt := range tests {
+		hotkey, err := ParseHotkey(tt.text)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseHotkey(%q) 错误 = %v，期望包含 %q", tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHotkey(%q): %v", tt.text, err)
+			continue
+		}
+		if hotkey.String() != tt.want || hotkey.Keycode != tt.keycode {
+			t.Errorf("ParseHotkey(%q) = %s (%#x)，期望 %s (%#x)", tt.text, hotkey, hotkey.Keycode, tt.want, tt.keycode)
+		}
+	}
+}
+
+func TestParseHotkeySettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		bindings map[string]string
+		wantErr  string
+	}{
+		{name: "默认设置", bindings: DefaultHotkeySettings().Bindings},
+		{name: "空绑定表示不绑定", bindings: map[string]string{HotkeyMarker: "", HotkeyTogglePause: "  "}},
+		{name: "单独的 F13 和 Pause", bindings: map[string]string{HotkeyMarker: "F13", HotkeyTogglePause: "Pause"}},
+		{
+			name:     "同一组合键绑定两个动作",
+			bindings: map[string]string{HotkeyMarker: "Ctrl+Shift+M", HotkeyTogglePause: "shift+ctrl+m"},
+			wantErr:  "同时绑定到",
+		},
+		{name: "系统保留", bindings: map[string]string{HotkeyMarker: "alt+f4"}, wantErr: "系统保留"},
+		{name: "保留组合键的别名", bindings: map[string]string{HotkeyMarker: "Cmd+L"}, wantErr: "系统保留"},
+		{name: "普通按键缺少修饰键", bindings: map[string]string{HotkeyMarker: "M"}, wantErr: "至少需要一个修饰键"},
+		{name: "F9 缺少修饰键", bindings: map[string]string{HotkeyMarker: "F9"}, wantErr: "至少需要一个修饰键"},
+		{name: "未知动作", bindings: map[string]string{"screenshot": "Ctrl+Shift+S"}, wantErr: "未知的快捷键动作"},
+		{name: "无效组合键", bindings: map[string]string{HotkeyMarker: "Ctrl+Hyper"}, wantErr: "未知按键"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseHotkeySettings(HotkeySettings{Enabled: true, Bindings: tt.bindings})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseHotkeySettings: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("错误 = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// keyEvent 构造 gohook 按键事件，mask 为事件发生时按住的修饰键（Rawcode 与键码相同，便于核对记录）
+func keyEvent(kind uint8, keycode uint16, mask uint16) hook.Event {
+	return hook.Event{Kind: kind, Keycode: keycode, Rawcode: keycode, Mask: mask}
+}
+
+// newTestHotkeys 创建使用默认绑定、不保存设置的快捷键服务
+func newTestHotkeys(t *testing.T) (*HotkeyService, chan HotkeyEvent) {
+	t.Helper()
+	s := NewHotkeyService("")
+	fired := make(chan HotkeyEvent, 10)
+	s.SetEventHandler(func(ev HotkeyEvent) { fired <- ev })
+	return s, fired
+}
+
+// expectFired 检查依次触发的快捷键动作
+func expectFired(t *testing.T, fired chan HotkeyEvent, actions ...string) {
+	t.Helper()
+	for _, action := range actions {
+		select {
+		case ev := <-fired:
+			if ev.Action != action {
+				t.Fatalf("触发 %s，期望 %s", ev.Action, action)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("没有触发 %s", action)
+		}
+	}
+	select {
+	case ev := <-fired:
+		t.Fatalf("多余的触发: %+v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestHotkeyHandleEvent(t *testing.T) {
+	s, fired := newTestHotkeys(t)
+	chord := uint16(1<<1 | 1<<0) // 左 Ctrl + 左 Shift
+
+	steps := []struct {
+		name string
+		ev   hook.Event
+		want bool
+	}{
+		{"按下 F9", keyEvent(hook.KeyDown, vcF9, chord), true},
+		{"按住 F9 自动重复", keyEvent(hook.KeyDown, vcF9, chord), true},
+		{"按住 F9 自动重复", keyEvent(hook.KeyDown, vcF9, chord), true},
+		{"松开 F9", keyEvent(hook.KeyUp, vcF9, chord), true},
+		{"只按 Ctrl 的 F9", keyEvent(hook.KeyDown, vcF9, 1<<1), false},
+		{"右侧修饰键的 F9", keyEvent(hook.KeyDown, vcF9, 1<<5|1<<4), true},
+		{"松开 F9", keyEvent(hook.KeyUp, vcF9, 0), true},
+		{"未触发的 F9 松开", keyEvent(hook.KeyUp, vcF9, 0), false},
+		{"普通按键", keyEvent(hook.KeyDown, vcA, chord), false},
+		{"鼠标事件", hook.Event{Kind: hook.MouseDown, Mask: chord}, false},
+	}
+	for _, step := range steps {
+		if got := s.HandleEvent(step.ev); got != step.want {
+			t.Errorf("%s: HandleEvent = %v，期望 %v", step.name, got, step.want)
+		}
+	}
+	// 自动重复只触发一次，松开后再次按下重新触发
+	expectFired(t, fired, HotkeyToggleRecording, HotkeyToggleRecording)
+
+	// 关闭后不吞掉按键
+	settings := s.GetSettings()
+	settings.Enabled = false
+	if err := s.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if s.HandleEvent(keyEvent(hook.KeyDown, vcF9, chord)) {
+		t.Error("关闭快捷键后仍吞掉按键")
+	}
+	expectFired(t, fired)
+}
+
+func TestHotkeyChordNotRecorded(t *testing.T) {
+	input := NewInputService()
+	hotkeys, fired := newTestHotkeys(t)
+	input.SetHotkeys(hotkeys)
+
+	// 不启动全局钩子，直接把事件交给输入服务分发
+	k := NewKeyboardHook(input)
+	k.clock.Join(time.Now())
+	k.isRecording = true
+	k.unlisten = input.AddListener(k.handleKeyEvent)
+	defer k.unlisten()
+
+	ctrl, ctrlShift := uint16(1<<1), uint16(1<<1|1<<0)
+	for _, ev := range []hook.Event{
+		// Ctrl+Shift+F9：修饰键在快捷键之前按下，晚于快捷键松开
+		keyEvent(hook.KeyDown, vcCtrl, ctrl),
+		keyEvent(hook.KeyDown, vcShift, ctrlShift),
+		keyEvent(hook.KeyDown, vcF9, ctrlShift),
+		keyEvent(hook.KeyUp, vcF9, ctrlShift),
+		keyEvent(hook.KeyUp, vcShift, ctrl),
+		keyEvent(hook.KeyUp, vcCtrl, 0),
+		// Ctrl+C 不是快捷键，照常记录
+		keyEvent(hook.KeyDown, vcCtrl, ctrl),
+		keyEvent(hook.KeyDown, vcC, ctrl),
+		keyEvent(hook.KeyUp, vcC, ctrl),
+		keyEvent(hook.KeyUp, vcCtrl, 0),
+		// 单独按下又松开的 Shift 照常记录
+		keyEvent(hook.KeyDown, vcShift, 1<<0),
+		keyEvent(hook.KeyUp, vcShift, 0),
+	} {
+		input.dispatch(ev)
+	}
+	expectFired(t, fired, HotkeyToggleRecording)
+
+	var got []string
+	for _, ev := range k.GetEvents() {
+		got = append(got, fmt.Sprintf("%s:%s:%#x", ev.EventType, strings.Join(ev.Modifiers, "+"), ev.Rawcode))
+	}
+	want := []string{
+		"key_down:Ctrl:0x1d", "key_down:Ctrl:0x2e", "key_up:Ctrl:0x2e", "key_up::0x1d",
+		"key_down:Shift:0x2a", "key_up::0x2a",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("键盘记录 = %v\n期望 %v", got, want)
+	}
+}
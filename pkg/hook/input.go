@@ -0,0 +1,159 @@
+package hook
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hook "github.com/robotn/gohook"
+)
+
+// InputEvent 分发给监听者的输入事件
+type InputEvent struct {
+	hook.Event
+	Time   time.Time // 收到事件的时刻
+	Hotkey bool      // 按键触发了全局快捷键（键盘录制不记录它）
+}
+
+// inputListener 已注册的监听者
+type inputListener struct {
+	id      int
+	handler func(InputEvent)
+}
+
+// InputService 输入捕获服务
+// gohook 是进程级的全局钩子，hook.Start 和 hook.End 只能由一处管理：
+// 本服务持有唯一的事件通道并把事件分发给鼠标、键盘录制和全局快捷键。
+// 使用者通过 Acquire/Release 引用计数，第一个使用者启动钩子，最后一个使用者释放时才停止
+type InputService struct {
+	mu        sync.Mutex
+	refs      int
+	stop      chan struct{}
+	hotkeys   *HotkeyService
+	listeners []inputListener
+	nextID    int
+	clock     SessionClock
+	lastInput atomic.Int64 // 最近一次鼠标或键盘输入的时间（UnixNano）
+}
+
+// NewInputService 创建输入捕获服务
+func NewInputService() *InputService {
+	return &InputService{}
+}
+
+// Acquire 增加引用，第一个引用启动全局钩子
+func (s *InputService) Acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs++
+	if s.refs > 1 {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	go s.run(hook.Start(), s.stop)
+	fmt.Println("✓ 输入钩子已启动")
+}
+
+// Release 释放引用，最后一个引用释放时停止全局钩子
+func (s *InputService) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refs == 0 {
+		return
+	}
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	close(s.stop)
+	s.stop = nil
+	hook.End()
+	fmt.Println("输入钩子已停止")
+}
+
+// Running 钩子是否在运行
+func (s *InputService) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refs > 0
+}
+
+// SetHotkeys 设置全局快捷键服务（按键在分发给监听者之前检测快捷键）
+func (s *InputService) SetHotkeys(hotkeys *HotkeyService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hotkeys = hotkeys
+}
+
+// AddListener 注册监听者，返回取消注册的函数
+// 监听者在事件协程中按注册顺序调用，不能阻塞
+func (s *InputService) AddListener(handler func(InputEvent)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := s.nextID
+	s.listeners = append(s.listeners, inputListener{id: id, handler: handler})
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, listener := range s.listeners {
+			if listener.id == id {
+				s.listeners = append(s.listeners[:i:i], s.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Clock 返回鼠标和键盘录制共用的会话时钟
+func (s *InputService) Clock() *SessionClock {
+	return &s.clock
+}
+
+// LastInputTime 获取最近一次鼠标或键盘输入的时间（没有输入时为零值）
+func (s *InputService) LastInputTime() time.Time {
+	nanos := s.lastInput.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// run 读取事件通道直到停止
+func (s *InputService) run(eventChan chan hook.Event, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case ev, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			s.dispatch(ev)
+		}
+	}
+}
+
+// dispatch 检测快捷键并把事件分发给所有监听者
+func (s *InputService) dispatch(ev hook.Event) {
+	input := InputEvent{Event: ev, Time: time.Now()}
+	if ev.Kind != hook.HookEnabled && ev.Kind != hook.HookDisabled {
+		s.lastInput.Store(input.Time.UnixNano())
+	}
+
+	s.mu.Lock()
+	hotkeys := s.hotkeys
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if hotkeys != nil && (ev.Kind == hook.KeyDown || ev.Kind == hook.KeyUp) {
+		input.Hotkey = hotkeys.HandleEvent(ev)
+	}
+	for _, listener := range listeners {
+		listener.handler(input)
+	}
+}
@@ -3,9 +3,12 @@ package hook
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
+	"SmoothScreen/pkg/events"
+
 	hook "github.com/robotn/gohook"
 )
 
@@ -18,24 +21,31 @@ type KeyboardEvent struct {
 	EventType string   `json:"type"`      // key_down, key_up
 }
 
+// KeyboardTopic 事件总线上的键盘主题（录制中的按键）
+var KeyboardTopic = events.NewTopic[KeyboardEvent]("keyboard")
+
 // KeyboardHook 键盘钩子
 type KeyboardHook struct {
 	events       []KeyboardEvent
 	eventsMu     sync.Mutex
 	isRecording  bool
 	isPaused     bool
-	startTime    time.Time
-	pausedTime   time.Duration
-	pauseStart   time.Time
-	stopChan     chan bool
+	input        *InputService       // 共享的输入捕获服务
+	clock        *SessionClock       // 与鼠标录制共用的会话时钟
+	unlisten     func()              // 录制期间注册的监听
 	eventHandler func(KeyboardEvent) // 可选的事件处理器
+	retention    time.Duration       // 只保留最近这段时间的事件（0 表示全部保留）
+	maxEvents    int                 // 最多保留的事件数（0 表示不限制）
+	suppressMods bool                // 快捷键触发后忽略随后松开的修饰键
+	pendingMods  []KeyboardEvent     // 尚未确定是否属于快捷键的修饰键按下事件
 }
 
 // NewKeyboardHook 创建键盘钩子
-func NewKeyboardHook() *KeyboardHook {
+func NewKeyboardHook(input *InputService) *KeyboardHook {
 	return &KeyboardHook{
-		events:   make([]KeyboardEvent, 0),
-		stopChan: make(chan bool),
+		events: make([]KeyboardEvent, 0),
+		input:  input,
+		clock:  input.Clock(),
 	}
 }
 
@@ -47,15 +57,17 @@ func (k *KeyboardHook) StartRecording() error {
 		return fmt.Errorf("键盘钩子已在运行")
 	}
 
+	// 加入会话时钟，鼠标已在录制时两者的时间戳使用同一起点
+	k.clock.Join(time.Now())
 	k.isRecording = true
 	k.isPaused = false
-	k.startTime = time.Now()
-	k.pausedTime = 0
+	k.suppressMods = false
+	k.pendingMods = nil
 	k.events = make([]KeyboardEvent, 0)
+	k.unlisten = k.input.AddListener(k.handleKeyEvent)
 	k.eventsMu.Unlock()
 
-	// 启动事件处理协程
-	go k.processEvents()
+	k.input.Acquire()
 
 	fmt.Println("键盘钩子已启动")
 	return nil
@@ -70,14 +82,16 @@ func (k *KeyboardHook) StopRecording() error {
 		return fmt.Errorf("键盘钩子未运行")
 	}
 
+	// 停止时仍按住的修饰键不属于快捷键，照常记录
+	k.flushPendingModifiers()
 	k.isRecording = false
 	k.isPaused = false
+	k.clock.Leave()
 
-	// 发送停止信号
-	select {
-	case k.stopChan <- true:
-	default:
-	}
+	// 只释放本钩子的引用，鼠标和快捷键仍在使用全局钩子
+	k.unlisten()
+	k.unlisten = nil
+	k.input.Release()
 
 	fmt.Printf("键盘钩子已停止，记录了 %d 个事件\n", len(k.events))
 	return nil
@@ -85,6 +99,11 @@ func (k *KeyboardHook) StopRecording() error {
 
 // PauseRecording 暂停录制
 func (k *KeyboardHook) PauseRecording() error {
+	return k.PauseRecordingAt(time.Now())
+}
+
+// PauseRecordingAt 从指定时刻起暂停录制（与视频暂停的时刻对齐）
+func (k *KeyboardHook) PauseRecordingAt(at time.Time) error {
 	k.eventsMu.Lock()
 	defer k.eventsMu.Unlock()
 
@@ -93,12 +112,17 @@ func (k *KeyboardHook) PauseRecording() error {
 	}
 
 	k.isPaused = true
-	k.pauseStart = time.Now()
+	k.clock.Pause(at)
 	return nil
 }
 
 // ResumeRecording 恢复录制
 func (k *KeyboardHook) ResumeRecording() error {
+	return k.ResumeRecordingAt(time.Now())
+}
+
+// ResumeRecordingAt 恢复录制，暂停时长计算到指定时刻（与视频恢复的时刻对齐）
+func (k *KeyboardHook) ResumeRecordingAt(at time.Time) error {
 	k.eventsMu.Lock()
 	defer k.eventsMu.Unlock()
 
@@ -106,46 +130,39 @@ func (k *KeyboardHook) ResumeRecording() error {
 		return fmt.Errorf("未暂停")
 	}
 
-	k.pausedTime += time.Since(k.pauseStart)
 	k.isPaused = false
+	k.clock.Resume(at)
 	return nil
 }
 
-// processEvents 处理键盘事件
-func (k *KeyboardHook) processEvents() {
-	// 启动 gohook 事件监听
-	eventChan := hook.Start()
-	defer hook.End()
-
-	fmt.Println("键盘事件处理协程已启动")
-
-	for {
-		select {
-		case <-k.stopChan:
-			fmt.Println("收到停止信号，退出键盘事件处理")
-			return
-
-		case ev := <-eventChan:
-			// 只处理键盘事件
-			if ev.Kind == hook.KeyDown || ev.Kind == hook.KeyUp {
-				k.handleKeyEvent(ev)
-			}
-		}
+// handleKeyEvent 处理单个键盘事件
+func (k *KeyboardHook) handleKeyEvent(ev InputEvent) {
+	if ev.Kind != hook.KeyDown && ev.Kind != hook.KeyUp {
+		return
 	}
-}
 
-// handleKeyEvent 处理单个键盘事件
-func (k *KeyboardHook) handleKeyEvent(ev hook.Event) {
 	k.eventsMu.Lock()
 	defer k.eventsMu.Unlock()
 
+	// 快捷键本身不写入键盘记录：丢弃组合键中已按下的修饰键，并忽略随后松开的修饰键
+	if ev.Hotkey {
+		k.pendingMods = nil
+		k.suppressMods = true
+		return
+	}
+	modifier := isModifierKey(ev.Keycode)
+	if !modifier || ev.Kind == hook.KeyDown {
+		k.suppressMods = false
+	} else if k.suppressMods {
+		return
+	}
+
 	if !k.isRecording || k.isPaused {
 		return
 	}
 
-	// 计算相对时间戳
-	elapsed := time.Since(k.startTime) - k.pausedTime
-	timestamp := elapsed.Milliseconds()
+	// 计算相对时间戳（会话时钟）
+	timestamp := k.clock.Elapsed(ev.Time).Milliseconds()
 
 	// 确定事件类型
 	eventType := "key_down"
@@ -154,7 +171,7 @@ func (k *KeyboardHook) handleKeyEvent(ev hook.Event) {
 	}
 
 	// 获取修饰键
-	modifiers := k.getModifiers(ev)
+	modifiers := k.getModifiers(ev.Event)
 
 	// 获取按键名称
 	keyName := k.getKeyName(ev.Rawcode, ev.Keychar)
@@ -168,8 +185,27 @@ func (k *KeyboardHook) handleKeyEvent(ev hook.Event) {
 		EventType: eventType,
 	}
 
-	// 保存事件
+	// 修饰键按下先暂存，等组合键的其余按键确定不是快捷键后再记录
+	if modifier && ev.Kind == hook.KeyDown {
+		k.pendingMods = append(k.pendingMods, event)
+		return
+	}
+	k.flushPendingModifiers()
+	k.addEvent(event)
+}
+
+// flushPendingModifiers 记录暂存的修饰键按下事件
+func (k *KeyboardHook) flushPendingModifiers() {
+	for _, event := range k.pendingMods {
+		k.addEvent(event)
+	}
+	k.pendingMods = nil
+}
+
+// addEvent 保存事件并调用事件处理器
+func (k *KeyboardHook) addEvent(event KeyboardEvent) {
 	k.events = append(k.events, event)
+	k.events = trimEvents(k.events, k.retention, k.maxEvents, func(e KeyboardEvent) int64 { return e.Timestamp })
 
 	// 调用事件处理器（如果有）
 	if k.eventHandler != nil {
@@ -179,69 +215,79 @@ func (k *KeyboardHook) handleKeyEvent(ev hook.Event) {
 
 // getModifiers 获取修饰键
 func (k *KeyboardHook) getModifiers(ev hook.Event) []string {
-	modifiers := []string{}
+	return eventModifiers(ev)
+}
 
-	// 检查修饰键状态
-	// 注意：这里需要根据实际情况检测
-	// gohook 的 ev.Mask 包含修饰键信息
+// libuiohook 的修饰键掩码（iohook.h 中的 MASK_*，左右两侧各占一位）
+const (
+	maskShift = 1<<0 | 1<<4
+	maskCtrl  = 1<<1 | 1<<5
+	maskMeta  = 1<<2 | 1<<6
+	maskAlt   = 1<<3 | 1<<7
+)
 
-	if ev.Mask&hook.Ctrl != 0 {
+// eventModifiers 获取事件的修饰键（按 Ctrl、Shift、Alt、Win 的顺序）
+// gohook 的 ev.Mask 即 libuiohook 的修饰键掩码
+func eventModifiers(ev hook.Event) []string {
+	modifiers := []string{}
+
+	if ev.Mask&maskCtrl != 0 {
 		modifiers = append(modifiers, "Ctrl")
 	}
-	if ev.Mask&hook.Shift != 0 {
+	if ev.Mask&maskShift != 0 {
 		modifiers = append(modifiers, "Shift")
 	}
-	if ev.Mask&hook.Alt != 0 {
+	if ev.Mask&maskAlt != 0 {
 		modifiers = append(modifiers, "Alt")
 	}
-	if ev.Mask&hook.Cmd != 0 {
+	if ev.Mask&maskMeta != 0 {
 		modifiers = append(modifiers, "Win")
 	}
 
 	return modifiers
 }
 
+// specialKeyNames 特殊键的虚拟键码和名称
+var specialKeyNames = map[uint16]string{
+	8:   "Backspace",
+	9:   "Tab",
+	13:  "Enter",
+	27:  "Esc",
+	32:  "Space",
+	33:  "PageUp",
+	34:  "PageDown",
+	35:  "End",
+	36:  "Home",
+	37:  "Left",
+	38:  "Up",
+	39:  "Right",
+	40:  "Down",
+	45:  "Insert",
+	46:  "Delete",
+	112: "F1",
+	113: "F2",
+	114: "F3",
+	115: "F4",
+	116: "F5",
+	117: "F6",
+	118: "F7",
+	119: "F8",
+	120: "F9",
+	121: "F10",
+	122: "F11",
+	123: "F12",
+	160: "LShift",
+	161: "RShift",
+	162: "LCtrl",
+	163: "RCtrl",
+	164: "LAlt",
+	165: "RAlt",
+}
+
 // getKeyName 获取按键名称
 func (k *KeyboardHook) getKeyName(rawcode uint16, keychar rune) string {
-	// 特殊键映射
-	specialKeys := map[uint16]string{
-		8:   "Backspace",
-		9:   "Tab",
-		13:  "Enter",
-		27:  "Esc",
-		32:  "Space",
-		33:  "PageUp",
-		34:  "PageDown",
-		35:  "End",
-		36:  "Home",
-		37:  "Left",
-		38:  "Up",
-		39:  "Right",
-		40:  "Down",
-		45:  "Insert",
-		46:  "Delete",
-		112: "F1",
-		113: "F2",
-		114: "F3",
-		115: "F4",
-		116: "F5",
-		117: "F6",
-		118: "F7",
-		119: "F8",
-		120: "F9",
-		121: "F10",
-		122: "F11",
-		123: "F12",
-		160: "LShift",
-		161: "RShift",
-		162: "LCtrl",
-		163: "RCtrl",
-		164: "LAlt",
-		165: "RAlt",
-	}
-
 	// 检查是否是特殊键
-	if name, ok := specialKeys[rawcode]; ok {
+	if name, ok := specialKeyNames[rawcode]; ok {
 		return name
 	}
 
@@ -275,7 +321,7 @@ func (k *KeyboardHook) SaveToFile(filename string) error {
 		return fmt.Errorf("序列化键盘事件失败: %w", err)
 	}
 
-	if err := saveToFile(filename, data); err != nil {
+	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("保存键盘事件失败: %w", err)
 	}
 
@@ -288,7 +334,7 @@ func (k *KeyboardHook) LoadFromFile(filename string) error {
 	k.eventsMu.Lock()
 	defer k.eventsMu.Unlock()
 
-	data, err := readFromFile(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("读取文件失败: %w", err)
 	}
@@ -310,6 +356,20 @@ func (k *KeyboardHook) SetEventHandler(handler func(KeyboardEvent)) {
 	k.eventHandler = handler
 }
 
+// SetRetention 设置事件保留窗口（回放缓冲使用），window 和 maxEvents 为 0 表示不限制
+func (k *KeyboardHook) SetRetention(window time.Duration, maxEvents int) {
+	k.eventsMu.Lock()
+	defer k.eventsMu.Unlock()
+	k.retention = window
+	k.maxEvents = maxEvents
+	k.events = trimEvents(k.events, k.retention, k.maxEvents, func(e KeyboardEvent) int64 { return e.Timestamp })
+}
+
+// GetStartTime 获取事件时间戳 0 对应的时刻（已扣除暂停时长）
+func (k *KeyboardHook) GetStartTime() time.Time {
+	return k.clock.Origin()
+}
+
 // GetLastEvent 获取最后一个事件
 func (k *KeyboardHook) GetLastEvent() *KeyboardEvent {
 	k.eventsMu.Lock()
@@ -0,0 +1,25 @@
+package hook
+
+import (
+	"time"
+)
+
+// trimEvents 按保留窗口和数量上限丢弃最旧的事件
+// 只移动切片起点，下次扩容时旧事件随底层数组一起释放
+func trimEvents[T any](events []T, window time.Duration, maxEvents int, timestamp func(T) int64) []T {
+	if len(events) == 0 {
+		return events
+	}
+	if maxEvents > 0 && len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	if window > 0 {
+		cutoff := timestamp(events[len(events)-1]) - window.Milliseconds()
+		drop := 0
+		for drop < len(events) && timestamp(events[drop]) < cutoff {
+			drop++
+		}
+		events = events[drop:]
+	}
+	return events
+}
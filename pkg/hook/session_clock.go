@@ -0,0 +1,102 @@
+package hook
+
+import (
+	"SmoothScreen/pkg/clock"
+	"sync"
+	"time"
+)
+
+// SessionClock 录制会话时钟
+// 鼠标和键盘录制共用同一个起点和同样的暂停区间，两者的时间戳可以直接对齐。
+// 第一个参与者加入时开始新的会话，最后一个参与者离开时会话结束。
+// 起点和暂停都记录在共享时钟（clock.Default）上，与音视频的流时间（StreamTiming）处于同一时间轴
+type SessionClock struct {
+	mu           sync.Mutex
+	base         *clock.Clock // 时间基准，nil 时使用 clock.Default
+	participants int
+	start        time.Duration // 会话起点（相对共享时钟）
+	pausedTime   time.Duration // 已结束的暂停总时长
+	pauseStart   time.Duration // 当前暂停的起点（相对共享时钟）
+	paused       bool
+}
+
+// timeBase 返回会话使用的共享时钟
+func (c *SessionClock) timeBase() *clock.Clock {
+	if c.base == nil {
+		return clock.Default
+	}
+	return c.base
+}
+
+// offset 返回 at 在共享时钟上的位置
+func (c *SessionClock) offset(at time.Time) time.Duration {
+	return c.timeBase().Offset(at)
+}
+
+// Join 加入会话（没有进行中的会话时以 at 为起点开始新的会话）
+func (c *SessionClock) Join(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.participants == 0 {
+		c.start = c.offset(at)
+		c.pausedTime = 0
+		c.paused = false
+	}
+	c.participants++
+}
+
+// Leave 离开会话
+func (c *SessionClock) Leave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.participants > 0 {
+		c.participants--
+	}
+}
+
+// Pause 从 at 起暂停（已暂停时忽略，鼠标和键盘可以各自调用）
+func (c *SessionClock) Pause(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.participants == 0 || c.paused {
+		return
+	}
+	c.paused = true
+	c.pauseStart = c.offset(at)
+}
+
+// Resume 在 at 恢复（未暂停时忽略）
+func (c *SessionClock) Resume(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.pausedTime += c.offset(at) - c.pauseStart
+	c.paused = false
+}
+
+// Paused 会话是否处于暂停中
+func (c *SessionClock) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Elapsed 返回 at 时刻的会话时间（已扣除暂停；暂停中停在暂停时刻）
+func (c *SessionClock) Elapsed(at time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.offset(at)
+	if c.paused && now > c.pauseStart {
+		now = c.pauseStart
+	}
+	return now - c.start - c.pausedTime
+}
+
+// Origin 返回会话时间 0 对应的时刻（已扣除结束的暂停）
+func (c *SessionClock) Origin() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timeBase().Origin().Add(c.start + c.pausedTime)
+}
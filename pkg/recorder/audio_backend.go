@@ -0,0 +1,114 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"time"
+)
+
+// AudioDeviceKind 音频设备类型
+type AudioDeviceKind string
+
+const (
+	AudioDeviceInput   AudioDeviceKind = "input"   // 输入设备（麦克风、线路输入）
+	AudioDeviceMonitor AudioDeviceKind = "monitor" // 系统音频（输出设备的监听源、立体声混音）
+)
+
+// AudioDevice 音频设备
+type AudioDevice struct {
+	ID      string          `json:"id"`      // 设备标识，直接作为 FFmpeg 的 -i 参数
+	Name    string          `json:"name"`    // 显示名称
+	Kind    AudioDeviceKind `json:"kind"`    // 设备类型
+	Default bool            `json:"default"` // 是否为该类型的默认设备
+	Backend string          `json:"backend"` // 所属后端（dshow、pulse、alsa）
+}
+
+// AudioBackend 音频采集后端
+// Windows 使用 DirectShow；Linux 优先使用 PulseAudio（PipeWire 通过 pipewire-pulse 兼容），其次 ALSA
+type AudioBackend interface {
+	// Name 返回后端名称，同时也是 FFmpeg 的输入格式（-f）
+	Name() string
+	// ListDevices 枚举可用的音频设备
+	ListDevices() ([]AudioDevice, error)
+}
+
+// audioToolTimeout 调用 pactl、arecord 等枚举工具的超时
+const audioToolTimeout = 5 * time.Second
+
+// NewAudioBackend 根据当前系统选择音频后端
+func NewAudioBackend(ffmpegPath string) (AudioBackend, error) {
+	switch goruntime.GOOS {
+	case "windows":
+		return &dshowBackend{ffmpegPath: ffmpegPath}, nil
+	case "linux":
+		if pulseAvailable() {
+			return &pulseBackend{}, nil
+		}
+		return &alsaBackend{}, nil
+	default:
+		return nil, fmt.Errorf("不支持在 %s 上录制音频", goruntime.GOOS)
+	}
+}
+
+// ResolveAudioDevice 按 ID 查找设备；id 为空时返回该类型的默认设备
+func ResolveAudioDevice(backend AudioBackend, id string, kind AudioDeviceKind) (AudioDevice, error) {
+	devices, err := backend.ListDevices()
+	if err != nil {
+		return AudioDevice{}, fmt.Errorf("枚举音频设备失败: %w", err)
+	}
+
+	if id != "" {
+		for _, device := range devices {
+			if device.ID == id {
+				return device, nil
+			}
+		}
+		return AudioDevice{}, fmt.Errorf("未找到音频设备: %s", id)
+	}
+
+	// 没有标记默认设备时使用该类型的第一个设备
+	var first *AudioDevice
+	for i, device := range devices {
+		if device.Kind != kind {
+			continue
+		}
+		if device.Default {
+			return device, nil
+		}
+		if first == nil {
+			first = &devices[i]
+		}
+	}
+	if first != nil {
+		return *first, nil
+	}
+
+	if kind == AudioDeviceMonitor {
+		return AudioDevice{}, fmt.Errorf("%s 后端没有可用的系统音频设备", backend.Name())
+	}
+	return AudioDevice{}, fmt.Errorf("%s 后端没有可用的音频输入设备", backend.Name())
+}
+
+// audioInputOptions 返回采集设备的 FFmpeg 输入选项
+func audioInputOptions(backend AudioBackend) []ffmpeg.Option {
+	return []ffmpeg.Option{ffmpeg.Opt("f", backend.Name())}
+}
+
+// runAudioTool 运行设备枚举工具并返回 stdout
+// pactl、arecord 的输出会按用户的语言翻译（如 "Source #0" 变成 "信源 #0"），固定使用 C 语言环境以便解析
+func runAudioTool(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), audioToolTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("运行 %s 失败: %w", name, err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,58 @@
+package recorder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// alsaBackend ALSA 音频后端（没有 PulseAudio/PipeWire 的 Linux 系统）
+// ALSA 没有通用的系统音频监听源，只能录制输入设备
+type alsaBackend struct{}
+
+// alsaCardPattern 匹配 arecord -l 的设备行
+// card 0: PCH [HDA Intel PCH], device 0: ALC3246 Analog [ALC3246 Analog]
+var alsaCardPattern = regexp.MustCompile(`^card (\d+): [^\[]*\[([^\]]*)\], device (\d+): [^\[]*\[([^\]]*)\]`)
+
+// Name 返回后端名称
+func (b *alsaBackend) Name() string {
+	return "alsa"
+}
+
+// ListDevices 使用 arecord -l 枚举录音设备
+// 始终包含 "default" 设备（由 ALSA 配置决定实际设备）
+func (b *alsaBackend) ListDevices() ([]AudioDevice, error) {
+	devices := []AudioDevice{{
+		ID:      "default",
+		Name:    "默认设备",
+		Kind:    AudioDeviceInput,
+		Default: true,
+		Backend: "alsa",
+	}}
+
+	output, err := runAudioTool("arecord", "-l")
+	if err != nil {
+		// 没有安装 alsa-utils 时仍然可以使用默认设备
+		fmt.Printf("警告: 枚举 ALSA 设备失败: %v\n", err)
+		return devices, nil
+	}
+	return append(devices, parseArecordDevices(output)...), nil
+}
+
+// parseArecordDevices 解析 arecord -l 的输出
+func parseArecordDevices(output string) []AudioDevice {
+	devices := []AudioDevice{}
+	for _, line := range strings.Split(output, "\n") {
+		match := alsaCardPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		devices = append(devices, AudioDevice{
+			ID:      fmt.Sprintf("hw:%s,%s", match[1], match[3]),
+			Name:    fmt.Sprintf("%s - %s", match[2], match[4]),
+			Kind:    AudioDeviceInput,
+			Backend: "alsa",
+		})
+	}
+	return devices
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"regexp"
+	"strings"
+)
+
+// dshowBackend Windows DirectShow 音频后端
+type dshowBackend struct {
+	ffmpegPath string
+}
+
+// dshowLoopbackNames 常见的系统音频（立体声混音）设备名称关键字
+var dshowLoopbackNames = []string{
+	"stereo mix",
+	"立体声混音",
+	"what u hear",
+	"wave out mix",
+	"loopback",
+	"virtual-audio-capturer",
+}
+
+// dshowDevicePattern 匹配设备行
+// FFmpeg 5+: [dshow @ 000001] "Microphone (Realtek Audio)" (audio)
+// 旧版本:     [dshow @ 000001]  "Microphone (Realtek Audio)"（类型由前面的分组标题决定）
+var dshowDevicePattern = regexp.MustCompile(`^\[dshow @ [^\]]+\]\s+"([^"]+)"(?:\s+\((audio|video|none)\))?`)
+
+// Name 返回后端名称
+func (b *dshowBackend) Name() string {
+	return "dshow"
+}
+
+// ListDevices 使用 ffmpeg -list_devices 枚举 DirectShow 音频设备
+// DirectShow 没有默认设备的概念，第一个输入设备和第一个立体声混音设备被视为默认
+func (b *dshowBackend) ListDevices() ([]AudioDevice, error) {
+	return parseDshowDevices(listDshowDevices(b.ffmpegPath)), nil
+}
+
+// dshowEntry -list_devices 输出中的一个设备
+type dshowEntry struct {
+	name       string
+	deviceType string // audio 或 video
+}
+
+// parseDshowDevices 解析 -list_devices 输出中的音频设备
+func parseDshowDevices(output string) []AudioDevice {
+	devices := []AudioDevice{}
+	hasDefault := map[AudioDeviceKind]bool{}
+
+	for _, entry := range parseDshowEntries(output) {
+		if entry.deviceType != "audio" {
+			continue
+		}
+
+		kind := AudioDeviceInput
+		if isDshowLoopback(entry.name) {
+			kind = AudioDeviceMonitor
+		}
+
+		devices = append(devices, AudioDevice{
+			ID:      "audio=" + entry.name,
+			Name:    entry.name,
+			Kind:    kind,
+			Default: !hasDefault[kind],
+			Backend: "dshow",
+		})
+		hasDefault[kind] = true
+	}
+	return devices
+}
+
+// parseDshowEntries 解析 -list_devices 输出中的所有设备及其类型
+func parseDshowEntries(output string) []dshowEntry {
+	entries := []dshowEntry{}
+	section := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+
+		// 旧版本的分组标题
+		if strings.Contains(lower, "directshow video devices") {
+			section = "video"
+			continue
+		}
+		if strings.Contains(lower, "directshow audio devices") {
+			section = "audio"
+			continue
+		}
+
+		match := dshowDevicePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		deviceType := match[2]
+		if deviceType == "" {
+			deviceType = section
+		}
+		entries = append(entries, dshowEntry{name: match[1], deviceType: deviceType})
+	}
+	return entries
+}
+
+// listDshowDevices 运行 ffmpeg -list_devices 并返回输出
+// 设备列表输出在 stderr，且 "dummy" 输入总是失败，忽略退出结果
+func listDshowDevices(ffmpegPath string) string {
+	args := []string{"-hide_banner", "-list_devices", "true", "-f", "dshow", "-i", "dummy"}
+	process := ffmpeg.NewProcess(ffmpegPath, args, ffmpeg.ProcessOptions{Name: "列出 DirectShow 设备", Quiet: true})
+	process.Run(context.Background())
+	return process.Stderr()
+}
+
+// isDshowLoopback 检查设备是否为系统音频（立体声混音）
+func isDshowLoopback(name string) bool {
+	lower := strings.ToLower(name)
+	for _, keyword := range dshowLoopbackNames {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
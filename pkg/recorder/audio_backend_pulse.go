@@ -0,0 +1,118 @@
+package recorder
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// pulseBackend PulseAudio 音频后端
+// PipeWire 通过 pipewire-pulse 提供相同的接口，FFmpeg 的 -f pulse 和 pactl 都可以直接使用
+// 系统音频通过输出设备的监听源（<sink>.monitor）录制
+type pulseBackend struct{}
+
+// Name 返回后端名称
+func (b *pulseBackend) Name() string {
+	return "pulse"
+}
+
+// pulseAvailable 检查 PulseAudio（或 pipewire-pulse）服务是否可用
+func pulseAvailable() bool {
+	if _, err := exec.LookPath("pactl"); err != nil {
+		return false
+	}
+	_, err := runAudioTool("pactl", "info")
+	return err == nil
+}
+
+// ListDevices 使用 pactl 枚举音频源
+func (b *pulseBackend) ListDevices() ([]AudioDevice, error) {
+	info, err := runAudioTool("pactl", "info")
+	if err != nil {
+		return nil, err
+	}
+	sources, err := runAudioTool("pactl", "list", "sources")
+	if err != nil {
+		return nil, err
+	}
+
+	defaultSource, defaultSink := parsePactlDefaults(info)
+	return parsePactlSources(sources, defaultSource, defaultSink), nil
+}
+
+// parsePactlDefaults 解析 pactl info 中的默认输入和输出设备
+func parsePactlDefaults(info string) (source, sink string) {
+	for _, line := range strings.Split(info, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Default Source":
+			source = strings.TrimSpace(value)
+		case "Default Sink":
+			sink = strings.TrimSpace(value)
+		}
+	}
+	return source, sink
+}
+
+// parsePactlSources 解析 pactl list sources 的输出
+// 每个源以 "Source #N" 开头，包含 Name、Description、Monitor of Sink 等字段
+func parsePactlSources(output, defaultSource, defaultSink string) []AudioDevice {
+	devices := []AudioDevice{}
+
+	var current *AudioDevice
+	flush := func() {
+		if current != nil && current.ID != "" {
+			devices = append(devices, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "Source #") {
+			flush()
+			current = &AudioDevice{Kind: AudioDeviceInput, Backend: "pulse"}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Name":
+			current.ID = value
+		case "Description":
+			current.Name = value
+		case "Monitor of Sink":
+			if value != "n/a" && value != "" {
+				current.Kind = AudioDeviceMonitor
+			}
+		}
+	}
+	flush()
+
+	for i := range devices {
+		device := &devices[i]
+		if device.Name == "" {
+			device.Name = device.ID
+		}
+		// 旧版本的 pactl 没有 Monitor of Sink 字段，按名称判断
+		if strings.HasSuffix(device.ID, ".monitor") {
+			device.Kind = AudioDeviceMonitor
+		}
+		switch device.Kind {
+		case AudioDeviceMonitor:
+			device.Default = defaultSink != "" && device.ID == defaultSink+".monitor"
+		case AudioDeviceInput:
+			device.Default = device.ID == defaultSource
+		}
+	}
+	return devices
+}
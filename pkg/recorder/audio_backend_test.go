@@ -0,0 +1,255 @@
+package recorder
+
+import (
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"testing"
+)
+
+// pactlInfo pactl info 的输出（PipeWire）
+const pactlInfo = `Server String: /run/user/1000/pulse/native
+Library Protocol Version: 35
+Server Protocol Version: 35
+Is Local: yes
+Server Name: PulseAudio (on PipeWire 1.0.5)
+Default Sink: alsa_output.pci-0000_00_1f.3.analog-stereo
+Default Source: alsa_input.pci-0000_00_1f.3.analog-stereo
+Cookie: 5a1b:9c2d
+`
+
+// pactlSources pactl list sources 的输出（节选）
+// 包含默认输出的监听源、HDMI 的监听源、内置麦克风和没有 Monitor of Sink 字段的旧版本 USB 麦克风
+const pactlSources = `Source #45
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+	Description: Monitor of Built-in Audio Analog Stereo
+	Driver: PipeWire
+	Sample Specification: s32le 2ch 48000Hz
+	Volume: front-left: 65536 / 100% / 0.00 dB,   front-right: 65536 / 100% / 0.00 dB
+	        balance 0.00
+	Monitor of Sink: alsa_output.pci-0000_00_1f.3.analog-stereo
+	Properties:
+		api.alsa.path = "front:0"
+		device.description = "Built-in Audio Analog Stereo"
+Source #46
+	State: RUNNING
+	Name: alsa_input.pci-0000_00_1f.3.analog-stereo
+	Description: Built-in Audio Analog Stereo
+	Monitor of Sink: n/a
+Source #47
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_01_00.1.hdmi-stereo.monitor
+	Description: Monitor of HDMI Audio
+	Monitor of Sink: alsa_output.pci-0000_01_00.1.hdmi-stereo
+Source #48
+	State: SUSPENDED
+	Name: alsa_input.usb-Blue_Yeti-00.analog-stereo
+`
+
+// pactlInfoGerman、pactlSourcesGerman 德语环境下的输出：字段名被翻译，冒号仍是 ASCII
+const pactlInfoGerman = `Server-Zeichenkette: /run/user/1000/pulse/native
+Standard-Ziel: alsa_output.pci-0000_00_1f.3.analog-stereo
+Standard-Quelle: alsa_input.pci-0000_00_1f.3.analog-stereo
+`
+
+const pactlSourcesGerman = `Quelle #46
+	Status: RUNNING
+	Name: alsa_input.pci-0000_00_1f.3.analog-stereo
+	Beschreibung: Built-in Audio Analog Stereo
+	Monitor von Ziel: n/a
+`
+
+func TestParsePactlDefaults(t *testing.T) {
+	tests := []struct {
+		name         string
+		info         string
+		source, sink string
+	}{
+		{"英文", pactlInfo, "alsa_input.pci-0000_00_1f.3.analog-stereo", "alsa_output.pci-0000_00_1f.3.analog-stereo"},
+		{"翻译后的字段名", pactlInfoGerman, "", ""},
+		{"空输出", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, sink := parsePactlDefaults(tt.info)
+			if source != tt.source || sink != tt.sink {
+				t.Errorf("parsePactlDefaults = (%q, %q), want (%q, %q)", source, sink, tt.source, tt.sink)
+			}
+		})
+	}
+}
+
+func TestParsePactlSources(t *testing.T) {
+	source, sink := parsePactlDefaults(pactlInfo)
+	got := parsePactlSources(pactlSources, source, sink)
+	want := []AudioDevice{
+		{
+			ID:      "alsa_output.pci-0000_00_1f.3.analog-stereo.monitor",
+			Name:    "Monitor of Built-in Audio Analog Stereo",
+			Kind:    AudioDeviceMonitor,
+			Default: true,
+			Backend: "pulse",
+		},
+		{
+			ID:      "alsa_input.pci-0000_00_1f.3.analog-stereo",
+			Name:    "Built-in Audio Analog Stereo",
+			Kind:    AudioDeviceInput,
+			Default: true,
+			Backend: "pulse",
+		},
+		{
+			ID:      "alsa_output.pci-0000_01_00.1.hdmi-stereo.monitor",
+			Name:    "Monitor of HDMI Audio",
+			Kind:    AudioDeviceMonitor,
+			Backend: "pulse",
+		},
+		{
+			// 没有 Description 时使用 Name
+			ID:      "alsa_input.usb-Blue_Yeti-00.analog-stereo",
+			Name:    "alsa_input.usb-Blue_Yeti-00.analog-stereo",
+			Kind:    AudioDeviceInput,
+			Backend: "pulse",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePactlSources =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// 旧版本 pactl 没有 Monitor of Sink 字段，按 .monitor 后缀识别系统音频
+	legacy := "Source #0\n\tName: alsa_output.pci.analog-stereo.monitor\n\tDescription: Monitor\n"
+	if devices := parsePactlSources(legacy, "", "alsa_output.pci.analog-stereo"); len(devices) != 1 || devices[0].Kind != AudioDeviceMonitor || !devices[0].Default {
+		t.Errorf("旧版本输出 = %+v", devices)
+	}
+
+	// 翻译后的输出不能解析出残缺的设备（runAudioTool 固定 LC_ALL=C 避免这种情况）
+	if devices := parsePactlSources(pactlSourcesGerman, "", ""); len(devices) != 0 {
+		t.Errorf("翻译后的输出 = %+v", devices)
+	}
+	if devices := parsePactlSources("", "", ""); devices == nil || len(devices) != 0 {
+		t.Errorf("空输出 = %#v, want 空列表", devices)
+	}
+}
+
+func TestParseArecordDevices(t *testing.T) {
+	output := `**** List of CAPTURE Hardware Devices ****
+card 0: PCH [HDA Intel PCH], device 0: ALC3246 Analog [ALC3246 Analog]
+  Subdevices: 1/1
+  Subdevice #0: subdevice #0
+card 2: Yeti [Yeti Stereo Microphone], device 0: USB Audio [USB Audio]
+  Subdevices: 1/1
+  Subdevice #0: subdevice #0
+`
+	want := []AudioDevice{
+		{ID: "hw:0,0", Name: "HDA Intel PCH - ALC3246 Analog", Kind: AudioDeviceInput, Backend: "alsa"},
+		{ID: "hw:2,0", Name: "Yeti Stereo Microphone - USB Audio", Kind: AudioDeviceInput, Backend: "alsa"},
+	}
+	if got := parseArecordDevices(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseArecordDevices =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// 德语环境下 card/device 被翻译为 Karte/Gerät
+	german := "**** Liste der Hardware-Geräte (CAPTURE) ****\nKarte 0: PCH [HDA Intel PCH], Gerät 0: ALC3246 Analog [ALC3246 Analog]\n"
+	if got := parseArecordDevices(german); len(got) != 0 {
+		t.Errorf("翻译后的输出 = %+v", got)
+	}
+	// 没有声卡时 arecord 只在 stderr 输出错误，stdout 为空
+	if got := parseArecordDevices(""); got == nil || len(got) != 0 {
+		t.Errorf("空输出 = %#v, want 空列表", got)
+	}
+}
+
+func TestParseDshowDevices(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []AudioDevice
+	}{
+		{
+			// FFmpeg 5+ 在设备名后标注类型，Alternative name 行不是设备
+			name: "FFmpeg 5+",
+			output: "[dshow @ 000001c8b7e0e940] \"Integrated Camera\" (video)\r\n" +
+				"[dshow @ 000001c8b7e0e940]   Alternative name \"@device_pnp_\\\\?\\usb#vid_04f2\"\r\n" +
+				"[dshow @ 000001c8b7e0e940] \"Microphone Array (Realtek(R) Audio)\" (audio)\r\n" +
+				"[dshow @ 000001c8b7e0e940]   Alternative name \"@device_cm_{33D9A762-90C8-11D0-BD43-00A0C911CE86}\\wave_{1}\"\r\n" +
+				"[dshow @ 000001c8b7e0e940] \"Stereo Mix (Realtek(R) Audio)\" (audio)\r\n" +
+				"[dshow @ 000001c8b7e0e940] \"Headset Microphone (USB Audio)\" (audio)\r\n" +
+				"[dshow @ 000001c8b7e0e940] \"OBS Virtual Camera\" (none)\r\n" +
+				"dummy: Immediate exit requested\r\n",
+			want: []AudioDevice{
+				{ID: "audio=Microphone Array (Realtek(R) Audio)", Name: "Microphone Array (Realtek(R) Audio)", Kind: AudioDeviceInput, Default: true, Backend: "dshow"},
+				{ID: "audio=Stereo Mix (Realtek(R) Audio)", Name: "Stereo Mix (Realtek(R) Audio)", Kind: AudioDeviceMonitor, Default: true, Backend: "dshow"},
+				{ID: "audio=Headset Microphone (USB Audio)", Name: "Headset Microphone (USB Audio)", Kind: AudioDeviceInput, Backend: "dshow"},
+			},
+		},
+		{
+			// 旧版本按分组标题区分类型；中文系统上设备名是中文
+			name: "旧版本中文系统",
+			output: "[dshow @ 0000000000354a80] DirectShow video devices (some may be both video and audio devices)\n" +
+				"[dshow @ 0000000000354a80]  \"Integrated Camera\"\n" +
+				"[dshow @ 0000000000354a80] DirectShow audio devices\n" +
+				"[dshow @ 0000000000354a80]  \"麦克风 (Realtek High Definition Audio)\"\n" +
+				"[dshow @ 0000000000354a80]     Alternative name \"@device_cm_{33D9A762}\\wave_{2}\"\n" +
+				"[dshow @ 0000000000354a80]  \"立体声混音 (Realtek High Definition Audio)\"\n",
+			want: []AudioDevice{
+				{ID: "audio=麦克风 (Realtek High Definition Audio)", Name: "麦克风 (Realtek High Definition Audio)", Kind: AudioDeviceInput, Default: true, Backend: "dshow"},
+				{ID: "audio=立体声混音 (Realtek High Definition Audio)", Name: "立体声混音 (Realtek High Definition Audio)", Kind: AudioDeviceMonitor, Default: true, Backend: "dshow"},
+			},
+		},
+		{
+			// FFmpeg 不是 Windows 构建或没有 dshow 时只有错误信息
+			name:   "没有 dshow",
+			output: "Unknown input format: 'dshow'\n",
+			want:   []AudioDevice{},
+		},
+		{name: "空输出", output: "", want: []AudioDevice{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDshowDevices(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDshowDevices =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAudioBackend 返回固定设备列表的音频后端
+type fakeAudioBackend struct {
+	devices []AudioDevice
+}
+
+func (b *fakeAudioBackend) Name() string { return "fake" }
+
+func (b *fakeAudioBackend) ListDevices() ([]AudioDevice, error) { return b.devices, nil }
+
+func TestResolveAudioDevice(t *testing.T) {
+	backend := &fakeAudioBackend{devices: parseArecordDevices("card 0: PCH [HDA Intel PCH], device 0: ALC3246 Analog [ALC3246 Analog]\n")}
+
+	// 没有标记默认设备时使用第一个输入设备
+	device, err := ResolveAudioDevice(backend, "", AudioDeviceInput)
+	if err != nil || device.ID != "hw:0,0" {
+		t.Errorf("默认输入设备 = %+v, %v", device, err)
+	}
+	// ALSA 没有系统音频设备，录制器据此只录制麦克风
+	if _, err := ResolveAudioDevice(backend, "", AudioDeviceMonitor); err == nil || !strings.Contains(err.Error(), "没有可用的系统音频设备") {
+		t.Errorf("系统音频设备 err = %v", err)
+	}
+	if _, err := ResolveAudioDevice(backend, "hw:9,0", AudioDeviceInput); err == nil {
+		t.Error("不存在的设备应返回错误")
+	}
+}
+
+func TestRunAudioToolLocale(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("枚举工具只在 Linux 上使用")
+	}
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	output, err := runAudioTool("sh", "-c", "echo $LC_ALL")
+	if err != nil {
+		t.Skipf("无法运行 sh: %v", err)
+	}
+	if strings.TrimSpace(output) != "C" {
+		t.Errorf("LC_ALL = %q, want C", strings.TrimSpace(output))
+	}
+}
@@ -0,0 +1,187 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	meterSampleRate = 8000                   // 电平计使用的采样率（只关心幅度，不需要音质）
+	meterInterval   = 100 * time.Millisecond // 电平刷新间隔（10 Hz）
+	meterFloorDB    = -96.0                  // 16 位 PCM 的动态范围下限，静音时报告该值
+
+	DefaultSilenceThresholdDB = -60.0           // 峰值低于该值视为没有信号
+	DefaultSilenceTimeout     = 5 * time.Second // 持续没有信号多久后发出警告
+)
+
+// AudioLevel 一条音频输入的实时电平
+type AudioLevel struct {
+	Track  string  `json:"track"`  // StreamSystemAudio 或 StreamMicAudio
+	Device string  `json:"device"` // 设备名称
+	PeakDB float64 `json:"peakDB"` // 峰值（dBFS）
+	RMSDB  float64 `json:"rmsDB"`  // 均方根（dBFS）
+	Time   int64   `json:"time"`   // 时间戳（毫秒）
+}
+
+// AudioSignalEvent 音频输入的信号状态变化
+// Silent 为 true 表示已持续 Duration 秒没有信号（静音、设备选错等），为 false 表示信号恢复
+type AudioSignalEvent struct {
+	Track    string  `json:"track"`
+	Device   string  `json:"device"`
+	Silent   bool    `json:"silent"`
+	Duration float64 `json:"duration"` // 无信号持续时间（秒）
+	Time     int64   `json:"time"`
+}
+
+// AudioMeter 音频电平计
+// 作为 io.Writer 接收 FFmpeg 输出的 8 kHz 单声道 s16le PCM，每 100ms 计算一次峰值和 RMS
+type AudioMeter struct {
+	track  string
+	device string
+
+	threshold float64       // 无信号阈值（dBFS）
+	timeout   time.Duration // 无信号警告时间
+
+	onLevel  func(AudioLevel)
+	onSignal func(AudioSignalEvent)
+
+	mu         sync.Mutex
+	pending    []byte // 不足一个采样的剩余字节
+	count      int    // 当前窗口的采样数
+	peak       int    // 当前窗口的峰值（绝对值）
+	sumSquares float64
+	level      AudioLevel
+	silentFor  time.Duration // 连续无信号时长（按采样数累计）
+	warned     bool
+}
+
+// NewAudioMeter 创建电平计
+func NewAudioMeter(track, device string, config AudioConfig, onLevel func(AudioLevel), onSignal func(AudioSignalEvent)) *AudioMeter {
+	threshold := config.SilenceThresholdDB
+	if threshold == 0 {
+		threshold = DefaultSilenceThresholdDB
+	}
+	timeout := config.SilenceTimeout
+	if timeout <= 0 {
+		timeout = DefaultSilenceTimeout
+	}
+
+	return &AudioMeter{
+		track:     track,
+		device:    device,
+		threshold: threshold,
+		timeout:   timeout,
+		onLevel:   onLevel,
+		onSignal:  onSignal,
+		level:     AudioLevel{Track: track, Device: device, PeakDB: meterFloorDB, RMSDB: meterFloorDB},
+	}
+}
+
+// Write 实现 io.Writer
+func (m *AudioMeter) Write(data []byte) (int, error) {
+	m.mu.Lock()
+	m.pending = append(m.pending, data...)
+	samplesPerWindow := int(meterSampleRate * meterInterval / time.Second)
+
+	levels := []AudioLevel{}
+	signals := []AudioSignalEvent{}
+	for len(m.pending) >= 2 {
+		sample := int(int16(binary.LittleEndian.Uint16(m.pending)))
+		m.pending = m.pending[2:]
+		if sample < 0 {
+			sample = -sample
+		}
+		if sample > m.peak {
+			m.peak = sample
+		}
+		m.sumSquares += float64(sample) * float64(sample)
+		m.count++
+
+		if m.count < samplesPerWindow {
+			continue
+		}
+		level, signal := m.flushWindowLocked()
+		levels = append(levels, level)
+		if signal != nil {
+			signals = append(signals, *signal)
+		}
+	}
+	// 复用底层数组，避免持续增长
+	m.pending = append(m.pending[:0], m.pending...)
+	m.mu.Unlock()
+
+	for _, level := range levels {
+		if m.onLevel != nil {
+			m.onLevel(level)
+		}
+	}
+	for _, signal := range signals {
+		if m.onSignal != nil {
+			m.onSignal(signal)
+		}
+	}
+	return len(data), nil
+}
+
+// flushWindowLocked 结束当前窗口，返回电平和信号状态变化（需持有 m.mu）
+func (m *AudioMeter) flushWindowLocked() (AudioLevel, *AudioSignalEvent) {
+	now := time.Now().UnixMilli()
+	m.level = AudioLevel{
+		Track:  m.track,
+		Device: m.device,
+		PeakDB: amplitudeToDB(float64(m.peak)),
+		RMSDB:  amplitudeToDB(math.Sqrt(m.sumSquares / float64(m.count))),
+		Time:   now,
+	}
+	m.count, m.peak, m.sumSquares = 0, 0, 0
+
+	var signal *AudioSignalEvent
+	if m.level.PeakDB < m.threshold {
+		m.silentFor += meterInterval
+		if !m.warned && m.silentFor >= m.timeout {
+			m.warned = true
+			signal = &AudioSignalEvent{Track: m.track, Device: m.device, Silent: true, Duration: m.silentFor.Seconds(), Time: now}
+		}
+	} else {
+		if m.warned {
+			signal = &AudioSignalEvent{Track: m.track, Device: m.device, Silent: false, Duration: m.silentFor.Seconds(), Time: now}
+		}
+		m.silentFor = 0
+		m.warned = false
+	}
+	return m.level, signal
+}
+
+// Level 返回最近一次电平
+func (m *AudioMeter) Level() AudioLevel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.level
+}
+
+// Silent 返回是否处于无信号警告状态
+func (m *AudioMeter) Silent() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.warned
+}
+
+// meterOutput 在录制命令中添加电平计输出：单声道 8 kHz s16le PCM 写到 stdout
+func meterOutput(command *ffmpeg.Command, audio ffmpeg.Pad) {
+	command.Output("pipe:1").
+		Map(audio).
+		Format("s16le").
+		AudioCodec("pcm_s16le").
+		With(ffmpeg.Opt("ac", 1), ffmpeg.Opt("ar", meterSampleRate))
+}
+
+// amplitudeToDB 把 16 位采样幅度转换为 dBFS
+func amplitudeToDB(amplitude float64) float64 {
+	if amplitude < 1 {
+		return meterFloorDB
+	}
+	return math.Max(meterFloorDB, 20*math.Log10(amplitude/32768))
+}
@@ -0,0 +1,143 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// samplesPerMeterWindow 每个电平窗口的采样数（8 kHz × 100ms）
+const samplesPerMeterWindow = 800
+
+// pcm 生成 s16le 单声道 PCM
+func pcm(samples ...int16) []byte {
+	data := make([]byte, 2*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(sample))
+	}
+	return data
+}
+
+// repeatSamples 重复 pattern 直到 n 个采样
+func repeatSamples(n int, pattern ...int16) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = pattern[i%len(pattern)]
+	}
+	return samples
+}
+
+// meterEvents 记录电平计的回调
+type meterEvents struct {
+	levels  []AudioLevel
+	signals []AudioSignalEvent
+}
+
+func newTestMeter(timeout time.Duration) (*AudioMeter, *meterEvents) {
+	events := &meterEvents{}
+	config := DefaultAudioConfig()
+	config.SilenceTimeout = timeout
+	meter := NewAudioMeter(StreamMicAudio, "测试麦克风", config,
+		func(level AudioLevel) { events.levels = append(events.levels, level) },
+		func(event AudioSignalEvent) { events.signals = append(events.signals, event) })
+	return meter, events
+}
+
+func TestAudioMeterSilence(t *testing.T) {
+	meter, events := newTestMeter(300 * time.Millisecond)
+
+	// 3 个窗口的静音达到 300ms 超时，只警告一次
+	meter.Write(pcm(repeatSamples(5*samplesPerMeterWindow, 0)...))
+	if len(events.levels) != 5 {
+		t.Fatalf("电平回调 %d 次, want 5", len(events.levels))
+	}
+	for _, level := range events.levels {
+		if level.PeakDB != meterFloorDB || level.RMSDB != meterFloorDB || level.Track != StreamMicAudio {
+			t.Errorf("静音电平 = %+v", level)
+		}
+	}
+	if len(events.signals) != 1 || !events.signals[0].Silent || events.signals[0].Duration != 0.3 {
+		t.Fatalf("信号事件 = %+v, want 一次 0.3 秒的无信号警告", events.signals)
+	}
+	if !meter.Silent() {
+		t.Error("Silent() = false")
+	}
+
+	// 低于阈值（-60 dBFS）的噪声仍视为没有信号
+	meter.Write(pcm(repeatSamples(samplesPerMeterWindow, 20, -20)...))
+	if len(events.signals) != 1 {
+		t.Errorf("噪声不应恢复信号: %+v", events.signals)
+	}
+
+	// 信号恢复时发出恢复事件并清零
+	meter.Write(pcm(repeatSamples(samplesPerMeterWindow, 1000, -1000)...))
+	if len(events.signals) != 2 || events.signals[1].Silent || events.signals[1].Duration != 0.6 {
+		t.Fatalf("恢复事件 = %+v", events.signals)
+	}
+	if meter.Silent() {
+		t.Error("信号恢复后 Silent() = true")
+	}
+}
+
+func TestAudioMeterFullScale(t *testing.T) {
+	meter, events := newTestMeter(0)
+
+	// 满幅方波：峰值和 RMS 都是 0 dBFS（-32768 的绝对值不能溢出）
+	meter.Write(pcm(repeatSamples(samplesPerMeterWindow, math.MinInt16, math.MaxInt16)...))
+	if len(events.levels) != 1 {
+		t.Fatalf("电平回调 %d 次, want 1", len(events.levels))
+	}
+	level := meter.Level()
+	if level.PeakDB != 0 || math.Abs(level.RMSDB) > 0.001 {
+		t.Errorf("满幅电平 = %+v, want 0 dBFS", level)
+	}
+
+	// 半幅正弦：峰值 -6 dBFS，RMS 比峰值低 3 dB
+	sine := make([]int16, samplesPerMeterWindow)
+	for i := range sine {
+		sine[i] = int16(16384 * math.Sin(2*math.Pi*1000*float64(i)/meterSampleRate))
+	}
+	meter.Write(pcm(sine...))
+	level = meter.Level()
+	if math.Abs(level.PeakDB+6.02) > 0.01 || math.Abs(level.RMSDB+9.03) > 0.01 {
+		t.Errorf("半幅正弦电平 = %+v, want 峰值 -6.02、RMS -9.03", level)
+	}
+	if len(events.signals) != 0 {
+		t.Errorf("有信号时不应发出事件: %+v", events.signals)
+	}
+}
+
+func TestAudioMeterWindowBoundary(t *testing.T) {
+	meter, events := newTestMeter(0)
+
+	// 第一个窗口静音，第二个窗口的第一个采样是满幅
+	data := pcm(append(repeatSamples(samplesPerMeterWindow, 0), repeatSamples(samplesPerMeterWindow, math.MaxInt16, 0)...)...)
+
+	// 在第一个窗口最后一个采样的中间切开，剩下的字节保留到下次写入
+	split := 2*samplesPerMeterWindow - 1
+	if n, err := meter.Write(data[:split]); n != split || err != nil {
+		t.Fatalf("Write = (%d, %v)", n, err)
+	}
+	if len(events.levels) != 0 {
+		t.Fatalf("不足一个窗口时不应报告电平: %+v", events.levels)
+	}
+
+	// 再写到第二个窗口中间的奇数位置
+	second := split + samplesPerMeterWindow + 1
+	meter.Write(data[split:second])
+	if len(events.levels) != 1 || events.levels[0].PeakDB != meterFloorDB {
+		t.Fatalf("第一个窗口 = %+v, want 一个静音窗口", events.levels)
+	}
+
+	meter.Write(data[second:])
+	if len(events.levels) != 2 {
+		t.Fatalf("电平回调 %d 次, want 2", len(events.levels))
+	}
+	if peak := events.levels[1].PeakDB; math.Abs(peak) > 0.001 {
+		t.Errorf("第二个窗口峰值 = %v, want 0 dBFS", peak)
+	}
+	if len(meter.pending) != 0 {
+		t.Errorf("剩余 %d 字节, want 0", len(meter.pending))
+	}
+}
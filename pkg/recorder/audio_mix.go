@@ -0,0 +1,256 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"fmt"
+	"os"
+)
+
+// AudioTrackConfig 单条音轨的混音参数
+type AudioTrackConfig struct {
+	GainDB float64 `json:"gainDB"` // 增益（dB），0 表示不调整
+	Muted  bool    `json:"muted"`  // 静音（不参与混音和输出）
+}
+
+// AudioMixConfig 混音配置
+// 录制结束合并 WAV 时应用增益、静音、降噪和闪避；封装进视频时再做响度标准化和编码
+type AudioMixConfig struct {
+	System AudioTrackConfig `json:"system"` // 系统音频
+	Mic    AudioTrackConfig `json:"mic"`    // 麦克风
+
+	// 闪避：麦克风有声音时自动压低系统音频
+	Ducking          bool    `json:"ducking"`
+	DuckingThreshold float64 `json:"duckingThreshold"` // 触发阈值（线性幅度 0-1）
+	DuckingRatio     float64 `json:"duckingRatio"`     // 压缩比
+	DuckingAttackMs  float64 `json:"duckingAttackMs"`  // 起效时间（毫秒）
+	DuckingReleaseMs float64 `json:"duckingReleaseMs"` // 恢复时间（毫秒）
+
+	// EBU R128 响度标准化
+	Normalize  bool    `json:"normalize"`
+	TargetLUFS float64 `json:"targetLUFS"` // 目标综合响度
+	TruePeak   float64 `json:"truePeak"`   // 真峰值上限（dBTP）
+	LRA        float64 `json:"lra"`        // 响度范围
+
+	// 麦克风降噪（afftdn）
+	NoiseSuppression bool `json:"noiseSuppression"`
+
+	// 系统音频和麦克风分别作为独立音轨写入输出（MKV/MP4 多音轨），便于后期重新混音
+	SeparateTracks bool `json:"separateTracks"`
+
+	AudioCodec   string `json:"audioCodec"`   // 输出音频编码器（默认 aac，"copy" 表示不重编码）
+	AudioBitrate string `json:"audioBitrate"` // 输出音频比特率（默认 192k）
+}
+
+// DefaultAudioMixConfig 默认混音配置（与之前的固定 amix + 192k AAC 行为一致）
+func DefaultAudioMixConfig() AudioMixConfig {
+	return AudioMixConfig{
+		DuckingThreshold: 0.03,
+		DuckingRatio:     8,
+		DuckingAttackMs:  20,
+		DuckingReleaseMs: 400,
+		TargetLUFS:       -16,
+		TruePeak:         -1.5,
+		LRA:              11,
+		AudioCodec:       "aac",
+		AudioBitrate:     "192k",
+	}
+}
+
+// defaultOutputSampleRate 会话中没有记录录制采样率时，响度标准化后使用的采样率
+const defaultOutputSampleRate = 48000
+
+// AudioTrack 参与混音或封装的一条音轨
+type AudioTrack struct {
+	Path       string  `json:"path"`
+	Kind       string  `json:"kind"`                 // StreamSystemAudio、StreamMicAudio 或 StreamAudio
+	Offset     float64 `json:"offset"`               // 相对视频的偏移（秒）
+	SampleRate int     `json:"sampleRate,omitempty"` // 录制采样率（Hz），0 表示未知
+}
+
+// trackPad 滤镜图中的一条音轨
+type trackPad struct {
+	pad  ffmpeg.Pad
+	kind string
+}
+
+// trackConfig 返回音轨对应的混音参数（合并后的音轨不再调整）
+func (c AudioMixConfig) trackConfig(kind string) AudioTrackConfig {
+	switch kind {
+	case StreamSystemAudio:
+		return c.System
+	case StreamMicAudio:
+		return c.Mic
+	}
+	return AudioTrackConfig{}
+}
+
+// mixTracks 对音轨应用静音、增益、降噪和闪避
+// separate 为 false 时用 amix 混合为一路；为 true 时每条音轨单独输出
+func mixTracks(graph *ffmpeg.FilterGraph, tracks []trackPad, config AudioMixConfig, separate bool) ([]ffmpeg.Pad, error) {
+	active := []trackPad{}
+	for _, track := range tracks {
+		trackConfig := config.trackConfig(track.kind)
+		if trackConfig.Muted {
+			continue
+		}
+
+		filters := []*ffmpeg.Filter{}
+		if trackConfig.GainDB != 0 {
+			filters = append(filters, ffmpeg.NewFilter("volume", fmt.Sprintf("%gdB", trackConfig.GainDB)))
+		}
+		if track.kind == StreamMicAudio && config.NoiseSuppression {
+			filters = append(filters, ffmpeg.NewFilter("afftdn"))
+		}
+		if len(filters) > 0 {
+			track.pad = graph.Chain([]ffmpeg.Pad{track.pad}, filters...)
+		}
+		active = append(active, track)
+	}
+	if len(active) == 0 {
+		return nil, fmt.Errorf("所有音轨都已静音")
+	}
+
+	if config.Ducking {
+		active = duckTracks(graph, active, config)
+	}
+
+	pads := make([]ffmpeg.Pad, len(active))
+	for i, track := range active {
+		pads[i] = track.pad
+	}
+	if separate || len(pads) == 1 {
+		return pads, nil
+	}
+
+	mixed := graph.Apply(ffmpeg.NewFilter("amix").
+		Set("inputs", len(pads)).
+		Set("duration", "longest").
+		Set("dropout_transition", 2), pads...)
+	return []ffmpeg.Pad{mixed}, nil
+}
+
+// duckTracks 用麦克风作为侧链压缩系统音频
+func duckTracks(graph *ffmpeg.FilterGraph, tracks []trackPad, config AudioMixConfig) []trackPad {
+	system, mic := -1, -1
+	for i, track := range tracks {
+		switch track.kind {
+		case StreamSystemAudio:
+			system = i
+		case StreamMicAudio:
+			mic = i
+		}
+	}
+	if system < 0 || mic < 0 {
+		return tracks
+	}
+
+	// 麦克风一路用于输出，一路作为侧链
+	split := graph.ChainN(2, []ffmpeg.Pad{tracks[mic].pad}, ffmpeg.NewFilter("asplit"))
+	tracks[mic].pad = split[0]
+	tracks[system].pad = graph.Apply(ffmpeg.NewFilter("sidechaincompress").
+		Set("threshold", config.DuckingThreshold).
+		Set("ratio", config.DuckingRatio).
+		Set("attack", config.DuckingAttackMs).
+		Set("release", config.DuckingReleaseMs), tracks[system].pad, split[1])
+	return tracks
+}
+
+// masterTrack 对最终输出的音轨做响度标准化
+// loudnorm 内部上采样到 192kHz，输出前重采样回录制采样率 sampleRate（0 时使用 48kHz）
+func masterTrack(graph *ffmpeg.FilterGraph, pad ffmpeg.Pad, config AudioMixConfig, sampleRate int) ffmpeg.Pad {
+	if !config.Normalize {
+		return pad
+	}
+	if sampleRate <= 0 {
+		sampleRate = defaultOutputSampleRate
+	}
+	return graph.Chain([]ffmpeg.Pad{pad},
+		ffmpeg.NewFilter("loudnorm").
+			Set("I", config.TargetLUFS).
+			Set("TP", config.TruePeak).
+			Set("LRA", config.LRA),
+		ffmpeg.NewFilter("aresample", sampleRate),
+	)
+}
+
+// FindAudioTracks 返回封装时使用的音轨
+// 要求分轨且会话中记录了合并前的系统音频和麦克风文件时返回各源文件，否则返回 audioPath 本身
+func FindAudioTracks(audioPath, videoPath string, config AudioMixConfig) []AudioTrack {
+	merged := AudioTrack{Path: audioPath, Kind: StreamAudio}
+	timing, offsets := findStreamTiming(audioPath, videoPath)
+	if timing != nil {
+		merged.Kind = timing.Kind
+		merged.Offset = timing.Offset
+		merged.SampleRate = timing.SampleRate
+	}
+	if !config.SeparateTracks || timing == nil || len(timing.Sources) < 2 {
+		return []AudioTrack{merged}
+	}
+
+	tracks := []AudioTrack{}
+	for _, source := range timing.Sources {
+		sourceTiming, ok := offsets[source]
+		if !ok || !fileExists(source) {
+			return []AudioTrack{merged}
+		}
+		tracks = append(tracks, AudioTrack{Path: source, Kind: sourceTiming.Kind, Offset: sourceTiming.Offset, SampleRate: sourceTiming.SampleRate})
+	}
+	return tracks
+}
+
+// AddAudioTracks 把音轨加入命令：对齐、混音、响度标准化后映射到输出并设置编码
+// 返回输出中的音轨数
+func AddAudioTracks(command *ffmpeg.Command, output *ffmpeg.Output, tracks []AudioTrack, config AudioMixConfig) (int, error) {
+	graph := command.FilterGraph()
+
+	pads := make([]trackPad, len(tracks))
+	filtered := false
+	sampleRate := 0
+	for i, track := range tracks {
+		if _, err := os.Stat(track.Path); err != nil {
+			return 0, fmt.Errorf("音频文件不可用: %w", err)
+		}
+		pad := command.Input(track.Path).Audio()
+		aligned := AlignAudio(command, pad, track.Offset)
+		if aligned != pad {
+			filtered = true
+			fmt.Printf("音频对齐偏移: %s %+.3f 秒\n", track.Path, track.Offset)
+		}
+		pads[i] = trackPad{pad: aligned, kind: track.Kind}
+		sampleRate = max(sampleRate, track.SampleRate)
+	}
+
+	// 合并后的单条音轨已经在录制结束时混过音，只做响度标准化
+	mixed := []ffmpeg.Pad{}
+	if len(pads) == 1 && pads[0].kind == StreamAudio {
+		mixed = append(mixed, pads[0].pad)
+	} else {
+		var err error
+		mixed, err = mixTracks(graph, pads, config, config.SeparateTracks)
+		if err != nil {
+			return 0, err
+		}
+		filtered = true
+	}
+
+	for _, pad := range mixed {
+		mastered := masterTrack(graph, pad, config, sampleRate)
+		if mastered != pad {
+			filtered = true
+		}
+		output.Map(mastered)
+	}
+
+	codec := config.AudioCodec
+	if codec == "" {
+		codec = "aac"
+	}
+	if codec == "copy" && filtered {
+		return 0, fmt.Errorf("音频需要对齐或混音处理，不能使用 copy 模式")
+	}
+	output.AudioCodec(codec)
+	if codec != "copy" && config.AudioBitrate != "" {
+		output.With(ffmpeg.Opt("b:a", config.AudioBitrate))
+	}
+	return len(mixed), nil
+}
@@ -0,0 +1,280 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// graphLabelPattern 匹配滤镜图中的流引用和滤镜输出标签
+var graphLabelPattern = regexp.MustCompile(`\[([^\]]+)\]`)
+
+// checkPadsUsedOnce 检查每个输入流和滤镜输出恰好被使用一次
+// 滤镜输出在滤镜图中出现一次，再被下一个滤镜或 -map 使用一次；输入流只被使用一次
+func checkPadsUsedOnce(t *testing.T, args []string) {
+	t.Helper()
+	counts := map[string]int{}
+	for i := 0; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-filter_complex":
+			for _, match := range graphLabelPattern.FindAllStringSubmatch(args[i+1], -1) {
+				counts[match[1]]++
+			}
+		case "-map":
+			counts[strings.Trim(args[i+1], "[]")]++
+		}
+	}
+	for label, count := range counts {
+		want := 2
+		if regexp.MustCompile(`^\d+:`).MatchString(label) {
+			want = 1
+		}
+		if count != want {
+			t.Errorf("[%s] 出现 %d 次, want %d", label, count, want)
+		}
+	}
+}
+
+// mixArgs 用两路输入（系统音频、麦克风）运行 mixTracks 并返回命令参数
+func mixArgs(t *testing.T, config AudioMixConfig, separate bool) []string {
+	t.Helper()
+	command := ffmpeg.NewCommand()
+	system := command.Input("system.wav").Audio()
+	mic := command.Input("mic.wav").Audio()
+	pads, err := mixTracks(command.FilterGraph(), []trackPad{
+		{pad: system, kind: StreamSystemAudio},
+		{pad: mic, kind: StreamMicAudio},
+	}, config, separate)
+	if err != nil {
+		t.Fatalf("mixTracks: %v", err)
+	}
+	command.Output("out.mka").Map(pads...)
+
+	args, err := command.Args()
+	if err != nil {
+		t.Fatalf("Args: %v", err)
+	}
+	checkPadsUsedOnce(t, args)
+	return args
+}
+
+func TestMixTracks(t *testing.T) {
+	defaults := DefaultAudioMixConfig()
+	gain := defaults
+	gain.System.GainDB = -6
+	gain.Mic.GainDB = 3.5
+	gain.NoiseSuppression = true
+	mutedSystem := defaults
+	mutedSystem.System.Muted = true
+	ducking := defaults
+	ducking.Ducking = true
+	duckingSeparate := ducking
+	duckingSeparate.NoiseSuppression = true
+
+	tests := []struct {
+		name     string
+		config   AudioMixConfig
+		separate bool
+		want     []string // -i 之后的参数
+	}{
+		{
+			name:   "默认",
+			config: defaults,
+			want: []string{"-filter_complex", "[0:a][1:a]amix=inputs=2:duration=longest:dropout_transition=2[amix0]",
+				"-map", "[amix0]"},
+		},
+		{
+			// 降噪只作用于麦克风
+			name:   "增益和降噪",
+			config: gain,
+			want: []string{"-filter_complex", "[0:a]volume=-6dB[volume0];[1:a]volume=3.5dB,afftdn[afftdn0];" +
+				"[volume0][afftdn0]amix=inputs=2:duration=longest:dropout_transition=2[amix0]",
+				"-map", "[amix0]"},
+		},
+		{
+			// 只剩一路时不需要 amix
+			name:   "系统音频静音",
+			config: mutedSystem,
+			want:   []string{"-map", "1:a"},
+		},
+		{
+			// 麦克风复制为两路：一路输出，一路作为系统音频的侧链
+			name:   "闪避",
+			config: ducking,
+			want: []string{"-filter_complex", "[1:a]asplit[asplit0][asplit1];" +
+				"[0:a][asplit1]sidechaincompress=threshold=0.03:ratio=8:attack=20:release=400[sidechaincompress0];" +
+				"[sidechaincompress0][asplit0]amix=inputs=2:duration=longest:dropout_transition=2[amix0]",
+				"-map", "[amix0]"},
+		},
+		{
+			name:     "闪避分轨",
+			config:   duckingSeparate,
+			separate: true,
+			want: []string{"-filter_complex", "[1:a]afftdn[afftdn0];[afftdn0]asplit[asplit0][asplit1];" +
+				"[0:a][asplit1]sidechaincompress=threshold=0.03:ratio=8:attack=20:release=400[sidechaincompress0]",
+				"-map", "[sidechaincompress0]", "-map", "[asplit0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := mixArgs(t, tt.config, tt.separate)
+			want := append([]string{"-i", "system.wav", "-i", "mic.wav"}, tt.want...)
+			want = append(want, "out.mka")
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+			}
+		})
+	}
+
+	// 全部静音时报错
+	muted := defaults
+	muted.System.Muted, muted.Mic.Muted = true, true
+	command := ffmpeg.NewCommand()
+	if _, err := mixTracks(command.FilterGraph(), []trackPad{{pad: command.Input("system.wav").Audio(), kind: StreamSystemAudio}}, muted, false); err == nil {
+		t.Error("全部静音时应返回错误")
+	}
+}
+
+func TestDuckTracksNeedsBothTracks(t *testing.T) {
+	command := ffmpeg.NewCommand()
+	tracks := []trackPad{{pad: command.Input("mic.wav").Audio(), kind: StreamMicAudio}}
+	if got := duckTracks(command.FilterGraph(), tracks, DefaultAudioMixConfig()); !reflect.DeepEqual(got, tracks) {
+		t.Errorf("只有麦克风时 duckTracks = %+v", got)
+	}
+}
+
+func TestMasterTrack(t *testing.T) {
+	normalize := DefaultAudioMixConfig()
+	normalize.Normalize = true
+
+	tests := []struct {
+		name       string
+		config     AudioMixConfig
+		sampleRate int
+		want       []string
+	}{
+		{"不标准化", DefaultAudioMixConfig(), 44100, []string{"-map", "0:a"}},
+		{
+			// loudnorm 输出 192kHz，重采样回录制采样率
+			"录制采样率", normalize, 44100,
+			[]string{"-filter_complex", "[0:a]loudnorm=I=-16:TP=-1.5:LRA=11,aresample=44100[aresample0]", "-map", "[aresample0]"},
+		},
+		{
+			"未知采样率", normalize, 0,
+			[]string{"-filter_complex", "[0:a]loudnorm=I=-16:TP=-1.5:LRA=11,aresample=48000[aresample0]", "-map", "[aresample0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := ffmpeg.NewCommand()
+			input := command.Input("audio.wav")
+			command.Output("out.m4a").Map(masterTrack(command.FilterGraph(), input.Audio(), tt.config, tt.sampleRate))
+
+			args, err := command.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			want := append([]string{"-i", "audio.wav"}, tt.want...)
+			want = append(want, "out.m4a")
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+			}
+		})
+	}
+}
+
+func TestAddAudioTracks(t *testing.T) {
+	dir := t.TempDir()
+	video := StreamTiming{Path: filepath.Join(dir, "screen.mp4"), Kind: StreamVideo, Start: 10}
+	system := StreamTiming{Path: filepath.Join(dir, "audio_system.wav"), Kind: StreamSystemAudio, Start: 10.2, SampleRate: 44100}
+	mic := StreamTiming{Path: filepath.Join(dir, "audio_mic.wav"), Kind: StreamMicAudio, Start: 9.9, SampleRate: 44100}
+	merged := StreamTiming{Path: filepath.Join(dir, "audio_merged.wav"), Kind: StreamAudio, Start: 9.9, SampleRate: 44100,
+		Sources: []string{system.Path, mic.Path}}
+	for _, timing := range []StreamTiming{system, mic, merged} {
+		if err := os.WriteFile(timing.Path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RecordAVSync(video, merged, system, mic); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultAudioMixConfig()
+	config.Normalize = true
+	separate := config
+	separate.SeparateTracks = true
+	separate.Ducking = true
+
+	tests := []struct {
+		name   string
+		config AudioMixConfig
+		tracks int
+		want   []string // 视频输入之后的参数
+	}{
+		{
+			// 合并后的音轨只对齐和响度标准化
+			name:   "合并音轨",
+			config: config,
+			tracks: 1,
+			want: []string{"-i", merged.Path,
+				"-filter_complex", "[1:a]atrim=start=0.100,asetpts=PTS-STARTPTS[asetpts0];" +
+					"[asetpts0]loudnorm=I=-16:TP=-1.5:LRA=11,aresample=44100[aresample0]",
+				"-map", "0:v", "-map", "[aresample0]", "-c:v", "copy", "-c:a", "aac", "-b:a", "192k"},
+		},
+		{
+			// 分轨时按源文件各自的偏移对齐，闪避后每条音轨分别标准化
+			name:   "分轨",
+			config: separate,
+			tracks: 2,
+			want: []string{"-i", system.Path, "-i", mic.Path,
+				"-filter_complex", "[1:a]adelay=delays=200:all=1[adelay0];" +
+					"[2:a]atrim=start=0.100,asetpts=PTS-STARTPTS[asetpts0];" +
+					"[asetpts0]asplit[asplit0][asplit1];" +
+					"[adelay0][asplit1]sidechaincompress=threshold=0.03:ratio=8:attack=20:release=400[sidechaincompress0];" +
+					"[sidechaincompress0]loudnorm=I=-16:TP=-1.5:LRA=11,aresample=44100[aresample0];" +
+					"[asplit0]loudnorm=I=-16:TP=-1.5:LRA=11,aresample=44100[aresample1]",
+				"-map", "0:v", "-map", "[aresample0]", "-map", "[aresample1]", "-c:v", "copy", "-c:a", "aac", "-b:a", "192k"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := ffmpeg.NewCommand()
+			input := command.Input(video.Path)
+			output := command.Output("out.mp4").Map(input.Video()).VideoCodec("copy")
+			tracks, err := AddAudioTracks(command, output, FindAudioTracks(merged.Path, video.Path, tt.config), tt.config)
+			if err != nil {
+				t.Fatalf("AddAudioTracks: %v", err)
+			}
+			if tracks != tt.tracks {
+				t.Errorf("音轨数 = %d, want %d", tracks, tt.tracks)
+			}
+
+			args, err := command.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			checkPadsUsedOnce(t, args)
+			want := append([]string{"-i", video.Path}, tt.want...)
+			want = append(want, "out.mp4")
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+			}
+		})
+	}
+
+	// 需要滤镜处理时不能 copy
+	copyConfig := config
+	copyConfig.AudioCodec = "copy"
+	command := ffmpeg.NewCommand()
+	output := command.Output("out.mp4").Map(command.Input(video.Path).Video())
+	if _, err := AddAudioTracks(command, output, FindAudioTracks(merged.Path, video.Path, copyConfig), copyConfig); err == nil {
+		t.Error("对齐后使用 copy 应返回错误")
+	}
+}
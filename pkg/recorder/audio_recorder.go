@@ -1,9 +1,10 @@
 package recorder
 
 import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
@@ -16,8 +17,24 @@ type AudioRecorder struct {
 	micAudioPath    string
 	outputPath      string
 
-	systemCmd *exec.Cmd
-	micCmd    *exec.Cmd
+	systemProcess *ffmpeg.Process
+	micProcess    *ffmpeg.Process
+	systemDevice  AudioDevice // 实际使用的系统音频设备
+	micDevice     AudioDevice // 实际使用的麦克风设备
+
+	// 各音频流的开始时间（相对共享时钟），停止时记录，用于对齐
+	systemTiming *StreamTiming
+	micTiming    *StreamTiming
+	timing       *StreamTiming // 最终输出（合并后或单一音源）的开始时间
+	mix          AudioMixConfig
+	sampleRate   int             // 录制采样率，写入流时间
+	pauses       []PauseInterval // 与视频一起暂停的区间，停止时从音频中剪掉
+
+	// 实时电平
+	systemMeter *AudioMeter
+	micMeter    *AudioMeter
+	onLevel     func(AudioLevel)
+	onSignal    func(AudioSignalEvent)
 
 	isRecording bool
 	isPaused    bool
@@ -28,13 +45,17 @@ type AudioRecorder struct {
 
 // AudioConfig 音频配置
 type AudioConfig struct {
-	RecordSystemAudio bool   // 是否录制系统音频
-	RecordMicrophone  bool   // 是否录制麦克风
-	SystemDevice      string // 系统音频设备名称
-	MicDevice         string // 麦克风设备名称
-	SampleRate        int    // 采样率 (默认 48000)
-	Channels          int    // 声道数 (默认 2)
-	Bitrate           string // 比特率 (默认 192k)
+	RecordSystemAudio bool           // 是否录制系统音频
+	RecordMicrophone  bool           // 是否录制麦克风
+	SystemDevice      string         // 系统音频设备 ID（AudioDevice.ID，空字符串表示默认监听源）
+	MicDevice         string         // 麦克风设备 ID（AudioDevice.ID，空字符串表示默认输入设备）
+	SampleRate        int            // 采样率 (默认 48000)
+	Channels          int            // 声道数 (默认 2)
+	Bitrate           string         // 比特率 (默认 192k)
+	Mix               AudioMixConfig // 合并系统音频和麦克风时的混音参数
+
+	SilenceThresholdDB float64       // 峰值低于该值视为没有信号（默认 -60 dBFS）
+	SilenceTimeout     time.Duration // 持续没有信号多久后发出警告（默认 5 秒）
 }
 
 // DefaultAudioConfig 默认音频配置
@@ -47,6 +68,7 @@ func DefaultAudioConfig() AudioConfig {
 		SampleRate:        48000,
 		Channels:          2,
 		Bitrate:           "192k",
+		Mix:               DefaultAudioMixConfig(),
 	}
 }
 
@@ -73,94 +95,89 @@ func (a *AudioRecorder) StartRecording(config AudioConfig) error {
 		return fmt.Errorf("音频录制已在进行中")
 	}
 
+	a.systemTiming, a.micTiming, a.timing = nil, nil, nil
+	a.pauses = nil
+	a.mix = config.Mix
+	a.sampleRate = config.SampleRate
+	a.systemMeter, a.micMeter = nil, nil
+
 	// 确保输出目录存在
 	os.MkdirAll(filepath.Dir(a.systemAudioPath), 0755)
 
-	var err error
+	backend, err := NewAudioBackend(a.ffmpegPath)
+	if err != nil {
+		return err
+	}
 
 	// 录制系统音频
-	if config.RecordSystemAudio {
-		a.systemCmd, err = a.startSystemAudioRecording(config)
+	recordSystem := config.RecordSystemAudio
+	if recordSystem {
+		a.systemDevice, err = ResolveAudioDevice(backend, config.SystemDevice, AudioDeviceMonitor)
+		if err != nil && config.RecordMicrophone {
+			// 没有可用的系统音频设备（如 ALSA 后端、未启用立体声混音）时只录制麦克风
+			fmt.Printf("警告: 无法录制系统音频，只录制麦克风: %v\n", err)
+			a.systemDevice = AudioDevice{}
+			recordSystem = false
+		} else if err != nil {
+			return fmt.Errorf("启动系统音频录制失败: %w", err)
+		}
+	}
+	if recordSystem {
+		a.systemMeter = NewAudioMeter(StreamSystemAudio, a.systemDevice.Name, config, a.onLevel, a.onSignal)
+		a.systemProcess, err = a.startDeviceRecording(backend, a.systemDevice, a.systemAudioPath, "系统音频录制", a.systemMeter, config)
 		if err != nil {
 			return fmt.Errorf("启动系统音频录制失败: %w", err)
 		}
-		fmt.Println("✓ 系统音频录制已启动")
+		fmt.Printf("✓ 系统音频录制已启动: %s (%s)\n", a.systemDevice.Name, backend.Name())
 	}
 
 	// 录制麦克风
 	if config.RecordMicrophone {
-		a.micCmd, err = a.startMicrophoneRecording(config)
+		a.micDevice, err = ResolveAudioDevice(backend, config.MicDevice, AudioDeviceInput)
+		if err == nil {
+			a.micMeter = NewAudioMeter(StreamMicAudio, a.micDevice.Name, config, a.onLevel, a.onSignal)
+			a.micProcess, err = a.startDeviceRecording(backend, a.micDevice, a.micAudioPath, "麦克风录制", a.micMeter, config)
+		}
 		if err != nil {
 			// 如果麦克风失败，停止系统音频
-			if a.systemCmd != nil {
-				a.systemCmd.Process.Kill()
+			if a.systemProcess != nil {
+				a.systemProcess.Stop()
 			}
 			return fmt.Errorf("启动麦克风录制失败: %w", err)
 		}
-		fmt.Println("✓ 麦克风录制已启动")
+		fmt.Printf("✓ 麦克风录制已启动: %s (%s)\n", a.micDevice.Name, backend.Name())
 	}
 
 	a.isRecording = true
 	return nil
 }
 
-// startSystemAudioRecording 启动系统音频录制
-func (a *AudioRecorder) startSystemAudioRecording(config AudioConfig) (*exec.Cmd, error) {
-	// Windows: 使用 dshow 捕获音频
-	// 自动检测音频设备
-	deviceName := config.SystemDevice
-	if deviceName == "" {
-		deviceName = "audio=Stereo Mix" // Windows 默认立体声混音
-	}
-
-	args := []string{
-		"-f", "dshow",
-		"-i", deviceName,
-		"-acodec", "pcm_s16le",
-		"-ar", fmt.Sprintf("%d", config.SampleRate),
-		"-ac", fmt.Sprintf("%d", config.Channels),
-		"-y",
-		a.systemAudioPath,
-	}
-
-	cmd := exec.Command(a.ffmpegPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
+// startDeviceRecording 启动一个设备的录制进程
+// 除了 WAV 文件，同时把低采样率 PCM 输出到 stdout 供电平计使用
+func (a *AudioRecorder) startDeviceRecording(backend AudioBackend, device AudioDevice, outputPath, name string, meter *AudioMeter, config AudioConfig) (*ffmpeg.Process, error) {
+	command := ffmpeg.NewCommand().Overwrite()
+	input := command.Input(device.ID, audioInputOptions(backend)...)
+	command.Output(outputPath).
+		Map(input.Audio()).
+		AudioCodec("pcm_s16le").
+		With(ffmpeg.Opt("ar", config.SampleRate), ffmpeg.Opt("ac", config.Channels))
+	meterOutput(command, input.Audio())
+
+	args, err := command.Args()
+	if err != nil {
 		return nil, err
 	}
 
-	return cmd, nil
-}
-
-// startMicrophoneRecording 启动麦克风录制
-func (a *AudioRecorder) startMicrophoneRecording(config AudioConfig) (*exec.Cmd, error) {
-	// Windows: 使用 dshow 捕获麦克风
-	deviceName := config.MicDevice
-	if deviceName == "" {
-		deviceName = "audio=Microphone" // Windows 默认麦克风
-	}
-
-	args := []string{
-		"-f", "dshow",
-		"-i", deviceName,
-		"-acodec", "pcm_s16le",
-		"-ar", fmt.Sprintf("%d", config.SampleRate),
-		"-ac", fmt.Sprintf("%d", config.Channels),
-		"-y",
-		a.micAudioPath,
-	}
-
-	cmd := exec.Command(a.ffmpegPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
+	process := ffmpeg.NewProcess(a.ffmpegPath, args, ffmpeg.ProcessOptions{
+		Name:      name,
+		Stdout:    meter,
+		RawStdout: true,
+	})
+	if err := process.Start(context.Background()); err != nil {
 		return nil, err
 	}
 
-	return cmd, nil
+	return process, nil
 }
 
 // StopRecording 停止录制并合并音频
@@ -172,21 +189,40 @@ func (a *AudioRecorder) StopRecording() (string, error) {
 		return "", fmt.Errorf("音频录制未在进行中")
 	}
 
-	// 停止系统音频
-	if a.systemCmd != nil && a.systemCmd.Process != nil {
-		a.systemCmd.Process.Kill()
-		a.systemCmd.Wait()
+	// 停止系统音频（发送 q 让 FFmpeg 写完 WAV 文件头）
+	if a.systemProcess != nil {
+		timing := processTiming(a.systemAudioPath, StreamSystemAudio, a.systemProcess)
+		timing.SampleRate = a.sampleRate
+		a.systemTiming = &timing
+		a.systemProcess.Stop()
+		if err := a.systemProcess.Wait(); err != nil {
+			fmt.Printf("警告: 系统音频录制异常结束: %v\n", err)
+		}
 		fmt.Println("✓ 系统音频录制已停止")
 	}
 
 	// 停止麦克风
-	if a.micCmd != nil && a.micCmd.Process != nil {
-		a.micCmd.Process.Kill()
-		a.micCmd.Wait()
+	if a.micProcess != nil {
+		timing := processTiming(a.micAudioPath, StreamMicAudio, a.micProcess)
+		timing.SampleRate = a.sampleRate
+		a.micTiming = &timing
+		a.micProcess.Stop()
+		if err := a.micProcess.Wait(); err != nil {
+			fmt.Printf("警告: 麦克风录制异常结束: %v\n", err)
+		}
 		fmt.Println("✓ 麦克风录制已停止")
 	}
 
 	a.isRecording = false
+	a.isPaused = false
+
+	// 视频暂停时音频没有中断，剪掉暂停区间后与视频保持同步
+	if err := cutPausedAudio(a.ffmpegPath, a.systemAudioPath, a.systemTiming, a.pauses); err != nil {
+		fmt.Printf("警告: 系统音频%v\n", err)
+	}
+	if err := cutPausedAudio(a.ffmpegPath, a.micAudioPath, a.micTiming, a.pauses); err != nil {
+		fmt.Printf("警告: 麦克风%v\n", err)
+	}
 
 	// 合并音频文件
 	mergedPath, err := a.mergeAudioFiles()
@@ -198,6 +234,7 @@ func (a *AudioRecorder) StopRecording() (string, error) {
 }
 
 // mergeAudioFiles 合并系统音频和麦克风音频
+// 两个采集进程的启动时间不同，先按各自的开始时间对齐（晚开始的一路在开头补静音）再混合
 func (a *AudioRecorder) mergeAudioFiles() (string, error) {
 	// 检查文件是否存在
 	systemExists := fileExists(a.systemAudioPath)
@@ -205,30 +242,58 @@ func (a *AudioRecorder) mergeAudioFiles() (string, error) {
 
 	// 如果只有一个音频源，直接返回
 	if systemExists && !micExists {
+		a.timing = a.systemTiming
 		return a.systemAudioPath, nil
 	}
 	if !systemExists && micExists {
+		a.timing = a.micTiming
 		return a.micAudioPath, nil
 	}
 	if !systemExists && !micExists {
 		return "", fmt.Errorf("没有录制到音频")
 	}
 
-	// 合并两个音频流
-	// 使用 amix 滤镜混合音频
-	args := []string{
-		"-i", a.systemAudioPath,
-		"-i", a.micAudioPath,
-		"-filter_complex", "amix=inputs=2:duration=longest:dropout_transition=2",
-		"-acodec", "pcm_s16le",
-		"-y",
-		a.outputPath,
+	command := ffmpeg.NewCommand().Overwrite()
+	system := command.Input(a.systemAudioPath).Audio()
+	mic := command.Input(a.micAudioPath).Audio()
+
+	// 以先开始的一路为基准，合并后的音频从该时刻开始
+	if a.systemTiming != nil && a.micTiming != nil {
+		first := *a.systemTiming
+		if a.micTiming.Start < first.Start {
+			first = *a.micTiming
+		}
+		system = AlignAudio(command, system, a.systemTiming.Start-first.Start)
+		mic = AlignAudio(command, mic, a.micTiming.Start-first.Start)
+
+		a.timing = &StreamTiming{
+			Path:       a.outputPath,
+			Kind:       StreamAudio,
+			Start:      first.Start,
+			Measured:   first.Measured,
+			Sources:    []string{a.systemAudioPath, a.micAudioPath},
+			SampleRate: a.sampleRate,
+		}
 	}
 
-	cmd := exec.Command(a.ffmpegPath, args...)
-	output, err := cmd.CombinedOutput()
+	// 应用增益、静音、降噪和闪避后用 amix 混合
+	mixed, err := mixTracks(command.FilterGraph(), []trackPad{
+		{pad: system, kind: StreamSystemAudio},
+		{pad: mic, kind: StreamMicAudio},
+	}, a.mix, false)
 	if err != nil {
-		return "", fmt.Errorf("合并音频失败: %w, 输出: %s", err, string(output))
+		return "", err
+	}
+	command.Output(a.outputPath).Map(mixed[0]).AudioCodec("pcm_s16le")
+
+	args, err := command.Args()
+	if err != nil {
+		return "", err
+	}
+
+	process := ffmpeg.NewProcess(a.ffmpegPath, args, ffmpeg.ProcessOptions{Name: "音频合并"})
+	if err := process.Run(context.Background()); err != nil {
+		return "", fmt.Errorf("合并音频失败: %w", err)
 	}
 
 	fmt.Printf("✓ 音频已合并: %s\n", a.outputPath)
@@ -264,6 +329,13 @@ func (a *AudioRecorder) ResumeRecording() error {
 	return nil
 }
 
+// SetPauses 设置与视频一起暂停的区间（停止录制前设置，停止时从音频中剪掉）
+func (a *AudioRecorder) SetPauses(pauses []PauseInterval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pauses = pauses
+}
+
 // GetSystemAudioPath 获取系统音频文件路径
 func (a *AudioRecorder) GetSystemAudioPath() string {
 	return a.systemAudioPath
@@ -293,50 +365,116 @@ func (a *AudioRecorder) IsPaused() bool {
 	return a.isPaused
 }
 
-// ListAudioDevices 列出可用的音频设备（Windows dshow）
-func ListAudioDevices(ffmpegPath string) ([]string, error) {
-	// 使用 FFmpeg 列出 dshow 设备
-	args := []string{
-		"-list_devices", "true",
-		"-f", "dshow",
-		"-i", "dummy",
+// ListAudioDevices 列出当前系统音频后端的可用设备
+func ListAudioDevices(ffmpegPath string) ([]AudioDevice, error) {
+	backend, err := NewAudioBackend(ffmpegPath)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.Command(ffmpegPath, args...)
-	output, _ := cmd.CombinedOutput()
+	devices, err := backend.ListDevices()
+	if err != nil {
+		return nil, fmt.Errorf("枚举音频设备失败: %w", err)
+	}
 
-	// 解析输出（简化处理）
-	devices := []string{}
-	// 这里可以解析 FFmpeg 输出，提取设备列表
-	// 实际实现需要解析 FFmpeg 的输出格式
+	fmt.Printf("可用音频设备 (%s): %d 个\n", backend.Name(), len(devices))
+	return devices, nil
+}
 
-	fmt.Println("可用音频设备:")
-	fmt.Println(string(output))
+// GetSystemDevice 获取实际使用的系统音频设备
+func (a *AudioRecorder) GetSystemDevice() AudioDevice {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.systemDevice
+}
 
-	return devices, nil
+// GetStreamTimings 获取最终输出音频及其源文件的开始时间（停止录制后可用）
+// 第一个元素为最终输出，合并时后面依次为系统音频和麦克风
+func (a *AudioRecorder) GetStreamTimings() []StreamTiming {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.timing == nil {
+		return nil
+	}
+
+	timings := []StreamTiming{*a.timing}
+	if len(a.timing.Sources) > 0 {
+		for _, source := range []*StreamTiming{a.systemTiming, a.micTiming} {
+			if source != nil {
+				timings = append(timings, *source)
+			}
+		}
+	}
+	return timings
+}
+
+// SetMeterHandler 设置实时电平和信号状态回调（在 StartRecording 之前调用）
+func (a *AudioRecorder) SetMeterHandler(onLevel func(AudioLevel), onSignal func(AudioSignalEvent)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onLevel = onLevel
+	a.onSignal = onSignal
+}
+
+// GetLevels 获取各音频输入最近一次的电平
+func (a *AudioRecorder) GetLevels() []AudioLevel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	levels := []AudioLevel{}
+	for _, meter := range []*AudioMeter{a.systemMeter, a.micMeter} {
+		if meter != nil {
+			levels = append(levels, meter.Level())
+		}
+	}
+	return levels
 }
 
-// MergeAudioWithVideo 将音频和视频合并
-func MergeAudioWithVideo(ffmpegPath, videoPath, audioPath, outputPath string) error {
-	args := []string{
-		"-i", videoPath,
-		"-i", audioPath,
-		"-c:v", "copy", // 视频流不重编码
-		"-c:a", "aac", // 音频编码为 AAC
-		"-b:a", "192k", // 音频比特率
-		"-strict", "experimental",
-		"-y",
-		outputPath,
-	}
-
-	cmd := exec.Command(ffmpegPath, args...)
-	output, err := cmd.CombinedOutput()
+// GetSilentTracks 获取当前处于无信号状态的音频输入
+func (a *AudioRecorder) GetSilentTracks() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tracks := []string{}
+	for _, meter := range []*AudioMeter{a.systemMeter, a.micMeter} {
+		if meter != nil && meter.Silent() {
+			tracks = append(tracks, meter.track)
+		}
+	}
+	return tracks
+}
+
+// GetMicDevice 获取实际使用的麦克风设备
+func (a *AudioRecorder) GetMicDevice() AudioDevice {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.micDevice
+}
+
+// MergeAudioWithVideo 将音频和视频合并，返回输出中的音轨数
+// 会话中记录了音频相对视频的偏移时自动对齐；按混音配置做响度标准化、分轨和编码
+func MergeAudioWithVideo(ffmpegPath, videoPath, audioPath, outputPath string, mix AudioMixConfig) (int, error) {
+	command := ffmpeg.NewCommand().Overwrite()
+	video := command.Input(videoPath)
+	output := command.Output(outputPath).Map(video.Video()).VideoCodec("copy") // 视频流不重编码
+
+	trackCount, err := AddAudioTracks(command, output, FindAudioTracks(audioPath, videoPath, mix), mix)
 	if err != nil {
-		return fmt.Errorf("合并音视频失败: %w, 输出: %s", err, string(output))
+		return 0, err
 	}
 
-	fmt.Printf("✓ 音视频已合并: %s\n", outputPath)
-	return nil
+	args, err := command.Args()
+	if err != nil {
+		return 0, fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
+
+	process := ffmpeg.NewProcess(ffmpegPath, args, ffmpeg.ProcessOptions{Name: "音视频合并"})
+	if err := process.Run(context.Background()); err != nil {
+		return 0, fmt.Errorf("合并音视频失败: %w", err)
+	}
+
+	fmt.Printf("✓ 音视频已合并: %s (%d 条音轨)\n", outputPath, trackCount)
+	return trackCount, nil
 }
 
 // fileExists 检查文件是否存在
@@ -0,0 +1,190 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/clock"
+	"SmoothScreen/pkg/ffmpeg"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 采集流类型
+const (
+	StreamVideo       = "video"        // 屏幕视频
+	StreamSystemAudio = "system_audio" // 系统音频
+	StreamMicAudio    = "mic_audio"    // 麦克风
+	StreamAudio       = "audio"        // 合并后的音频
+	StreamWebcam      = "webcam"       // 摄像头视频
+)
+
+// minAlignOffset 小于该值（秒）的偏移不做处理，避免为亚帧级误差引入滤镜
+const minAlignOffset = 0.005
+
+// StreamTiming 采集流的开始时间
+// 所有流的 Start 都相对同一个单调时钟（clock.Default）计算，可以直接相减得到偏移；
+// 鼠标、键盘事件的会话时钟（hook.SessionClock）也以它为基准
+type StreamTiming struct {
+	Path     string  `json:"path"`
+	Kind     string  `json:"kind"`
+	Start    float64 `json:"start"`    // 输出时间戳 0 对应的时刻（秒，相对共享时钟起点）
+	Measured bool    `json:"measured"` // true: 由输出时间戳推算；false: 退回到进程启动时刻
+	// 对齐基准（录制时同时进行的视频）及相对基准的偏移（秒）
+	// 正数表示该流晚于视频开始，负数表示早于视频开始
+	Reference string  `json:"reference,omitempty"`
+	Offset    float64 `json:"offset"`
+	// 合并音频的源文件（系统音频、麦克风），用于分轨输出
+	Sources []string `json:"sources,omitempty"`
+	// 音频的录制采样率（Hz），封装时响度标准化后重采样回该值
+	SampleRate int `json:"sampleRate,omitempty"`
+}
+
+// processTiming 根据进程的媒体起点生成流时间
+func processTiming(path, kind string, process *ffmpeg.Process) StreamTiming {
+	start, measured := process.MediaStart()
+	return newStreamTiming(path, kind, start, measured)
+}
+
+// newStreamTiming 生成相对共享时钟的流时间
+func newStreamTiming(path, kind string, start time.Time, measured bool) StreamTiming {
+	return StreamTiming{
+		Path:     path,
+		Kind:     kind,
+		Start:    clock.Default.Seconds(start),
+		Measured: measured,
+	}
+}
+
+// AlignTo 以 reference 为基准计算偏移
+func (t StreamTiming) AlignTo(reference StreamTiming) StreamTiming {
+	t.Reference = reference.Path
+	t.Offset = t.Start - reference.Start
+	return t
+}
+
+// RecordStreams 记录采集流时间并立即保存
+func (s *Session) RecordStreams(timings ...StreamTiming) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range timings {
+		timing := timings[i]
+		s.Streams[timing.Path] = &timing
+	}
+	return s.saveLocked()
+}
+
+// recordStreamTiming 把单个流时间写入其所属的会话
+func recordStreamTiming(timing StreamTiming) error {
+	session, err := LoadSession(SessionDirFor(timing.Path))
+	if err == nil {
+		err = session.RecordStreams(timing)
+	}
+	if err != nil {
+		return fmt.Errorf("保存流时间失败: %w", err)
+	}
+	return nil
+}
+
+// RecordAVSync 把视频和与之同时录制的其他流（音频、摄像头）的流时间写入会话
+// 视频所在目录和各流所在目录的会话都会记录，导出 H.264 时只知道音频路径也能找到偏移
+func RecordAVSync(video StreamTiming, audio ...StreamTiming) error {
+	timings := []StreamTiming{video}
+	for _, timing := range audio {
+		timings = append(timings, timing.AlignTo(video))
+	}
+
+	dirs := []string{SessionDirFor(video.Path)}
+	for _, timing := range audio {
+		dir := SessionDirFor(timing.Path)
+		if !containsString(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		session, err := LoadSession(dir)
+		if err == nil {
+			err = session.RecordStreams(timings...)
+		}
+		if err != nil {
+			return fmt.Errorf("保存音视频同步信息失败: %w", err)
+		}
+	}
+
+	for _, timing := range timings[1:] {
+		fmt.Printf("✓ 流偏移: %s 相对视频 %+.3f 秒\n", timing.Path, timing.Offset)
+	}
+	return nil
+}
+
+// FindAudioOffset 在音频和视频所属的会话中查找音频相对视频的偏移
+func FindAudioOffset(audioPath, videoPath string) (float64, bool) {
+	timing, _ := findStreamTiming(audioPath, videoPath)
+	if timing == nil || timing.Reference == "" {
+		return 0, false
+	}
+	return timing.Offset, true
+}
+
+// findStreamTiming 在音频和视频所属的会话中查找音频的流时间，同时返回该会话的全部流
+func findStreamTiming(audioPath, videoPath string) (*StreamTiming, map[string]*StreamTiming) {
+	dirs := []string{SessionDirFor(audioPath)}
+	if videoPath != "" && !containsString(dirs, SessionDirFor(videoPath)) {
+		dirs = append(dirs, SessionDirFor(videoPath))
+	}
+
+	for _, dir := range dirs {
+		session, err := LoadSession(dir)
+		if err != nil {
+			continue
+		}
+		if timing, streams := session.streamTiming(audioPath); timing != nil {
+			return timing, streams
+		}
+	}
+	return nil, nil
+}
+
+// streamTiming 在会话中查找流时间，返回副本（会话是共享的，其他协程可能同时写入）
+func (s *Session) streamTiming(path string) (*StreamTiming, map[string]*StreamTiming) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.Streams[path]; !ok {
+		return nil, nil
+	}
+	streams := make(map[string]*StreamTiming, len(s.Streams))
+	for key, value := range s.Streams {
+		copied := *value
+		streams[key] = &copied
+	}
+	return streams[path], streams
+}
+
+// AlignAudio 按偏移对齐音频输入，返回对齐后的音频
+// 音频晚于视频开始时用 adelay 在开头补静音；早于视频开始时用 atrim 裁掉多录的部分
+func AlignAudio(command *ffmpeg.Command, audio ffmpeg.Pad, offset float64) ffmpeg.Pad {
+	if math.Abs(offset) < minAlignOffset {
+		return audio
+	}
+
+	graph := command.FilterGraph()
+	if offset > 0 {
+		delay := int64(math.Round(offset * 1000))
+		return graph.Apply(ffmpeg.NewFilter("adelay").Set("delays", delay).Set("all", 1), audio)
+	}
+	return graph.Chain([]ffmpeg.Pad{audio},
+		ffmpeg.NewFilter("atrim").Set("start", fmt.Sprintf("%.3f", -offset)),
+		ffmpeg.NewFilter("asetpts", "PTS-STARTPTS"),
+	)
+}
+
+// containsString 检查切片是否包含字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/clock"
+	"SmoothScreen/pkg/ffmpeg"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlignAudio(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset float64
+		want   []string // 对齐后的滤镜图和映射
+	}{
+		// 亚帧级误差不引入滤镜
+		{"忽略微小偏移", 0.004, []string{"-map", "0:a"}},
+		{"忽略微小负偏移", -0.004, []string{"-map", "0:a"}},
+		// 音频晚于视频开始，在开头补静音
+		{"正偏移", 0.25, []string{"-filter_complex", "[0:a]adelay=delays=250:all=1[adelay0]", "-map", "[adelay0]"}},
+		{"正偏移四舍五入到毫秒", 0.0426, []string{"-filter_complex", "[0:a]adelay=delays=43:all=1[adelay0]", "-map", "[adelay0]"}},
+		// 音频早于视频开始，裁掉多录的部分并把时间戳归零
+		{"负偏移", -0.1234, []string{"-filter_complex", "[0:a]atrim=start=0.123,asetpts=PTS-STARTPTS[asetpts0]", "-map", "[asetpts0]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := ffmpeg.NewCommand()
+			input := command.Input("audio.wav")
+			command.Output("out.wav").Map(AlignAudio(command, input.Audio(), tt.offset))
+
+			args, err := command.Args()
+			if err != nil {
+				t.Fatalf("Args: %v", err)
+			}
+			want := append([]string{"-i", "audio.wav"}, tt.want...)
+			want = append(want, "out.wav")
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+			}
+		})
+	}
+}
+
+func TestNewStreamTiming(t *testing.T) {
+	start := clock.Default.Origin().Add(1500 * time.Millisecond)
+	timing := newStreamTiming("screen.mp4", StreamVideo, start, true)
+	if timing.Start != 1.5 || !timing.Measured || timing.Kind != StreamVideo {
+		t.Errorf("newStreamTiming = %+v", timing)
+	}
+	if aligned := (StreamTiming{Path: "mic.wav", Start: 1.2}).AlignTo(timing); aligned.Reference != "screen.mp4" || math.Abs(aligned.Offset+0.3) > 1e-9 {
+		t.Errorf("AlignTo = %+v, want 相对 screen.mp4 偏移 -0.3", aligned)
+	}
+}
+
+func TestRecordAVSync(t *testing.T) {
+	videoDir, audioDir := t.TempDir(), t.TempDir()
+	video := StreamTiming{Path: filepath.Join(videoDir, "screen.mp4"), Kind: StreamVideo, Start: 10, Measured: true}
+	audio := StreamTiming{Path: filepath.Join(audioDir, "audio_merged.wav"), Kind: StreamAudio, Start: 10.25, Measured: true}
+	webcam := StreamTiming{Path: filepath.Join(videoDir, "webcam.mp4"), Kind: StreamWebcam, Start: 9.9}
+
+	if err := RecordAVSync(video, audio, webcam); err != nil {
+		t.Fatalf("RecordAVSync: %v", err)
+	}
+
+	// 视频和音频所在目录的会话都记录了全部流
+	for _, dir := range []string{videoDir, audioDir} {
+		session, err := readSession(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(session.Streams) != 3 {
+			t.Fatalf("%s 的流 = %+v, want 3 个", dir, session.Streams)
+		}
+		if got := session.Streams[video.Path]; got.Reference != "" || got.Offset != 0 {
+			t.Errorf("视频流 = %+v, want 没有基准", got)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		videoPath string
+		want      float64
+		found     bool
+	}{
+		{"音频晚于视频", audio.Path, video.Path, 0.25, true},
+		// 导出时只知道音频路径也能在音频所在目录找到偏移
+		{"只有音频路径", audio.Path, "", 0.25, true},
+		{"摄像头早于视频", webcam.Path, video.Path, -0.1, true},
+		// 视频本身没有基准
+		{"视频", video.Path, video.Path, 0, false},
+		{"未记录的流", filepath.Join(audioDir, "other.wav"), video.Path, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, found := FindAudioOffset(tt.path, tt.videoPath)
+			if found != tt.found || math.Abs(offset-tt.want) > 1e-9 {
+				t.Errorf("FindAudioOffset = (%v, %v), want (%v, %v)", offset, found, tt.want, tt.found)
+			}
+		})
+	}
+}
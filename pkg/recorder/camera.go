@@ -24,6 +24,40 @@ type CameraController struct {
 	zoomOnClick  bool
 	clickZoom    float64
 	defaultZoom  float64
+
+	// Typing-driven zoom, configured next to the click zoom above
+	zoomOnTyping  bool
+	typingZoom    float64      // Zoom level while a typing burst is active
+	typingHold    int64        // Keep the typing zoom this long (ms) after the last keystroke
+	typingMinKeys int          // Keystrokes needed within typingWindow to start a burst
+	typingWindow  int64        // Burst detection window in milliseconds
+	typingRegion  *FocusRegion // Optional fixed caret region, overrides the click position
+
+	// Typing burst state
+	typingKeys    []int64 // Timestamps of recent typing keystrokes
+	typingActive  bool
+	lastTypingKey int64
+	settling      bool    // Still easing back after a typing burst ended
+	caretX        float64 // Last known caret proxy (last click position)
+	caretY        float64
+	hasCaret      bool
+	mouseX        float64 // Last known mouse position
+	mouseY        float64
+
+	// Gesture-driven zoom: drags and selections zoom to fit their bounding box
+	zoomOnDrag  bool
+	dragHold    int64    // Keep the drag framing this long (ms) after release
+	dragPadding float64  // Space around the drag bounds, as a fraction of their size
+	focus       *Gesture // Drag or selection currently framed
+}
+
+// FocusRegion is a screen rectangle the camera can frame: the caret proxy
+// while typing, or the bounds of a mouse gesture
+type FocusRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
 }
 
 // NewCameraController creates a new camera controller
@@ -49,6 +83,17 @@ func NewCameraController(screenWidth, screenHeight int) *CameraController {
 		zoomOnClick:  true,
 		clickZoom:    1.5, // Zoom to 1.5x when clicking
 		defaultZoom:  1.0,
+
+		zoomOnTyping:  false,
+		typingZoom:    1.8,  // Zoom closer while typing than when clicking
+		typingHold:    1500, // Hold for 1.5s after the last keystroke
+		typingMinKeys: 3,    // 3 keystrokes ...
+		typingWindow:  1000, // ... within 1s start a burst
+		zoomOnDrag:    false,
+		dragHold:      1000, // Hold the drag framing for 1s after release
+		dragPadding:   0.15,
+		mouseX:        float64(screenWidth) / 2,
+		mouseY:        float64(screenHeight) / 2,
 	}
 }
 
@@ -57,6 +102,32 @@ func NewCameraController(screenWidth, screenHeight int) *CameraController {
 func (c *CameraController) Update(event hook.MouseEvent) bool {
 	changed := false
 
+	c.mouseX = float64(event.X)
+	c.mouseY = float64(event.Y)
+
+	if c.focus != nil {
+		if event.Timestamp <= c.focus.End || !isButtonDown(event.EventType) {
+			// The drag's own events and later drift stay inside the framed bounds
+			return c.step()
+		}
+		// A new press ends the drag framing early
+		c.endFocus()
+	}
+
+	switch event.EventType {
+	case "l_down", "r_down", "m_down", "l_up", "r_up", "m_up", "hold":
+		// A click moves the caret and ends any typing burst
+		c.caretX = float64(event.X)
+		c.caretY = float64(event.Y)
+		c.hasCaret = true
+		c.endTypingBurst()
+	default:
+		if c.typingActive {
+			// Mouse drift while typing should not pull the camera off the caret
+			return false
+		}
+	}
+
 	// Update target position based on mouse position
 	c.targetState.X = float64(event.X)
 	c.targetState.Y = float64(event.Y)
@@ -78,7 +149,16 @@ func (c *CameraController) Update(event hook.MouseEvent) bool {
 		}
 	}
 
-	// Apply smooth interpolation (easing)
+	if c.step() {
+		changed = true
+	}
+
+	return changed
+}
+
+// step applies smooth interpolation (easing) towards the target state
+// Returns true if the camera actually moved
+func (c *CameraController) step() bool {
 	prevX := c.currentState.X
 	prevY := c.currentState.Y
 	prevZoom := c.currentState.Zoom
@@ -88,13 +168,169 @@ func (c *CameraController) Update(event hook.MouseEvent) bool {
 	c.currentState.Zoom = lerp(c.currentState.Zoom, c.targetState.Zoom, c.smoothFactor*0.5) // Slower zoom transition
 
 	// Check if camera actually moved
-	if math.Abs(c.currentState.X-prevX) > 0.1 ||
+	return math.Abs(c.currentState.X-prevX) > 0.1 ||
 		math.Abs(c.currentState.Y-prevY) > 0.1 ||
-		math.Abs(c.currentState.Zoom-prevZoom) > 0.01 {
-		changed = true
+		math.Abs(c.currentState.Zoom-prevZoom) > 0.01
+}
+
+// UpdateKeyboard feeds a keyboard event into typing burst detection
+// Returns true if a typing burst started with this event
+func (c *CameraController) UpdateKeyboard(event hook.KeyboardEvent) bool {
+	if !c.zoomOnTyping || !IsTypingKey(event) {
+		return false
 	}
 
-	return changed
+	// Keep only keystrokes inside the burst window
+	c.typingKeys = append(c.typingKeys, event.Timestamp)
+	cutoff := event.Timestamp - c.typingWindow
+	keep := c.typingKeys[:0]
+	for _, t := range c.typingKeys {
+		if t >= cutoff {
+			keep = append(keep, t)
+		}
+	}
+	c.typingKeys = keep
+	c.lastTypingKey = event.Timestamp
+
+	if c.typingActive || len(c.typingKeys) < c.typingMinKeys {
+		return false
+	}
+
+	c.typingActive = true
+	c.settling = false
+	c.targetState.X, c.targetState.Y = c.caretPosition()
+	c.targetState.Zoom = c.typingZoom
+	return true
+}
+
+// Advance moves the camera along for a frame without a new mouse event
+// It ends typing bursts after the hold time and keeps easing while a
+// typing zoom is engaged or being released. Returns true if the camera moved.
+func (c *CameraController) Advance(timestamp int64) bool {
+	if c.typingActive && timestamp-c.lastTypingKey > c.typingHold {
+		c.endTypingBurst()
+	}
+
+	if c.focus != nil && timestamp-c.focus.End > c.dragHold {
+		c.endFocus()
+	}
+
+	if !c.typingActive && !c.settling && c.focus == nil {
+		return false
+	}
+
+	moved := c.step()
+	if c.settling && c.atTarget() {
+		c.settling = false
+	}
+	return moved
+}
+
+// atTarget reports whether the camera has eased (almost) all the way to its target
+// A single step can move less than the change threshold long before arriving
+func (c *CameraController) atTarget() bool {
+	return math.Abs(c.currentState.X-c.targetState.X) < 0.5 &&
+		math.Abs(c.currentState.Y-c.targetState.Y) < 0.5 &&
+		math.Abs(c.currentState.Zoom-c.targetState.Zoom) < 0.005
+}
+
+// endTypingBurst releases the typing zoom and returns to following the mouse
+func (c *CameraController) endTypingBurst() {
+	c.typingKeys = c.typingKeys[:0]
+	if !c.typingActive {
+		return
+	}
+	c.typingActive = false
+	c.settling = true
+	c.targetState.X = c.mouseX
+	c.targetState.Y = c.mouseY
+	c.targetState.Zoom = c.defaultZoom
+}
+
+// FocusGesture frames a drag or selection gesture, zooming to fit its bounds
+// until dragHold after the release. Other gestures are ignored.
+// Returns true if the camera started framing the gesture.
+func (c *CameraController) FocusGesture(gesture Gesture) bool {
+	if !c.zoomOnDrag || (gesture.Type != GestureDrag && gesture.Type != GestureSelection) {
+		return false
+	}
+
+	c.endTypingBurst()
+	c.focus = &gesture
+	c.settling = false
+	c.targetState.X = float64(gesture.Bounds.X) + float64(gesture.Bounds.Width)/2
+	c.targetState.Y = float64(gesture.Bounds.Y) + float64(gesture.Bounds.Height)/2
+	c.targetState.Zoom = c.fitZoom(gesture.Bounds)
+	return true
+}
+
+// endFocus releases the drag framing and returns to following the mouse
+func (c *CameraController) endFocus() {
+	c.focus = nil
+	c.settling = true
+	c.targetState.X = c.mouseX
+	c.targetState.Y = c.mouseY
+	c.targetState.Zoom = c.defaultZoom
+}
+
+// fitZoom returns the zoom that fits a region (plus padding) on screen,
+// never closer than the click zoom and never wider than the default zoom
+func (c *CameraController) fitZoom(region FocusRegion) float64 {
+	width := math.Max(float64(region.Width)*(1+2*c.dragPadding), 1)
+	height := math.Max(float64(region.Height)*(1+2*c.dragPadding), 1)
+	zoom := math.Min(float64(c.screenWidth)/width, float64(c.screenHeight)/height)
+	return math.Max(c.defaultZoom, math.Min(zoom, c.clickZoom))
+}
+
+// FocusedGesture returns the drag or selection being framed (nil if none)
+func (c *CameraController) FocusedGesture() *Gesture {
+	return c.focus
+}
+
+// isButtonDown reports whether a mouse event type is a button press
+func isButtonDown(eventType string) bool {
+	return eventType == "l_down" || eventType == "r_down" || eventType == "m_down"
+}
+
+// caretPosition returns the best known caret proxy
+// Priority: configured region > last click position > current mouse position
+func (c *CameraController) caretPosition() (float64, float64) {
+	if c.typingRegion != nil {
+		return float64(c.typingRegion.X) + float64(c.typingRegion.Width)/2,
+			float64(c.typingRegion.Y) + float64(c.typingRegion.Height)/2
+	}
+	if c.hasCaret {
+		return c.caretX, c.caretY
+	}
+	return c.mouseX, c.mouseY
+}
+
+// IsTyping reports whether a typing burst is currently active
+func (c *CameraController) IsTyping() bool {
+	return c.typingActive
+}
+
+// IsTypingKey reports whether a keyboard event counts as typing
+// Shortcuts (Ctrl/Alt/Win combos), bare modifiers, navigation and
+// function keys do not count; Shift is allowed for capitals and symbols.
+func IsTypingKey(event hook.KeyboardEvent) bool {
+	if event.EventType != "key_down" {
+		return false
+	}
+
+	for _, mod := range event.Modifiers {
+		if mod == "Ctrl" || mod == "Alt" || mod == "Win" {
+			return false
+		}
+	}
+
+	switch event.Key {
+	case "Space", "Backspace", "Enter", "Tab", "Delete":
+		return true
+	}
+
+	// Printable characters are reported as a single character
+	return len(event.Key) == 1
 }
 
 // GetViewport calculates the viewport rectangle for the current camera state
@@ -166,6 +402,55 @@ func (c *CameraController) SetClickZoom(zoom float64) {
 	c.clickZoom = zoom
 }
 
+// SetZoomOnTyping enables or disables zoom on typing bursts
+func (c *CameraController) SetZoomOnTyping(enabled bool) {
+	c.zoomOnTyping = enabled
+}
+
+// SetTypingZoom sets the zoom level while typing
+func (c *CameraController) SetTypingZoom(zoom float64) {
+	c.typingZoom = zoom
+}
+
+// SetTypingHold sets how long (ms) the typing zoom is held after the last keystroke
+func (c *CameraController) SetTypingHold(holdMs int64) {
+	if holdMs < 0 {
+		holdMs = 0
+	}
+	c.typingHold = holdMs
+}
+
+// SetTypingBurst sets how many keystrokes within windowMs start a typing burst
+// A non-positive window keeps the default of 1000 ms
+func (c *CameraController) SetTypingBurst(minKeys int, windowMs int64) {
+	if minKeys < 1 {
+		minKeys = 1
+	}
+	if windowMs <= 0 {
+		windowMs = 1000 // Fall back to the default 1s window instead of disabling bursts
+	}
+	c.typingMinKeys = minKeys
+	c.typingWindow = windowMs
+}
+
+// SetZoomOnDrag enables or disables zooming to fit drag and selection bounds
+func (c *CameraController) SetZoomOnDrag(enabled bool) {
+	c.zoomOnDrag = enabled
+}
+
+// SetDragHold sets how long (ms) a drag stays framed after release
+func (c *CameraController) SetDragHold(holdMs int64) {
+	if holdMs < 0 {
+		holdMs = 0
+	}
+	c.dragHold = holdMs
+}
+
+// SetTypingRegion sets a fixed caret region (nil = use the last click position)
+func (c *CameraController) SetTypingRegion(region *FocusRegion) {
+	c.typingRegion = region
+}
+
 // GetState returns the current camera state
 func (c *CameraController) GetState() CameraState {
 	return c.currentState
@@ -181,6 +466,11 @@ func (c *CameraController) Reset() {
 		Height: c.screenHeight,
 	}
 	c.targetState = c.currentState
+	c.typingKeys = nil
+	c.typingActive = false
+	c.settling = false
+	c.hasCaret = false
+	c.focus = nil
 }
 
 // lerp performs linear interpolation between two values
@@ -201,38 +491,77 @@ func EaseOutQuad(t float64) float64 {
 	return 1 - (1-t)*(1-t)
 }
 
+// clickEffectHoldMs keeps a click effect visible after the button is released
+const clickEffectHoldMs = 300
+
 // CameraFrame represents a camera state at a specific time
 type CameraFrame struct {
-	Timestamp int64   // Timestamp in milliseconds
-	X         float64 // Camera X position
-	Y         float64 // Camera Y position
-	Zoom      float64 // Zoom level
-	MouseX    int16   // Mouse X position
-	MouseY    int16   // Mouse Y position
-	EventType string  // Event type that triggered this frame
+	Timestamp  int64   // Timestamp in milliseconds
+	X          float64 // Camera X position
+	Y          float64 // Camera Y position
+	Zoom       float64 // Zoom level
+	MouseX     int16   // Mouse X position
+	MouseY     int16   // Mouse Y position
+	EventType  string  // Event type that triggered this frame
+	Gesture    string  // Click, multi-click, drag or selection shown by the click effect ("" = none)
+	ClickCount int     // Number of clicks in a click gesture (2 for a double click)
 }
 
 // GenerateCameraPath generates smooth camera frames from mouse events
 func GenerateCameraPath(mouseEvents []hook.MouseEvent, screenWidth, screenHeight int, fps int) []CameraFrame {
+	controller := NewCameraController(screenWidth, screenHeight)
+	return GenerateCameraPathWithKeyboard(mouseEvents, nil, controller, fps)
+}
+
+// GenerateCameraPathWithKeyboard generates camera frames from mouse and keyboard events
+// using a pre-configured controller. Keyboard events drive typing-aware zoom, and
+// gestures are analyzed up front so the camera can frame a whole drag and the
+// click effect can tell a double click or a selection from single clicks.
+func GenerateCameraPathWithKeyboard(mouseEvents []hook.MouseEvent, keyboardEvents []hook.KeyboardEvent, controller *CameraController, fps int) []CameraFrame {
 	if len(mouseEvents) == 0 {
 		return []CameraFrame{}
 	}
 
-	controller := NewCameraController(screenWidth, screenHeight)
 	frames := make([]CameraFrame, 0)
 
 	// Get time range
 	startTime := mouseEvents[0].Timestamp
 	endTime := mouseEvents[len(mouseEvents)-1].Timestamp
+	if len(keyboardEvents) > 0 && keyboardEvents[len(keyboardEvents)-1].Timestamp > endTime {
+		endTime = keyboardEvents[len(keyboardEvents)-1].Timestamp
+	}
 	frameDuration := int64(1000 / fps) // Frame duration in ms
 
+	gestures := AnalyzeGestures(mouseEvents, DefaultGestureConfig())
+
 	eventIndex := 0
+	keyIndex := 0
+	gestureIndex := 0
+	var effect *Gesture // Latest click, drag or selection for the click effect
 	for timestamp := startTime; timestamp <= endTime; timestamp += frameDuration {
+		// Start framing drags as soon as they begin
+		for gestureIndex < len(gestures) && gestures[gestureIndex].Start <= timestamp {
+			gesture := &gestures[gestureIndex]
+			controller.FocusGesture(*gesture)
+			if gesture.Type != GestureScroll {
+				effect = gesture
+			}
+			gestureIndex++
+		}
+		if effect != nil && timestamp > effect.End+clickEffectHoldMs {
+			effect = nil
+		}
+
 		// Find all events within this frame
 		for eventIndex < len(mouseEvents) && mouseEvents[eventIndex].Timestamp <= timestamp {
 			controller.Update(mouseEvents[eventIndex])
 			eventIndex++
 		}
+		for keyIndex < len(keyboardEvents) && keyboardEvents[keyIndex].Timestamp <= timestamp {
+			controller.UpdateKeyboard(keyboardEvents[keyIndex])
+			keyIndex++
+		}
+		controller.Advance(timestamp)
 
 		// Record camera frame
 		state := controller.GetState()
@@ -250,6 +579,15 @@ func GenerateCameraPath(mouseEvents []hook.MouseEvent, screenWidth, screenHeight
 			frame.MouseY = lastEvent.Y
 			frame.EventType = lastEvent.EventType
 		}
+		if controller.IsTyping() {
+			frame.EventType = "typing"
+		} else if focus := controller.FocusedGesture(); focus != nil {
+			frame.EventType = focus.Type
+		}
+		if effect != nil {
+			frame.Gesture = effect.Type
+			frame.ClickCount = effect.Count
+		}
 
 		frames = append(frames, frame)
 	}
@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"math"
+	"testing"
+)
+
+// legacyCameraPath 打字缩放之前的相机算法：每个鼠标事件朝目标插值一次，按下放大、松开还原
+func legacyCameraPath(events []hook.MouseEvent, width, height, fps int) []CameraFrame {
+	x, y, zoom := float64(width)/2, float64(height)/2, 1.0
+	targetZoom := 1.0
+	var frames []CameraFrame
+	index := 0
+	for timestamp := events[0].Timestamp; timestamp <= events[len(events)-1].Timestamp; timestamp += int64(1000 / fps) {
+		for index < len(events) && events[index].Timestamp <= timestamp {
+			event := events[index]
+			switch event.EventType {
+			case "l_down", "r_down", "m_down", "hold":
+				targetZoom = 1.5
+			case "l_up", "r_up", "m_up":
+				targetZoom = 1.0
+			}
+			x = lerp(x, float64(event.X), 0.15)
+			y = lerp(y, float64(event.Y), 0.15)
+			zoom = lerp(zoom, targetZoom, 0.075)
+			index++
+		}
+		frames = append(frames, CameraFrame{Timestamp: timestamp, X: x, Y: y, Zoom: zoom})
+	}
+	return frames
+}
+
+// exportCameraPath 按导出配置生成相机路径
+func exportCameraPath(config ExportConfig, mouse []hook.MouseEvent, keyboard []hook.KeyboardEvent) []CameraFrame {
+	config.ScreenWidth, config.ScreenHeight = 1920, 1080
+	return GenerateCameraPathWithKeyboard(mouse, keyboard, NewCameraControllerForConfig(config), config.FPS)
+}
+
+func TestCameraPathClickOnlyMatchesLegacy(t *testing.T) {
+	var events []hook.MouseEvent
+	for i := range 20 {
+		t0 := int64(i) * 200
+		x, y := int16(100+i*80), int16(200+i*30)
+		events = append(events,
+			hook.MouseEvent{Timestamp: t0, X: x, Y: y, EventType: "move"},
+			hook.MouseEvent{Timestamp: t0 + 60, X: x, Y: y, EventType: "l_down", Button: "left"},
+			hook.MouseEvent{Timestamp: t0 + 120, X: x, Y: y, EventType: "l_up", Button: "left"},
+		)
+	}
+
+	// 默认导出配置下只有点击时与原来的相机路径一致（打字缩放默认关闭，键盘数据不影响）
+	keyboard := typingBurst(500, 10)
+	got := exportCameraPath(DefaultExportConfig(), events, keyboard)
+	want := legacyCameraPath(events, 1920, 1080, DefaultExportConfig().FPS)
+	if len(got) < len(want) {
+		t.Fatalf("帧数 = %d, want >= %d", len(got), len(want))
+	}
+	for i, frame := range want {
+		if math.Abs(got[i].X-frame.X) > 1e-9 || math.Abs(got[i].Y-frame.Y) > 1e-9 || math.Abs(got[i].Zoom-frame.Zoom) > 1e-9 {
+			t.Fatalf("第 %d 帧 = (%.3f, %.3f, %.4f), want (%.3f, %.3f, %.4f)",
+				i, got[i].X, got[i].Y, got[i].Zoom, frame.X, frame.Y, frame.Zoom)
+		}
+	}
+}
+
+func TestCameraPathDragMatchesLegacy(t *testing.T) {
+	// 按下后拖过半个屏幕再松开（包含选择和拖动）
+	var events []hook.MouseEvent
+	for i := range 5 {
+		t0 := int64(i) * 1000
+		x, y := int16(200+i*100), int16(300+i*50)
+		events = append(events, hook.MouseEvent{Timestamp: t0, X: x, Y: y, EventType: "l_down", Button: "left"})
+		for step := range 8 {
+			events = append(events, hook.MouseEvent{
+				Timestamp: t0 + 50 + int64(step)*50,
+				X:         x + int16(step)*80,
+				Y:         y + int16(step*i)*10,
+				EventType: "move",
+			})
+		}
+		events = append(events, hook.MouseEvent{Timestamp: t0 + 500, X: x + 560, Y: y + int16(7*i)*10, EventType: "l_up", Button: "left"})
+	}
+
+	// 拖动缩放默认关闭，默认导出配置下与原来的相机路径一致
+	got := exportCameraPath(DefaultExportConfig(), events, nil)
+	want := legacyCameraPath(events, 1920, 1080, DefaultExportConfig().FPS)
+	if len(got) < len(want) {
+		t.Fatalf("帧数 = %d, want >= %d", len(got), len(want))
+	}
+	for i, frame := range want {
+		if math.Abs(got[i].X-frame.X) > 1e-9 || math.Abs(got[i].Y-frame.Y) > 1e-9 || math.Abs(got[i].Zoom-frame.Zoom) > 1e-9 {
+			t.Fatalf("第 %d 帧 = (%.3f, %.3f, %.4f), want (%.3f, %.3f, %.4f)",
+				i, got[i].X, got[i].Y, got[i].Zoom, frame.X, frame.Y, frame.Zoom)
+		}
+	}
+}
+
+// typingBurst 从 start 开始每 100ms 输入一个字符
+func typingBurst(start int64, count int) []hook.KeyboardEvent {
+	var events []hook.KeyboardEvent
+	for i := range count {
+		events = append(events, hook.KeyboardEvent{Timestamp: start + int64(i)*100, Key: "a", EventType: "key_down"})
+	}
+	return events
+}
+
+func TestCameraPathTypingZoom(t *testing.T) {
+	mouse := []hook.MouseEvent{
+		{Timestamp: 0, X: 300, Y: 400, EventType: "l_down", Button: "left"},
+		{Timestamp: 50, X: 300, Y: 400, EventType: "l_up", Button: "left"},
+		{Timestamp: 5000, X: 1500, Y: 900, EventType: "move"},
+	}
+	keyboard := typingBurst(1000, 10) // 1.0s - 1.9s
+
+	config := DefaultExportConfig()
+	config.EnableTypingZoom = true
+	frames := exportCameraPath(config, mouse, keyboard)
+	frameAt := func(timestamp int64) CameraFrame {
+		for _, frame := range frames {
+			if frame.Timestamp >= timestamp {
+				return frame
+			}
+		}
+		t.Fatalf("没有 %d ms 的帧", timestamp)
+		return CameraFrame{}
+	}
+
+	// 打字开始后放大并移向点击位置（光标位置的近似）
+	before, typing := frameAt(900), frameAt(1900)
+	if typing.EventType != "typing" || typing.Zoom <= before.Zoom || typing.Zoom < 1.5 {
+		t.Errorf("打字时 = %+v, 打字前 = %+v", typing, before)
+	}
+	if math.Abs(typing.X-300) > 50 || math.Abs(typing.Y-400) > 50 {
+		t.Errorf("打字时相机应对准点击位置: (%.0f, %.0f)", typing.X, typing.Y)
+	}
+	// 最后一次按键 1.5s 后还原
+	if after := frameAt(4900); after.EventType == "typing" || after.Zoom > 1.05 {
+		t.Errorf("打字结束后 = %+v", after)
+	}
+
+	// 关闭打字缩放时键盘数据不影响缩放
+	config.EnableTypingZoom = false
+	for _, frame := range exportCameraPath(config, mouse, keyboard) {
+		if frame.EventType == "typing" || frame.Zoom > 1.5 {
+			t.Fatalf("关闭打字缩放时出现打字缩放: %+v", frame)
+		}
+	}
+
+	// 窗口不是正数时使用默认的 1 秒，仍能识别打字
+	config.EnableTypingZoom = true
+	config.ScreenWidth, config.ScreenHeight = 1920, 1080
+	for _, windowMs := range []int64{0, -500} {
+		controller := NewCameraControllerForConfig(config)
+		controller.SetTypingBurst(3, windowMs)
+		typed := false
+		for _, frame := range GenerateCameraPathWithKeyboard(mouse, keyboard, controller, config.FPS) {
+			typed = typed || frame.EventType == "typing"
+		}
+		if !typed {
+			t.Errorf("窗口 %d ms 时没有识别打字", windowMs)
+		}
+	}
+}
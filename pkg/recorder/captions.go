@@ -0,0 +1,159 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CaptionStyle 烧录字幕的样式预设（渲染为 subtitles 滤镜的 force_style，ASS 样式字段）
+type CaptionStyle struct {
+	Name          string `json:"name"`
+	FontName      string `json:"fontName"`
+	FontSize      int    `json:"fontSize"`
+	Bold          bool   `json:"bold"`
+	PrimaryColour string `json:"primaryColour"` // ASS 颜色 &HAABBGGRR
+	OutlineColour string `json:"outlineColour"`
+	BackColour    string `json:"backColour"`
+	BorderStyle   int    `json:"borderStyle"` // 1: 描边 + 阴影，3: 背景框
+	Outline       int    `json:"outline"`
+	Shadow        int    `json:"shadow"`
+	MarginV       int    `json:"marginV"` // 距底部的距离
+}
+
+// CaptionStyles 内置样式预设
+var CaptionStyles = map[string]CaptionStyle{
+	"default": {
+		Name: "default", FontName: "Arial", FontSize: 20,
+		PrimaryColour: "&H00FFFFFF", OutlineColour: "&H00000000", BackColour: "&H80000000",
+		BorderStyle: 1, Outline: 2, Shadow: 0, MarginV: 30,
+	},
+	"large": {
+		Name: "large", FontName: "Arial", FontSize: 28, Bold: true,
+		PrimaryColour: "&H00FFFFFF", OutlineColour: "&H00000000", BackColour: "&H80000000",
+		BorderStyle: 1, Outline: 3, Shadow: 1, MarginV: 40,
+	},
+	"boxed": {
+		Name: "boxed", FontName: "Arial", FontSize: 20,
+		PrimaryColour: "&H00FFFFFF", OutlineColour: "&H60000000", BackColour: "&H60000000",
+		BorderStyle: 3, Outline: 6, Shadow: 0, MarginV: 30,
+	},
+	"minimal": {
+		Name: "minimal", FontName: "Arial", FontSize: 16,
+		PrimaryColour: "&H00FFFFFF", OutlineColour: "&H00000000", BackColour: "&H00000000",
+		BorderStyle: 1, Outline: 1, Shadow: 0, MarginV: 20,
+	},
+}
+
+// GetCaptionStyle 按名称获取样式预设
+func GetCaptionStyle(name string) (CaptionStyle, error) {
+	style, ok := CaptionStyles[name]
+	if !ok {
+		return CaptionStyle{}, fmt.Errorf("未知的字幕样式: %s", name)
+	}
+	return style, nil
+}
+
+// ForceStyle 渲染为 subtitles 滤镜的 force_style 参数
+func (s CaptionStyle) ForceStyle() string {
+	bold := 0
+	if s.Bold {
+		bold = 1
+	}
+	fields := []string{
+		"FontName=" + s.FontName,
+		fmt.Sprintf("FontSize=%d", s.FontSize),
+		fmt.Sprintf("Bold=%d", bold),
+		"PrimaryColour=" + s.PrimaryColour,
+		"OutlineColour=" + s.OutlineColour,
+		"BackColour=" + s.BackColour,
+		fmt.Sprintf("BorderStyle=%d", s.BorderStyle),
+		fmt.Sprintf("Outline=%d", s.Outline),
+		fmt.Sprintf("Shadow=%d", s.Shadow),
+		fmt.Sprintf("MarginV=%d", s.MarginV),
+	}
+	return strings.Join(fields, ",")
+}
+
+// FormatSRT 生成 SRT 字幕
+func FormatSRT(segments []TranscriptSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatCaptionTime(segment.Start, ","),
+			formatCaptionTime(segment.End, ","),
+			segment.Text)
+	}
+	return b.String()
+}
+
+// FormatVTT 生成 WebVTT 字幕
+func FormatVTT(segments []TranscriptSegment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, segment := range segments {
+		// WebVTT 中 "-->" 有特殊含义，文本中出现时替换掉
+		text := strings.ReplaceAll(segment.Text, "-->", "->")
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n",
+			formatCaptionTime(segment.Start, "."),
+			formatCaptionTime(segment.End, "."),
+			text)
+	}
+	return b.String()
+}
+
+// formatCaptionTime 格式化为 HH:MM:SS,mmm（SRT）或 HH:MM:SS.mmm（WebVTT）
+func formatCaptionTime(seconds float64, separator string) string {
+	ms := int64(math.Round(math.Max(0, seconds) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// CaptionPaths 返回视频旁边的字幕文件路径
+func CaptionPaths(videoPath string) (srtPath, vttPath string) {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	return base + ".srt", base + ".vtt"
+}
+
+// WriteCaptionFiles 把字幕稿导出为视频旁边的 SRT 和 WebVTT 文件
+func WriteCaptionFiles(transcript *Transcript) (srtPath, vttPath string, err error) {
+	srtPath, vttPath = CaptionPaths(transcript.VideoPath)
+	if err := os.WriteFile(srtPath, []byte(FormatSRT(transcript.Segments)), 0644); err != nil {
+		return "", "", fmt.Errorf("写入 SRT 字幕失败: %w", err)
+	}
+	if err := os.WriteFile(vttPath, []byte(FormatVTT(transcript.Segments)), 0644); err != nil {
+		return "", "", fmt.Errorf("写入 WebVTT 字幕失败: %w", err)
+	}
+
+	fmt.Printf("✓ 字幕已导出: %s, %s\n", srtPath, vttPath)
+	return srtPath, vttPath, nil
+}
+
+// captionStage 后处理阶段：把字幕稿烧录进导出的视频
+// 导出视频与录制视频时间轴一致，直接使用录制视频的字幕稿；返回的 cleanup 删除临时字幕文件
+func captionStage(transcript *Transcript, outputPath, styleName string) (postProcessStage, func(), error) {
+	style, err := GetCaptionStyle(styleName)
+	if err != nil {
+		return postProcessStage{}, nil, err
+	}
+	if len(transcript.Segments) == 0 {
+		return postProcessStage{}, nil, fmt.Errorf("字幕稿为空")
+	}
+
+	subtitlePath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".captions.srt"
+	if err := os.WriteFile(subtitlePath, []byte(FormatSRT(transcript.Segments)), 0644); err != nil {
+		return postProcessStage{}, nil, fmt.Errorf("写入临时字幕失败: %w", err)
+	}
+	fmt.Printf("烧录字幕: %s 样式，%d 条\n", style.Name, len(transcript.Segments))
+
+	return postProcessStage{
+		name: "烧录字幕",
+		video: func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error) {
+			return command.FilterGraph().Apply(ffmpeg.NewFilter("subtitles").
+				Set("filename", filepath.ToSlash(subtitlePath)).
+				Set("force_style", style.ForceStyle()), video), nil
+		},
+	}, func() { os.Remove(subtitlePath) }, nil
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 麦克风比视频晚 0.5 秒开始，识别结果换算到视频时间轴后整体后移 0.5 秒
+const (
+	goldenSRT = "1\n00:00:00,500 --> 00:00:01,700\n大家好\n\n" +
+		"2\n00:00:02,000 --> 00:00:03,750\n今天演示 --> 录屏\n\n"
+	goldenVTT = "WEBVTT\n\n" +
+		"00:00:00.500 --> 00:00:01.700\n大家好\n\n" +
+		"00:00:02.000 --> 00:00:03.750\n今天演示 -> 录屏\n\n"
+)
+
+// newCaptionSession 在临时目录中准备一段视频和与之同时录制的麦克风音频
+func newCaptionSession(t *testing.T) (videoPath, micPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	videoPath = filepath.Join(dir, "recording.mp4")
+	micPath = filepath.Join(dir, "recording_mic.wav")
+	for _, path := range []string{videoPath, micPath} {
+		if err := os.WriteFile(path, []byte("media"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	video := StreamTiming{Path: videoPath, Kind: StreamVideo, Start: 10, Measured: true}
+	mic := StreamTiming{Path: micPath, Kind: StreamMicAudio, Start: 10.5, Measured: true}
+	if err := RecordAVSync(video, mic); err != nil {
+		t.Fatal(err)
+	}
+	return videoPath, micPath
+}
+
+func TestTranscribeRecordingCaptions(t *testing.T) {
+	videoPath, micPath := newCaptionSession(t)
+	transcriber := &FakeTranscriber{Segments: []TranscriptSegment{
+		{Start: -1, End: -0.6, Text: "视频开始前"}, // 换算后整段在视频开始前，丢弃
+		{Start: 0, End: 1.2, Text: " 大家好 "},
+		{Start: 1.5, End: 3.25, Text: "今天演示 --> 录屏"},
+		{Start: 4, End: 5, Text: "   "}, // 空白文本，丢弃
+	}}
+
+	transcript, err := TranscribeRecording(context.Background(), transcriber, videoPath, AudioTrack{}, TranscribeOptions{Language: "zh"})
+	if err != nil {
+		t.Fatalf("TranscribeRecording: %v", err)
+	}
+	if transcript.AudioPath != micPath || transcript.Offset != 0.5 || transcript.Engine != "fake" {
+		t.Errorf("字幕稿来源不符: audio=%s offset=%v engine=%s", transcript.AudioPath, transcript.Offset, transcript.Engine)
+	}
+
+	if srt := FormatSRT(transcript.Segments); srt != goldenSRT {
+		t.Errorf("SRT 不符\n got: %q\nwant: %q", srt, goldenSRT)
+	}
+	if vtt := FormatVTT(transcript.Segments); vtt != goldenVTT {
+		t.Errorf("WebVTT 不符\n got: %q\nwant: %q", vtt, goldenVTT)
+	}
+
+	// 字幕稿保存在会话中，再导出得到相同的文件
+	saved, err := LoadTranscript(videoPath)
+	if err != nil {
+		t.Fatalf("LoadTranscript: %v", err)
+	}
+	srtPath, vttPath, err := WriteCaptionFiles(saved)
+	if err != nil {
+		t.Fatalf("WriteCaptionFiles: %v", err)
+	}
+	for path, want := range map[string]string{srtPath: goldenSRT, vttPath: goldenVTT} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s 不符\n got: %q\nwant: %q", filepath.Base(path), data, want)
+		}
+	}
+}
+
+func TestUpdateTranscriptCaptions(t *testing.T) {
+	videoPath, _ := newCaptionSession(t)
+	transcriber := &FakeTranscriber{Segments: []TranscriptSegment{{Start: 0, End: 1, Text: "原文"}}}
+	if _, err := TranscribeRecording(context.Background(), transcriber, videoPath, AudioTrack{}, TranscribeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 编辑后的分段已经是视频时间，不再应用偏移
+	edited, err := UpdateTranscript(videoPath, []TranscriptSegment{
+		{Start: 3725.0049, End: 3727.5, Text: "修改后"},
+		{Start: 5, End: 4, Text: "倒置"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTranscript: %v", err)
+	}
+	if !edited.Edited {
+		t.Error("编辑后的字幕稿应标记为 Edited")
+	}
+
+	want := "1\n01:02:05,005 --> 01:02:07,500\n修改后\n\n" +
+		"2\n00:00:05,000 --> 00:00:05,000\n倒置\n\n"
+	if srt := FormatSRT(edited.Segments); srt != want {
+		t.Errorf("SRT 不符\n got: %q\nwant: %q", srt, want)
+	}
+}
+
+func TestTranscribeRecordingError(t *testing.T) {
+	videoPath, _ := newCaptionSession(t)
+	failure := errors.New("模型加载失败")
+
+	_, err := TranscribeRecording(context.Background(), &FakeTranscriber{Err: failure}, videoPath, AudioTrack{}, TranscribeOptions{})
+	if !errors.Is(err, failure) {
+		t.Fatalf("TranscribeRecording 错误 = %v, want %v", err, failure)
+	}
+	if _, err := LoadTranscript(videoPath); err == nil {
+		t.Error("识别失败时不应保存字幕稿")
+	}
+}
@@ -0,0 +1,217 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"SmoothScreen/pkg/hook"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cursorHiddenOffset 不显示的光标图像放到画面之外
+const cursorHiddenOffset = -10000
+
+// standardCursorSize 系统光标在 100% 缩放下的标准尺寸（ExportConfig.CursorSize 相对它缩放）
+const standardCursorSize = 32
+
+// cursorOverlay 把录制的真实光标图像叠加到源视频上
+// 每个光标图像是一路循环的 PNG 输入和一个命名的 overlay 滤镜，
+// sendcmd 按鼠标位置和形状变化移动当前显示的图像，其余图像移到画面之外。
+// 光标在相机变换之前叠加，随画面一起缩放和平移
+type cursorOverlay struct {
+	dir     string // 临时目录：光标 PNG 和 sendcmd 命令文件
+	track   *hook.CursorTrack
+	mouse   []hook.MouseEvent
+	sprites []string       // 按首次出现排序的图像哈希
+	index   map[string]int // 图像哈希 -> 下标（overlay@cursorN）
+	scale   float64
+}
+
+// loadCursorTrack 加载导出使用的光标形状数据
+// 默认读取 CursorPathFor(视频路径)，不存在时返回 nil
+func loadCursorTrack(config ExportConfig) (*hook.CursorTrack, error) {
+	path := config.CursorDataPath
+	if path == "" {
+		if config.VideoPath == "" {
+			return nil, nil
+		}
+		path = CursorPathFor(config.VideoPath)
+		if !fileExists(path) {
+			return nil, nil
+		}
+	}
+	return hook.LoadCursorTrack(path)
+}
+
+// newCursorOverlay 准备光标叠加，把用到的光标图像写入临时目录
+// 没有光标形状数据时返回 nil（导出中不绘制光标）
+func newCursorOverlay(config ExportConfig, mouse []hook.MouseEvent) (*cursorOverlay, error) {
+	track, err := loadCursorTrack(config)
+	if err != nil || track == nil || len(track.Events) == 0 {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "smoothscreen-cursor-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建光标临时目录失败: %w", err)
+	}
+
+	overlay := &cursorOverlay{
+		dir:   dir,
+		track: track,
+		mouse: mouse,
+		index: make(map[string]int),
+		scale: 1.0,
+	}
+	if config.CursorSize > 0 {
+		overlay.scale = float64(config.CursorSize) / standardCursorSize
+	}
+
+	for _, event := range track.Events {
+		if _, ok := overlay.index[event.Sprite]; ok || event.Sprite == "" {
+			continue
+		}
+		sprite, ok := track.Sprites[event.Sprite]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(overlay.spritePath(len(overlay.sprites)), sprite.PNG, 0644); err != nil {
+			overlay.Close()
+			return nil, fmt.Errorf("写入光标图像失败: %w", err)
+		}
+		overlay.index[event.Sprite] = len(overlay.sprites)
+		overlay.sprites = append(overlay.sprites, event.Sprite)
+	}
+	if len(overlay.sprites) == 0 {
+		overlay.Close()
+		return nil, nil
+	}
+	return overlay, nil
+}
+
+// spritePath 第 index 个光标图像的文件路径
+func (o *cursorOverlay) spritePath(index int) string {
+	return filepath.Join(o.dir, fmt.Sprintf("cursor%d.png", index))
+}
+
+// apply 在 source 上叠加光标，返回叠加后的视频
+// [startMs, endMs] 为这段视频对应的录制时间（整段导出时 endMs 为 0），分段导出只生成该段的命令
+func (o *cursorOverlay) apply(cmd *ffmpeg.Command, source ffmpeg.Pad, startMs, endMs int64) (ffmpeg.Pad, error) {
+	commandPath := filepath.Join(o.dir, fmt.Sprintf("commands_%d.txt", startMs))
+	if err := os.WriteFile(commandPath, []byte(o.commands(startMs, endMs)), 0644); err != nil {
+		return ffmpeg.Pad{}, fmt.Errorf("写入光标命令失败: %w", err)
+	}
+
+	graph := cmd.FilterGraph()
+	video := graph.Apply(ffmpeg.NewFilter("sendcmd").Set("f", commandPath), source)
+	for i := range o.sprites {
+		sprite := cmd.Input(o.spritePath(i), ffmpeg.Opt("loop", 1)).Video()
+		if o.scale != 1.0 {
+			sprite = graph.Apply(ffmpeg.NewFilter("scale").
+				Set("w", fmt.Sprintf("iw*%.3f", o.scale)).
+				Set("h", fmt.Sprintf("ih*%.3f", o.scale)), sprite)
+		}
+		// 循环的图像输入是无限长的，以视频结束为准
+		video = graph.Apply(ffmpeg.NewFilter(fmt.Sprintf("overlay@cursor%d", i)).
+			Set("x", cursorHiddenOffset).
+			Set("y", cursorHiddenOffset).
+			Set("shortest", 1), video, sprite)
+	}
+	return video, nil
+}
+
+// commands 生成 sendcmd 命令：形状变化时切换显示的图像，鼠标移动时移动图像（按热点对齐）
+func (o *cursorOverlay) commands(startMs, endMs int64) string {
+	var b strings.Builder
+	shown, shownX, shownY := -1, 0, 0 // 已显示的图像和位置
+	current := -1                     // 当前形状对应的图像（隐藏时为 -1）
+	mouseX, mouseY, hasMouse := 0, 0, false
+	pending := false // startMs 之前的变化在时间 0 一并生效
+
+	emit := func(timestamp int64) {
+		if timestamp < startMs {
+			pending = true
+			return
+		}
+		pending = false
+		target := current
+		if !hasMouse {
+			target = -1
+		}
+
+		var commands []string
+		if shown >= 0 && target != shown {
+			commands = append(commands, o.move(shown, cursorHiddenOffset, cursorHiddenOffset)...)
+		}
+		x, y := 0, 0
+		if target >= 0 {
+			sprite := o.track.Sprites[o.sprites[target]]
+			x = mouseX - int(math.Round(float64(sprite.HotspotX)*o.scale))
+			y = mouseY - int(math.Round(float64(sprite.HotspotY)*o.scale))
+			if target != shown || x != shownX || y != shownY {
+				commands = append(commands, o.move(target, x, y)...)
+			}
+		}
+		shown, shownX, shownY = target, x, y
+
+		if len(commands) > 0 {
+			fmt.Fprintf(&b, "%.3f %s;\n", float64(timestamp-startMs)/1000, strings.Join(commands, ", "))
+		}
+	}
+
+	mouseIndex, shapeIndex := 0, 0
+	for mouseIndex < len(o.mouse) || shapeIndex < len(o.track.Events) {
+		// 按时间合并鼠标事件和形状事件，同一时刻的事件一起生效
+		timestamp := int64(math.MaxInt64)
+		if mouseIndex < len(o.mouse) {
+			timestamp = o.mouse[mouseIndex].Timestamp
+		}
+		if shapeIndex < len(o.track.Events) {
+			timestamp = min(timestamp, o.track.Events[shapeIndex].Timestamp)
+		}
+		if pending && timestamp > startMs {
+			emit(startMs)
+		}
+		if endMs > 0 && timestamp > endMs {
+			break
+		}
+
+		for mouseIndex < len(o.mouse) && o.mouse[mouseIndex].Timestamp == timestamp {
+			mouseX, mouseY = int(o.mouse[mouseIndex].X), int(o.mouse[mouseIndex].Y)
+			hasMouse = true
+			mouseIndex++
+		}
+		for shapeIndex < len(o.track.Events) && o.track.Events[shapeIndex].Timestamp == timestamp {
+			event := o.track.Events[shapeIndex]
+			current = -1
+			if index, ok := o.index[event.Sprite]; ok && event.Visible {
+				current = index
+			}
+			shapeIndex++
+		}
+		emit(timestamp)
+	}
+	if pending {
+		emit(startMs)
+	}
+	return b.String()
+}
+
+// move 移动第 index 个光标图像的命令
+func (o *cursorOverlay) move(index, x, y int) []string {
+	target := fmt.Sprintf("overlay@cursor%d", index)
+	return []string{
+		fmt.Sprintf("%s x %d", target, x),
+		fmt.Sprintf("%s y %d", target, y),
+	}
+}
+
+// Close 删除临时文件
+func (o *cursorOverlay) Close() {
+	if o == nil {
+		return
+	}
+	os.RemoveAll(o.dir)
+}
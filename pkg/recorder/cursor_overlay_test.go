@@ -0,0 +1,129 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"strings"
+	"testing"
+)
+
+// testCursorOverlay 两个光标图像：箭头（热点 2,3）和文本光标（热点 8,8）
+func testCursorOverlay(scale float64, mouse []hook.MouseEvent, shapes []hook.CursorShapeEvent) *cursorOverlay {
+	return &cursorOverlay{
+		track: &hook.CursorTrack{
+			Events: shapes,
+			Sprites: map[string]hook.CursorSprite{
+				"arrow": {Width: 32, Height: 32, HotspotX: 2, HotspotY: 3},
+				"ibeam": {Width: 16, Height: 24, HotspotX: 8, HotspotY: 8},
+			},
+		},
+		mouse:   mouse,
+		sprites: []string{"arrow", "ibeam"},
+		index:   map[string]int{"arrow": 0, "ibeam": 1},
+		scale:   scale,
+	}
+}
+
+func TestCursorOverlayCommands(t *testing.T) {
+	mouse := []hook.MouseEvent{
+		{Timestamp: 0, X: 100, Y: 100, EventType: "move"},
+		{Timestamp: 300, X: 110, Y: 120, EventType: "move"},
+		{Timestamp: 500, X: 200, Y: 200, EventType: "move"},
+		{Timestamp: 1000, X: 210, Y: 210, EventType: "move"},
+		{Timestamp: 1500, X: 300, Y: 300, EventType: "move"},
+	}
+	shapes := []hook.CursorShapeEvent{
+		{Timestamp: 0, Visible: true, Sprite: "arrow"},
+		{Timestamp: 500, Visible: true, Sprite: "ibeam"},
+		{Timestamp: 800, Visible: false},
+		{Timestamp: 1200, Visible: true, Sprite: "arrow"},
+	}
+
+	tests := []struct {
+		name    string
+		overlay *cursorOverlay
+		startMs int64
+		endMs   int64
+		want    []string
+	}{
+		{
+			// 按热点对齐，切换形状时隐藏旧图像，隐藏期间的移动不输出
+			name:    "整段导出",
+			overlay: testCursorOverlay(1, mouse, shapes),
+			want: []string{
+				"0.000 overlay@cursor0 x 98, overlay@cursor0 y 97;",
+				"0.300 overlay@cursor0 x 108, overlay@cursor0 y 117;",
+				"0.500 overlay@cursor0 x -10000, overlay@cursor0 y -10000, overlay@cursor1 x 192, overlay@cursor1 y 192;",
+				"0.800 overlay@cursor1 x -10000, overlay@cursor1 y -10000;",
+				"1.200 overlay@cursor0 x 208, overlay@cursor0 y 207;",
+				"1.500 overlay@cursor0 x 298, overlay@cursor0 y 297;",
+			},
+		},
+		{
+			// 段首之前的变化在段首一并生效，时间相对段首，段尾之后的事件忽略
+			name:    "分段导出",
+			overlay: testCursorOverlay(1, mouse, shapes),
+			startMs: 400,
+			endMs:   1100,
+			want: []string{
+				"0.000 overlay@cursor0 x 108, overlay@cursor0 y 117;",
+				"0.100 overlay@cursor0 x -10000, overlay@cursor0 y -10000, overlay@cursor1 x 192, overlay@cursor1 y 192;",
+				"0.400 overlay@cursor1 x -10000, overlay@cursor1 y -10000;",
+			},
+		},
+		{
+			name:    "段首与事件同时",
+			overlay: testCursorOverlay(1, mouse, shapes),
+			startMs: 500,
+			endMs:   900,
+			want: []string{
+				"0.000 overlay@cursor1 x 192, overlay@cursor1 y 192;",
+				"0.300 overlay@cursor1 x -10000, overlay@cursor1 y -10000;",
+			},
+		},
+		{
+			name:    "段内没有事件",
+			overlay: testCursorOverlay(1, mouse, shapes),
+			startMs: 2000,
+			want:    []string{"0.000 overlay@cursor0 x 298, overlay@cursor0 y 297;"},
+		},
+		{
+			name:    "段内光标隐藏",
+			overlay: testCursorOverlay(1, mouse, shapes),
+			startMs: 850,
+			endMs:   1100,
+		},
+		{
+			// 热点按比例对齐，鼠标位置未知时不显示
+			name: "缩放光标",
+			overlay: testCursorOverlay(1.5,
+				[]hook.MouseEvent{{Timestamp: 200, X: 100, Y: 100, EventType: "move"}},
+				[]hook.CursorShapeEvent{{Timestamp: 0, Visible: true, Sprite: "arrow"}, {Timestamp: 400, Visible: true, Sprite: "ibeam"}},
+			),
+			want: []string{
+				"0.200 overlay@cursor0 x 97, overlay@cursor0 y 95;",
+				"0.400 overlay@cursor0 x -10000, overlay@cursor0 y -10000, overlay@cursor1 x 88, overlay@cursor1 y 88;",
+			},
+		},
+		{
+			name: "未知图像视为隐藏",
+			overlay: testCursorOverlay(1, mouse[:1],
+				[]hook.CursorShapeEvent{{Timestamp: 0, Visible: true, Sprite: "arrow"}, {Timestamp: 100, Visible: true, Sprite: "unknown"}},
+			),
+			want: []string{
+				"0.000 overlay@cursor0 x 98, overlay@cursor0 y 97;",
+				"0.100 overlay@cursor0 x -10000, overlay@cursor0 y -10000;",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := ""
+			if len(tt.want) > 0 {
+				want = strings.Join(tt.want, "\n") + "\n"
+			}
+			if got := tt.overlay.commands(tt.startMs, tt.endMs); got != want {
+				t.Errorf("命令不符\n got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -3,12 +3,13 @@ package recorder
 import (
 	"SmoothScreen/pkg/ffmpeg"
 	"SmoothScreen/pkg/hook"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // CustomExportParams 自定义导出参数
@@ -39,16 +40,20 @@ type CustomExporter struct {
 	bgParams      BackgroundParams
 	cursorImage   string // 光标图片（base64 或文件路径）
 	mouseEvents   []hook.MouseEvent
+	keyEvents     []hook.KeyboardEvent
 	cameraFrames  []CameraFrame
-	cmd           *exec.Cmd
-	isExporting   bool
+	isExporting   atomic.Bool // Stop 可能在其他协程调用
+	log           *tailBuffer // FFmpeg 输出末尾，用于错误日志
+
+	mu      sync.Mutex // 保护导出进程
+	process *ffmpeg.Process
 }
 
 // NewCustomExporter 创建自定义导出器
 func NewCustomExporter(ffmpegManager *ffmpeg.FFmpegManager) *CustomExporter {
 	return &CustomExporter{
 		ffmpegManager: ffmpegManager,
-		isExporting:   false,
+		log:           newTailBuffer(exportLogTailSize),
 		customParams: CustomExportParams{
 			Smoothness:      0.15,
 			ZoomLevel:       1.5,
@@ -94,6 +99,12 @@ func (e *CustomExporter) PrepareCustomExport(
 		return fmt.Errorf("解析鼠标数据失败: %w", err)
 	}
 
+	// 加载键盘数据（打字缩放）
+	e.keyEvents, err = LoadKeyboardEvents(config)
+	if err != nil {
+		return fmt.Errorf("加载键盘数据失败: %w", err)
+	}
+
 	// 使用自定义参数生成相机路径
 	e.cameraFrames = e.generateCustomCameraPath()
 
@@ -107,60 +118,28 @@ func (e *CustomExporter) PrepareCustomExport(
 }
 
 // generateCustomCameraPath 使用自定义参数生成相机路径
+// 导出配置中的其他相机选项（打字缩放、拖拽缩放等）保持生效
 func (e *CustomExporter) generateCustomCameraPath() []CameraFrame {
-	controller := NewCameraController(
-		e.config.ScreenWidth,
-		e.config.ScreenHeight,
-		e.customParams.Smoothness, // 使用自定义平滑度
-		e.customParams.ZoomLevel,  // 使用自定义缩放
-	)
-
-	frames := []CameraFrame{}
-	frameDuration := 1000.0 / float64(e.config.FPS)
-	totalDuration := 0.0
+	controller := NewCameraControllerForConfig(e.config)
+	controller.SetClickZoom(e.customParams.ZoomLevel)
 
-	if len(e.mouseEvents) > 0 {
-		totalDuration = float64(e.mouseEvents[len(e.mouseEvents)-1].Timestamp)
+	// 速度越大，跟随越快（平滑系数越大，每帧越接近目标）
+	smoothness := e.customParams.Smoothness
+	if e.customParams.Speed > 0 {
+		smoothness *= e.customParams.Speed
 	}
+	controller.SetSmoothFactor(smoothness)
 
-	lastEventIndex := 0
-
-	for t := 0.0; t < totalDuration; t += frameDuration {
-		currentTime := int64(t)
-
-		// 应用当前时间点的鼠标事件
-		for lastEventIndex < len(e.mouseEvents) && e.mouseEvents[lastEventIndex].Timestamp <= currentTime {
-			controller.Update(e.mouseEvents[lastEventIndex])
-			lastEventIndex++
-		}
-
-		// 生成相机帧
-		frame := controller.GetCurrentFrame(currentTime)
-
-		// 应用速度调整（通过调整平滑度实现）
-		if e.customParams.Speed != 1.0 {
-			// 速度越大，跟随越快
-			speedFactor := e.customParams.Speed
-			frame.X = frame.X * speedFactor
-			frame.Y = frame.Y * speedFactor
-		}
-
-		frames = append(frames, frame)
-	}
-
-	return frames
+	return GenerateCameraPathWithKeyboard(e.mouseEvents, e.keyEvents, controller, e.config.FPS)
 }
 
 // ExportWithCustomParams 使用自定义参数导出
-func (e *CustomExporter) ExportWithCustomParams() error {
-	if e.isExporting {
+// 取消 ctx 会终止 FFmpeg 进程
+func (e *CustomExporter) ExportWithCustomParams(ctx context.Context) error {
+	if !e.isExporting.CompareAndSwap(false, true) {
 		return fmt.Errorf("导出已在进行中")
 	}
-
-	ffmpegPath, err := e.ffmpegManager.GetFFmpegPath()
-	if err != nil {
-		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
-	}
+	defer e.isExporting.Store(false)
 
 	codec, err := e.ffmpegManager.GetBestEncoder()
 	if err != nil {
@@ -169,85 +148,88 @@ func (e *CustomExporter) ExportWithCustomParams() error {
 	preset := e.ffmpegManager.GetBestPreset(codec)
 
 	// 构建 FFmpeg 命令
-	args := e.buildCustomExportCommand(ffmpegPath, codec, preset)
-
-	fmt.Printf("执行自定义导出命令:\n%s %s\n", ffmpegPath, strings.Join(args, " "))
-
-	e.cmd = exec.Command(ffmpegPath, args...)
-	e.cmd.Stdout = os.Stdout
-	e.cmd.Stderr = os.Stderr
+	args, err := e.buildCustomExportCommand(codec, preset)
+	if err != nil {
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
 
-	e.isExporting = true
+	e.log.Reset()
+	process, err := e.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: "自定义导出", Log: e.log})
+	if err != nil {
+		return fmt.Errorf("创建 FFmpeg 进程失败: %w", err)
+	}
+	e.mu.Lock()
+	e.process = process
+	e.mu.Unlock()
 
-	if err := e.cmd.Run(); err != nil {
-		e.isExporting = false
+	if err := process.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("导出已取消")
+		}
 		return fmt.Errorf("FFmpeg 执行失败: %w", err)
 	}
 
-	e.isExporting = false
 	fmt.Printf("✓ 自定义导出完成: %s\n", e.config.OutputPath)
 	return nil
 }
 
 // buildCustomExportCommand 构建自定义导出命令
-func (e *CustomExporter) buildCustomExportCommand(ffmpegPath, codec, preset string) []string {
-	args := []string{}
+func (e *CustomExporter) buildCustomExportCommand(codec, preset string) ([]string, error) {
+	cmd := ffmpeg.NewCommand().Overwrite().Global(ffmpeg.HWDeviceArgs(codec)...)
+
+	// 输入视频
+	input := cmd.Input(e.config.VideoPath)
 
 	// 硬件加速
 	if strings.Contains(codec, "nvenc") {
-		args = append(args, "-hwaccel", "cuda")
-		args = append(args, "-hwaccel_output_format", "cuda")
+		input.With(ffmpeg.Opt("hwaccel", "cuda"), ffmpeg.Opt("hwaccel_output_format", "cuda"))
 	}
 
-	// 输入视频
-	args = append(args, "-i", e.config.VideoPath)
-
-	// 构建复杂滤镜链
-	filterComplex := e.buildCustomFilterComplex()
-	if filterComplex != "" {
-		args = append(args, "-filter_complex", filterComplex)
+	// 构建滤镜图
+	graph := cmd.FilterGraph()
+	video := e.buildCustomFilterGraph(graph, input.Video())
+	upload := ffmpeg.HWUploadFilters(codec)
+	if len(upload) > 0 {
+		// VAAPI 等编码器需要把帧上传到显存
+		video = graph.Chain([]ffmpeg.Pad{video}, upload...)
 	}
 
+	// 输出：处理后的视频 + 原视频的音频（如果有）
+	output := cmd.Output(e.config.OutputPath).
+		Map(video, input.Stream("a?")).
+		VideoCodec(codec)
+
 	// 编码器设置
-	args = append(args, "-c:v", codec)
-	args = append(args, "-preset", preset)
+	if preset != "" {
+		output.With(ffmpeg.Opt("preset", preset))
+	}
 
-	if codec == "libx264" {
-		args = append(args, "-crf", "23")
+	if !ffmpeg.IsHardwareEncoder(codec) {
+		output.With(softwareQualityOptions(codec)...)
 	} else if strings.Contains(codec, "nvenc") {
-		args = append(args, "-cq", "23")
-		args = append(args, "-b:v", "5M")
+		output.With(ffmpeg.Opt("cq", 23), ffmpeg.Opt("b:v", "5M"))
 	}
 
-	args = append(args, "-r", fmt.Sprintf("%d", e.config.FPS))
-	args = append(args, "-pix_fmt", "yuv420p")
-	args = append(args, "-y")
-	args = append(args, e.config.OutputPath)
+	output.With(ffmpeg.Opt("r", e.config.FPS))
+	if len(upload) == 0 {
+		output.With(ffmpeg.Opt("pix_fmt", "yuv420p"))
+	}
 
-	return args
+	return cmd.Args()
 }
 
-// buildCustomFilterComplex 构建自定义滤镜链
-func (e *CustomExporter) buildCustomFilterComplex() string {
-	filters := []string{}
-
+// buildCustomFilterGraph 构建自定义滤镜图，返回最终的视频输出
+func (e *CustomExporter) buildCustomFilterGraph(graph *ffmpeg.FilterGraph, source ffmpeg.Pad) ffmpeg.Pad {
 	// 1. 生成背景
-	bgFilter := e.generateBackgroundFilter()
-	if bgFilter != "" {
-		filters = append(filters, bgFilter)
-	}
+	bg, hasBackground := e.addBackground(graph)
 
 	// 2. 缩放视频内容
 	videoScale := e.customParams.VideoScale
+	scaled := source
 	if videoScale != 1.0 {
 		scaledWidth := int(float64(e.config.ScreenWidth) * videoScale)
 		scaledHeight := int(float64(e.config.ScreenHeight) * videoScale)
-		filters = append(filters, fmt.Sprintf(
-			"[0:v]scale=%d:%d[scaled]",
-			scaledWidth, scaledHeight,
-		))
-	} else {
-		filters = append(filters, "[0:v]copy[scaled]")
+		scaled = graph.Apply(ffmpeg.NewFilter("scale", scaledWidth, scaledHeight), source)
 	}
 
 	// 3. 应用相机变换（crop + scale）
@@ -273,66 +255,53 @@ func (e *CustomExporter) buildCustomFilterComplex() string {
 		cropY = e.config.ScreenHeight - cropH
 	}
 
-	filters = append(filters, fmt.Sprintf(
-		"[scaled]crop=%d:%d:%d:%d[cropped]",
-		cropW, cropH, cropX, cropY,
-	))
-
-	// 4. 缩放回目标分辨率
-	filters = append(filters, fmt.Sprintf(
-		"[cropped]scale=%d:%d[final]",
-		e.config.ScreenWidth, e.config.ScreenHeight,
-	))
+	// 4. 裁剪后缩放回目标分辨率
+	final := graph.Chain([]ffmpeg.Pad{scaled},
+		ffmpeg.NewFilter("crop", cropW, cropH, cropX, cropY),
+		ffmpeg.NewFilter("scale", e.config.ScreenWidth, e.config.ScreenHeight),
+	)
 
 	// 5. 叠加到背景（如果有）
-	if bgFilter != "" {
-		offsetX := int(float64(e.config.ScreenWidth) * (1.0 - videoScale) / 2)
-		offsetY := int(float64(e.config.ScreenHeight) * (1.0 - videoScale) / 2)
-
-		filters = append(filters, fmt.Sprintf(
-			"[bg][final]overlay=%d:%d[output]",
-			offsetX, offsetY,
-		))
+	if !hasBackground {
+		return final
 	}
 
-	return strings.Join(filters, ";")
+	offsetX := int(float64(e.config.ScreenWidth) * (1.0 - videoScale) / 2)
+	offsetY := int(float64(e.config.ScreenHeight) * (1.0 - videoScale) / 2)
+
+	// 背景源是无限长的，以视频结束为准
+	return graph.Apply(
+		ffmpeg.NewFilter("overlay", offsetX, offsetY).Set("shortest", 1),
+		bg, final,
+	)
 }
 
-// generateBackgroundFilter 生成背景滤镜
-func (e *CustomExporter) generateBackgroundFilter() string {
+// addBackground 向滤镜图添加背景源，返回背景输出
+func (e *CustomExporter) addBackground(graph *ffmpeg.FilterGraph) (ffmpeg.Pad, bool) {
+	size := fmt.Sprintf("%dx%d", e.config.ScreenWidth, e.config.ScreenHeight)
+
 	switch e.bgParams.Type {
 	case "solid":
 		// 纯色背景
-		return fmt.Sprintf(
-			"color=c=%s:s=%dx%d[bg]",
-			e.bgParams.Color,
-			e.config.ScreenWidth,
-			e.config.ScreenHeight,
-		)
+		return graph.Source(ffmpeg.NewFilter("color").Set("c", e.bgParams.Color).Set("s", size)), true
 
 	case "gradient":
 		// 渐变背景（使用 FFmpeg 的 color 和 blend）
-		return fmt.Sprintf(
-			"color=c=%s:s=%dx%d[c1];color=c=%s:s=%dx%d[c2];[c1][c2]blend=all_mode='addition'[bg]",
-			e.bgParams.GradientColor1,
-			e.config.ScreenWidth,
-			e.config.ScreenHeight,
-			e.bgParams.GradientColor2,
-			e.config.ScreenWidth,
-			e.config.ScreenHeight,
-		)
+		c1 := graph.Source(ffmpeg.NewFilter("color").Set("c", e.bgParams.GradientColor1).Set("s", size))
+		c2 := graph.Source(ffmpeg.NewFilter("color").Set("c", e.bgParams.GradientColor2).Set("s", size))
+		return graph.Apply(ffmpeg.NewFilter("blend").Set("all_mode", "addition"), c1, c2), true
 
 	case "image":
-		// 图片背景
+		// 图片背景（循环播放并缩放到画面大小，路径由滤镜图转义）
 		if e.bgParams.ImagePath != "" {
-			return fmt.Sprintf(
-				"movie=%s[bg]",
-				e.bgParams.ImagePath,
-			)
+			return graph.Source(
+				ffmpeg.NewFilter("movie").Set("filename", e.bgParams.ImagePath).Set("loop", 0),
+				ffmpeg.NewFilter("scale", e.config.ScreenWidth, e.config.ScreenHeight),
+			), true
 		}
 	}
 
-	return ""
+	return ffmpeg.Pad{}, false
 }
 
 // calculateAverageCameraParams 计算平均相机参数
@@ -356,20 +325,26 @@ func (e *CustomExporter) calculateAverageCameraParams() (float64, float64, float
 
 // Stop 停止导出
 func (e *CustomExporter) Stop() error {
-	if !e.isExporting {
+	if !e.isExporting.Load() {
 		return nil
 	}
 
-	if e.cmd != nil && e.cmd.Process != nil {
-		if err := e.cmd.Process.Kill(); err != nil {
+	e.mu.Lock()
+	process := e.process
+	e.mu.Unlock()
+	if process != nil {
+		if err := process.Stop(); err != nil {
 			return err
 		}
 	}
-
-	e.isExporting = false
 	return nil
 }
 
+// GetLog 获取最近一次导出的 FFmpeg 输出末尾
+func (e *CustomExporter) GetLog() string {
+	return e.log.String()
+}
+
 // GetCameraFrames 获取相机帧
 func (e *CustomExporter) GetCameraFrames() []CameraFrame {
 	return e.cameraFrames
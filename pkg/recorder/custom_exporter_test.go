@@ -0,0 +1,81 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCustomExportBackgroundImageArgs(t *testing.T) {
+	e := NewCustomExporter(nil)
+	e.config = DefaultExportConfig()
+	e.config.ScreenWidth, e.config.ScreenHeight = 1920, 1080
+	e.config.VideoPath = `C:\Users\张三\录制\it's a, test.mp4`
+	e.config.OutputPath = `C:\Users\张三\导出\final [v2].mp4`
+	e.bgParams = BackgroundParams{Type: "image", ImagePath: `C:\Users\张三\背景\bg's image, [1];2.png`}
+
+	args, err := e.buildCustomExportCommand("h264_nvenc", "")
+	if err != nil {
+		t.Fatalf("buildCustomExportCommand: %v", err)
+	}
+
+	// 输入输出路径原样作为参数；movie 源的路径在滤镜图中按两级规则转义
+	want := []string{
+		"-y",
+		"-hwaccel", "cuda", "-hwaccel_output_format", "cuda", "-i", `C:\Users\张三\录制\it's a, test.mp4`,
+		"-filter_complex",
+		`movie=filename=C\\:\\\\Users\\\\张三\\\\背景\\\\bg\\\'s image\, \[1\]\;2.png:loop=0,scale=1920:1080[scale0];` +
+			`[0:v]crop=1920:1080:0:0,scale=1920:1080[scale1];` +
+			`[scale0][scale1]overlay=0:0:shortest=1[overlay0]`,
+		"-map", "[overlay0]", "-map", "0:a?",
+		"-c:v", "h264_nvenc", "-cq", "23", "-b:v", "5M", "-r", "30", "-pix_fmt", "yuv420p",
+		`C:\Users\张三\导出\final [v2].mp4`,
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+	}
+}
+
+func TestCustomExportTypingZoom(t *testing.T) {
+	dir := t.TempDir()
+	writeJSON := func(name string, v any) string {
+		path := filepath.Join(dir, name)
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	config := DefaultExportConfig()
+	config.ScreenWidth, config.ScreenHeight = 1920, 1080
+	config.EnableTypingZoom = true
+	config.MouseDataPath = writeJSON("mouse.json", []hook.MouseEvent{
+		{Timestamp: 0, X: 300, Y: 400, EventType: "l_down", Button: "left"},
+		{Timestamp: 50, X: 300, Y: 400, EventType: "l_up", Button: "left"},
+		{Timestamp: 5000, X: 1500, Y: 900, EventType: "move"},
+	})
+	config.KeyboardDataPath = writeJSON("keyboard.json", typingBurst(1000, 10))
+
+	// 自定义导出同样读取键盘数据并遵循导出配置中的打字缩放
+	e := NewCustomExporter(nil)
+	if err := e.PrepareCustomExport(config, "", "", ""); err != nil {
+		t.Fatalf("PrepareCustomExport: %v", err)
+	}
+	typing := 0
+	for _, frame := range e.cameraFrames {
+		if frame.EventType == "typing" {
+			typing++
+		}
+	}
+	if typing == 0 {
+		t.Error("自定义导出没有打字缩放帧")
+	}
+}
@@ -0,0 +1,310 @@
+package recorder
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 磁盘空间级别
+const (
+	DiskLevelOK       = "ok"
+	DiskLevelWarning  = "warning"
+	DiskLevelCritical = "critical"
+)
+
+const (
+	// diskRateWindow 估算码率时使用的最近采样时长
+	diskRateWindow = 10 * time.Second
+	mb             = 1024 * 1024
+)
+
+// DiskGuardConfig 磁盘空间保护和码率预算配置
+type DiskGuardConfig struct {
+	Enabled         bool    `json:"enabled"`
+	WarnFreeMB      []int64 `json:"warnFreeMB"`      // 剩余空间低于这些阈值时各警告一次
+	CriticalFreeMB  int64   `json:"criticalFreeMB"`  // 剩余空间低于此值时自动停止录制（留出封装文件尾的空间）
+	IntervalSeconds float64 `json:"intervalSeconds"` // 检查间隔（秒）
+	MaxBitrateKbps  int     `json:"maxBitrateKbps"`  // 捕获编码的码率上限（kbit/s，0 表示不限制）
+}
+
+// DefaultDiskGuardConfig 返回默认的磁盘空间保护配置
+func DefaultDiskGuardConfig() DiskGuardConfig {
+	return DiskGuardConfig{
+		Enabled:         true,
+		WarnFreeMB:      []int64{10240, 5120, 2048},
+		CriticalFreeMB:  1024,
+		IntervalSeconds: 2,
+	}
+}
+
+// normalizeDiskGuardConfig 校验配置并按从大到小排列警告阈值
+func normalizeDiskGuardConfig(config DiskGuardConfig) (DiskGuardConfig, error) {
+	if config.CriticalFreeMB < 0 {
+		return config, fmt.Errorf("临界剩余空间不能为负数")
+	}
+	if config.MaxBitrateKbps < 0 {
+		return config, fmt.Errorf("码率上限不能为负数")
+	}
+	if config.IntervalSeconds <= 0 {
+		config.IntervalSeconds = 2
+	}
+
+	thresholds := make([]int64, 0, len(config.WarnFreeMB))
+	for _, threshold := range config.WarnFreeMB {
+		if threshold <= config.CriticalFreeMB {
+			return config, fmt.Errorf("警告阈值 %d MB 必须大于临界值 %d MB", threshold, config.CriticalFreeMB)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] > thresholds[j] })
+	config.WarnFreeMB = thresholds
+	return config, nil
+}
+
+// DiskSpaceStatus 输出卷的空间状态
+type DiskSpaceStatus struct {
+	Path             string  `json:"path"` // 检查的目录
+	FreeBytes        uint64  `json:"freeBytes"`
+	TotalBytes       uint64  `json:"totalBytes"`
+	Level            string  `json:"level"`
+	BitrateKbps      float64 `json:"bitrateKbps"`                // 最近观测到的写入码率
+	RemainingSeconds float64 `json:"remainingSeconds,omitempty"` // 按当前码率估算的到达临界值前的可录制时长
+	Error            string  `json:"error,omitempty"`
+}
+
+// DiskSpaceEvent 磁盘空间事件
+type DiskSpaceEvent struct {
+	Type        string          `json:"type"`                  // warning、critical
+	ThresholdMB int64           `json:"thresholdMB,omitempty"` // 跨越的阈值
+	Status      DiskSpaceStatus `json:"status"`
+	Message     string          `json:"message"`
+	Time        time.Time       `json:"time"`
+}
+
+// diskSample 已写入字节数的采样
+type diskSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// DiskGuard 录制期间监控输出卷的剩余空间
+// 每个警告阈值只在首次跨越时通知；到达临界值时调用 onCritical（只调用一次）
+type DiskGuard struct {
+	config     DiskGuardConfig
+	dir        string
+	written    func() int64 // 已写入的字节数（用于估算码率）
+	onCritical func(DiskSpaceEvent)
+	usage      func(path string) (free, total uint64, err error) // 查询卷空间（测试时替换）
+
+	mu           sync.Mutex
+	status       DiskSpaceStatus
+	samples      []diskSample
+	warned       map[int64]bool
+	critical     bool
+	eventHandler func(DiskSpaceEvent)
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewDiskGuard 创建磁盘空间监控
+// written 返回录制已写入的字节数，onCritical 在剩余空间低于临界值时调用
+func NewDiskGuard(config DiskGuardConfig, outputPath string, written func() int64, onCritical func(DiskSpaceEvent)) (*DiskGuard, error) {
+	config, err := normalizeDiskGuardConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskGuard{
+		config:     config,
+		dir:        filepath.Dir(outputPath),
+		written:    written,
+		onCritical: onCritical,
+		usage:      diskUsage,
+		warned:     make(map[int64]bool),
+		status:     DiskSpaceStatus{Path: filepath.Dir(outputPath), Level: DiskLevelOK},
+	}, nil
+}
+
+// SetEventHandler 设置磁盘空间事件回调
+func (g *DiskGuard) SetEventHandler(handler func(DiskSpaceEvent)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.eventHandler = handler
+}
+
+// CheckStart 开始录制前检查剩余空间，已低于临界值时拒绝开始
+func (g *DiskGuard) CheckStart() error {
+	free, _, err := g.usage(g.dir)
+	if err != nil {
+		// 无法获取空间时不阻止录制，由后续检查继续尝试
+		fmt.Printf("警告: %v\n", err)
+		return nil
+	}
+	if free < uint64(g.config.CriticalFreeMB)*mb {
+		return fmt.Errorf("输出磁盘剩余空间不足: %s（至少需要 %d MB）", formatBytes(free), g.config.CriticalFreeMB)
+	}
+	return nil
+}
+
+// Start 开始监控
+func (g *DiskGuard) Start() {
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	g.check(time.Now())
+	go g.run()
+}
+
+// Stop 停止监控
+func (g *DiskGuard) Stop() {
+	if g.stop == nil {
+		return
+	}
+	close(g.stop)
+	<-g.done
+	g.stop = nil
+}
+
+// GetStatus 获取最近一次检查的空间状态
+func (g *DiskGuard) GetStatus() DiskSpaceStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status
+}
+
+// run 定期检查剩余空间
+func (g *DiskGuard) run() {
+	defer close(g.done)
+	ticker := time.NewTicker(time.Duration(g.config.IntervalSeconds * float64(time.Second)))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case now := <-ticker.C:
+			g.check(now)
+		}
+	}
+}
+
+// check 检查一次剩余空间并在跨越阈值时发出事件
+func (g *DiskGuard) check(now time.Time) {
+	free, total, err := g.usage(g.dir)
+
+	g.mu.Lock()
+	if err != nil {
+		g.status.Error = err.Error()
+		g.mu.Unlock()
+		return
+	}
+	g.status.Error = ""
+	g.status.FreeBytes = free
+	g.status.TotalBytes = total
+
+	// 根据最近一段时间的写入量估算码率和剩余可录制时长
+	if g.written != nil {
+		g.samples = append(g.samples, diskSample{at: now, bytes: g.written()})
+		for len(g.samples) > 2 && now.Sub(g.samples[1].at) >= diskRateWindow {
+			g.samples = g.samples[1:]
+		}
+	}
+	bytesPerSecond := sampleRate(g.samples)
+	g.status.BitrateKbps = bytesPerSecond * 8 / 1000
+	g.status.RemainingSeconds = 0
+	if critical := uint64(g.config.CriticalFreeMB) * mb; bytesPerSecond > 0 && free > critical {
+		g.status.RemainingSeconds = float64(free-critical) / bytesPerSecond
+	}
+
+	var events []DiskSpaceEvent
+	freeMB := int64(free / mb)
+	switch {
+	case freeMB < g.config.CriticalFreeMB:
+		g.status.Level = DiskLevelCritical
+		if !g.critical {
+			g.critical = true
+			events = append(events, DiskSpaceEvent{
+				Type:        DiskLevelCritical,
+				ThresholdMB: g.config.CriticalFreeMB,
+				Message:     fmt.Sprintf("磁盘剩余空间仅 %s，自动停止录制", formatBytes(free)),
+			})
+		}
+	default:
+		// 阈值从大到小排列，找到已跨越的最低阈值；同时跨越多个阈值时只通知最低的一个
+		g.status.Level = DiskLevelOK
+		crossed := int64(0)
+		for _, threshold := range g.config.WarnFreeMB {
+			if freeMB < threshold {
+				crossed = threshold
+			}
+		}
+		if crossed > 0 {
+			g.status.Level = DiskLevelWarning
+			if !g.warned[crossed] {
+				for _, threshold := range g.config.WarnFreeMB {
+					if threshold >= crossed {
+						g.warned[threshold] = true
+					}
+				}
+				events = append(events, DiskSpaceEvent{
+					Type:        DiskLevelWarning,
+					ThresholdMB: crossed,
+					Message:     fmt.Sprintf("磁盘剩余空间 %s，低于 %d MB", formatBytes(free), crossed),
+				})
+			}
+		}
+	}
+
+	handler := g.eventHandler
+	for i := range events {
+		events[i].Status = g.status
+		events[i].Time = now
+		if g.status.RemainingSeconds > 0 && events[i].Type == DiskLevelWarning {
+			events[i].Message += fmt.Sprintf("，按当前码率约可再录制 %s", formatRemaining(g.status.RemainingSeconds))
+		}
+	}
+	g.mu.Unlock()
+
+	for _, event := range events {
+		fmt.Printf("✗ %s\n", event.Message)
+		if handler != nil {
+			handler(event)
+		}
+		if event.Type == DiskLevelCritical && g.onCritical != nil {
+			g.onCritical(event)
+		}
+	}
+}
+
+// sampleRate 根据采样计算每秒写入的字节数
+func sampleRate(samples []diskSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 || last.bytes < first.bytes {
+		return 0
+	}
+	return float64(last.bytes-first.bytes) / elapsed
+}
+
+// formatBytes 格式化字节数
+func formatBytes(bytes uint64) string {
+	switch {
+	case bytes >= 1024*mb:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1024*mb))
+	default:
+		return fmt.Sprintf("%.0f MB", float64(bytes)/mb)
+	}
+}
+
+// formatRemaining 格式化剩余时长
+func formatRemaining(seconds float64) string {
+	duration := time.Duration(seconds) * time.Second
+	if duration >= time.Hour {
+		return fmt.Sprintf("%d 小时 %d 分钟", int(duration.Hours()), int(duration.Minutes())%60)
+	}
+	return fmt.Sprintf("%d 分钟", int(duration.Minutes()))
+}
@@ -0,0 +1,197 @@
+package recorder
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// diskStep 一次检查时的剩余空间和期望的结果
+type diskStep struct {
+	freeMB int64
+	level  string
+	events string // 本次检查发出的事件（类型:阈值，逗号分隔）
+}
+
+// newTestDiskGuard 创建使用假卷空间的磁盘保护，每次检查前把剩余空间设为 *free
+func newTestDiskGuard(t *testing.T, free *uint64, written *int64) (*DiskGuard, *[]DiskSpaceEvent, *int) {
+	t.Helper()
+	var events []DiskSpaceEvent
+	criticalCalls := 0
+	guard, err := NewDiskGuard(DefaultDiskGuardConfig(), "/videos/recording.mp4",
+		func() int64 { return *written },
+		func(DiskSpaceEvent) { criticalCalls++ },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard.usage = func(path string) (uint64, uint64, error) {
+		if path != "/videos" {
+			t.Errorf("检查的目录 = %s", path)
+		}
+		return *free, 500 * 1024 * mb, nil
+	}
+	guard.SetEventHandler(func(event DiskSpaceEvent) { events = append(events, event) })
+	return guard, &events, &criticalCalls
+}
+
+func TestDiskGuardCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []diskStep
+		criticalCalls int
+	}{
+		{
+			name: "逐个跨越阈值各警告一次",
+			steps: []diskStep{
+				{freeMB: 20000, level: DiskLevelOK},
+				{freeMB: 10240, level: DiskLevelOK}, // 等于阈值不算跨越
+				{freeMB: 9000, level: DiskLevelWarning, events: "warning:10240"},
+				{freeMB: 8000, level: DiskLevelWarning},
+				{freeMB: 4000, level: DiskLevelWarning, events: "warning:5120"},
+				{freeMB: 9000, level: DiskLevelWarning}, // 空间回升后再次跨越不重复警告
+				{freeMB: 4000, level: DiskLevelWarning},
+				{freeMB: 2000, level: DiskLevelWarning, events: "warning:2048"},
+				{freeMB: 20000, level: DiskLevelOK},
+			},
+		},
+		{
+			name: "同时跨越多个阈值只通知最低的",
+			steps: []diskStep{
+				{freeMB: 20000, level: DiskLevelOK},
+				{freeMB: 3000, level: DiskLevelWarning, events: "warning:5120"},
+				{freeMB: 9000, level: DiskLevelWarning},
+				{freeMB: 2047, level: DiskLevelWarning, events: "warning:2048"},
+			},
+		},
+		{
+			name: "到达临界值只停止一次",
+			steps: []diskStep{
+				{freeMB: 1500, level: DiskLevelWarning, events: "warning:2048"},
+				{freeMB: 1023, level: DiskLevelCritical, events: "critical:1024"},
+				{freeMB: 800, level: DiskLevelCritical},
+				{freeMB: 1500, level: DiskLevelWarning},
+				{freeMB: 900, level: DiskLevelCritical},
+			},
+			criticalCalls: 1,
+		},
+		{
+			name: "开始时已低于临界值",
+			steps: []diskStep{
+				{freeMB: 100, level: DiskLevelCritical, events: "critical:1024"},
+			},
+			criticalCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var free uint64
+			var written int64
+			guard, events, criticalCalls := newTestDiskGuard(t, &free, &written)
+			now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+			for i, step := range tt.steps {
+				free = uint64(step.freeMB) * mb
+				before := len(*events)
+				guard.check(now.Add(time.Duration(i) * 2 * time.Second))
+
+				var got []string
+				for _, event := range (*events)[before:] {
+					got = append(got, fmt.Sprintf("%s:%d", event.Type, event.ThresholdMB))
+				}
+				if strings.Join(got, ",") != step.events {
+					t.Errorf("第 %d 次检查（%d MB）事件 = %v，期望 %q", i+1, step.freeMB, got, step.events)
+				}
+				if status := guard.GetStatus(); status.Level != step.level || status.FreeBytes != free {
+					t.Errorf("第 %d 次检查（%d MB）状态 = %s (%d)，期望 %s", i+1, step.freeMB, status.Level, status.FreeBytes, step.level)
+				}
+			}
+			if *criticalCalls != tt.criticalCalls {
+				t.Errorf("onCritical 调用 %d 次，期望 %d", *criticalCalls, tt.criticalCalls)
+			}
+		})
+	}
+}
+
+func TestDiskGuardRemainingTime(t *testing.T) {
+	var free uint64
+	var written int64
+	guard, events, _ := newTestDiskGuard(t, &free, &written)
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	// 每 2 秒写入 4 MB（2 MB/s），最后一次检查时剩余空间比临界值多 600 MB
+	free = 20000 * mb
+	for i := range 5 {
+		if i == 4 {
+			free = (1024 + 600) * mb
+		}
+		written = int64(i) * 4 * mb
+		guard.check(start.Add(time.Duration(i) * 2 * time.Second))
+	}
+	status := guard.GetStatus()
+	if want := 2.0 * mb * 8 / 1000; math.Abs(status.BitrateKbps-want) > 1e-6 {
+		t.Errorf("码率 = %v kbps，期望 %v", status.BitrateKbps, want)
+	}
+	if math.Abs(status.RemainingSeconds-300) > 1e-6 {
+		t.Errorf("剩余时长 = %v 秒，期望 300", status.RemainingSeconds)
+	}
+	// 警告中附带按当前码率估算的剩余时长
+	if len(*events) != 1 || !strings.Contains((*events)[0].Message, "约可再录制 5 分钟") {
+		t.Errorf("事件 = %+v", *events)
+	}
+
+	// 停止写入超过采样窗口后码率归零，不再估算剩余时长
+	for i := 5; i < 13; i++ {
+		guard.check(start.Add(time.Duration(i) * 2 * time.Second))
+	}
+	if status := guard.GetStatus(); status.BitrateKbps != 0 || status.RemainingSeconds != 0 {
+		t.Errorf("停止写入后码率 = %v，剩余时长 = %v", status.BitrateKbps, status.RemainingSeconds)
+	}
+
+	// 已低于临界值时不估算剩余时长
+	written += 100 * mb
+	free = 1000 * mb
+	guard.check(start.Add(26 * time.Second))
+	if status := guard.GetStatus(); status.BitrateKbps == 0 || status.RemainingSeconds != 0 {
+		t.Errorf("临界时码率 = %v，剩余时长 = %v", status.BitrateKbps, status.RemainingSeconds)
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(seconds float64, bytes int64) diskSample {
+		return diskSample{at: start.Add(time.Duration(seconds * float64(time.Second))), bytes: bytes}
+	}
+	tests := []struct {
+		name    string
+		samples []diskSample
+		want    float64
+	}{
+		{"没有采样", nil, 0},
+		{"只有一个采样", []diskSample{at(0, 100)}, 0},
+		{"时间没有前进", []diskSample{at(1, 100), at(1, 200)}, 0},
+		{"写入量减少（录制重新开始）", []diskSample{at(0, 500), at(2, 100)}, 0},
+		{"按首尾采样计算", []diskSample{at(0, 0), at(1, 5000), at(4, 8000)}, 2000},
+		{"停止写入", []diskSample{at(0, 8000), at(10, 8000)}, 0},
+	}
+	for _, tt := range tests {
+		if got := sampleRate(tt.samples); got != tt.want {
+			t.Errorf("%s: sampleRate = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiskGuardCheckStart(t *testing.T) {
+	var written int64
+	for _, tt := range []struct {
+		freeMB  int64
+		wantErr bool
+	}{{2000, false}, {1024, false}, {1023, true}} {
+		free := uint64(tt.freeMB) * mb
+		guard, _, _ := newTestDiskGuard(t, &free, &written)
+		if err := guard.CheckStart(); (err != nil) != tt.wantErr {
+			t.Errorf("剩余 %d MB: CheckStart = %v", tt.freeMB, err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package recorder
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// diskUsage 获取 path 所在卷的可用空间和总空间（字节）
+func diskUsage(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, fmt.Errorf("获取磁盘空间失败: %w", err)
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
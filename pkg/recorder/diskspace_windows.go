@@ -0,0 +1,32 @@
+//go:build windows
+
+package recorder
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskUsage 获取 path 所在卷的可用空间和总空间（字节）
+// 可用空间为当前用户可用的部分（考虑磁盘配额）
+func diskUsage(path string) (free, total uint64, err error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var freeAvailable, totalBytes, totalFree uint64
+	ret, _, callErr := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if ret == 0 {
+		return 0, 0, fmt.Errorf("获取磁盘空间失败: %w", callErr)
+	}
+	return freeAvailable, totalBytes, nil
+}
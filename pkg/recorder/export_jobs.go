@@ -0,0 +1,660 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExportJobKind 导出任务类型
+type ExportJobKind string
+
+const (
+	ExportJobGPU          ExportJobKind = "gpu"           // GPU 加速导出
+	ExportJobGPUSegmented ExportJobKind = "gpu_segmented" // GPU 加速分段导出
+	ExportJobCustom       ExportJobKind = "custom"        // 自定义参数导出
+)
+
+// ExportJobStatus 导出任务状态
+type ExportJobStatus string
+
+const (
+	ExportJobQueued    ExportJobStatus = "queued"
+	ExportJobRunning   ExportJobStatus = "running"
+	ExportJobSucceeded ExportJobStatus = "succeeded"
+	ExportJobFailed    ExportJobStatus = "failed"
+	ExportJobCanceled  ExportJobStatus = "canceled"
+)
+
+// exportLogTailSize 任务错误日志保留的 FFmpeg 输出字节数
+const exportLogTailSize = 8 * 1024
+
+// maxExportHistory 历史文件最多保存的已结束任务数（排队和运行中的任务总会保存）
+const maxExportHistory = 100
+
+// ExportJobRequest 导出任务参数
+type ExportJobRequest struct {
+	Kind             ExportJobKind `json:"kind"`
+	Config           ExportConfig  `json:"config"`
+	CustomParamsJSON string        `json:"customParams,omitempty"` // 仅 custom 类型
+	BgParamsJSON     string        `json:"bgParams,omitempty"`     // 仅 custom 类型
+	CursorImage      string        `json:"cursorImage,omitempty"`  // 仅 custom 类型
+}
+
+// ExportJob 导出任务
+type ExportJob struct {
+	ID         string           `json:"id"`
+	Request    ExportJobRequest `json:"request"`
+	Status     ExportJobStatus  `json:"status"`
+	Progress   float64          `json:"progress"` // 0-100
+	CreatedAt  time.Time        `json:"createdAt"`
+	StartedAt  time.Time        `json:"startedAt,omitempty"`
+	FinishedAt time.Time        `json:"finishedAt,omitempty"`
+	DurationMs int64            `json:"durationMs"`
+	OutputSize int64            `json:"outputSize"`
+	Error      string           `json:"error,omitempty"`
+	ErrorLog   string           `json:"errorLog,omitempty"` // FFmpeg 输出末尾
+	RetryOf    string           `json:"retryOf,omitempty"`
+
+	Verification *ffmpeg.ProbeReport `json:"verification,omitempty"` // 输出校验报告
+}
+
+// ExportJobEvent 导出任务生命周期事件
+type ExportJobEvent struct {
+	Type string    `json:"type"` // queued, started, progress, succeeded, failed, canceled
+	Job  ExportJob `json:"job"`
+}
+
+// exportJobState 任务的运行时状态
+type exportJobState struct {
+	job      ExportJob
+	cancel   context.CancelFunc
+	progress func() float64
+	done     chan struct{}
+	err      error
+}
+
+// ExportJobManager 导出任务管理器
+// 任务按 FIFO 排队，同时运行的任务数受并发上限限制；
+// 每个任务有独立的 context，取消会终止其所有 FFmpeg 进程。
+type ExportJobManager struct {
+	ffmpegManager *ffmpeg.FFmpegManager
+	historyPath   string
+	concurrency   int
+
+	// saveMu 串行化历史文件的写入：提交、取消和各任务结束都会保存，
+	// 快照在持有锁时获取，后写入的文件总是更新的快照
+	saveMu sync.Mutex
+
+	mu       sync.Mutex
+	jobs     map[string]*exportJobState
+	queue    []string
+	running  int
+	sequence int
+	onEvent  func(ExportJobEvent)
+
+	// execute 运行任务的导出步骤，返回 FFmpeg 日志末尾和错误（测试中替换为假的导出器）
+	execute func(ctx context.Context, state *exportJobState) (string, error)
+
+	// 事件按发生顺序排队，由一个协程依次交给 onEvent，
+	// 保证界面收到的 queued/started/progress/结束事件不乱序
+	events      []ExportJobEvent
+	dispatching bool
+}
+
+// NewExportJobManager 创建导出任务管理器
+// historyPath 为任务历史文件路径（空字符串表示不持久化）
+func NewExportJobManager(ffmpegManager *ffmpeg.FFmpegManager, historyPath string, concurrency int) *ExportJobManager {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	m := &ExportJobManager{
+		ffmpegManager: ffmpegManager,
+		historyPath:   historyPath,
+		concurrency:   concurrency,
+		jobs:          make(map[string]*exportJobState),
+	}
+	m.execute = m.runExport
+
+	if err := m.loadHistory(); err != nil {
+		fmt.Printf("警告: 加载导出任务历史失败: %v\n", err)
+	}
+	return m
+}
+
+// SetEventHandler 设置任务事件处理器
+func (m *ExportJobManager) SetEventHandler(handler func(ExportJobEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEvent = handler
+}
+
+// Submit 提交导出任务，返回任务 ID
+func (m *ExportJobManager) Submit(request ExportJobRequest) (string, error) {
+	return m.submit(request, "")
+}
+
+// submit 创建任务并加入队列
+func (m *ExportJobManager) submit(request ExportJobRequest, retryOf string) (string, error) {
+	switch request.Kind {
+	case ExportJobGPU, ExportJobGPUSegmented, ExportJobCustom:
+	default:
+		return "", fmt.Errorf("未知的导出任务类型: %s", request.Kind)
+	}
+
+	m.mu.Lock()
+	m.sequence++
+	id := fmt.Sprintf("export_%s_%03d", time.Now().Format("20060102_150405"), m.sequence)
+	state := &exportJobState{
+		job: ExportJob{
+			ID:        id,
+			Request:   request,
+			Status:    ExportJobQueued,
+			CreatedAt: time.Now(),
+			RetryOf:   retryOf,
+		},
+		done: make(chan struct{}),
+	}
+	m.jobs[id] = state
+	m.queue = append(m.queue, id)
+	m.emitLocked("queued", state)
+	m.scheduleLocked()
+	m.mu.Unlock()
+
+	m.saveHistory()
+	return id, nil
+}
+
+// Wait 等待任务结束并返回其错误
+func (m *ExportJobManager) Wait(id string) error {
+	m.mu.Lock()
+	state, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("导出任务不存在: %s", id)
+	}
+	if state.done == nil {
+		// 从历史加载的任务，返回记录的结果
+		return state.err
+	}
+
+	<-state.done
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return state.err
+}
+
+// Cancel 取消任务（排队中的任务直接移出队列，运行中的任务终止 FFmpeg）
+func (m *ExportJobManager) Cancel(id string) error {
+	m.mu.Lock()
+	state, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("导出任务不存在: %s", id)
+	}
+
+	switch state.job.Status {
+	case ExportJobQueued:
+		for i, queued := range m.queue {
+			if queued == id {
+				m.queue = append(m.queue[:i], m.queue[i+1:]...)
+				break
+			}
+		}
+		m.finishLocked(state, ExportJobCanceled, fmt.Errorf("导出已取消"))
+		m.mu.Unlock()
+		m.saveHistory()
+		return nil
+	case ExportJobRunning:
+		cancel := state.cancel
+		m.mu.Unlock()
+		cancel()
+		return nil
+	default:
+		m.mu.Unlock()
+		return fmt.Errorf("导出任务已结束: %s", state.job.Status)
+	}
+}
+
+// CancelKind 取消指定类型的所有未结束任务
+func (m *ExportJobManager) CancelKind(kinds ...ExportJobKind) {
+	for _, job := range m.List() {
+		if job.Status != ExportJobQueued && job.Status != ExportJobRunning {
+			continue
+		}
+		for _, kind := range kinds {
+			if job.Request.Kind == kind {
+				m.Cancel(job.ID)
+				break
+			}
+		}
+	}
+}
+
+// Retry 以相同参数重新提交已结束的任务，返回新任务 ID
+func (m *ExportJobManager) Retry(id string) (string, error) {
+	m.mu.Lock()
+	state, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return "", fmt.Errorf("导出任务不存在: %s", id)
+	}
+	if state.job.Status == ExportJobQueued || state.job.Status == ExportJobRunning {
+		m.mu.Unlock()
+		return "", fmt.Errorf("导出任务尚未结束: %s", id)
+	}
+	request := state.job.Request
+	m.mu.Unlock()
+
+	return m.submit(request, id)
+}
+
+// List 返回所有任务（按创建时间排序）
+func (m *ExportJobManager) List() []ExportJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]ExportJob, 0, len(m.jobs))
+	for _, state := range m.jobs {
+		jobs = append(jobs, m.snapshotLocked(state))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Get 获取单个任务
+func (m *ExportJobManager) Get(id string) (ExportJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.jobs[id]
+	if !ok {
+		return ExportJob{}, false
+	}
+	return m.snapshotLocked(state), true
+}
+
+// LatestRunning 返回指定类型中最近开始的运行中任务
+func (m *ExportJobManager) LatestRunning(kinds ...ExportJobKind) (ExportJob, bool) {
+	var latest ExportJob
+	found := false
+	for _, job := range m.List() {
+		if job.Status != ExportJobRunning {
+			continue
+		}
+		for _, kind := range kinds {
+			if job.Request.Kind == kind && (!found || job.StartedAt.After(latest.StartedAt)) {
+				latest = job
+				found = true
+			}
+		}
+	}
+	return latest, found
+}
+
+// snapshotLocked 生成任务快照（运行中的任务附带实时进度）
+func (m *ExportJobManager) snapshotLocked(state *exportJobState) ExportJob {
+	job := state.job
+	if job.Status == ExportJobRunning && state.progress != nil {
+		job.Progress = state.progress()
+	}
+	return job
+}
+
+// scheduleLocked 在并发上限内启动排队的任务
+func (m *ExportJobManager) scheduleLocked() {
+	for m.running < m.concurrency && len(m.queue) > 0 {
+		id := m.queue[0]
+		m.queue = m.queue[1:]
+
+		state := m.jobs[id]
+		ctx, cancel := context.WithCancel(context.Background())
+		state.cancel = cancel
+		state.job.Status = ExportJobRunning
+		state.job.StartedAt = time.Now()
+		m.running++
+		m.emitLocked("started", state)
+
+		go m.run(ctx, state)
+	}
+}
+
+// run 执行任务
+func (m *ExportJobManager) run(ctx context.Context, state *exportJobState) {
+	// 定期发送进度事件
+	stopProgress := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.mu.Lock()
+				m.emitLocked("progress", state)
+				m.mu.Unlock()
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+
+	log, err := m.execute(ctx, state)
+	// 等进度协程退出后再结束任务，结束事件之后不会再有进度事件
+	close(stopProgress)
+	<-progressDone
+
+	// FFmpeg 正常退出不代表输出可用，校验后才算成功
+	var report *ffmpeg.ProbeReport
+	if err == nil && ctx.Err() == nil {
+		report, err = m.verify(state.job.Request)
+	}
+
+	m.mu.Lock()
+	state.job.Verification = report
+	state.job.ErrorLog = ""
+	status := ExportJobSucceeded
+	if ctx.Err() != nil {
+		status = ExportJobCanceled
+		err = fmt.Errorf("导出已取消")
+	} else if err != nil {
+		status = ExportJobFailed
+		state.job.ErrorLog = log
+	}
+	state.cancel()
+	m.running--
+	m.finishLocked(state, status, err)
+	m.scheduleLocked()
+	m.mu.Unlock()
+
+	m.saveHistory()
+}
+
+// runExport 按任务类型运行导出器及后续处理，返回 FFmpeg 日志末尾和错误
+func (m *ExportJobManager) runExport(ctx context.Context, state *exportJobState) (string, error) {
+	request := state.job.Request
+
+	log, err := m.export(ctx, state)
+	if err != nil || ctx.Err() != nil {
+		return log, err
+	}
+
+	// 导出完成后一次完成摄像头画中画、字幕烧录和剪辑
+	if request.Config.Webcam.Enabled || request.Config.CaptionStyle != "" || len(request.Config.Trims) > 0 {
+		tail := newTailBuffer(exportLogTailSize)
+		if err := PostProcessExport(ctx, m.ffmpegManager, request.Config, tail); err != nil {
+			return tail.String(), err
+		}
+		log = tail.String()
+	}
+
+	// 最后写入章节（章节时间按剪辑调整）
+	if ctx.Err() != nil {
+		return log, ctx.Err()
+	}
+	chapters, err := ExportChapters(m.ffmpegManager, request.Config)
+	if err != nil {
+		fmt.Printf("警告: 读取章节标记失败: %v\n", err)
+		return log, nil
+	}
+	tail := newTailBuffer(exportLogTailSize)
+	if err := WriteChapters(ctx, m.ffmpegManager, chapters, request.Config.OutputPath, tail); err != nil {
+		return tail.String(), err
+	}
+	return log, nil
+}
+
+// export 按任务类型运行导出器
+func (m *ExportJobManager) export(ctx context.Context, state *exportJobState) (string, error) {
+	request := state.job.Request
+
+	switch request.Kind {
+	case ExportJobGPU, ExportJobGPUSegmented:
+		exporter := NewGPUExporter(m.ffmpegManager)
+		m.setProgressFunc(state, exporter.GetProgress)
+		if err := exporter.PrepareExport(request.Config); err != nil {
+			return "", fmt.Errorf("准备 GPU 导出失败: %w", err)
+		}
+		var err error
+		if request.Kind == ExportJobGPUSegmented {
+			err = exporter.ExportWithSegments(ctx)
+		} else {
+			err = exporter.ExportWithGPU(ctx)
+		}
+		return exporter.GetLog(), err
+
+	case ExportJobCustom:
+		exporter := NewCustomExporter(m.ffmpegManager)
+		if err := exporter.PrepareCustomExport(request.Config, request.CustomParamsJSON, request.BgParamsJSON, request.CursorImage); err != nil {
+			return "", fmt.Errorf("准备自定义导出失败: %w", err)
+		}
+		err := exporter.ExportWithCustomParams(ctx)
+		return exporter.GetLog(), err
+	}
+
+	return "", fmt.Errorf("未知的导出任务类型: %s", request.Kind)
+}
+
+// verify 校验导出输出
+// 输出时长应与源视频（扣除剪掉的范围）一致；GPU 导出的分辨率和帧率由配置决定
+func (m *ExportJobManager) verify(request ExportJobRequest) (*ffmpeg.ProbeReport, error) {
+	config := request.Config
+	expect := ffmpeg.ProbeExpectation{
+		VideoStreams: 1,
+		FPS:          float64(config.FPS),
+	}
+
+	source, err := m.ffmpegManager.ProbeOutput(config.VideoPath, ffmpeg.ProbeExpectation{SkipFrameCheck: true})
+	if err == nil {
+		expect.Duration = trimmedDuration(source.Duration, config.Trims)
+	}
+
+	if request.Kind != ExportJobCustom {
+		expect.Width = config.ScreenWidth
+		expect.Height = config.ScreenHeight
+	}
+
+	report, err := VerifyOutput(m.ffmpegManager, config.VideoPath, config.OutputPath, expect)
+	if errors.Is(err, ffmpeg.ErrOutputMismatch) || errors.Is(err, ffmpeg.ErrProbeUnavailable) {
+		// 参数不符只记录在报告中，没有 ffprobe 时跳过校验，都不判定任务失败
+		err = nil
+	}
+	return report, err
+}
+
+// setProgressFunc 设置任务的进度查询函数
+func (m *ExportJobManager) setProgressFunc(state *exportJobState, progress func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state.progress = progress
+}
+
+// finishLocked 记录任务结果并通知等待者
+func (m *ExportJobManager) finishLocked(state *exportJobState, status ExportJobStatus, err error) {
+	state.job.Status = status
+	state.job.FinishedAt = time.Now()
+	if !state.job.StartedAt.IsZero() {
+		state.job.DurationMs = state.job.FinishedAt.Sub(state.job.StartedAt).Milliseconds()
+	}
+	if status == ExportJobSucceeded {
+		state.job.Progress = 100
+		if info, statErr := os.Stat(state.job.Request.Config.OutputPath); statErr == nil {
+			state.job.OutputSize = info.Size()
+		}
+	}
+	if err != nil {
+		state.job.Error = err.Error()
+	}
+	state.err = err
+	state.progress = nil
+
+	m.emitLocked(string(status), state)
+	close(state.done)
+}
+
+// emitLocked 发送任务事件
+func (m *ExportJobManager) emitLocked(eventType string, state *exportJobState) {
+	if m.onEvent == nil {
+		return
+	}
+	m.events = append(m.events, ExportJobEvent{Type: eventType, Job: m.snapshotLocked(state)})
+	if !m.dispatching {
+		m.dispatching = true
+		go m.dispatchEvents()
+	}
+}
+
+// dispatchEvents 按顺序发送排队的事件，队列清空后退出
+func (m *ExportJobManager) dispatchEvents() {
+	for {
+		m.mu.Lock()
+		if len(m.events) == 0 || m.onEvent == nil {
+			m.events = nil
+			m.dispatching = false
+			m.mu.Unlock()
+			return
+		}
+		event := m.events[0]
+		m.events = m.events[1:]
+		handler := m.onEvent
+		m.mu.Unlock()
+
+		handler(event)
+	}
+}
+
+// loadHistory 加载任务历史
+// 上次退出时仍在排队或运行的任务标记为失败
+func (m *ExportJobManager) loadHistory() error {
+	if m.historyPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.historyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var jobs []ExportJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.Status == ExportJobQueued || job.Status == ExportJobRunning {
+			job.Status = ExportJobFailed
+			job.Error = "应用退出时任务被中断"
+		}
+		state := &exportJobState{job: job}
+		if job.Error != "" && job.Status != ExportJobSucceeded {
+			state.err = errors.New(job.Error)
+		}
+		m.jobs[job.ID] = state
+	}
+	return nil
+}
+
+// saveHistory 保存任务历史（只保留最近 maxExportHistory 个已结束的任务）
+func (m *ExportJobManager) saveHistory() {
+	if m.historyPath == "" {
+		return
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	jobs := historyJobs(m.List(), maxExportHistory)
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		fmt.Printf("警告: 序列化导出任务历史失败: %v\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.historyPath), 0755); err != nil {
+		fmt.Printf("警告: 创建导出任务历史目录失败: %v\n", err)
+		return
+	}
+	tmpPath := m.historyPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		fmt.Printf("警告: 保存导出任务历史失败: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmpPath, m.historyPath); err != nil {
+		fmt.Printf("警告: 保存导出任务历史失败: %v\n", err)
+	}
+}
+
+// historyJobs 选出要保存的任务：排队和运行中的任务，加上最近 limit 个已结束的任务
+// jobs 按创建时间排序，结果保持原顺序
+func historyJobs(jobs []ExportJob, limit int) []ExportJob {
+	finished := 0
+	for _, job := range jobs {
+		if job.Status != ExportJobQueued && job.Status != ExportJobRunning {
+			finished++
+		}
+	}
+
+	kept := make([]ExportJob, 0, len(jobs))
+	for _, job := range jobs {
+		if job.Status != ExportJobQueued && job.Status != ExportJobRunning {
+			if finished > limit {
+				finished--
+				continue
+			}
+		}
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// tailBuffer 只保留最后 max 字节的线程安全缓冲区
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+// newTailBuffer 创建尾部缓冲区
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+// Write 实现 io.Writer
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append([]byte(nil), t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// Reset 清空缓冲区（新一次导出开始时调用）
+func (t *tailBuffer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = nil
+}
+
+// String 返回缓冲区内容
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
@@ -0,0 +1,368 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExporter 假的导出器：每个任务阻塞到测试放行或被取消
+type fakeExporter struct {
+	mu      sync.Mutex
+	started []string                 // 按开始顺序记录的输出文件名
+	release map[string]chan error    // 按输出文件名放行，发送的错误作为导出结果
+	running map[string]chan struct{} // 任务开始运行时关闭
+}
+
+func newFakeExporter() *fakeExporter {
+	return &fakeExporter{release: make(map[string]chan error), running: make(map[string]chan struct{})}
+}
+
+// gate 返回任务的放行和开始通道
+func (f *fakeExporter) gate(name string) (chan error, chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.release[name]; !ok {
+		f.release[name] = make(chan error, 1)
+		f.running[name] = make(chan struct{})
+	}
+	return f.release[name], f.running[name]
+}
+
+// waitStarted 等待任务开始运行
+func (f *fakeExporter) waitStarted(t *testing.T, name string) {
+	t.Helper()
+	_, running := f.gate(name)
+	select {
+	case <-running:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("任务 %s 没有开始", name)
+	}
+}
+
+func (f *fakeExporter) execute(m *ExportJobManager) func(context.Context, *exportJobState) (string, error) {
+	return func(ctx context.Context, state *exportJobState) (string, error) {
+		output := state.job.Request.Config.OutputPath
+		name := filepath.Base(output)
+		release, running := f.gate(name)
+		f.mu.Lock()
+		f.started = append(f.started, name)
+		f.mu.Unlock()
+
+		m.setProgressFunc(state, func() float64 { return 50 })
+		close(running)
+		select {
+		case err := <-release:
+			if err != nil {
+				return "ffmpeg: " + err.Error(), err
+			}
+			return "", os.WriteFile(output, []byte("video"), 0644)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// jobEvents 记录任务事件
+type jobEvents struct {
+	mu     sync.Mutex
+	events []ExportJobEvent
+}
+
+func (j *jobEvents) add(event ExportJobEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, event)
+}
+
+// types 返回某个任务按顺序收到的事件类型
+func (j *jobEvents) types(id string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var types []string
+	for _, event := range j.events {
+		if event.Job.ID == id {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+// newTestJobManager 创建使用假导出器的任务管理器
+// FFmpeg 指向一个空的可执行文件，找不到对应的 ffprobe，输出校验被跳过
+func newTestJobManager(t *testing.T, concurrency int) (*ExportJobManager, *fakeExporter, *jobEvents, string) {
+	t.Helper()
+	dir := t.TempDir()
+	ffmpegPath := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpegPath, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv(ffmpeg.EnvFFmpegPath, ffmpegPath)
+
+	m := NewExportJobManager(ffmpeg.NewFFmpegManager(context.Background()), "", concurrency)
+	fake := newFakeExporter()
+	m.execute = fake.execute(m)
+	events := &jobEvents{}
+	m.SetEventHandler(events.add)
+	return m, fake, events, dir
+}
+
+// submitJob 提交输出到 dir/name 的任务
+func submitJob(t *testing.T, m *ExportJobManager, dir, name string) string {
+	t.Helper()
+	config := DefaultExportConfig()
+	config.VideoPath = filepath.Join(dir, "source.mp4")
+	config.OutputPath = filepath.Join(dir, name)
+	id, err := m.Submit(ExportJobRequest{Kind: ExportJobGPU, Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// status 返回任务当前状态
+func status(t *testing.T, m *ExportJobManager, id string) ExportJobStatus {
+	t.Helper()
+	job, ok := m.Get(id)
+	if !ok {
+		t.Fatalf("任务 %s 不存在", id)
+	}
+	return job.Status
+}
+
+// checkLifecycle 检查任务事件顺序：queued、started、若干 progress，结束事件在最后
+func checkLifecycle(t *testing.T, events *jobEvents, id string, final ExportJobStatus, started bool) {
+	t.Helper()
+	// 事件异步投递，先等结束事件到达
+	waitFor(t, 2*time.Second, "任务 "+id+" 的结束事件", func() bool {
+		types := events.types(id)
+		return len(types) > 0 && types[len(types)-1] == string(final)
+	})
+	types := events.types(id)
+	want := []string{"queued"}
+	if started {
+		want = append(want, "started")
+	}
+	if len(types) < len(want)+1 {
+		t.Fatalf("任务 %s 事件 = %v", id, types)
+	}
+	for i, typ := range want {
+		if types[i] != typ {
+			t.Fatalf("任务 %s 事件 = %v, 第 %d 个应为 %s", id, types, i, typ)
+		}
+	}
+	for _, typ := range types[len(want) : len(types)-1] {
+		if typ != "progress" {
+			t.Fatalf("任务 %s 事件 = %v, 开始和结束之间只应有进度事件", id, types)
+		}
+	}
+	if last := types[len(types)-1]; last != string(final) {
+		t.Errorf("任务 %s 事件 = %v, 最后应为 %s", id, types, final)
+	}
+}
+
+func TestExportJobsRunInQueueOrder(t *testing.T) {
+	m, fake, events, dir := newTestJobManager(t, 1)
+
+	first := submitJob(t, m, dir, "first.mp4")
+	second := submitJob(t, m, dir, "second.mp4")
+	third := submitJob(t, m, dir, "third.mp4")
+
+	// 并发上限为 1：只有第一个任务运行，其余按提交顺序排队
+	fake.waitStarted(t, "first.mp4")
+	if s := status(t, m, first); s != ExportJobRunning {
+		t.Errorf("first = %s, want running", s)
+	}
+	for _, id := range []string{second, third} {
+		if s := status(t, m, id); s != ExportJobQueued {
+			t.Errorf("%s = %s, want queued", id, s)
+		}
+	}
+
+	// 进度定时器至少触发一次后结束第一个任务
+	time.Sleep(600 * time.Millisecond)
+	release, _ := fake.gate("first.mp4")
+	release <- nil
+	if err := m.Wait(first); err != nil {
+		t.Fatalf("Wait(first): %v", err)
+	}
+	fake.waitStarted(t, "second.mp4")
+	release, _ = fake.gate("second.mp4")
+	release <- fmt.Errorf("编码失败")
+	if err := m.Wait(second); err == nil {
+		t.Error("Wait(second) 应返回导出错误")
+	}
+	fake.waitStarted(t, "third.mp4")
+	release, _ = fake.gate("third.mp4")
+	release <- nil
+	if err := m.Wait(third); err != nil {
+		t.Fatalf("Wait(third): %v", err)
+	}
+
+	fake.mu.Lock()
+	started := append([]string(nil), fake.started...)
+	fake.mu.Unlock()
+	if fmt.Sprint(started) != "[first.mp4 second.mp4 third.mp4]" {
+		t.Errorf("开始顺序 = %v", started)
+	}
+
+	job, _ := m.Get(first)
+	if job.Status != ExportJobSucceeded || job.Progress != 100 || job.OutputSize != int64(len("video")) {
+		t.Errorf("first = %+v", job)
+	}
+	job, _ = m.Get(second)
+	if job.Status != ExportJobFailed || job.Error != "编码失败" || job.ErrorLog != "ffmpeg: 编码失败" {
+		t.Errorf("second = %+v", job)
+	}
+
+	// 结束事件之后不再有进度事件（多等一个进度周期）
+	time.Sleep(600 * time.Millisecond)
+	checkLifecycle(t, events, first, ExportJobSucceeded, true)
+	checkLifecycle(t, events, second, ExportJobFailed, true)
+	checkLifecycle(t, events, third, ExportJobSucceeded, true)
+	if types := events.types(first); len(types) < 4 {
+		t.Errorf("运行中的任务应收到进度事件: %v", types)
+	}
+}
+
+func TestExportJobCancel(t *testing.T) {
+	m, fake, events, dir := newTestJobManager(t, 1)
+
+	running := submitJob(t, m, dir, "running.mp4")
+	queued := submitJob(t, m, dir, "queued.mp4")
+	next := submitJob(t, m, dir, "next.mp4")
+	fake.waitStarted(t, "running.mp4")
+
+	// 取消排队中的任务：直接结束，不会开始运行
+	if err := m.Cancel(queued); err != nil {
+		t.Fatalf("Cancel(queued): %v", err)
+	}
+	if s := status(t, m, queued); s != ExportJobCanceled {
+		t.Errorf("queued = %s, want canceled", s)
+	}
+	if err := m.Wait(queued); err == nil {
+		t.Error("Wait(queued) 应返回取消错误")
+	}
+
+	// 取消运行中的任务：导出器的 context 被取消，队列中的下一个任务开始
+	if err := m.Cancel(running); err != nil {
+		t.Fatalf("Cancel(running): %v", err)
+	}
+	if err := m.Wait(running); err == nil {
+		t.Error("Wait(running) 应返回取消错误")
+	}
+	if s := status(t, m, running); s != ExportJobCanceled {
+		t.Errorf("running = %s, want canceled", s)
+	}
+	fake.waitStarted(t, "next.mp4")
+	if err := m.Cancel(running); err == nil {
+		t.Error("取消已结束的任务应返回错误")
+	}
+
+	// 已结束的任务可以重试，新任务记录原任务 ID
+	retry, err := m.Retry(running)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if job, _ := m.Get(retry); job.RetryOf != running || job.Status != ExportJobQueued {
+		t.Errorf("重试任务 = %+v", job)
+	}
+	if _, err := m.Retry(next); err == nil {
+		t.Error("运行中的任务不能重试")
+	}
+
+	m.CancelKind(ExportJobGPU)
+	for _, id := range []string{next, retry} {
+		m.Wait(id)
+		if s := status(t, m, id); s != ExportJobCanceled {
+			t.Errorf("CancelKind 后 %s = %s", id, s)
+		}
+	}
+
+	checkLifecycle(t, events, running, ExportJobCanceled, true)
+	checkLifecycle(t, events, queued, ExportJobCanceled, false)
+	checkLifecycle(t, events, retry, ExportJobCanceled, false)
+}
+
+func TestExportJobHistoryConcurrentSaves(t *testing.T) {
+	m, fake, _, dir := newTestJobManager(t, 4)
+	m.historyPath = filepath.Join(dir, "history", "export_jobs.json")
+
+	// 四个任务同时结束，另有取消和提交与之交错，每次都写历史文件
+	var ids []string
+	for i := range 4 {
+		ids = append(ids, submitJob(t, m, dir, fmt.Sprintf("job%d.mp4", i)))
+	}
+	for i := range 4 {
+		fake.waitStarted(t, fmt.Sprintf("job%d.mp4", i))
+	}
+	queued := submitJob(t, m, dir, "queued.mp4")
+	var wg sync.WaitGroup
+	for i := range 4 {
+		release, _ := fake.gate(fmt.Sprintf("job%d.mp4", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release <- nil
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Cancel(queued)
+	}()
+	wg.Wait()
+	for _, id := range ids {
+		if err := m.Wait(id); err != nil {
+			t.Fatalf("Wait(%s): %v", id, err)
+		}
+	}
+	m.Wait(queued)
+
+	// 最后一次保存完成后，历史文件是完整的最终状态
+	m.saveHistory()
+	loaded := NewExportJobManager(nil, m.historyPath, 1)
+	for _, id := range ids {
+		if s := status(t, loaded, id); s != ExportJobSucceeded {
+			t.Errorf("历史中 %s = %s", id, s)
+		}
+	}
+	if s := status(t, loaded, queued); s != ExportJobCanceled {
+		t.Errorf("历史中 %s = %s", queued, s)
+	}
+	if _, err := os.Stat(m.historyPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("临时文件未清理: %v", err)
+	}
+}
+
+func TestExportHistoryKeepsRecentFinishedJobs(t *testing.T) {
+	statuses := []ExportJobStatus{
+		ExportJobSucceeded, ExportJobFailed, ExportJobRunning, ExportJobCanceled,
+		ExportJobSucceeded, ExportJobQueued, ExportJobSucceeded,
+	}
+	var jobs []ExportJob
+	for i, s := range statuses {
+		jobs = append(jobs, ExportJob{ID: fmt.Sprintf("job%d", i), Status: s})
+	}
+
+	var kept []string
+	for _, job := range historyJobs(jobs, 2) {
+		kept = append(kept, job.ID)
+	}
+	// 运行中和排队的任务总会保存，已结束的只保留最近两个
+	want := []string{"job2", "job4", "job5", "job6"}
+	if fmt.Sprint(kept) != fmt.Sprint(want) {
+		t.Errorf("保存的任务 = %v, want %v", kept, want)
+	}
+	if got := historyJobs(jobs, 10); len(got) != len(jobs) {
+		t.Errorf("未超过上限时应全部保存，得到 %d 个", len(got))
+	}
+}
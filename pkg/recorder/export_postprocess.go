@@ -0,0 +1,179 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// postProcessStage 导出后处理的一个阶段（摄像头画中画、字幕烧录等）
+// 所有阶段接在同一个滤镜图上，整个后处理只重新编码一次
+type postProcessStage struct {
+	name string
+	// video 处理画面，返回处理后的视频
+	video func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error)
+	// audio 处理声音（为 nil 时不改变音频，音频直接复制）
+	audio func(command *ffmpeg.Command, audio ffmpeg.Pad) ffmpeg.Pad
+}
+
+// postProcessEncoder 后处理使用的视频编码器（与导出使用同一个编码器和质量参数）
+type postProcessEncoder struct {
+	codec  string
+	preset string
+}
+
+// exportEncoder 选择与导出相同的编码器
+func exportEncoder(ffmpegManager *ffmpeg.FFmpegManager) postProcessEncoder {
+	codec, err := ffmpegManager.GetBestEncoder()
+	if err != nil {
+		codec = "libx264"
+	}
+	return postProcessEncoder{codec: codec, preset: ffmpegManager.GetBestPreset(codec)}
+}
+
+// PostProcessExport 按导出配置对导出结果做后处理：叠加摄像头画中画、烧录字幕（字幕在最上层）、剪掉不需要的范围
+// 各阶段组合成一个滤镜图，视频只重新编码一次；没有需要的阶段时不做任何处理
+func PostProcessExport(ctx context.Context, ffmpegManager *ffmpeg.FFmpegManager, config ExportConfig, log io.Writer) error {
+	var stages []postProcessStage
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+
+	trims := normalizeTrims(config.Trims)
+	var report *ffmpeg.ProbeReport
+	if config.Webcam.Enabled || len(trims) > 0 {
+		var err error
+		report, err = ffmpegManager.ProbeOutput(config.OutputPath, ffmpeg.ProbeExpectation{SkipFrameCheck: true})
+		if err != nil {
+			return fmt.Errorf("读取导出视频信息失败: %w", err)
+		}
+	}
+
+	if config.Webcam.Enabled {
+		stage, cleanup, err := webcamStage(config, report.Width, report.Height)
+		if cleanup != nil {
+			cleanups = append(cleanups, cleanup)
+		}
+		if err != nil {
+			return fmt.Errorf("合成摄像头画中画失败: %w", err)
+		}
+		stages = append(stages, stage)
+	}
+	if config.CaptionStyle != "" {
+		transcript, err := LoadTranscript(config.VideoPath)
+		if err != nil {
+			return fmt.Errorf("烧录字幕失败: %w", err)
+		}
+		stage, cleanup, err := captionStage(transcript, config.OutputPath, config.CaptionStyle)
+		if cleanup != nil {
+			cleanups = append(cleanups, cleanup)
+		}
+		if err != nil {
+			return fmt.Errorf("烧录字幕失败: %w", err)
+		}
+		stages = append(stages, stage)
+	}
+	// 剪辑放在最后，前面各阶段的时间都按剪辑前的时间轴计算
+	if len(trims) > 0 {
+		stages = append(stages, trimStage(trims, report.AudioStreams > 0))
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(stages))
+	for i, stage := range stages {
+		names[i] = stage.name
+	}
+	name := strings.Join(names, "、")
+	encoder := exportEncoder(ffmpegManager)
+	return replaceExport(config.OutputPath, name, func(sourcePath string) error {
+		command, err := buildPostProcessCommand(sourcePath, config.OutputPath, stages, encoder)
+		if err != nil {
+			return err
+		}
+		return runFFmpegCommand(ctx, ffmpegManager, command, name, log)
+	})
+}
+
+// buildPostProcessCommand 构建后处理命令：source 依次经过各阶段，用 encoder 编码
+// 有阶段处理声音时音频重新编码为 AAC，否则直接复制
+func buildPostProcessCommand(sourcePath, outputPath string, stages []postProcessStage, encoder postProcessEncoder) (*ffmpeg.Command, error) {
+	command := ffmpeg.NewCommand().Overwrite().Global(ffmpeg.HWDeviceArgs(encoder.codec)...)
+	source := command.Input(sourcePath)
+
+	video := source.Video()
+	audio := source.Audio()
+	audioFiltered := false
+	for _, stage := range stages {
+		var err error
+		if video, err = stage.video(command, video); err != nil {
+			return nil, fmt.Errorf("%s: %w", stage.name, err)
+		}
+		if stage.audio != nil {
+			audio = stage.audio(command, audio)
+			audioFiltered = true
+		}
+	}
+	upload := ffmpeg.HWUploadFilters(encoder.codec)
+	if len(upload) > 0 {
+		// VAAPI 等编码器需要把帧上传到显存
+		video = command.FilterGraph().Chain([]ffmpeg.Pad{video}, upload...)
+	}
+
+	output := command.Output(outputPath).Map(video)
+	if audioFiltered {
+		output.Map(audio)
+	} else {
+		output.Map(source.Stream("a?"))
+	}
+	output.VideoCodec(encoder.codec).With(videoEncoderOptions(encoder.codec, encoder.preset)...)
+	if len(upload) == 0 {
+		output.With(ffmpeg.Opt("pix_fmt", "yuv420p"))
+	}
+	if audioFiltered {
+		output.AudioCodec("aac")
+	} else {
+		output.AudioCodec("copy")
+	}
+	return command, nil
+}
+
+// replaceExport 用 run 生成的新文件替换导出结果
+// 原文件先改名为临时文件交给 run 作为输入；失败时恢复原文件，成功后删除临时文件
+func replaceExport(outputPath, name string, run func(sourcePath string) error) error {
+	ext := filepath.Ext(outputPath)
+	sourcePath := strings.TrimSuffix(outputPath, ext) + ".source" + ext
+	if err := os.Rename(outputPath, sourcePath); err != nil {
+		return fmt.Errorf("准备%s失败: %w", name, err)
+	}
+
+	if err := run(sourcePath); err != nil {
+		os.Remove(outputPath)
+		os.Rename(sourcePath, outputPath)
+		return fmt.Errorf("%s失败: %w", name, err)
+	}
+
+	os.Remove(sourcePath)
+	return nil
+}
+
+// runFFmpegCommand 构建参数并运行 FFmpeg
+func runFFmpegCommand(ctx context.Context, ffmpegManager *ffmpeg.FFmpegManager, command *ffmpeg.Command, name string, log io.Writer) error {
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+	process, err := ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: name, Log: log})
+	if err != nil {
+		return err
+	}
+	return process.Run(ctx)
+}
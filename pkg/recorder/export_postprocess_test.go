@@ -0,0 +1,188 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostProcessCommandCombinesStages(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "export.mp4")
+	captions, cleanup, err := captionStage(&Transcript{Segments: []TranscriptSegment{{Start: 0, End: 1.5, Text: "你好"}}}, outputPath, "default")
+	if err != nil {
+		t.Fatalf("captionStage: %v", err)
+	}
+	subtitlePath := filepath.Join(dir, "export.captions.srt")
+	if _, err := os.Stat(subtitlePath); err != nil {
+		t.Fatalf("没有写入临时字幕: %v", err)
+	}
+	defer func() {
+		cleanup()
+		if _, err := os.Stat(subtitlePath); !os.IsNotExist(err) {
+			t.Errorf("临时字幕未删除: %v", err)
+		}
+	}()
+	// 代替摄像头画中画的阶段：叠加一个额外输入
+	overlay := postProcessStage{
+		name: "叠加",
+		video: func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error) {
+			return command.FilterGraph().Apply(ffmpeg.NewFilter("overlay", 10, 20), video, command.Input("cam.mp4").Video()), nil
+		},
+	}
+	stages := []postProcessStage{overlay, captions}
+	style, _ := GetCaptionStyle("default")
+	subtitles := "subtitles=filename=" + ffmpeg.EscapeFilterValue(filepath.ToSlash(subtitlePath)) +
+		":force_style=" + ffmpeg.EscapeFilterValue(style.ForceStyle())
+
+	tests := []struct {
+		name    string
+		encoder postProcessEncoder
+		want    []string
+	}{
+		{
+			name:    "软件编码",
+			encoder: postProcessEncoder{codec: "libx264", preset: "veryfast"},
+			want: []string{
+				"-y", "-i", "source.mp4", "-i", "cam.mp4",
+				"-filter_complex", "[0:v][1:v]overlay=10:20[overlay0];[overlay0]" + subtitles + "[subtitles0]",
+				"-map", "[subtitles0]", "-map", "0:a?",
+				"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p", "-c:a", "copy",
+				outputPath,
+			},
+		},
+		{
+			name:    "与导出相同的硬件编码器",
+			encoder: postProcessEncoder{codec: "h264_vaapi"},
+			want: []string{
+				"-y", "-vaapi_device", "/dev/dri/renderD128", "-i", "source.mp4", "-i", "cam.mp4",
+				"-filter_complex", "[0:v][1:v]overlay=10:20[overlay0];[overlay0]" + subtitles + "[subtitles0];" +
+					"[subtitles0]format=nv12,hwupload[hwupload0]",
+				"-map", "[hwupload0]", "-map", "0:a?",
+				"-c:v", "h264_vaapi", "-qp", "23", "-c:a", "copy",
+				outputPath,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := buildPostProcessCommand("source.mp4", outputPath, stages, tt.encoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args, err := command.Args()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(tt.want, " "))
+			}
+		})
+	}
+}
+
+func TestPostProcessCommandTrim(t *testing.T) {
+	trims := normalizeTrims([]TimeRange{{Start: 5, End: 7.5}, {Start: 1, End: 2}})
+	overlay := postProcessStage{
+		name: "叠加",
+		video: func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error) {
+			return command.FilterGraph().Apply(ffmpeg.NewFilter("overlay", 10, 20), video, command.Input("cam.mp4").Video()), nil
+		},
+	}
+	encoder := postProcessEncoder{codec: "libx264", preset: "veryfast"}
+	keep := "not(between(t,1.000,2.000)+between(t,5.000,7.500))"
+
+	tests := []struct {
+		name   string
+		stages []postProcessStage
+		want   []string
+	}{
+		{
+			name:   "叠加后剪辑，音频一起剪掉",
+			stages: []postProcessStage{overlay, trimStage(trims, true)},
+			want: []string{
+				"-y", "-i", "source.mp4", "-i", "cam.mp4",
+				"-filter_complex", "[0:v][1:v]overlay=10:20[overlay0];" +
+					"[overlay0]select=" + ffmpeg.EscapeFilterValue(keep) + ",setpts=N/FRAME_RATE/TB[setpts0];" +
+					"[0:a]aselect=" + ffmpeg.EscapeFilterValue(keep) + ",asetpts=N/SR/TB[asetpts0]",
+				"-map", "[setpts0]", "-map", "[asetpts0]",
+				"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p", "-c:a", "aac",
+				"export.mp4",
+			},
+		},
+		{
+			name:   "没有音频时只剪画面",
+			stages: []postProcessStage{trimStage(trims, false)},
+			want: []string{
+				"-y", "-i", "source.mp4",
+				"-filter_complex", "[0:v]select=" + ffmpeg.EscapeFilterValue(keep) + ",setpts=N/FRAME_RATE/TB[setpts0]",
+				"-map", "[setpts0]", "-map", "0:a?",
+				"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p", "-c:a", "copy",
+				"export.mp4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := buildPostProcessCommand("source.mp4", "export.mp4", tt.stages, encoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args, err := command.Args()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(tt.want, " "))
+			}
+		})
+	}
+}
+
+func TestReplaceExport(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "export.mp4")
+	sourcePath := filepath.Join(dir, "export.source.mp4")
+	readOutput := func() string {
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if err := os.WriteFile(outputPath, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 失败时删除写了一半的输出并恢复原文件
+	err := replaceExport(outputPath, "测试处理", func(source string) error {
+		if source != sourcePath {
+			t.Errorf("输入 = %s", source)
+		}
+		os.WriteFile(outputPath, []byte("partial"), 0644)
+		return os.ErrInvalid
+	})
+	if err == nil || !strings.Contains(err.Error(), "测试处理失败") {
+		t.Errorf("错误 = %v", err)
+	}
+	if got := readOutput(); got != "original" {
+		t.Errorf("失败后输出 = %q", got)
+	}
+
+	// 成功时保留新文件并删除临时的原文件
+	err = replaceExport(outputPath, "测试处理", func(source string) error {
+		return os.WriteFile(outputPath, []byte("processed"), 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readOutput(); got != "processed" {
+		t.Errorf("成功后输出 = %q", got)
+	}
+	if _, err := os.Stat(sourcePath); !os.IsNotExist(err) {
+		t.Errorf("临时文件未删除: %v", err)
+	}
+}
@@ -7,41 +7,120 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // ExportConfig represents export configuration
 type ExportConfig struct {
-	VideoPath     string  // Input video path
-	MouseDataPath string  // Mouse data JSON path
-	OutputPath    string  // Output video path
-	FPS           int     // Output frame rate
-	EnableZoom    bool    // Enable zoom on click
-	ZoomLevel     float64 // Zoom level when clicking
-	SmoothFactor  float64 // Camera smoothness (0.0-1.0)
-	ShowCursor    bool    // Show cursor in export
-	CursorSize    int     // Cursor size in pixels
-	ScreenWidth   int     // Screen width
-	ScreenHeight  int     // Screen height
+	VideoPath        string       // Input video path
+	MouseDataPath    string       // Mouse data JSON path
+	KeyboardDataPath string       // Keyboard data JSON path (empty = <video>_keyboard.json if present)
+	OutputPath       string       // Output video path
+	FPS              int          // Output frame rate
+	EnableZoom       bool         // Enable zoom on click
+	ZoomLevel        float64      // Zoom level when clicking
+	EnableTypingZoom bool         // Enable zoom on typing bursts (opt-in, off by default)
+	TypingZoomLevel  float64      // Zoom level while typing
+	TypingHoldMs     int64        // Hold the typing zoom this long after the last keystroke
+	TypingRegion     *FocusRegion // Fixed caret region (nil = last click position)
+	EnableDragZoom   bool         // Zoom to fit drag and selection bounds (opt-in, off by default)
+	SmoothFactor     float64      // Camera smoothness (0.0-1.0)
+	ShowCursor       bool         // Draw the recorded cursor sprites in the export
+	CursorSize       int          // Cursor size in pixels (sprites are scaled relative to the standard 32px cursor)
+	CursorDataPath   string       // Cursor shape JSON path (empty = <video>_cursor.json if present)
+	ScreenWidth      int          // Screen width
+	ScreenHeight     int          // Screen height
+
+	// Segmented export
+	SegmentFrames      int // Target frames per segment (boundaries snap to keyframes)
+	SegmentParallelism int // Concurrent segment encoders (0 = auto from CPU count)
+	SegmentRetries     int // Extra attempts for a failed segment
+
+	// Captions
+	CaptionStyle string // Burn the session transcript in with this preset (see CaptionStyles; empty = none)
+
+	// Webcam picture-in-picture
+	Webcam WebcamOverlayConfig // Composite the session webcam track over the export when enabled
+
+	// Trims and chapters
+	Trims []TimeRange // Source ranges cut from the export; session markers become chapters shifted past the cuts
 }
 
 // DefaultExportConfig returns default export configuration
 func DefaultExportConfig() ExportConfig {
 	return ExportConfig{
-		FPS:          30,
-		EnableZoom:   true,
-		ZoomLevel:    1.5,
-		SmoothFactor: 0.15,
-		ShowCursor:   true,
-		CursorSize:   32,
+		FPS:              30,
+		EnableZoom:       true,
+		ZoomLevel:        1.5,
+		EnableTypingZoom: false,
+		TypingZoomLevel:  1.8,
+		TypingHoldMs:     1500,
+		EnableDragZoom:   false,
+		SmoothFactor:     0.15,
+		ShowCursor:       true,
+		CursorSize:       32,
+		SegmentFrames:    300,
+		SegmentRetries:   2,
 	}
 }
 
+// NewCameraControllerForConfig creates a camera controller using the zoom
+// settings from an export configuration
+func NewCameraControllerForConfig(config ExportConfig) *CameraController {
+	controller := NewCameraController(config.ScreenWidth, config.ScreenHeight)
+	controller.SetZoomOnClick(config.EnableZoom)
+	if config.ZoomLevel > 0 {
+		controller.SetClickZoom(config.ZoomLevel)
+	}
+	if config.SmoothFactor > 0 {
+		controller.SetSmoothFactor(config.SmoothFactor)
+	}
+	controller.SetZoomOnTyping(config.EnableTypingZoom)
+	if config.TypingZoomLevel > 0 {
+		controller.SetTypingZoom(config.TypingZoomLevel)
+	}
+	if config.TypingHoldMs > 0 {
+		controller.SetTypingHold(config.TypingHoldMs)
+	}
+	controller.SetTypingRegion(config.TypingRegion)
+	controller.SetZoomOnDrag(config.EnableDragZoom)
+	return controller
+}
+
+// LoadKeyboardEvents loads keyboard events for an export
+// Falls back to <video>_keyboard.json next to the video; returns nil if no data exists
+func LoadKeyboardEvents(config ExportConfig) ([]hook.KeyboardEvent, error) {
+	path := config.KeyboardDataPath
+	if path == "" {
+		if config.VideoPath == "" {
+			return nil, nil
+		}
+		path = strings.TrimSuffix(config.VideoPath, filepath.Ext(config.VideoPath)) + "_keyboard.json"
+		if !fileExists(path) {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyboard data: %w", err)
+	}
+
+	var events []hook.KeyboardEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse keyboard data: %w", err)
+	}
+
+	return events, nil
+}
+
 // Exporter handles video export with camera movements
 type Exporter struct {
-	config        ExportConfig
-	ffmpegManager *ffmpeg.FFmpegManager
-	mouseEvents   []hook.MouseEvent
-	cameraFrames  []CameraFrame
+	config         ExportConfig
+	ffmpegManager  *ffmpeg.FFmpegManager
+	mouseEvents    []hook.MouseEvent
+	keyboardEvents []hook.KeyboardEvent
+	cameraFrames   []CameraFrame
 }
 
 // NewExporter creates a new exporter
@@ -73,11 +152,11 @@ func (e *Exporter) GenerateCameraPath() error {
 		return fmt.Errorf("no mouse events loaded")
 	}
 
-	// Generate camera frames based on mouse events
-	e.cameraFrames = GenerateCameraPath(
+	// Generate camera frames based on mouse and keyboard events
+	e.cameraFrames = GenerateCameraPathWithKeyboard(
 		e.mouseEvents,
-		e.config.ScreenWidth,
-		e.config.ScreenHeight,
+		e.keyboardEvents,
+		NewCameraControllerForConfig(e.config),
 		e.config.FPS,
 	)
 
@@ -119,6 +198,15 @@ func (e *Exporter) PrepareExport() error {
 		}
 	}
 
+	// Load keyboard data for typing-aware zoom (optional)
+	if len(e.keyboardEvents) == 0 && e.config.EnableTypingZoom {
+		keyboardEvents, err := LoadKeyboardEvents(e.config)
+		if err != nil {
+			return fmt.Errorf("failed to load keyboard data: %w", err)
+		}
+		e.keyboardEvents = keyboardEvents
+	}
+
 	// Generate camera path
 	if err := e.GenerateCameraPath(); err != nil {
 		return fmt.Errorf("failed to generate camera path: %w", err)
@@ -140,6 +228,9 @@ func (e *Exporter) GetExportInfo() map[string]interface{} {
 	info["fps"] = e.config.FPS
 	info["enableZoom"] = e.config.EnableZoom
 	info["zoomLevel"] = e.config.ZoomLevel
+	info["keyboardEventCount"] = len(e.keyboardEvents)
+	info["enableTypingZoom"] = e.config.EnableTypingZoom
+	info["typingZoomLevel"] = e.config.TypingZoomLevel
 	info["smoothFactor"] = e.config.SmoothFactor
 	info["showCursor"] = e.config.ShowCursor
 
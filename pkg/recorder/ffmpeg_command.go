@@ -1,18 +1,25 @@
 package recorder
 
 import (
+	"SmoothScreen/pkg/ffmpeg"
 	"fmt"
-	"os/exec"
-	"strings"
 )
 
 // CaptureConfig 屏幕捕获配置
 type CaptureConfig struct {
 	OutputPath string // 输出文件路径
 	FrameRate  int    // 帧率（默认 60）
-	Codec      string // 编码器（h264_nvenc 或 libx264）
+	Codec      string // 编码器（h264_nvenc、h264_qsv、h264_amf 或 libx264）
 	Quality    int    // 质量参数
 	Preset     string // 编码预设
+	MaxBitrate int    // 码率上限（kbit/s，0 表示不限制，只按质量参数编码）
+
+	// 分段输出（回放缓冲）：SegmentSeconds > 0 时 OutputPath 为分段文件名模板（如 seg_%06d.ts）
+	SegmentSeconds  int    // 每段时长（秒）
+	SegmentList     string // 分段列表（CSV：文件名,开始时间,结束时间）
+	SegmentListSize int    // 列表中保留的最近分段数（0 表示全部）
+
+	streams []streamRelay // 推流中继输出（每路单独编码，见 live_stream.go）
 }
 
 // DefaultCaptureConfig 返回默认的捕获配置
@@ -28,92 +35,141 @@ func DefaultCaptureConfig(outputPath string) CaptureConfig {
 
 // BuildDDAGrabCommand 构建 ddagrap 命令（推荐，使用 lavfi 滤镜）
 // ddagrab 是 Windows 10/11 的高性能屏幕捕获方法
-func BuildDDAGrabCommand(ffmpegPath string, config CaptureConfig) []string {
-	// 基础命令
-	args := []string{
-		"-y", // 覆盖输出文件
-		"-f", "lavfi",
-		"-i", fmt.Sprintf("ddagrab=framerate=%d:draw_mouse=0", config.FrameRate),
-	}
+func BuildDDAGrabCommand(ffmpegPath string, config CaptureConfig) ([]string, error) {
+	source := ffmpeg.NewFilter("ddagrab").
+		Set("framerate", config.FrameRate).
+		Set("draw_mouse", 0)
 
-	// 添加编码器参数
-	args = append(args, buildEncoderArgs(config)...)
+	cmd := ffmpeg.NewCommand().Overwrite() // 覆盖输出文件
+	cmd.Input(source.String()).Format("lavfi")
+	addCaptureOutput(cmd, config)
 
-	// 输出文件
-	args = append(args, config.OutputPath)
-
-	return args
+	return cmd.Args()
 }
 
 // BuildGDIRABCommand 构建 gdigrab 命令（回退方案）
 // gdigrab 是 Windows 的传统屏幕捕获方法
-func BuildGDIRABCommand(ffmpegPath string, config CaptureConfig) []string {
-	// 基础命令
-	args := []string{
-		"-y", // 覆盖输出文件
-		"-f", "gdigrab",
-		"-framerate", fmt.Sprintf("%d", config.FrameRate),
-		"-i", "desktop", // 捕获整个桌面
-	}
+func BuildGDIRABCommand(ffmpegPath string, config CaptureConfig) ([]string, error) {
+	cmd := ffmpeg.NewCommand().Overwrite()                                            // 覆盖输出文件
+	cmd.Input("desktop", ffmpeg.Opt("framerate", config.FrameRate)).Format("gdigrab") // 捕获整个桌面
+	addCaptureOutput(cmd, config)
 
-	// 添加编码器参数
-	args = append(args, buildEncoderArgs(config)...)
+	return cmd.Args()
+}
 
-	// 输出文件
-	args = append(args, config.OutputPath)
+// addCaptureOutput 添加捕获输出（单个文件或分段，以及推流中继）
+func addCaptureOutput(cmd *ffmpeg.Command, config CaptureConfig) {
+	output := cmd.Output(config.OutputPath, buildEncoderOptions(config)...)
+	if config.SegmentSeconds > 0 {
+		// 每段以关键帧开始，分段可以单独解码并无损拼接
+		output.Format("segment").With(
+			ffmpeg.Opt("force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", config.SegmentSeconds)),
+			ffmpeg.Opt("segment_time", config.SegmentSeconds),
+			ffmpeg.Opt("segment_format", "mpegts"),
+			ffmpeg.Opt("segment_list", config.SegmentList),
+			ffmpeg.Opt("segment_list_type", "csv"),
+			ffmpeg.Opt("segment_list_size", config.SegmentListSize),
+			ffmpeg.Opt("reset_timestamps", 1),
+		)
+	}
 
-	return args
+	// 推流输出写到本地中继，推流中断不影响捕获进程
+	for _, relay := range config.streams {
+		cmd.Output(relay.url, streamEncoderOptions(relay.config, config.FrameRate)...).Format("mpegts")
+	}
 }
 
-// buildEncoderArgs 构建编码器参数
-func buildEncoderArgs(config CaptureConfig) []string {
-	var args []string
-
+// buildEncoderOptions 构建编码器参数
+func buildEncoderOptions(config CaptureConfig) []ffmpeg.Option {
+	if config.MaxBitrate > 0 {
+		return buildCappedEncoderOptions(config)
+	}
 	switch config.Codec {
 	case "h264_nvenc":
 		// NVIDIA NVENC 编码器参数
-		args = []string{
-			"-c:v", "h264_nvenc",
-			"-qp", fmt.Sprintf("%d", config.Quality),
-			"-preset", config.Preset,
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_nvenc"),
+			ffmpeg.Opt("qp", config.Quality),
+			ffmpeg.Opt("preset", config.Preset),
+		}
+	case "h264_qsv":
+		// Intel QSV 编码器参数
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_qsv"),
+			ffmpeg.Opt("global_quality", config.Quality),
+			ffmpeg.Opt("preset", config.Preset),
+		}
+	case "h264_amf":
+		// AMD AMF 编码器参数
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_amf"),
+			ffmpeg.Opt("rc", "cqp"),
+			ffmpeg.Opt("qp_i", config.Quality),
+			ffmpeg.Opt("qp_p", config.Quality),
 		}
 	case "libx264":
 		// libx264 编码器参数
-		args = []string{
-			"-c:v", "libx264",
-			"-preset", config.Preset,
-			"-crf", fmt.Sprintf("%d", config.Quality),
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "libx264"),
+			ffmpeg.Opt("preset", config.Preset),
+			ffmpeg.Opt("crf", config.Quality),
 		}
 	default:
-		// 默认使用 libx264
-		args = []string{
-			"-c:v", "libx264",
-			"-preset", "ultrafast",
-			"-crf", "23",
+		// 默认使用 libx264（VAAPI、VideoToolbox 等不适用于 Windows 屏幕捕获）
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "libx264"),
+			ffmpeg.Opt("preset", "ultrafast"),
+			ffmpeg.Opt("crf", 23),
 		}
 	}
-
-	return args
 }
 
-// DetectBestCodec 检测最佳编码器
-// 优先级: h264_nvenc > libx264
-func DetectBestCodec(ffmpegPath string) (string, error) {
-	// 1. 尝试检测 h264_nvenc
-	cmd := exec.Command(ffmpegPath, "-encoders")
-	output, err := cmd.Output()
-	if err == nil && strings.Contains(string(output), "h264_nvenc") {
-		return "h264_nvenc", nil
-	}
+// buildCappedEncoderOptions 构建限制码率的编码器参数
+// 画面简单时仍按质量参数编码，复杂画面的码率不超过上限（缓冲区为 2 秒）
+func buildCappedEncoderOptions(config CaptureConfig) []ffmpeg.Option {
+	maxRate := fmt.Sprintf("%dk", config.MaxBitrate)
+	bufSize := fmt.Sprintf("%dk", config.MaxBitrate*2)
 
-	// 2. 回退到 libx264
-	cmd = exec.Command(ffmpegPath, "-encoders")
-	output, err = cmd.Output()
-	if err == nil && strings.Contains(string(output), "libx264") {
-		return "libx264", nil
+	switch config.Codec {
+	case "h264_nvenc":
+		// 固定 QP 模式不受 maxrate 限制，改用 VBR + 目标质量
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_nvenc"),
+			ffmpeg.Opt("rc", "vbr"),
+			ffmpeg.Opt("cq", config.Quality),
+			ffmpeg.Opt("b:v", 0),
+			ffmpeg.Opt("maxrate", maxRate),
+			ffmpeg.Opt("bufsize", bufSize),
+			ffmpeg.Opt("preset", config.Preset),
+		}
+	case "h264_qsv":
+		// ICQ 模式不支持 maxrate，使用 VBR
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_qsv"),
+			ffmpeg.Opt("b:v", maxRate),
+			ffmpeg.Opt("maxrate", maxRate),
+			ffmpeg.Opt("bufsize", bufSize),
+			ffmpeg.Opt("preset", config.Preset),
+		}
+	case "h264_amf":
+		return []ffmpeg.Option{
+			ffmpeg.Opt("c:v", "h264_amf"),
+			ffmpeg.Opt("rc", "vbr_peak"),
+			ffmpeg.Opt("b:v", maxRate),
+			ffmpeg.Opt("maxrate", maxRate),
+			ffmpeg.Opt("bufsize", bufSize),
+		}
+	default:
+		// libx264：CRF + VBV 上限
+		options := buildEncoderOptions(CaptureConfig{Codec: config.Codec, Preset: config.Preset, Quality: config.Quality})
+		return append(options, ffmpeg.Opt("maxrate", maxRate), ffmpeg.Opt("bufsize", bufSize))
 	}
+}
 
-	return "", fmt.Errorf("未找到可用的视频编码器")
+// DetectBestCodec 检测最佳编码器
+// 优先级见 ffmpeg.EncoderRanking
+func DetectBestCodec(ffmpegManager *ffmpeg.FFmpegManager) (string, error) {
+	return ffmpegManager.GetBestEncoder()
 }
 
 // GetBestPreset 获取编码器的最佳预设
@@ -131,15 +187,12 @@ func GetBestPreset(codec string) string {
 }
 
 // BuildExportCommand 构建导出命令（从 stdin 接收图像数据）
-func BuildExportCommand(ffmpegPath string, outputPath string, frameRate int) []string {
-	return []string{
-		"-y", // 覆盖输出文件
-		"-f", "image2pipe",
-		"-framerate", fmt.Sprintf("%d", frameRate),
-		"-i", "-", // 从 stdin 读取
-		"-c:v", "libx264",
-		"-preset", "medium",
-		"-crf", "20",
-		outputPath,
-	}
+func BuildExportCommand(ffmpegPath string, outputPath string, frameRate int) ([]string, error) {
+	cmd := ffmpeg.NewCommand().Overwrite()                                  // 覆盖输出文件
+	cmd.Input("-", ffmpeg.Opt("framerate", frameRate)).Format("image2pipe") // 从 stdin 读取
+	cmd.Output(outputPath).
+		VideoCodec("libx264").
+		With(ffmpeg.Opt("preset", "medium"), ffmpeg.Opt("crf", 20))
+
+	return cmd.Args()
 }
@@ -0,0 +1,100 @@
+package recorder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildDDAGrabCommand(t *testing.T) {
+	source := []string{"-y", "-f", "lavfi", "-i", "ddagrab=framerate=60:draw_mouse=0"}
+
+	tests := []struct {
+		name   string
+		config CaptureConfig
+		want   []string
+	}{
+		{
+			name:   "nvenc",
+			config: CaptureConfig{OutputPath: "out.mp4", FrameRate: 60, Codec: "h264_nvenc", Quality: 20, Preset: "p4"},
+			want:   []string{"-c:v", "h264_nvenc", "-qp", "20", "-preset", "p4", "out.mp4"},
+		},
+		{
+			// 没有预设时不能输出 -preset，否则会吞掉后面的输出路径
+			name:   "qsv 没有预设",
+			config: CaptureConfig{OutputPath: "out.mp4", FrameRate: 60, Codec: "h264_qsv", Quality: 20},
+			want:   []string{"-c:v", "h264_qsv", "-global_quality", "20", "out.mp4"},
+		},
+		{
+			name:   "libx264 码率上限",
+			config: CaptureConfig{OutputPath: "out.mp4", FrameRate: 60, Codec: "libx264", Quality: 23, MaxBitrate: 8000},
+			want:   []string{"-c:v", "libx264", "-crf", "23", "-maxrate", "8000k", "-bufsize", "16000k", "out.mp4"},
+		},
+		{
+			name:   "nvenc 码率上限",
+			config: CaptureConfig{OutputPath: "out.mp4", FrameRate: 60, Codec: "h264_nvenc", Quality: 20, Preset: "p4", MaxBitrate: 6000},
+			want: []string{"-c:v", "h264_nvenc", "-rc", "vbr", "-cq", "20", "-b:v", "0",
+				"-maxrate", "6000k", "-bufsize", "12000k", "-preset", "p4", "out.mp4"},
+		},
+		{
+			name: "amf 分段",
+			config: CaptureConfig{OutputPath: "seg_%06d.ts", FrameRate: 60, Codec: "h264_amf", Quality: 22,
+				SegmentSeconds: 2, SegmentList: "segments.csv", SegmentListSize: 30},
+			want: []string{"-c:v", "h264_amf", "-rc", "cqp", "-qp_i", "22", "-qp_p", "22",
+				"-f", "segment", "-force_key_frames", "expr:gte(t,n_forced*2)", "-segment_time", "2",
+				"-segment_format", "mpegts", "-segment_list", "segments.csv", "-segment_list_type", "csv",
+				"-segment_list_size", "30", "-reset_timestamps", "1", "seg_%06d.ts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := BuildDDAGrabCommand("ffmpeg", tt.config)
+			if err != nil {
+				t.Fatalf("BuildDDAGrabCommand: %v", err)
+			}
+			want := append(append([]string{}, source...), tt.want...)
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+			}
+		})
+	}
+}
+
+func TestBuildGDIRABCommand(t *testing.T) {
+	args, err := BuildGDIRABCommand("ffmpeg", CaptureConfig{OutputPath: "out.mp4", FrameRate: 30, Codec: "libx264", Quality: 23, Preset: "ultrafast"})
+	if err != nil {
+		t.Fatalf("BuildGDIRABCommand: %v", err)
+	}
+	want := []string{"-y", "-framerate", "30", "-f", "gdigrab", "-i", "desktop",
+		"-c:v", "libx264", "-preset", "ultrafast", "-crf", "23", "out.mp4"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+	}
+}
+
+func TestBuildCaptureCommandInvalidConfig(t *testing.T) {
+	// 用户配置无效时返回错误而不是 panic
+	if _, err := BuildDDAGrabCommand("ffmpeg", CaptureConfig{FrameRate: 60, Codec: "libx264"}); err == nil {
+		t.Error("没有输出路径时 BuildDDAGrabCommand 应返回错误")
+	}
+	if _, err := BuildGDIRABCommand("ffmpeg", CaptureConfig{FrameRate: 60}); err == nil {
+		t.Error("没有输出路径时 BuildGDIRABCommand 应返回错误")
+	}
+}
+
+func TestBuildExportCommand(t *testing.T) {
+	args, err := BuildExportCommand("ffmpeg", "export.mp4", 60)
+	if err != nil {
+		t.Fatalf("BuildExportCommand: %v", err)
+	}
+	want := []string{"-y", "-framerate", "60", "-f", "image2pipe", "-i", "-",
+		"-c:v", "libx264", "-preset", "medium", "-crf", "20", "export.mp4"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("参数不符\n got: %s\nwant: %s", strings.Join(args, " "), strings.Join(want, " "))
+	}
+
+	if _, err := BuildExportCommand("ffmpeg", "", 60); err == nil {
+		t.Error("没有输出路径时 BuildExportCommand 应返回错误")
+	}
+}
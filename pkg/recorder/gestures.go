@@ -0,0 +1,242 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// 手势类型
+const (
+	GestureClick       = "click"
+	GestureDoubleClick = "double_click"
+	GestureTripleClick = "triple_click"
+	GestureDrag        = "drag"
+	GestureSelection   = "selection" // 近似文本选择的拖动（左键、扁平的范围）
+	GestureScroll      = "scroll"    // 连续滚动
+)
+
+// Gesture 由原始鼠标事件归纳出的高层手势
+type Gesture struct {
+	Type     string      `json:"type"`
+	Button   string      `json:"button,omitempty"`
+	Start    int64       `json:"start"` // 开始时间（毫秒，与鼠标事件同一时间轴）
+	End      int64       `json:"end"`
+	X        int16       `json:"x"` // 起点（点击位置、拖动起点或滚动位置）
+	Y        int16       `json:"y"`
+	EndX     int16       `json:"endX"` // 终点（拖动松开的位置）
+	EndY     int16       `json:"endY"`
+	Bounds   FocusRegion `json:"bounds"`             // 包含整个手势路径的矩形
+	Count    int         `json:"count,omitempty"`    // 连击次数或滚动次数
+	Delta    int         `json:"delta,omitempty"`    // 滚动增量总和
+	Distance float64     `json:"distance,omitempty"` // 拖动路径长度（像素）
+}
+
+// GestureConfig 手势识别参数
+type GestureConfig struct {
+	MultiClickMs       int64   `json:"multiClickMs"`       // 连击中相邻两次点击的最大间隔
+	MultiClickRadius   float64 `json:"multiClickRadius"`   // 连击中点击位置的最大距离（像素）
+	DragThreshold      float64 `json:"dragThreshold"`      // 按下后移动超过此距离视为拖动（像素）
+	SelectionMaxHeight int     `json:"selectionMaxHeight"` // 左键拖动的范围不高于此值时视为文本选择（像素）
+	ScrollGapMs        int64   `json:"scrollGapMs"`        // 间隔不超过此值的滚动属于同一次连续滚动
+}
+
+// DefaultGestureConfig 返回默认的手势识别参数（与常见系统的双击设置接近）
+func DefaultGestureConfig() GestureConfig {
+	return GestureConfig{
+		MultiClickMs:       500,
+		MultiClickRadius:   6,
+		DragThreshold:      8,
+		SelectionMaxHeight: 48,
+		ScrollGapMs:        400,
+	}
+}
+
+// pressState 按下中的按键
+type pressState struct {
+	down     hook.MouseEvent
+	bounds   gestureBounds
+	lastX    int16
+	lastY    int16
+	maxDist  float64 // 离按下位置的最远距离
+	distance float64 // 路径长度
+}
+
+// gestureBounds 累积的边界
+type gestureBounds struct {
+	minX, minY, maxX, maxY int16
+}
+
+// newGestureBounds 以一个点创建边界
+func newGestureBounds(x, y int16) gestureBounds {
+	return gestureBounds{minX: x, minY: y, maxX: x, maxY: y}
+}
+
+// add 扩展边界以包含一个点
+func (b *gestureBounds) add(x, y int16) {
+	b.minX, b.maxX = min(b.minX, x), max(b.maxX, x)
+	b.minY, b.maxY = min(b.minY, y), max(b.maxY, y)
+}
+
+// boundsOf 由矩形创建边界
+func boundsOf(region FocusRegion) gestureBounds {
+	b := newGestureBounds(int16(region.X), int16(region.Y))
+	b.add(int16(region.X+region.Width), int16(region.Y+region.Height))
+	return b
+}
+
+// distance 两点之间的距离
+func distance(x1, y1, x2, y2 int16) float64 {
+	return math.Hypot(float64(x2)-float64(x1), float64(y2)-float64(y1))
+}
+
+// region 转换为矩形
+func (b gestureBounds) region() FocusRegion {
+	return FocusRegion{
+		X:      int(b.minX),
+		Y:      int(b.minY),
+		Width:  int(b.maxX) - int(b.minX),
+		Height: int(b.maxY) - int(b.minY),
+	}
+}
+
+// AnalyzeGestures 从按时间排序的鼠标事件中识别点击、连击、拖动、选择和连续滚动
+// 结果按开始时间排序；没有松开的按下（录制在按住时结束）不产生手势
+func AnalyzeGestures(events []hook.MouseEvent, config GestureConfig) []Gesture {
+	var gestures []Gesture
+	pressed := make(map[string]*pressState)
+	lastClick := -1  // 最近一次点击手势的下标（用于合并连击）
+	lastScroll := -1 // 最近一次滚动手势的下标
+
+	for _, event := range events {
+		switch event.EventType {
+		case "move":
+			for _, press := range pressed {
+				press.distance += distance(press.lastX, press.lastY, event.X, event.Y)
+				press.maxDist = math.Max(press.maxDist, distance(press.down.X, press.down.Y, event.X, event.Y))
+				press.bounds.add(event.X, event.Y)
+				press.lastX, press.lastY = event.X, event.Y
+			}
+
+		case "l_down", "r_down", "m_down":
+			pressed[event.Button] = &pressState{
+				down:   event,
+				bounds: newGestureBounds(event.X, event.Y),
+				lastX:  event.X,
+				lastY:  event.Y,
+			}
+
+		case "l_up", "r_up", "m_up":
+			press, ok := pressed[event.Button]
+			if !ok {
+				continue
+			}
+			delete(pressed, event.Button)
+			press.bounds.add(event.X, event.Y)
+			press.maxDist = math.Max(press.maxDist, distance(press.down.X, press.down.Y, event.X, event.Y))
+
+			gesture := Gesture{
+				Button: event.Button,
+				Start:  press.down.Timestamp,
+				End:    event.Timestamp,
+				X:      press.down.X,
+				Y:      press.down.Y,
+				EndX:   event.X,
+				EndY:   event.Y,
+				Bounds: press.bounds.region(),
+			}
+
+			if press.maxDist >= config.DragThreshold {
+				gesture.Type = GestureDrag
+				gesture.Distance = math.Round(press.distance*10) / 10
+				if event.Button == "left" && gesture.Bounds.Height <= config.SelectionMaxHeight {
+					gesture.Type = GestureSelection
+				}
+				gestures = append(gestures, gesture)
+				lastClick = -1
+				continue
+			}
+
+			// 与上一次点击合并为双击、三击
+			if lastClick >= 0 && mergeClick(&gestures[lastClick], gesture, config) {
+				continue
+			}
+			gesture.Type = GestureClick
+			gesture.Count = 1
+			gestures = append(gestures, gesture)
+			lastClick = len(gestures) - 1
+
+		case "scroll":
+			if lastScroll >= 0 && event.Timestamp-gestures[lastScroll].End <= config.ScrollGapMs {
+				scroll := &gestures[lastScroll]
+				scroll.End = event.Timestamp
+				scroll.EndX, scroll.EndY = event.X, event.Y
+				scroll.Count++
+				scroll.Delta += event.Delta
+				bounds := boundsOf(scroll.Bounds)
+				bounds.add(event.X, event.Y)
+				scroll.Bounds = bounds.region()
+				continue
+			}
+			gestures = append(gestures, Gesture{
+				Type:   GestureScroll,
+				Start:  event.Timestamp,
+				End:    event.Timestamp,
+				X:      event.X,
+				Y:      event.Y,
+				EndX:   event.X,
+				EndY:   event.Y,
+				Bounds: FocusRegion{X: int(event.X), Y: int(event.Y)},
+				Count:  1,
+				Delta:  event.Delta,
+			})
+			lastScroll = len(gestures) - 1
+		}
+	}
+
+	// 拖动在松开时才加入，期间的滚动可能排在它前面
+	sort.SliceStable(gestures, func(i, j int) bool { return gestures[i].Start < gestures[j].Start })
+	return gestures
+}
+
+// mergeClick 把点击并入上一次点击（同一按键、间隔和距离足够小、不超过三击）
+func mergeClick(previous *Gesture, click Gesture, config GestureConfig) bool {
+	if previous.Button != click.Button || previous.Count >= 3 {
+		return false
+	}
+	if click.Start-previous.End > config.MultiClickMs {
+		return false
+	}
+	if distance(previous.X, previous.Y, click.X, click.Y) > config.MultiClickRadius {
+		return false
+	}
+
+	previous.Count++
+	previous.End = click.End
+	previous.EndX, previous.EndY = click.EndX, click.EndY
+	bounds := boundsOf(previous.Bounds)
+	bounds.add(click.X, click.Y)
+	previous.Bounds = bounds.region()
+	if previous.Count == 2 {
+		previous.Type = GestureDoubleClick
+	} else {
+		previous.Type = GestureTripleClick
+	}
+	return true
+}
+
+// LoadGestures 读取鼠标数据文件并识别手势
+func LoadGestures(mouseDataPath string, config GestureConfig) ([]Gesture, error) {
+	data, err := os.ReadFile(mouseDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取鼠标数据失败: %w", err)
+	}
+	var events []hook.MouseEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("解析鼠标数据失败: %w", err)
+	}
+	return AnalyzeGestures(events, config), nil
+}
@@ -0,0 +1,210 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"testing"
+)
+
+// click 在 t 毫秒按下、t+80 毫秒松开左键
+func click(t int64, x, y int16) []hook.MouseEvent {
+	return []hook.MouseEvent{
+		{Timestamp: t, X: x, Y: y, EventType: "l_down", Button: "left"},
+		{Timestamp: t + 80, X: x, Y: y, EventType: "l_up", Button: "left"},
+	}
+}
+
+// drag 从 (x, y) 按下左键，经过 (toX, toY) 后在该处松开
+// 按住时的 move 对应 gohook 的 MouseDrag（鼠标钩子把它记录为移动，见 TestMouseHookRecordsDrag）
+func drag(t int64, x, y, toX, toY int16) []hook.MouseEvent {
+	return []hook.MouseEvent{
+		{Timestamp: t, X: x, Y: y, EventType: "l_down", Button: "left"},
+		{Timestamp: t + 50, X: toX, Y: toY, EventType: "move"},
+		{Timestamp: t + 100, X: toX, Y: toY, EventType: "l_up", Button: "left"},
+	}
+}
+
+// sequence 依次拼接事件
+func sequence(groups ...[]hook.MouseEvent) []hook.MouseEvent {
+	var events []hook.MouseEvent
+	for _, group := range groups {
+		events = append(events, group...)
+	}
+	return events
+}
+
+func TestAnalyzeGesturesClickCount(t *testing.T) {
+	config := DefaultGestureConfig()
+
+	tests := []struct {
+		name   string
+		events []hook.MouseEvent
+		want   []string
+		counts []int
+	}{
+		{"单击", click(0, 100, 100), []string{GestureClick}, []int{1}},
+		{
+			"双击",
+			sequence(click(0, 100, 100), click(200, 101, 100)),
+			[]string{GestureDoubleClick}, []int{2},
+		},
+		{
+			"三击",
+			sequence(click(0, 100, 100), click(200, 100, 100), click(400, 100, 100)),
+			[]string{GestureTripleClick}, []int{3},
+		},
+		{
+			// 最多合并到三击，第四次点击开始新的点击
+			"四次点击",
+			sequence(click(0, 100, 100), click(200, 100, 100), click(400, 100, 100), click(600, 100, 100)),
+			[]string{GestureTripleClick, GestureClick}, []int{3, 1},
+		},
+		{
+			// 间隔恰好为 MultiClickMs 时仍是双击
+			"间隔在阈值上",
+			sequence(click(0, 100, 100), click(80+config.MultiClickMs, 100, 100)),
+			[]string{GestureDoubleClick}, []int{2},
+		},
+		{
+			"间隔超过阈值",
+			sequence(click(0, 100, 100), click(81+config.MultiClickMs, 100, 100)),
+			[]string{GestureClick, GestureClick}, []int{1, 1},
+		},
+		{
+			"距离超过阈值",
+			sequence(click(0, 100, 100), click(200, 100+int16(config.MultiClickRadius)+1, 100)),
+			[]string{GestureClick, GestureClick}, []int{1, 1},
+		},
+		{
+			"不同按键",
+			sequence(click(0, 100, 100), []hook.MouseEvent{
+				{Timestamp: 200, X: 100, Y: 100, EventType: "r_down", Button: "right"},
+				{Timestamp: 280, X: 100, Y: 100, EventType: "r_up", Button: "right"},
+			}),
+			[]string{GestureClick, GestureClick}, []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gestures := AnalyzeGestures(tt.events, config)
+			if len(gestures) != len(tt.want) {
+				t.Fatalf("手势 = %+v, want %v", gestures, tt.want)
+			}
+			for i, gesture := range gestures {
+				if gesture.Type != tt.want[i] || gesture.Count != tt.counts[i] {
+					t.Errorf("手势 %d = %s×%d, want %s×%d", i, gesture.Type, gesture.Count, tt.want[i], tt.counts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeGesturesDragThresholds(t *testing.T) {
+	config := DefaultGestureConfig()
+	threshold := int16(config.DragThreshold)
+	maxHeight := int16(config.SelectionMaxHeight)
+
+	tests := []struct {
+		name   string
+		events []hook.MouseEvent
+		want   string
+	}{
+		{"移动不到拖动阈值", drag(0, 100, 100, 100+threshold-1, 100), GestureClick},
+		{"移动达到拖动阈值", drag(0, 100, 100, 100, 100+threshold), GestureSelection},
+		{"扁平的左键拖动", drag(0, 100, 100, 400, 100+maxHeight), GestureSelection},
+		{"高于选择阈值的左键拖动", drag(0, 100, 100, 400, 100+maxHeight+1), GestureDrag},
+		{"右键拖动", []hook.MouseEvent{
+			{Timestamp: 0, X: 100, Y: 100, EventType: "r_down", Button: "right"},
+			{Timestamp: 50, X: 400, Y: 110, EventType: "move"},
+			{Timestamp: 100, X: 400, Y: 110, EventType: "r_up", Button: "right"},
+		}, GestureDrag},
+		{
+			// 拖出阈值后回到起点松开仍是拖动
+			"回到起点松开",
+			[]hook.MouseEvent{
+				{Timestamp: 0, X: 100, Y: 100, EventType: "l_down", Button: "left"},
+				{Timestamp: 50, X: 100, Y: 300, EventType: "move"},
+				{Timestamp: 100, X: 100, Y: 100, EventType: "l_up", Button: "left"},
+			},
+			GestureDrag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gestures := AnalyzeGestures(tt.events, config)
+			if len(gestures) != 1 || gestures[0].Type != tt.want {
+				t.Fatalf("手势 = %+v, want %s", gestures, tt.want)
+			}
+		})
+	}
+
+	// 拖动之后的点击不与拖动之前的点击合并
+	gestures := AnalyzeGestures(sequence(click(0, 100, 100), drag(150, 100, 100, 300, 100), click(300, 100, 100)), config)
+	if len(gestures) != 3 || gestures[0].Type != GestureClick || gestures[2].Type != GestureClick {
+		t.Errorf("点击-拖动-点击 = %+v", gestures)
+	}
+}
+
+func TestAnalyzeGesturesRecordedDrag(t *testing.T) {
+	// 鼠标钩子从 libuiohook 的真实序列（按下、若干 MouseDrag、松开，按住期间没有 MouseMove）记录的事件
+	events := []hook.MouseEvent{
+		{Timestamp: 0, X: 90, Y: 100, EventType: "move"},
+		{Timestamp: 100, X: 100, Y: 100, EventType: "l_down", Button: "left"},
+		{Timestamp: 116, X: 150, Y: 120, EventType: "move"},
+		{Timestamp: 132, X: 300, Y: 200, EventType: "move"},
+		{Timestamp: 148, X: 400, Y: 300, EventType: "move"},
+		{Timestamp: 180, X: 400, Y: 300, EventType: "l_up", Button: "left"},
+		{Timestamp: 200, X: 410, Y: 300, EventType: "move"},
+	}
+	want := Gesture{
+		Type:     GestureDrag,
+		Button:   "left",
+		Start:    100,
+		End:      180,
+		X:        100,
+		Y:        100,
+		EndX:     400,
+		EndY:     300,
+		Bounds:   FocusRegion{X: 100, Y: 100, Width: 300, Height: 200},
+		Distance: 365.3,
+	}
+	gestures := AnalyzeGestures(events, DefaultGestureConfig())
+	if len(gestures) != 1 || gestures[0] != want {
+		t.Fatalf("手势 = %+v\nwant %+v", gestures, want)
+	}
+}
+
+func TestCameraPathClickEffectGestures(t *testing.T) {
+	events := sequence(click(0, 100, 100), click(200, 100, 100), drag(1000, 100, 100, 500, 120))
+	events = append(events, hook.MouseEvent{Timestamp: 2000, X: 500, Y: 120, EventType: "move"})
+
+	frames := GenerateCameraPath(events, 1920, 1080, 10)
+	gestureAt := func(timestamp int64) (string, int) {
+		for _, frame := range frames {
+			if frame.Timestamp == timestamp {
+				return frame.Gesture, frame.ClickCount
+			}
+		}
+		t.Fatalf("没有 %d ms 的帧", timestamp)
+		return "", 0
+	}
+
+	// 双击的特效持续到松开后 clickEffectHoldMs，之后是拖动的选择特效
+	for _, tt := range []struct {
+		timestamp int64
+		gesture   string
+		count     int
+	}{
+		{0, GestureDoubleClick, 2},
+		{200, GestureDoubleClick, 2},
+		{500, GestureDoubleClick, 2},
+		{700, "", 0},
+		{1000, GestureSelection, 0},
+		{1500, "", 0},
+	} {
+		if gesture, count := gestureAt(tt.timestamp); gesture != tt.gesture || count != tt.count {
+			t.Errorf("%d ms: 特效 = %q×%d, want %q×%d", tt.timestamp, gesture, count, tt.gesture, tt.count)
+		}
+	}
+}
@@ -3,31 +3,43 @@ package recorder
 import (
 	"SmoothScreen/pkg/ffmpeg"
 	"SmoothScreen/pkg/hook"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // GPUExporter GPU 加速的视频导出器
 // 使用 FFmpeg 硬件加速和滤镜链处理视频，无需前端渲染
 type GPUExporter struct {
-	ffmpegManager *ffmpeg.FFmpegManager
-	config        ExportConfig
-	mouseEvents   []hook.MouseEvent
-	cameraFrames  []CameraFrame
-	cmd           *exec.Cmd
-	isExporting   bool
+	ffmpegManager  *ffmpeg.FFmpegManager
+	config         ExportConfig
+	mouseEvents    []hook.MouseEvent
+	keyboardEvents []hook.KeyboardEvent
+	cameraFrames   []CameraFrame
+	cursor         *cursorOverlay // 录制的光标形状（没有数据或不显示光标时为 nil）
+	isExporting    atomic.Bool    // Stop 和 IsExporting 可能在其他协程调用
+
+	// 导出进程和分段导出状态
+	mu          sync.Mutex
+	process     *ffmpeg.Process
+	cancel      context.CancelFunc
+	segmentDone []int // 每段已编码的帧数
+	totalFrames int
+
+	log *tailBuffer // FFmpeg 输出末尾，用于错误日志
 }
 
 // NewGPUExporter 创建 GPU 加速导出器
 func NewGPUExporter(ffmpegManager *ffmpeg.FFmpegManager) *GPUExporter {
 	return &GPUExporter{
 		ffmpegManager: ffmpegManager,
-		isExporting:   false,
+		log:           newTailBuffer(exportLogTailSize),
 	}
 }
 
@@ -50,6 +62,19 @@ func (e *GPUExporter) PrepareExport(config ExportConfig) error {
 		return fmt.Errorf("创建输出目录失败: %w", err)
 	}
 
+	// 光标形状可选，没有数据时导出中不绘制光标
+	e.cursor.Close()
+	e.cursor = nil
+	if config.ShowCursor {
+		cursor, err := newCursorOverlay(config, e.mouseEvents)
+		if err != nil {
+			fmt.Printf("警告: %v\n", err)
+		} else if cursor != nil {
+			e.cursor = cursor
+			fmt.Printf("✓ 使用录制的光标形状: %d 个光标图像\n", len(cursor.sprites))
+		}
+	}
+
 	return nil
 }
 
@@ -65,15 +90,27 @@ func (e *GPUExporter) loadMouseData() error {
 	}
 
 	fmt.Printf("✓ 加载了 %d 个鼠标事件\n", len(e.mouseEvents))
+
+	// 键盘数据可选，用于打字时的自动缩放
+	if e.config.EnableTypingZoom {
+		keyboardEvents, err := LoadKeyboardEvents(e.config)
+		if err != nil {
+			return err
+		}
+		e.keyboardEvents = keyboardEvents
+		if len(keyboardEvents) > 0 {
+			fmt.Printf("✓ 加载了 %d 个键盘事件\n", len(keyboardEvents))
+		}
+	}
 	return nil
 }
 
 // generateCameraPath 生成相机路径
 func (e *GPUExporter) generateCameraPath() error {
-	e.cameraFrames = GenerateCameraPath(
+	e.cameraFrames = GenerateCameraPathWithKeyboard(
 		e.mouseEvents,
-		e.config.ScreenWidth,
-		e.config.ScreenHeight,
+		e.keyboardEvents,
+		NewCameraControllerForConfig(e.config),
 		e.config.FPS,
 	)
 
@@ -83,16 +120,13 @@ func (e *GPUExporter) generateCameraPath() error {
 
 // ExportWithGPU 使用 GPU 加速导出视频
 // 此方法使用 FFmpeg 的硬件加速和滤镜链，无需前端渲染
-func (e *GPUExporter) ExportWithGPU() error {
-	if e.isExporting {
+// 取消 ctx 会终止 FFmpeg 进程
+func (e *GPUExporter) ExportWithGPU(ctx context.Context) error {
+	if !e.isExporting.CompareAndSwap(false, true) {
 		return fmt.Errorf("导出已在进行中")
 	}
-
-	// 获取 FFmpeg 路径
-	ffmpegPath, err := e.ffmpegManager.GetFFmpegPath()
-	if err != nil {
-		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
-	}
+	defer e.isExporting.Store(false)
+	defer e.closeCursor()
 
 	// 获取最佳编码器
 	codec, err := e.ffmpegManager.GetBestEncoder()
@@ -104,25 +138,42 @@ func (e *GPUExporter) ExportWithGPU() error {
 	preset := e.ffmpegManager.GetBestPreset(codec)
 
 	// 构建 FFmpeg 命令
-	args := e.buildGPUExportCommand(ffmpegPath, codec, preset)
-
-	fmt.Printf("执行 GPU 加速导出命令:\n%s %s\n", ffmpegPath, strings.Join(args, " "))
-
-	// 创建命令
-	e.cmd = exec.Command(ffmpegPath, args...)
-	e.cmd.Stdout = os.Stdout
-	e.cmd.Stderr = os.Stderr
-
-	e.isExporting = true
+	args, err := e.buildGPUExportCommand(codec, preset)
+	if err != nil {
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
+
+	// 整个视频作为一段统计进度
+	e.mu.Lock()
+	e.totalFrames = len(e.cameraFrames)
+	e.segmentDone = []int{0}
+	e.mu.Unlock()
+
+	// 创建进程
+	e.log.Reset()
+	process, err := e.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{
+		Name: "GPU 导出",
+		Log:  e.log,
+		OnProgress: func(progress ffmpeg.Progress) {
+			e.setSegmentProgress(0, progress.Frame)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("创建 FFmpeg 进程失败: %w", err)
+	}
+	e.mu.Lock()
+	e.process = process
+	e.mu.Unlock()
 
-	// 启动 FFmpeg
+	// 启动 FFmpeg，取消 ctx 时进程会被停止
 	startTime := time.Now()
-	if err := e.cmd.Run(); err != nil {
-		e.isExporting = false
+	if err := process.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("导出已取消")
+		}
 		return fmt.Errorf("FFmpeg 执行失败: %w", err)
 	}
 
-	e.isExporting = false
 	duration := time.Since(startTime)
 
 	fmt.Printf("✓ GPU 加速导出完成: %s (耗时: %.2f 秒)\n", e.config.OutputPath, duration.Seconds())
@@ -130,118 +181,142 @@ func (e *GPUExporter) ExportWithGPU() error {
 }
 
 // buildGPUExportCommand 构建 GPU 加速的 FFmpeg 命令
-func (e *GPUExporter) buildGPUExportCommand(ffmpegPath, codec, preset string) []string {
-	args := []string{}
+func (e *GPUExporter) buildGPUExportCommand(codec, preset string) ([]string, error) {
+	cmd := ffmpeg.NewCommand().Overwrite()
 
 	// 硬件加速选项
+	cmd.Global(ffmpeg.HWDeviceArgs(codec)...)
+	input := cmd.Input(e.config.VideoPath)
 	if strings.Contains(codec, "nvenc") {
 		// NVIDIA GPU 加速
-		args = append(args, "-hwaccel", "cuda")
-		args = append(args, "-hwaccel_output_format", "cuda")
+		input.With(ffmpeg.Opt("hwaccel", "cuda"), ffmpeg.Opt("hwaccel_output_format", "cuda"))
 	} else if strings.Contains(codec, "qsv") {
 		// Intel QSV 加速
-		args = append(args, "-hwaccel", "qsv")
-		args = append(args, "-hwaccel_output_format", "qsv")
+		input.With(ffmpeg.Opt("hwaccel", "qsv"), ffmpeg.Opt("hwaccel_output_format", "qsv"))
 	} else if strings.Contains(codec, "amf") {
 		// AMD AMF 加速
-		args = append(args, "-hwaccel", "d3d11va")
-		args = append(args, "-hwaccel_output_format", "d3d11")
+		input.With(ffmpeg.Opt("hwaccel", "d3d11va"), ffmpeg.Opt("hwaccel_output_format", "d3d11"))
 	}
 
-	// 输入文件
-	args = append(args, "-i", e.config.VideoPath)
+	// 构建滤镜链
+	filters := e.buildFilters()
+	upload := ffmpeg.HWUploadFilters(codec)
+	filters = append(filters, upload...) // VAAPI 等编码器需要把帧上传到显存
+
+	// 光标在相机变换之前叠加，随画面一起缩放
+	video := input.Video()
+	if e.cursor != nil {
+		var err error
+		if video, err = e.cursor.apply(cmd, video, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(filters) > 0 {
+		video = cmd.FilterGraph().Chain([]ffmpeg.Pad{video}, filters...)
+	}
+
+	// 输出：处理后的视频 + 原视频的音频（如果有）
+	output := cmd.Output(e.config.OutputPath).
+		Map(video, input.Stream("a?")).
+		VideoCodec(codec).
+		With(videoEncoderOptions(codec, preset)...)
 
-	// 构建复杂滤镜链
-	filterComplex := e.buildFilterComplex()
-	if filterComplex != "" {
-		args = append(args, "-filter_complex", filterComplex)
+	// 帧率
+	output.With(ffmpeg.Opt("r", e.config.FPS))
+
+	// Pixel format（硬件上传后由编码器决定）
+	if len(upload) == 0 {
+		output.With(ffmpeg.Opt("pix_fmt", "yuv420p"))
 	}
 
-	// 编码器设置
-	args = append(args, "-c:v", codec)
+	return cmd.Args()
+}
 
-	// 编码器特定选项
+// videoEncoderOptions 返回编码器的预设和质量参数（导出和导出后处理共用）
+func videoEncoderOptions(codec, preset string) []ffmpeg.Option {
+	var opts []ffmpeg.Option
 	if strings.Contains(codec, "nvenc") {
 		// NVIDIA 编码器优化
-		args = append(args, "-preset", preset)
-		args = append(args, "-rc", "vbr")        // 可变比特率
-		args = append(args, "-cq", "23")         // 质量控制
-		args = append(args, "-b:v", "5M")        // 目标比特率
-		args = append(args, "-maxrate", "8M")    // 最大比特率
-		args = append(args, "-bufsize", "10M")   // 缓冲区大小
-		args = append(args, "-spatial_aq", "1")  // 空间自适应量化
-		args = append(args, "-temporal_aq", "1") // 时间自适应量化
-		args = append(args, "-gpu", "0")         // 使用第一个 GPU
+		opts = append(opts,
+			ffmpeg.Opt("preset", preset),
+			ffmpeg.Opt("rc", "vbr"),      // 可变比特率
+			ffmpeg.Opt("cq", 23),         // 质量控制
+			ffmpeg.Opt("b:v", "5M"),      // 目标比特率
+			ffmpeg.Opt("maxrate", "8M"),  // 最大比特率
+			ffmpeg.Opt("bufsize", "10M"), // 缓冲区大小
+			ffmpeg.Opt("spatial_aq", 1),  // 空间自适应量化
+			ffmpeg.Opt("temporal_aq", 1), // 时间自适应量化
+			ffmpeg.Opt("gpu", 0),         // 使用第一个 GPU
+		)
 	} else if strings.Contains(codec, "qsv") {
 		// Intel QSV 优化
-		args = append(args, "-preset", preset)
-		args = append(args, "-global_quality", "23")
-		args = append(args, "-look_ahead", "1")
+		opts = append(opts,
+			ffmpeg.Opt("preset", preset),
+			ffmpeg.Opt("global_quality", 23),
+			ffmpeg.Opt("look_ahead", 1),
+		)
 	} else if strings.Contains(codec, "amf") {
 		// AMD AMF 优化
-		args = append(args, "-quality", "balanced")
-		args = append(args, "-rc", "vbr_latency")
-		args = append(args, "-qp_i", "22")
-		args = append(args, "-qp_p", "23")
+		opts = append(opts,
+			ffmpeg.Opt("quality", "balanced"),
+			ffmpeg.Opt("rc", "vbr_latency"),
+			ffmpeg.Opt("qp_i", 22),
+			ffmpeg.Opt("qp_p", 23),
+		)
+	} else if strings.Contains(codec, "vaapi") {
+		// VAAPI 优化
+		opts = append(opts, ffmpeg.Opt("qp", 23))
+	} else if strings.Contains(codec, "videotoolbox") {
+		// Apple VideoToolbox 优化
+		opts = append(opts, ffmpeg.Opt("b:v", "8M"), ffmpeg.Opt("realtime", 0))
 	} else {
-		// 软件编码 (libx264) 优化
-		args = append(args, "-preset", preset)
-		args = append(args, "-crf", "23")
+		// 软件编码 (libx264/libx265/SVT-AV1/VP9) 优化
+		if preset != "" {
+			opts = append(opts, ffmpeg.Opt("preset", preset))
+		}
+		opts = append(opts, softwareQualityOptions(codec)...)
 	}
+	return opts
+}
 
-	// 帧率
-	args = append(args, "-r", fmt.Sprintf("%d", e.config.FPS))
-
-	// Pixel format
-	args = append(args, "-pix_fmt", "yuv420p")
-
-	// 覆盖输出文件
-	args = append(args, "-y")
-
-	// 输出文件
-	args = append(args, e.config.OutputPath)
-
-	return args
+// softwareQualityOptions 返回软件编码器的质量参数
+func softwareQualityOptions(codec string) []ffmpeg.Option {
+	switch codec {
+	case "libvpx-vp9":
+		// VP9 的 CRF 需要配合 -b:v 0 才是恒定质量模式
+		return []ffmpeg.Option{
+			ffmpeg.Opt("crf", 31),
+			ffmpeg.Opt("b:v", 0),
+			ffmpeg.Opt("deadline", "realtime"),
+			ffmpeg.Opt("cpu-used", 8),
+		}
+	case "libsvtav1":
+		return []ffmpeg.Option{ffmpeg.Opt("crf", 35)}
+	default:
+		return []ffmpeg.Option{ffmpeg.Opt("crf", 23)}
+	}
 }
 
-// buildFilterComplex 构建复杂滤镜链
+// buildFilters 构建视频滤镜链
 // 使用 zoompan 滤镜实现相机运动
-func (e *GPUExporter) buildFilterComplex() string {
+func (e *GPUExporter) buildFilters() []*ffmpeg.Filter {
 	if len(e.cameraFrames) == 0 {
-		return ""
+		return nil
 	}
 
-	// 生成 zoompan 表达式
-	zoomExpr := e.generateZoomExpression()
-	xExpr := e.generateXExpression()
-	yExpr := e.generateYExpression()
-
-	// 构建滤镜链
-	filters := []string{}
-
 	// 1. zoompan 滤镜 - 实现缩放和平移
-	zoompanFilter := fmt.Sprintf(
-		"zoompan=z='%s':x='%s':y='%s':d=1:s=%dx%d:fps=%d",
-		zoomExpr,
-		xExpr,
-		yExpr,
-		e.config.ScreenWidth,
-		e.config.ScreenHeight,
-		e.config.FPS,
-	)
-	filters = append(filters, zoompanFilter)
-
-	// 2. 如果需要绘制光标（可选）
-	if e.config.ShowCursor {
-		// 使用 drawtext 滤镜绘制光标指示器
-		// 注意：这里简化处理，实际可以用 overlay 叠加光标图像
-		cursorFilter := e.generateCursorFilter()
-		if cursorFilter != "" {
-			filters = append(filters, cursorFilter)
-		}
-	}
-
-	return strings.Join(filters, ",")
+	filters := []*ffmpeg.Filter{
+		ffmpeg.NewFilter("zoompan").
+			Set("z", e.generateZoomExpression()).
+			Set("x", e.generateXExpression()).
+			Set("y", e.generateYExpression()).
+			Set("d", 1).
+			Set("s", fmt.Sprintf("%dx%d", e.config.ScreenWidth, e.config.ScreenHeight)).
+			Set("fps", e.config.FPS),
+	}
+
+	// 光标需要多路输入，由 buildGPUExportCommand 在相机变换之前叠加
+	return filters
 }
 
 // generateZoomExpression 生成缩放表达式
@@ -297,192 +372,58 @@ func (e *GPUExporter) generateYExpression() string {
 	return fmt.Sprintf("%.1f-(ih/zoom/2)", avgY)
 }
 
-// generateCursorFilter 生成光标绘制滤镜
-func (e *GPUExporter) generateCursorFilter() string {
-	// 这里简化处理，实际应该使用 overlay 滤镜叠加光标图像
-	// 或者在前端预处理时叠加光标
-	return ""
+// closeCursor 删除光标叠加的临时文件
+func (e *GPUExporter) closeCursor() {
+	e.cursor.Close()
+	e.cursor = nil
 }
 
-// ExportWithSegments 分段导出（更精确的相机控制）
-// 将视频分成多个段，每段应用不同的滤镜参数
-func (e *GPUExporter) ExportWithSegments() error {
-	if e.isExporting {
-		return fmt.Errorf("导出已在进行中")
-	}
-
-	// 获取 FFmpeg 路径
-	ffmpegPath, err := e.ffmpegManager.GetFFmpegPath()
-	if err != nil {
-		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+// Stop 停止导出
+// 分段导出时会取消上下文，终止所有工作进程
+func (e *GPUExporter) Stop() error {
+	if !e.isExporting.Load() {
+		return nil
 	}
 
-	// 获取编码器
-	codec, err := e.ffmpegManager.GetBestEncoder()
-	if err != nil {
-		codec = "libx264"
+	e.mu.Lock()
+	cancel := e.cancel
+	process := e.process
+	e.mu.Unlock()
+	if cancel != nil {
+		cancel()
 	}
-	preset := e.ffmpegManager.GetBestPreset(codec)
-
-	fmt.Printf("开始分段导出 (共 %d 帧)...\n", len(e.cameraFrames))
 
-	// 创建临时目录
-	tempDir := filepath.Join(filepath.Dir(e.config.OutputPath), "temp_segments")
-	os.MkdirAll(tempDir, 0755)
-	defer os.RemoveAll(tempDir) // 清理临时文件
-
-	// 分段导出
-	segmentSize := 300 // 每段 300 帧 (10秒@30fps)
-	segments := []string{}
-
-	for i := 0; i < len(e.cameraFrames); i += segmentSize {
-		end := i + segmentSize
-		if end > len(e.cameraFrames) {
-			end = len(e.cameraFrames)
-		}
-
-		segmentPath := filepath.Join(tempDir, fmt.Sprintf("segment_%04d.mp4", i/segmentSize))
-		segments = append(segments, segmentPath)
-
-		// 导出这一段
-		if err := e.exportSegment(ffmpegPath, codec, preset, i, end, segmentPath); err != nil {
-			return fmt.Errorf("导出段 %d-%d 失败: %w", i, end, err)
+	if process != nil {
+		if err := process.Stop(); err != nil {
+			return err
 		}
-
-		progress := float64(end) / float64(len(e.cameraFrames)) * 100
-		fmt.Printf("进度: %.1f%% (%d/%d 帧)\n", progress, end, len(e.cameraFrames))
-	}
-
-	// 合并所有段
-	fmt.Println("合并视频段...")
-	if err := e.concatenateSegments(ffmpegPath, segments); err != nil {
-		return fmt.Errorf("合并段失败: %w", err)
 	}
-
-	fmt.Printf("✓ 分段导出完成: %s\n", e.config.OutputPath)
 	return nil
 }
 
-// exportSegment 导出单个视频段
-func (e *GPUExporter) exportSegment(ffmpegPath, codec, preset string, startFrame, endFrame int, outputPath string) error {
-	// 计算时间范围
-	startTime := float64(e.cameraFrames[startFrame].Timestamp) / 1000.0
-	duration := float64(e.cameraFrames[endFrame-1].Timestamp-e.cameraFrames[startFrame].Timestamp) / 1000.0
-
-	// 计算此段的平均缩放和位置
-	avgZoom := 0.0
-	avgX := 0.0
-	avgY := 0.0
-	for i := startFrame; i < endFrame; i++ {
-		avgZoom += e.cameraFrames[i].Zoom
-		avgX += e.cameraFrames[i].X
-		avgY += e.cameraFrames[i].Y
-	}
-	count := float64(endFrame - startFrame)
-	avgZoom /= count
-	avgX /= count
-	avgY /= count
-
-	// 构建命令
-	args := []string{
-		"-ss", fmt.Sprintf("%.3f", startTime),
-		"-t", fmt.Sprintf("%.3f", duration),
-		"-i", e.config.VideoPath,
-	}
-
-	// 应用滤镜
-	cropW := int(float64(e.config.ScreenWidth) / avgZoom)
-	cropH := int(float64(e.config.ScreenHeight) / avgZoom)
-	cropX := int(avgX - float64(cropW)/2)
-	cropY := int(avgY - float64(cropH)/2)
-
-	// 限制裁剪区域
-	if cropX < 0 {
-		cropX = 0
-	}
-	if cropY < 0 {
-		cropY = 0
-	}
-	if cropX+cropW > e.config.ScreenWidth {
-		cropX = e.config.ScreenWidth - cropW
-	}
-	if cropY+cropH > e.config.ScreenHeight {
-		cropY = e.config.ScreenHeight - cropH
-	}
-
-	filterComplex := fmt.Sprintf("crop=%d:%d:%d:%d,scale=%d:%d",
-		cropW, cropH, cropX, cropY,
-		e.config.ScreenWidth, e.config.ScreenHeight)
-
-	args = append(args, "-filter_complex", filterComplex)
-
-	// 编码器设置
-	args = append(args, "-c:v", codec)
-	args = append(args, "-preset", preset)
-	if codec == "libx264" {
-		args = append(args, "-crf", "23")
-	}
-	args = append(args, "-r", fmt.Sprintf("%d", e.config.FPS))
-	args = append(args, "-pix_fmt", "yuv420p")
-	args = append(args, "-y")
-	args = append(args, outputPath)
-
-	// 执行
-	cmd := exec.Command(ffmpegPath, args...)
-	return cmd.Run()
-}
-
-// concatenateSegments 合并视频段
-func (e *GPUExporter) concatenateSegments(ffmpegPath string, segments []string) error {
-	// 创建合并列表文件
-	listPath := filepath.Join(filepath.Dir(segments[0]), "concat_list.txt")
-	listContent := ""
-	for _, seg := range segments {
-		listContent += fmt.Sprintf("file '%s'\n", filepath.Base(seg))
-	}
-
-	if err := os.WriteFile(listPath, []byte(listContent), 0644); err != nil {
-		return err
-	}
-
-	// 使用 concat demuxer 合并
-	args := []string{
-		"-f", "concat",
-		"-safe", "0",
-		"-i", listPath,
-		"-c", "copy", // 直接复制，不重新编码
-		"-y",
-		e.config.OutputPath,
-	}
-
-	cmd := exec.Command(ffmpegPath, args...)
-	return cmd.Run()
-}
+// GetProgress 获取导出进度（0-100）
+// 分段导出时汇总所有段已编码的帧数
+func (e *GPUExporter) GetProgress() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-// Stop 停止导出
-func (e *GPUExporter) Stop() error {
-	if !e.isExporting {
-		return nil
+	if e.totalFrames == 0 {
+		return 0.0
 	}
 
-	if e.cmd != nil && e.cmd.Process != nil {
-		if err := e.cmd.Process.Kill(); err != nil {
-			return err
-		}
+	done := 0
+	for _, frames := range e.segmentDone {
+		done += frames
 	}
-
-	e.isExporting = false
-	return nil
+	return float64(done) / float64(e.totalFrames) * 100
 }
 
-// GetProgress 获取导出进度（估算）
-func (e *GPUExporter) GetProgress() float64 {
-	// 这里需要解析 FFmpeg 输出来获取真实进度
-	// 简化处理，返回 0
-	return 0.0
+// GetLog 获取最近一次导出的 FFmpeg 输出末尾
+func (e *GPUExporter) GetLog() string {
+	return e.log.String()
 }
 
 // IsExporting 检查是否正在导出
 func (e *GPUExporter) IsExporting() bool {
-	return e.isExporting
+	return e.isExporting.Load()
 }
@@ -1,37 +1,35 @@
 package recorder
 
 import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"os/exec"
 	"sync"
-	"time"
 )
 
 // HttpPipeServer HTTP 管道服务器
 // 用于接收前端通过 HTTP POST 发送的 JPEG Blob 数据
 // 并直接写入 FFmpeg stdin，避免 Wails IPC 开销
 type HttpPipeServer struct {
-	ffmpegPath  string
-	ffmpegCmd   *exec.Cmd
-	ffmpegStdin io.WriteCloser
-	server      *http.Server
-	port        int
-	isRunning   bool
-	mu          sync.Mutex
+	ffmpegPath    string
+	ffmpegProcess *ffmpeg.Process
+	server        *http.Server
+	port          int
+	isRunning     bool
+	mu            sync.Mutex
 }
 
 // NewHttpPipeServer 创建 HTTP 管道服务器
 func NewHttpPipeServer() *HttpPipeServer {
 	return &HttpPipeServer{
-		ffmpegPath:  "",
-		ffmpegCmd:   nil,
-		ffmpegStdin: nil,
-		server:      nil,
-		port:        13000, // 默认端口 13000
-		isRunning:   false,
-		mu:          sync.Mutex{},
+		ffmpegPath:    "",
+		ffmpegProcess: nil,
+		server:        nil,
+		port:          13000, // 默认端口 13000
+		isRunning:     false,
+		mu:            sync.Mutex{},
 	}
 }
 
@@ -55,35 +53,33 @@ func (s *HttpPipeServer) Start(ffmpegPath string, outputPath string, frameRate i
 	}
 
 	// 构建 FFmpeg 命令（使用 JPEG 输入，HTTP 管道）
-	args := []string{
-		"-y",
-		"-f", "image2pipe",
-		"-vcodec", "mjpeg", // 必须明确告诉 ffmpeg 输入流是 mjpeg 格式
-		"-r", fmt.Sprintf("%d", frameRate),
-		"-i", "-", // 从 stdin 读取
-
-		// 编码参数
-		"-c:v", "libx264",
-		"-pix_fmt", "yuv420p",
-		"-preset", "ultrafast",
-		"-s", fmt.Sprintf("%dx%d", width, height),
-
-		outputPath,
-	}
-
-	// 创建 FFmpeg 命令
-	s.ffmpegCmd = exec.Command(s.ffmpegPath, args...)
-
-	// 获取 stdin 管道
-	stdin, err := s.ffmpegCmd.StdinPipe()
+	command := ffmpeg.NewCommand().Overwrite()
+	command.Input("-", // 从 stdin 读取
+		ffmpeg.Opt("vcodec", "mjpeg"), // 必须明确告诉 ffmpeg 输入流是 mjpeg 格式
+		ffmpeg.Opt("r", frameRate),
+	).Format("image2pipe")
+
+	// 编码参数
+	command.Output(outputPath).
+		VideoCodec("libx264").
+		With(
+			ffmpeg.Opt("pix_fmt", "yuv420p"),
+			ffmpeg.Opt("preset", "ultrafast"),
+			ffmpeg.Opt("s", fmt.Sprintf("%dx%d", width, height)),
+		)
+
+	args, err := command.Args()
 	if err != nil {
-		return fmt.Errorf("创建 stdin 管道失败: %w", err)
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
 	}
-	s.ffmpegStdin = stdin
 
-	// 启动 FFmpeg 进程
-	if err := s.ffmpegCmd.Start(); err != nil {
-		return fmt.Errorf("启动 FFmpeg 进程失败: %w", err)
+	// 创建并启动 FFmpeg 进程（帧数据通过 stdin 写入）
+	s.ffmpegProcess = ffmpeg.NewProcess(s.ffmpegPath, args, ffmpeg.ProcessOptions{
+		Name:      "HTTP 管道导出",
+		PipeStdin: true,
+	})
+	if err := s.ffmpegProcess.Start(context.Background()); err != nil {
+		return err
 	}
 
 	// 启动 HTTP 服务器
@@ -92,7 +88,6 @@ func (s *HttpPipeServer) Start(ffmpegPath string, outputPath string, frameRate i
 	s.isRunning = true
 
 	fmt.Printf("HTTP 管道服务器已启动: 端口 %d\n", s.port)
-	fmt.Printf("FFmpeg 命令: %s %s\n", s.ffmpegPath, args)
 	return nil
 }
 
@@ -144,7 +139,7 @@ func (s *HttpPipeServer) handleFrameUpload(w http.ResponseWriter, r *http.Reques
 	}
 
 	// 直接将请求体复制到 FFmpeg stdin（零拷贝优化）
-	written, err := io.Copy(s.ffmpegStdin, r.Body)
+	written, err := io.Copy(s.ffmpegProcess, r.Body)
 	if err != nil {
 		fmt.Printf("写入 FFmpeg stdin 失败: %v\n", err)
 		http.Error(w, "Failed to write to FFmpeg", http.StatusInternalServerError)
@@ -173,33 +168,10 @@ func (s *HttpPipeServer) Stop() error {
 		}
 	}
 
-	// 关闭 FFmpeg stdin
-	if s.ffmpegStdin != nil {
-		if err := s.ffmpegStdin.Close(); err != nil {
-			fmt.Printf("关闭 FFmpeg stdin 失败: %v\n", err)
-		}
-	}
-
-	// 等待 FFmpeg 进程完成
-	if s.ffmpegCmd != nil && s.ffmpegCmd.Process != nil {
-		// 先尝试优雅等待 5 秒
-		done := make(chan error, 1)
-		go func() {
-			done <- s.ffmpegCmd.Wait()
-		}()
-
-		select {
-		case err := <-done:
-			if err != nil {
-				fmt.Printf("FFmpeg 进程退出: %v\n", err)
-			}
-		case <-time.After(5 * time.Second):
-			// 超时后强制终止
-			if err := s.ffmpegCmd.Process.Kill(); err != nil {
-				fmt.Printf("强制终止 FFmpeg 进程失败: %v\n", err)
-			} else {
-				fmt.Println("FFmpeg 进程已强制终止")
-			}
+	// 关闭 FFmpeg stdin 并等待进程完成（超时后发送信号或强制终止）
+	if s.ffmpegProcess != nil {
+		if err := s.ffmpegProcess.Stop(); err != nil {
+			fmt.Printf("停止 FFmpeg 进程失败: %v\n", err)
 		}
 	}
 
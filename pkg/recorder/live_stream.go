@@ -0,0 +1,450 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 推流状态
+const (
+	StreamStateConnecting   = "connecting"   // 推流进程已启动，尚未输出
+	StreamStateLive         = "live"         // 正在推流
+	StreamStateReconnecting = "reconnecting" // 推流中断，等待重连
+	StreamStateStopped      = "stopped"      // 已停止
+)
+
+const (
+	streamBackoffMin   = 1 * time.Second  // 首次重连等待
+	streamBackoffMax   = 30 * time.Second // 重连等待上限
+	streamStableAfter  = 30 * time.Second // 推流持续这么久后重置退避
+	streamRelayTimeout = 5 * time.Second  // 中继无数据（或推流进程不读取）超过此时间视为中断
+)
+
+// StreamOutputConfig 推流输出配置，每个输出单独编码
+type StreamOutputConfig struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`             // rtmp://、rtmps:// 或 srt://
+	Codec           string `json:"codec"`           // 编码器（默认 libx264）
+	Bitrate         int    `json:"bitrate"`         // 视频码率（kbit/s，默认 4500）
+	Preset          string `json:"preset"`          // 编码预设（默认 veryfast）
+	FrameRate       int    `json:"frameRate"`       // 推流帧率（0 使用捕获帧率）
+	Width           int    `json:"width"`           // 推流分辨率（0 保持捕获分辨率）
+	Height          int    `json:"height"`          //
+	KeyframeSeconds int    `json:"keyframeSeconds"` // 关键帧间隔（秒，默认 2）
+}
+
+// StreamHealth 推流健康状态
+type StreamHealth struct {
+	Name       string    `json:"name"`
+	URL        string    `json:"url"` // 隐去推流密钥
+	State      string    `json:"state"`
+	Bitrate    float64   `json:"bitrate"` // kbit/s
+	FPS        float64   `json:"fps"`
+	Frames     int       `json:"frames"`
+	Reconnects int       `json:"reconnects"` // 重连次数
+	LastError  string    `json:"lastError,omitempty"`
+	Since      time.Time `json:"since"` // 进入当前状态的时间
+}
+
+// streamRelay 捕获进程写入的本地中继输出
+type streamRelay struct {
+	config StreamOutputConfig
+	url    string
+}
+
+// streamFormat 根据推流地址选择封装格式
+func streamFormat(streamURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(streamURL, "rtmp://"), strings.HasPrefix(streamURL, "rtmps://"):
+		return "flv", nil
+	case strings.HasPrefix(streamURL, "srt://"):
+		return "mpegts", nil
+	default:
+		return "", fmt.Errorf("不支持的推流地址: %s（支持 rtmp://、rtmps://、srt://）", redactStreamURL(streamURL))
+	}
+}
+
+// redactStreamURL 隐去推流密钥（RTMP 路径最后一段、SRT 的查询参数）
+func redactStreamURL(streamURL string) string {
+	parsed, err := url.Parse(streamURL)
+	if err != nil {
+		return "***"
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = "***"
+	}
+	if parsed.User != nil {
+		parsed.User = url.User("***")
+	}
+	if segments := strings.Split(strings.Trim(parsed.Path, "/"), "/"); len(segments) > 1 {
+		segments[len(segments)-1] = "***"
+		parsed.Path = "/" + strings.Join(segments, "/")
+	}
+	return strings.Replace(parsed.String(), "%2A%2A%2A", "***", -1)
+}
+
+// normalizeStreamOutput 补全推流默认值并校验地址
+func normalizeStreamOutput(config StreamOutputConfig) (StreamOutputConfig, error) {
+	if _, err := streamFormat(config.URL); err != nil {
+		return config, err
+	}
+	if config.Name == "" {
+		config.Name = redactStreamURL(config.URL)
+	}
+	if config.Codec == "" {
+		config.Codec = "libx264"
+	}
+	if config.Bitrate <= 0 {
+		config.Bitrate = 4500
+	}
+	if config.Preset == "" && config.Codec == "libx264" {
+		config.Preset = "veryfast"
+	}
+	if config.KeyframeSeconds <= 0 {
+		config.KeyframeSeconds = 2
+	}
+	return config, nil
+}
+
+// streamEncoderOptions 推流输出的编码参数（恒定码率，固定关键帧间隔）
+func streamEncoderOptions(config StreamOutputConfig, captureFrameRate int) []ffmpeg.Option {
+	frameRate := config.FrameRate
+	if frameRate <= 0 {
+		frameRate = captureFrameRate
+	}
+
+	options := []ffmpeg.Option{ffmpeg.Opt("c:v", config.Codec)}
+	if config.Preset != "" {
+		options = append(options, ffmpeg.Opt("preset", config.Preset))
+	}
+	if config.Codec == "libx264" {
+		options = append(options, ffmpeg.Opt("tune", "zerolatency"))
+	}
+	options = append(options,
+		ffmpeg.Opt("b:v", fmt.Sprintf("%dk", config.Bitrate)),
+		ffmpeg.Opt("maxrate", fmt.Sprintf("%dk", config.Bitrate)),
+		ffmpeg.Opt("bufsize", fmt.Sprintf("%dk", config.Bitrate*2)),
+		ffmpeg.Opt("r", frameRate),
+		ffmpeg.Opt("g", frameRate*config.KeyframeSeconds),
+		ffmpeg.Opt("pix_fmt", "yuv420p"),
+	)
+	if config.Width > 0 && config.Height > 0 {
+		options = append(options, ffmpeg.Opt("s", fmt.Sprintf("%dx%d", config.Width, config.Height)))
+	}
+	return options
+}
+
+// LiveStream 一路推流
+// 捕获进程把编码好的流以 MPEG-TS 发送到本地 UDP 中继，推流进程通过本地 TCP 连接读取并原样转发到服务器。
+// 中继的两个端口由本进程绑定并在推流期间一直保持，推流进程重连时不需要重新绑定，端口不会被其他程序抢占。
+// 推流进程退出（网络中断、服务器重启）时按指数退避重连，捕获进程和本地录制不受影响；
+// 推流进程未连接时中继数据直接丢弃。
+type LiveStream struct {
+	ffmpegManager *ffmpeg.FFmpegManager
+	config        StreamOutputConfig
+	relay         streamRelay
+	relayConn     net.PacketConn // 接收捕获进程发送的数据
+	feed          net.Listener   // 推流进程连接到这里读取数据
+	workers       sync.WaitGroup // 中继转发协程
+	closeOnce     sync.Once
+
+	mu         sync.Mutex
+	feedConn   net.Conn // 当前推流进程的连接
+	closed     bool     // 中继端口已释放
+	process    *ffmpeg.Process
+	state      string
+	since      time.Time
+	progress   ffmpeg.Progress
+	reconnects int
+	lastError  string
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewLiveStream 创建推流并绑定本地中继端口
+func NewLiveStream(ffmpegManager *ffmpeg.FFmpegManager, config StreamOutputConfig) (*LiveStream, error) {
+	config, err := normalizeStreamOutput(config)
+	if err != nil {
+		return nil, err
+	}
+	relayConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("绑定推流中继端口失败: %w", err)
+	}
+	feed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		relayConn.Close()
+		return nil, fmt.Errorf("绑定推流中继端口失败: %w", err)
+	}
+
+	return &LiveStream{
+		ffmpegManager: ffmpegManager,
+		config:        config,
+		relayConn:     relayConn,
+		feed:          feed,
+		relay: streamRelay{
+			config: config,
+			url:    fmt.Sprintf("udp://%s?pkt_size=1316", relayConn.LocalAddr()),
+		},
+		state: StreamStateStopped,
+	}, nil
+}
+
+// Start 启动推流（在后台运行并自动重连）
+func (s *LiveStream) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.workers.Add(2)
+	go s.acceptFeed()
+	go s.forward()
+	go s.supervise(s.stop, s.done)
+}
+
+// Stop 停止推流并释放中继端口
+func (s *LiveStream) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	s.closeOnce.Do(func() {
+		s.relayConn.Close()
+		s.feed.Close()
+		s.mu.Lock()
+		s.closed = true
+		if s.feedConn != nil {
+			s.feedConn.Close()
+			s.feedConn = nil
+		}
+		s.mu.Unlock()
+	})
+	s.workers.Wait()
+}
+
+// acceptFeed 接受推流进程的连接，新的连接替换旧的连接
+func (s *LiveStream) acceptFeed() {
+	defer s.workers.Done()
+	for {
+		conn, err := s.feed.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.feedConn != nil {
+			s.feedConn.Close()
+		}
+		s.feedConn = conn
+		if s.closed {
+			conn.Close()
+			s.feedConn = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// forward 把中继收到的数据转发给推流进程，推流进程未连接时丢弃
+func (s *LiveStream) forward() {
+	defer s.workers.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := s.relayConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		conn := s.feedConn
+		s.mu.Unlock()
+		if conn == nil {
+			continue
+		}
+
+		// 推流进程卡住时不能阻塞中继，超时后断开，推流进程读取超时后退出重连
+		conn.SetWriteDeadline(time.Now().Add(streamRelayTimeout))
+		if _, err := conn.Write(buf[:n]); err != nil {
+			conn.Close()
+			s.mu.Lock()
+			if s.feedConn == conn {
+				s.feedConn = nil
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// supervise 运行推流进程，退出后按退避时间重连，直到 stop 关闭
+func (s *LiveStream) supervise(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	defer s.setState(StreamStateStopped)
+
+	backoff := streamBackoffMin
+	for {
+		startedAt := time.Now()
+		err := s.runOnce(stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// 推流稳定运行一段时间后中断，从最短等待重新开始
+		if time.Since(startedAt) >= streamStableAfter {
+			backoff = streamBackoffMin
+		}
+
+		s.mu.Lock()
+		s.reconnects++
+		if err != nil {
+			s.lastError = err.Error()
+		}
+		s.mu.Unlock()
+		s.setState(StreamStateReconnecting)
+		fmt.Printf("✗ 推流中断 (%s)，%v 后重连: %v\n", s.config.Name, backoff, err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > streamBackoffMax {
+			backoff = streamBackoffMax
+		}
+	}
+}
+
+// runOnce 运行一次推流进程直到退出或停止
+func (s *LiveStream) runOnce(stop <-chan struct{}) error {
+	format, _ := streamFormat(s.config.URL)
+
+	command := ffmpeg.NewCommand()
+	input := command.Input(
+		fmt.Sprintf("tcp://%s?timeout=%d", s.feed.Addr(), streamRelayTimeout.Microseconds()),
+	).Format("mpegts")
+	output := command.Output(s.config.URL).Map(input.Video()).Codec("copy").Format(format)
+	if format == "flv" {
+		output.With(ffmpeg.Opt("flvflags", "no_duration_filesize"))
+	}
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+
+	process, err := s.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{
+		Name: "推流 " + s.config.Name,
+		OnProgress: func(progress ffmpeg.Progress) {
+			s.mu.Lock()
+			s.progress = progress
+			s.mu.Unlock()
+			if progress.Frame > 0 {
+				s.setState(StreamStateLive)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s.setState(StreamStateConnecting)
+	if err := process.Start(context.Background()); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.process = process
+	s.mu.Unlock()
+
+	select {
+	case <-stop:
+		process.Stop()
+		process.Wait()
+	case <-process.Done():
+	}
+
+	s.mu.Lock()
+	s.progress = ffmpeg.Progress{}
+	s.process = nil
+	s.mu.Unlock()
+
+	if err := process.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("推流进程已退出")
+}
+
+// setState 更新推流状态
+func (s *LiveStream) setState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == state {
+		return
+	}
+	s.state = state
+	s.since = time.Now()
+	if state == StreamStateLive {
+		fmt.Printf("✓ 推流已连接: %s\n", s.config.Name)
+	}
+}
+
+// Health 获取推流健康状态
+func (s *LiveStream) Health() StreamHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return StreamHealth{
+		Name:       s.config.Name,
+		URL:        redactStreamURL(s.config.URL),
+		State:      s.state,
+		Bitrate:    s.progress.Bitrate,
+		FPS:        s.progress.FPS,
+		Frames:     s.progress.Frame,
+		Reconnects: s.reconnects,
+		LastError:  s.lastError,
+		Since:      s.since,
+	}
+}
+
+// StartStreamListener 启动本地 FFmpeg 接收端，把收到的流保存到 outputPath
+// 用于在没有推流服务器时测试推流：rtmp://127.0.0.1:1935/live/test 或 srt://127.0.0.1:9000
+func StartStreamListener(ffmpegManager *ffmpeg.FFmpegManager, listenURL, outputPath string) (*ffmpeg.Process, error) {
+	format, err := streamFormat(listenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	command := ffmpeg.NewCommand().Overwrite()
+	input := command.Input(listenURL)
+	if format == "flv" {
+		input.With(ffmpeg.Opt("listen", 1))
+	} else {
+		input.With(ffmpeg.Opt("mode", "listener"))
+	}
+	input.Format(format)
+	command.Output(outputPath).Map(input.Video()).Codec("copy")
+	args, err := command.Args()
+	if err != nil {
+		return nil, err
+	}
+
+	process, err := ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: "推流接收端"})
+	if err != nil {
+		return nil, err
+	}
+	if err := process.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	return process, nil
+}
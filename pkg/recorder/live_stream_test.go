@@ -0,0 +1,292 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeFFmpegEnv 设置后测试二进制以假 FFmpeg 运行（见 TestMain）
+const fakeFFmpegEnv = "SMOOTHSCREEN_FAKE_FFMPEG"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeFFmpegEnv) == "1" {
+		os.Exit(runFakeFFmpeg(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeFFmpeg 假的推流进程：从 -i 的 tcp:// 中继读取数据，写到最后一个参数的推流地址（TCP）
+// 推流服务器断开时像 FFmpeg 一样以错误退出；从 stdin 收到 q 或 stdin 关闭时正常退出。
+// 只用于模拟服务器掉线和重连，真实 FFmpeg 的端到端推流见 TestLiveStreamToListener
+func runFakeFFmpeg(args []string) int {
+	var input string
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-i" {
+			input = args[i+1]
+		}
+	}
+	inputURL, _ := url.Parse(input)
+	outputURL, _ := url.Parse(args[len(args)-1])
+
+	relay, err := net.Dial("tcp", inputURL.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", input, err)
+		return 1
+	}
+	server, err := net.Dial("tcp", outputURL.Host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", outputURL.Host, err)
+		return 1
+	}
+	defer server.Close()
+
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			if b, err := reader.ReadByte(); err != nil || b == 'q' {
+				relay.Close()
+				return
+			}
+		}
+	}()
+
+	chunk := make([]byte, 1316)
+	for frame := 1; ; frame++ {
+		n, err := relay.Read(chunk)
+		if err != nil {
+			return 0
+		}
+		if _, err := server.Write(chunk[:n]); err != nil {
+			fmt.Fprintf(os.Stderr, "写入输出失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("frame=%d\nprogress=continue\n", frame)
+	}
+}
+
+// newFakeFFmpegManager 返回以测试二进制作为 FFmpeg 的管理器
+func newFakeFFmpegManager(t *testing.T) *ffmpeg.FFmpegManager {
+	t.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不读取用户的 FFmpeg 设置
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(fakeFFmpegEnv, "1")
+	t.Setenv(ffmpeg.EnvFFmpegPath, self)
+	return ffmpeg.NewFFmpegManager(context.Background())
+}
+
+// testStreamServer 本地 TCP 推流接收端
+type testStreamServer struct {
+	listener net.Listener
+	accepted atomic.Int32
+	received atomic.Int64
+}
+
+// newTestStreamServer 启动接收端；drop 为 true 时接受连接后立即断开（模拟服务器掉线）
+func newTestStreamServer(t *testing.T, drop bool) *testStreamServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testStreamServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.accepted.Add(1)
+			if drop {
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					server.received.Add(int64(n))
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return server
+}
+
+// url 推流地址
+func (s *testStreamServer) url(name string) string {
+	return fmt.Sprintf("rtmp://%s/live/%s", s.listener.Addr(), name)
+}
+
+// waitFor 轮询直到条件满足或超时
+func waitFor(t *testing.T, timeout time.Duration, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestLiveStreamFanOutSurvivesDroppedOutput(t *testing.T) {
+	manager := newFakeFFmpegManager(t)
+	healthy := newTestStreamServer(t, false)
+	dropping := newTestStreamServer(t, true)
+
+	streamA, err := NewLiveStream(manager, StreamOutputConfig{Name: "A", URL: healthy.url("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamB, err := NewLiveStream(manager, StreamOutputConfig{Name: "B", URL: dropping.url("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamA.Start()
+	defer streamA.Stop()
+	streamB.Start()
+	defer streamB.Stop()
+
+	// 代替捕获进程向两路中继发送数据：推流断开时中继照常接收，发送方不受影响
+	stop := make(chan struct{})
+	sendErr := make(chan error, 1)
+	go func() {
+		defer close(sendErr)
+		var conns []net.Conn
+		for _, stream := range []*LiveStream{streamA, streamB} {
+			conn, err := net.Dial("udp", stream.relayConn.LocalAddr().String())
+			if err != nil {
+				sendErr <- err
+				return
+			}
+			defer conn.Close()
+			conns = append(conns, conn)
+		}
+		packet := make([]byte, 1316)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			for _, conn := range conns {
+				if _, err := conn.Write(packet); err != nil {
+					sendErr <- err
+					return
+				}
+			}
+		}
+	}()
+
+	waitFor(t, 10*time.Second, "推流 A 收到数据", func() bool { return healthy.received.Load() > 0 })
+	waitFor(t, 10*time.Second, "推流 B 断开后重连", func() bool { return dropping.accepted.Load() >= 2 })
+
+	health := streamB.Health()
+	if health.Reconnects == 0 || health.LastError == "" {
+		t.Errorf("推流 B 应记录中断: %+v", health)
+	}
+	if health := streamA.Health(); health.State != StreamStateLive || health.Reconnects != 0 {
+		t.Errorf("推流 A 不应受推流 B 影响: %+v", health)
+	}
+
+	close(stop)
+	if err := <-sendErr; err != nil {
+		t.Errorf("向中继发送失败: %v", err)
+	}
+
+	// 停止后释放中继端口
+	streamB.Stop()
+	if conn, err := net.ListenPacket("udp", streamB.relayConn.LocalAddr().String()); err != nil {
+		t.Errorf("停止后中继端口仍被占用: %v", err)
+	} else {
+		conn.Close()
+	}
+}
+
+func TestLiveStreamToListener(t *testing.T) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("没有安装 FFmpeg")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(ffmpeg.EnvFFmpegPath, ffmpegPath)
+	manager := ffmpeg.NewFFmpegManager(context.Background())
+
+	// RTMP 接收端需要固定端口
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenURL := fmt.Sprintf("rtmp://%s/live/test", probe.Addr())
+	probe.Close()
+
+	dir := t.TempDir()
+	received := filepath.Join(dir, "received.flv")
+	listener, err := StartStreamListener(manager, listenURL, received)
+	if err != nil {
+		t.Fatalf("StartStreamListener: %v", err)
+	}
+	defer listener.Stop()
+
+	stream, err := NewLiveStream(manager, StreamOutputConfig{Name: "本地", URL: listenURL, Preset: "ultrafast", FrameRate: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Start()
+	defer stream.Stop()
+
+	cmd := ffmpeg.NewCommand().Overwrite()
+	cmd.Input("testsrc=size=320x240:rate=15").Format("lavfi").With(ffmpeg.Flag("re"))
+	addCaptureOutput(cmd, CaptureConfig{
+		OutputPath: filepath.Join(dir, "recording.mp4"),
+		FrameRate:  15,
+		Codec:      "libx264",
+		Preset:     "ultrafast",
+		Quality:    30,
+		streams:    []streamRelay{stream.relay},
+	})
+	args, err := cmd.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture := NewFFmpegCapture(manager)
+	if err := capture.Start(args); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, 20*time.Second, "推流连接", func() bool {
+		health := stream.Health()
+		return health.State == StreamStateLive && health.Frames > 0
+	})
+	if err := capture.Stop(); err != nil {
+		t.Fatalf("停止捕获失败: %v", err)
+	}
+	stream.Stop()
+	listener.Wait()
+
+	if info, err := os.Stat(received); err != nil || info.Size() == 0 {
+		t.Errorf("接收端没有写出数据: %v", err)
+	}
+}
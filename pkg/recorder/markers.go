@@ -0,0 +1,354 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// chapterIntroTitle 第一个标记不在开头时补充的章节标题（YouTube 要求章节从 0:00 开始）
+const chapterIntroTitle = "开始"
+
+// Marker 录制时添加的章节标记
+type Marker struct {
+	ID        string    `json:"id"`
+	Time      float64   `json:"time"` // 视频时间（秒，与鼠标数据同一时间轴，已扣除暂停）
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TimeRange 时间范围（秒）
+type TimeRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Chapter 导出视频中的章节（导出时间轴，已按剪辑调整）
+type Chapter struct {
+	Start float64 `json:"start"` // 秒
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+}
+
+// AddMarker 在当前录制时间添加标记，label 为空时按序号命名
+// 暂停中添加的标记位于暂停处（即恢复后的第一帧）
+// 快捷键和界面可能同时添加，标记在 mu 下追加并按顺序写入会话
+func (r *Recorder) AddMarker(label string) (Marker, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isRecording {
+		return Marker{}, ErrNotRecording
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(r.startTime) - pausedDuration(r.pauses, now)
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = fmt.Sprintf("章节 %d", len(r.markers)+1)
+	}
+	marker := Marker{
+		ID:        fmt.Sprintf("marker-%d", len(r.markers)+1),
+		Time:      math.Max(elapsed.Seconds(), 0),
+		Label:     label,
+		CreatedAt: now,
+	}
+	r.markers = append(r.markers, marker)
+
+	// 立即写入会话，录制异常中断时标记也不会丢失
+	if err := SaveMarkers(r.outputPath, r.markers); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+	fmt.Printf("✓ 已添加标记 %s @ %s\n", marker.Label, formatChapterTime(marker.Time))
+	return marker, nil
+}
+
+// GetMarkers 获取当前（或最近一次）录制的标记
+func (r *Recorder) GetMarkers() []Marker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Marker(nil), r.markers...)
+}
+
+// SaveMarkers 把视频的标记写入所属的会话（按时间排序，同一目录的其他录制不受影响）
+func SaveMarkers(videoPath string, markers []Marker) error {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return fmt.Errorf("保存标记失败: %w", err)
+	}
+
+	sorted := append([]Marker(nil), markers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.Markers[videoPath] = sorted
+	if err := session.saveLocked(); err != nil {
+		return fmt.Errorf("保存标记失败: %w", err)
+	}
+	return nil
+}
+
+// LoadMarkers 读取会话中保存的视频标记（没有标记时返回空列表）
+func LoadMarkers(videoPath string) ([]Marker, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return nil, err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return append([]Marker{}, session.Markers[videoPath]...), nil
+}
+
+// normalizeTrims 排序并合并重叠的剪辑范围，丢弃无效范围
+func normalizeTrims(trims []TimeRange) []TimeRange {
+	sorted := make([]TimeRange, 0, len(trims))
+	for _, trim := range trims {
+		trim.Start = math.Max(trim.Start, 0)
+		if trim.End > trim.Start {
+			sorted = append(sorted, trim)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	merged := make([]TimeRange, 0, len(sorted))
+	for _, trim := range sorted {
+		if last := len(merged) - 1; last >= 0 && trim.Start <= merged[last].End {
+			merged[last].End = math.Max(merged[last].End, trim.End)
+			continue
+		}
+		merged = append(merged, trim)
+	}
+	return merged
+}
+
+// trimmedTime 把源视频时间换算为剪辑后的时间
+// 位于被剪掉范围内的时间移到该范围之后的第一帧
+func trimmedTime(t float64, trims []TimeRange) float64 {
+	removed := 0.0
+	for _, trim := range trims {
+		if t <= trim.Start {
+			break
+		}
+		removed += math.Min(t, trim.End) - trim.Start
+	}
+	return t - removed
+}
+
+// trimmedDuration 剪辑后的时长
+func trimmedDuration(duration float64, trims []TimeRange) float64 {
+	return math.Max(trimmedTime(duration, normalizeTrims(trims)), 0)
+}
+
+// BuildChapters 由标记生成导出视频的章节
+// trims 为从源视频中剪掉的范围，duration 为剪辑后的时长（0 表示未知，最后一章没有结束时间，写入视频时再补上）
+// 同一时刻的多个标记只保留第一个；第一个标记不在开头时补充一个从 0 开始的章节
+func BuildChapters(markers []Marker, trims []TimeRange, duration float64) []Chapter {
+	trims = normalizeTrims(trims)
+	sorted := append([]Marker(nil), markers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	var chapters []Chapter
+	for _, marker := range sorted {
+		start := math.Round(trimmedTime(marker.Time, trims)*1000) / 1000
+		if duration > 0 && start >= duration {
+			continue
+		}
+		if n := len(chapters); n > 0 && start-chapters[n-1].Start < 0.001 {
+			continue
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: marker.Label})
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	if chapters[0].Start > 0 {
+		chapters = append([]Chapter{{Start: 0, Title: chapterIntroTitle}}, chapters...)
+	}
+
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		} else {
+			chapters[i].End = duration
+		}
+	}
+	return chapters
+}
+
+// ChapterText 生成 YouTube 风格的章节列表（每行 "0:00 标题"）
+func ChapterText(chapters []Chapter) string {
+	var text strings.Builder
+	for _, chapter := range chapters {
+		fmt.Fprintf(&text, "%s %s\n", formatChapterTime(chapter.Start), chapter.Title)
+	}
+	return text.String()
+}
+
+// formatChapterTime 格式化章节时间（m:ss，超过一小时为 h:mm:ss）
+func formatChapterTime(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// chapterMetadata 生成 FFmpeg 元数据文件（ffmetadata）
+func chapterMetadata(chapters []Chapter) string {
+	var metadata strings.Builder
+	metadata.WriteString(";FFMETADATA1\n")
+	for _, chapter := range chapters {
+		metadata.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&metadata, "START=%d\n", int64(math.Round(chapter.Start*1000)))
+		fmt.Fprintf(&metadata, "END=%d\n", int64(math.Round(chapter.End*1000)))
+		fmt.Fprintf(&metadata, "title=%s\n", escapeMetadata(chapter.Title))
+	}
+	return metadata.String()
+}
+
+// escapeMetadata 转义 ffmetadata 中的特殊字符（= ; # \ 和换行）
+func escapeMetadata(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune("=;#\\\n", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// supportsChapters 检查容器是否支持章节
+func supportsChapters(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov", ".mkv":
+		return true
+	}
+	return false
+}
+
+// WriteChapters 把章节写入导出视频（流直接复制，只替换章节）
+func WriteChapters(ctx context.Context, ffmpegManager *ffmpeg.FFmpegManager, chapters []Chapter, outputPath string, log io.Writer) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+	if !supportsChapters(outputPath) {
+		fmt.Printf("警告: %s 的容器不支持章节，跳过写入\n", filepath.Base(outputPath))
+		return nil
+	}
+
+	// ffprobe 不可用时最后一章没有结束时间，按导出视频的实际时长补上
+	if last := chapters[len(chapters)-1]; last.End <= last.Start {
+		duration, err := exportDuration(ctx, ffmpegManager, outputPath)
+		if err != nil {
+			return fmt.Errorf("读取导出时长失败: %w", err)
+		}
+		if chapters = closeChapters(chapters, duration); len(chapters) == 0 {
+			return nil
+		}
+	}
+
+	metadataPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".chapters.txt"
+	if err := os.WriteFile(metadataPath, []byte(chapterMetadata(chapters)), 0644); err != nil {
+		return fmt.Errorf("写入章节元数据失败: %w", err)
+	}
+	defer os.Remove(metadataPath)
+
+	err := replaceExport(outputPath, "写入章节", func(sourcePath string) error {
+		command := ffmpeg.NewCommand().Overwrite()
+		source := command.Input(sourcePath)
+		metadata := command.Input(metadataPath).Format("ffmetadata")
+		command.Output(outputPath).
+			Map(source.Video(), source.Stream("a?")).
+			Codec("copy").
+			With(ffmpeg.Opt("map_chapters", metadata.Index()))
+		return runFFmpegCommand(ctx, ffmpegManager, command, "写入章节", log)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ 已写入 %d 个章节\n", len(chapters))
+	return nil
+}
+
+// closeChapters 以 duration 作为最后一章的结束时间，去掉从 duration 之后开始的章节
+func closeChapters(chapters []Chapter, duration float64) []Chapter {
+	n := len(chapters)
+	for n > 0 && chapters[n-1].Start >= duration {
+		n--
+	}
+	closed := append([]Chapter(nil), chapters[:n]...)
+	if n > 0 {
+		closed[n-1].End = duration
+	}
+	return closed
+}
+
+// exportDuration 用 FFmpeg 读一遍导出视频得到时长（视频流直接复制，不解码；不依赖 ffprobe）
+func exportDuration(ctx context.Context, ffmpegManager *ffmpeg.FFmpegManager, path string) (float64, error) {
+	command := ffmpeg.NewCommand().Global(ffmpeg.Opt("progress", "pipe:1"), ffmpeg.Flag("nostats"))
+	source := command.Input(path)
+	command.Output("-").Map(source.Video()).Codec("copy").Format("null")
+	args, err := command.Args()
+	if err != nil {
+		return 0, err
+	}
+	process, err := ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: "读取导出时长", Quiet: true})
+	if err != nil {
+		return 0, err
+	}
+	if err := process.Run(ctx); err != nil {
+		return 0, err
+	}
+	return process.Progress().OutTime.Seconds(), nil
+}
+
+// trimStage 从导出视频中剪掉指定的时间范围（trims 已经过 normalizeTrims）
+// 作为后处理的最后一个阶段，摄像头和字幕的时间仍按剪辑前计算；没有音频时只处理画面
+func trimStage(trims []TimeRange, hasAudio bool) postProcessStage {
+	ranges := make([]string, len(trims))
+	for i, trim := range trims {
+		ranges[i] = fmt.Sprintf("between(t,%.3f,%.3f)", trim.Start, trim.End)
+	}
+	keep := fmt.Sprintf("not(%s)", strings.Join(ranges, "+"))
+
+	stage := postProcessStage{
+		name: "剪辑导出",
+		video: func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error) {
+			return command.FilterGraph().Chain([]ffmpeg.Pad{video},
+				ffmpeg.NewFilter("select", keep),
+				ffmpeg.NewFilter("setpts", "N/FRAME_RATE/TB"),
+			), nil
+		},
+	}
+	if hasAudio {
+		stage.audio = func(command *ffmpeg.Command, audio ffmpeg.Pad) ffmpeg.Pad {
+			return command.FilterGraph().Chain([]ffmpeg.Pad{audio},
+				ffmpeg.NewFilter("aselect", keep),
+				ffmpeg.NewFilter("asetpts", "N/SR/TB"),
+			)
+		}
+	}
+	return stage
+}
+
+// ExportChapters 按导出配置生成章节（标记来自会话，时间按剪辑调整）
+func ExportChapters(ffmpegManager *ffmpeg.FFmpegManager, config ExportConfig) ([]Chapter, error) {
+	markers, err := LoadMarkers(config.VideoPath)
+	if err != nil || len(markers) == 0 {
+		return nil, err
+	}
+	duration := 0.0
+	if report, err := ffmpegManager.ProbeOutput(config.VideoPath, ffmpeg.ProbeExpectation{SkipFrameCheck: true}); err == nil {
+		duration = trimmedDuration(report.Duration, config.Trims)
+	}
+	return BuildChapters(markers, config.Trims, duration), nil
+}
@@ -0,0 +1,88 @@
+package recorder
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddMarkerConcurrent(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "recording.mp4")
+	r := &Recorder{isRecording: true, outputPath: videoPath, startTime: time.Now()}
+
+	// 快捷键和界面同时添加、读取标记
+	const writers, perWriter = 4, 10
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWriter {
+				if _, err := r.AddMarker(""); err != nil {
+					t.Error(err)
+				}
+				r.GetMarkers()
+			}
+		}()
+	}
+	wg.Wait()
+
+	markers := r.GetMarkers()
+	if len(markers) != writers*perWriter {
+		t.Fatalf("标记数量 = %d, want %d", len(markers), writers*perWriter)
+	}
+	ids := make(map[string]bool)
+	for _, marker := range markers {
+		ids[marker.ID] = true
+	}
+	if len(ids) != len(markers) {
+		t.Errorf("标记 ID 重复: %d 个标记只有 %d 个 ID", len(markers), len(ids))
+	}
+	saved, err := LoadMarkers(videoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != len(markers) {
+		t.Errorf("会话中的标记数量 = %d, want %d", len(saved), len(markers))
+	}
+
+	r.isRecording = false
+	if _, err := r.AddMarker(""); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("未录制时 AddMarker 错误 = %v, want ErrNotRecording", err)
+	}
+}
+
+func TestChaptersUnknownDuration(t *testing.T) {
+	markers := []Marker{{Time: 5, Label: "安装"}, {Time: 20, Label: "配置"}, {Time: 40, Label: "总结"}}
+
+	// 时长未知（没有 ffprobe）时最后一章没有结束时间
+	chapters := BuildChapters(markers, nil, 0)
+	if len(chapters) != 4 || chapters[3].Title != "总结" || chapters[3].End != 0 {
+		t.Fatalf("BuildChapters = %+v", chapters)
+	}
+
+	// 按导出视频的时长补上结束时间，不修改原章节
+	closed := closeChapters(chapters, 45.5)
+	want := ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=5000\ntitle=开始\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=5000\nEND=20000\ntitle=安装\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=20000\nEND=40000\ntitle=配置\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=40000\nEND=45500\ntitle=总结\n"
+	if got := chapterMetadata(closed); got != want {
+		t.Errorf("chapterMetadata =\n%s\nwant\n%s", got, want)
+	}
+	if chapters[3].End != 0 {
+		t.Errorf("原章节被修改: %+v", chapters[3])
+	}
+
+	// 导出视频比标记短时去掉之后开始的章节
+	closed = closeChapters(chapters, 30)
+	if len(closed) != 3 || closed[2].Title != "配置" || closed[2].End != 30 {
+		t.Errorf("closeChapters(30) = %+v", closed)
+	}
+	if closed := closeChapters(chapters, 0); len(closed) != 0 {
+		t.Errorf("closeChapters(0) = %+v", closed)
+	}
+}
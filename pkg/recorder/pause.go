@@ -0,0 +1,214 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/clock"
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PauseInterval 暂停区间（视频中不包含这段时间）
+type PauseInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end,omitzero"` // 暂停中为零值
+}
+
+// pausedDuration 计算暂停的总时长（未结束的区间计算到 now）
+func pausedDuration(pauses []PauseInterval, now time.Time) time.Duration {
+	var total time.Duration
+	for _, pause := range pauses {
+		end := pause.End
+		if end.IsZero() {
+			end = now
+		}
+		total += end.Sub(pause.Start)
+	}
+	return total
+}
+
+// partPath 返回暂停前录制的第 index 段的路径
+func partPath(outputPath string, index int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s_part%03d%s", strings.TrimSuffix(outputPath, ext), index, ext)
+}
+
+// PauseRecording 暂停录制
+// FFmpeg 的屏幕捕获无法暂停，这里结束当前捕获进程并保存为一段，恢复时开始新的一段，停止时无损拼接
+func (r *Recorder) PauseRecording() error {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if !r.IsRecording() {
+		return ErrNotRecording
+	}
+	if r.isPaused {
+		return fmt.Errorf("录制已暂停")
+	}
+	if r.webcam != nil {
+		return fmt.Errorf("摄像头录制时暂不支持暂停")
+	}
+
+	// 视频从第一段开始，流时间以第一段为准
+	if len(r.parts) == 0 {
+		start, measured := r.capture.MediaStart()
+		r.mu.Lock()
+		r.videoTiming = newStreamTiming(r.outputPath, StreamVideo, start, measured)
+		r.mu.Unlock()
+	}
+
+	pausedAt := time.Now()
+	if err := r.capture.Stop(); err != nil {
+		fmt.Printf("停止 FFmpeg 捕获失败: %v\n", err)
+	}
+	part := partPath(r.outputPath, len(r.parts))
+	if err := os.Rename(r.outputPath, part); err != nil {
+		return fmt.Errorf("保存录制片段失败: %w", err)
+	}
+	r.parts = append(r.parts, part)
+	r.mu.Lock()
+	r.pauses = append(r.pauses, PauseInterval{Start: pausedAt})
+	r.isPaused = true
+	r.mu.Unlock()
+	r.mouseHook.PauseRecordingAt(pausedAt)
+
+	fmt.Printf("✓ 录制已暂停（第 %d 段）\n", len(r.parts))
+	return nil
+}
+
+// ResumeRecording 恢复录制（开始新的一段）
+func (r *Recorder) ResumeRecording() error {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if !r.isRecording || !r.isPaused {
+		return fmt.Errorf("录制未暂停")
+	}
+
+	ffmpegPath, err := r.ffmpegManager.GetFFmpegPath()
+	if err != nil {
+		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+	}
+	if err := r.startCapture(ffmpegPath, r.captureConfig); err != nil {
+		return fmt.Errorf("恢复屏幕捕获失败: %w", err)
+	}
+
+	// 暂停区间截止到新一段的视频起点，鼠标、键盘和音频据此对齐
+	resumedAt, _ := r.capture.MediaStart()
+	r.mu.Lock()
+	r.pauses[len(r.pauses)-1].End = resumedAt
+	r.isPaused = false
+	r.mu.Unlock()
+	r.mouseHook.ResumeRecordingAt(resumedAt)
+
+	fmt.Println("✓ 录制已恢复")
+	return nil
+}
+
+// IsPaused 检查录制是否暂停
+func (r *Recorder) IsPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isPaused
+}
+
+// GetPauses 获取最近一次录制的暂停区间
+func (r *Recorder) GetPauses() []PauseInterval {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PauseInterval(nil), r.pauses...)
+}
+
+// joinParts 把暂停前后的各段拼接为最终输出
+func (r *Recorder) joinParts() error {
+	if !r.isPaused {
+		part := partPath(r.outputPath, len(r.parts))
+		if err := os.Rename(r.outputPath, part); err != nil {
+			return fmt.Errorf("保存录制片段失败: %w", err)
+		}
+		r.parts = append(r.parts, part)
+	}
+
+	listPath := filepath.Join(filepath.Dir(r.outputPath), "parts_concat.txt")
+	if err := concatCopy(r.ffmpegManager, "拼接录制片段", listPath, r.parts, r.outputPath, nil); err != nil {
+		return err
+	}
+	os.Remove(listPath)
+	for _, part := range r.parts {
+		os.Remove(part)
+	}
+	fmt.Printf("✓ 已拼接 %d 段录制\n", len(r.parts))
+	return nil
+}
+
+// concatCopy 用 concat demuxer 无损拼接文件（各文件编码参数必须一致）
+func concatCopy(ffmpegManager *ffmpeg.FFmpegManager, name, listPath string, paths []string, outputPath string, options []ffmpeg.Option) error {
+	var list strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(filepath.ToSlash(path), "'", `'\''`))
+	}
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		return fmt.Errorf("写入拼接列表失败: %w", err)
+	}
+
+	command := ffmpeg.NewCommand().Overwrite()
+	command.Input(listPath, ffmpeg.Opt("safe", 0)).Format("concat")
+	command.Output(outputPath).Codec("copy").With(options...)
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+	process, err := ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{Name: name})
+	if err != nil {
+		return err
+	}
+	if err := process.Run(context.Background()); err != nil {
+		return fmt.Errorf("%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// cutPausedAudio 从音频文件中剪掉暂停区间（timing 为该文件的流时间）
+func cutPausedAudio(ffmpegPath string, path string, timing *StreamTiming, pauses []PauseInterval) error {
+	if timing == nil || len(pauses) == 0 || !fileExists(path) {
+		return nil
+	}
+
+	var ranges []string
+	for _, pause := range pauses {
+		if pause.End.IsZero() {
+			continue
+		}
+		start := clock.Default.Seconds(pause.Start) - timing.Start
+		end := clock.Default.Seconds(pause.End) - timing.Start
+		if end <= 0 {
+			continue
+		}
+		ranges = append(ranges, fmt.Sprintf("between(t,%.3f,%.3f)", max(start, 0), end))
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	tempPath := strings.TrimSuffix(path, filepath.Ext(path)) + "_cut" + filepath.Ext(path)
+	command := ffmpeg.NewCommand().Overwrite()
+	audio := command.Input(path).Audio()
+	cut := command.FilterGraph().Chain([]ffmpeg.Pad{audio},
+		ffmpeg.NewFilter("aselect", fmt.Sprintf("not(%s)", strings.Join(ranges, "+"))),
+		ffmpeg.NewFilter("asetpts", "N/SR/TB"),
+	)
+	command.Output(tempPath).Map(cut).AudioCodec("pcm_s16le")
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+
+	process := ffmpeg.NewProcess(ffmpegPath, args, ffmpeg.ProcessOptions{Name: "剪除暂停音频"})
+	if err := process.Run(context.Background()); err != nil {
+		return fmt.Errorf("剪除暂停区间失败: %w", err)
+	}
+	return os.Rename(tempPath, path)
+}
@@ -2,20 +2,17 @@ package recorder
 
 import (
 	"SmoothScreen/pkg/ffmpeg"
+	"context"
 	"encoding/base64"
 	"fmt"
-	"io"
-	"os/exec"
 	"sync"
-	"time"
 )
 
 // PipeWriter FFmpeg 管道写入器
 // 接收 base64 图像数据并写入 FFmpeg stdin
 type PipeWriter struct {
 	ffmpegManager *ffmpeg.FFmpegManager
-	cmd           *exec.Cmd
-	stdin         io.WriteCloser
+	process       *ffmpeg.Process
 	isWriting     bool
 	mu            sync.Mutex
 	totalFrames   int
@@ -47,30 +44,26 @@ func (p *PipeWriter) StartExport(outputPath string, frameRate int) error {
 	}
 
 	// 构建导出命令
-	args := BuildExportCommand(ffmpegPath, outputPath, frameRate)
-
-	// 创建命令
-	p.cmd = exec.Command(ffmpegPath, args...)
-
-	// 获取 stdin 管道
-	stdin, err := p.cmd.StdinPipe()
+	args, err := BuildExportCommand(ffmpegPath, outputPath, frameRate)
 	if err != nil {
-		return fmt.Errorf("创建 stdin 管道失败: %w", err)
+		return fmt.Errorf("构建导出命令失败: %w", err)
 	}
 
-	p.stdin = stdin
-	p.outputPath = outputPath
-
-	// 启动 FFmpeg 进程
-	if err := p.cmd.Start(); err != nil {
-		return fmt.Errorf("启动 FFmpeg 进程失败: %w", err)
+	// 创建并启动 FFmpeg 进程（图像数据通过 stdin 写入）
+	p.process, err = p.ffmpegManager.NewProcess(args, ffmpeg.ProcessOptions{
+		Name:      "管道导出",
+		PipeStdin: true,
+	})
+	if err != nil {
+		return fmt.Errorf("创建 FFmpeg 进程失败: %w", err)
+	}
+	if err := p.process.Start(context.Background()); err != nil {
+		return err
 	}
 
+	p.outputPath = outputPath
 	p.isWriting = true
 	p.totalFrames = 0
-
-	fmt.Printf("FFmpeg 导出进程已启动: %s\n", outputPath)
-	fmt.Printf("命令: %s %s\n", ffmpegPath, args)
 	return nil
 }
 
@@ -79,7 +72,7 @@ func (p *PipeWriter) WriteFrame(base64Data string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if !p.isWriting || p.stdin == nil {
+	if !p.isWriting || p.process == nil {
 		return fmt.Errorf("导出未启动或 stdin 未初始化")
 	}
 
@@ -91,7 +84,7 @@ func (p *PipeWriter) WriteFrame(base64Data string) error {
 
 	// toDataURL() 返回的已经是完整的 PNG 文件格式（包含 PNG 文件头）
 	// 直接写入即可
-	if _, err := p.stdin.Write(imageData); err != nil {
+	if _, err := p.process.Write(imageData); err != nil {
 		// 写入失败时，标记为不再写入
 		p.isWriting = false
 		return fmt.Errorf("写入图像数据失败: %w", err)
@@ -107,12 +100,12 @@ func (p *PipeWriter) WriteFrameBinary(data []byte) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if !p.isWriting || p.stdin == nil {
+	if !p.isWriting || p.process == nil {
 		return fmt.Errorf("导出未启动或 stdin 未初始化")
 	}
 
 	// 直接写入完整的 PNG 数据（已包含 PNG 文件头）
-	if _, err := p.stdin.Write(data); err != nil {
+	if _, err := p.process.Write(data); err != nil {
 		return fmt.Errorf("写入图像数据失败: %w", err)
 	}
 
@@ -131,19 +124,16 @@ func (p *PipeWriter) FinishExport() error {
 	}
 
 	// 关闭 stdin
-	if p.stdin != nil {
-		if err := p.stdin.Close(); err != nil {
-			return fmt.Errorf("关闭 stdin 失败: %w", err)
-		}
-		p.stdin = nil
+	if err := p.process.CloseStdin(); err != nil {
+		return fmt.Errorf("关闭 stdin 失败: %w", err)
 	}
 
 	// 等待 FFmpeg 进程完成
-	if err := p.cmd.Wait(); err != nil {
+	p.isWriting = false
+	if err := p.process.Wait(); err != nil {
 		return fmt.Errorf("FFmpeg 进程退出失败: %w", err)
 	}
 
-	p.isWriting = false
 	fmt.Printf("导出完成: %s, 共 %d 帧\n", p.outputPath, p.totalFrames)
 	return nil
 }
@@ -160,35 +150,10 @@ func (p *PipeWriter) StopExport() error {
 	// 先标记为不再写入，防止继续写入
 	p.isWriting = false
 
-	// 关闭 stdin（忽略错误，可能已经关闭）
-	if p.stdin != nil {
-		_ = p.stdin.Close()
-		p.stdin = nil
-	}
-
-	// 等待 FFmpeg 进程自然退出（最多等待 5 秒）
-	done := make(chan error, 1)
-	go func() {
-		done <- p.cmd.Wait()
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			fmt.Printf("FFmpeg 进程退出: %v\n", err)
-		}
-	case <-time.After(5 * time.Second):
-		// 超时后强制终止
-		if p.cmd.Process != nil {
-			if err := p.cmd.Process.Kill(); err != nil {
-				fmt.Printf("终止 FFmpeg 进程失败: %v\n", err)
-			} else {
-				fmt.Println("FFmpeg 进程已强制终止")
-			}
-		}
-	}
-
-	fmt.Println("导出已停止")
+	// 关闭 stdin 并等待 FFmpeg 进程自然退出，超时后发送信号或强制终止
+	if err := p.process.Stop(); err != nil {
+		fmt.Printf("警告: 终止 FFmpeg 进程失败: %v\n", err)
+	}
 	return nil
 }
 
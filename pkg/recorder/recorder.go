@@ -6,33 +6,75 @@ import (
 	"SmoothScreen/pkg/io"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrNotRecording 没有正在进行的录制（重复停止时返回，调用方可以忽略）
+var ErrNotRecording = errors.New("没有正在进行的录制")
+
+// captureStartupGrace 启动捕获后等待确认进程未立即退出的时间
+const captureStartupGrace = 500 * time.Millisecond
+
 // Recorder 录制管理器
+// 界面、触发器、全局快捷键和磁盘保护可能同时开始、暂停或停止录制：
+// 这些操作持有 opMu 依次执行；状态字段的修改同时持有 mu，查询状态只需要 mu，不必等待正在进行的操作
 type Recorder struct {
 	ffmpegManager *ffmpeg.FFmpegManager
-	capture       *FFmpegCapture
 	mouseHook     *hook.MouseHook
 	fileWriter    *io.FileWriter
+	ctx           context.Context
+
+	opMu sync.Mutex // 串行化开始、停止、暂停、恢复和回放缓冲操作
+	mu   sync.Mutex // 保护以下字段
+
+	capture       *FFmpegCapture
+	cursorTracker *hook.CursorTracker // 光标形状捕获（可选）
 	startTime     time.Time
 	isRecording   bool
 	outputPath    string
 	mouseDataPath string
-	ctx           context.Context
+	frameRate     int
+	verification  *ffmpeg.ProbeReport  // 最近一次录制的输出校验报告
+	videoTiming   StreamTiming         // 最近一次录制的视频流开始时间
+	webcamConfig  WebcamConfig         // 摄像头录制配置
+	webcam        *WebcamRecorder      // 正在进行的摄像头录制
+	replay        *ReplayBuffer        // 回放缓冲（与普通录制互斥）
+	streamOutputs []StreamOutputConfig // 录制时同时推流的输出
+	streams       []*LiveStream        // 正在进行的推流
+
+	diskGuardConfig  DiskGuardConfig      // 磁盘空间保护和码率预算
+	diskGuard        *DiskGuard           // 录制期间的磁盘空间监控
+	diskEventHandler func(DiskSpaceEvent) // 磁盘空间事件回调
+	autoStopHandler  func(DiskSpaceEvent) // 空间不足时停止录制的方式（为空时只停止屏幕录制）
+
+	captureConfig CaptureConfig   // 当前录制的捕获配置（恢复录制时复用）
+	isPaused      bool            // 录制已暂停（捕获进程已结束）
+	parts         []string        // 暂停前录制的各段
+	pauses        []PauseInterval // 暂停区间
+	markers       []Marker        // 章节标记
 }
 
 // RecorderStatus 录制状态
 type RecorderStatus struct {
-	IsRecording     bool   `json:"isRecording"`
-	OutputPath      string `json:"outputPath"`
-	MouseDataPath   string `json:"mouseDataPath"`
-	Duration        int64  `json:"duration"` // 录制时长（毫秒）
-	MouseEventCount int    `json:"mouseEventCount"`
-	FFmpegPID       int    `json:"ffmpegPID"`
+	IsRecording     bool             `json:"isRecording"`
+	IsPaused        bool             `json:"isPaused"`
+	OutputPath      string           `json:"outputPath"`
+	MouseDataPath   string           `json:"mouseDataPath"`
+	Duration        int64            `json:"duration"` // 录制时长（毫秒）
+	MouseEventCount int              `json:"mouseEventCount"`
+	FFmpegPID       int              `json:"ffmpegPID"`
+	WebcamRecording bool             `json:"webcamRecording"`
+	Replay          *ReplayStatus    `json:"replay,omitempty"`    // 回放缓冲状态（未启动时为空）
+	DroppedFrames   int              `json:"droppedFrames"`       // 捕获进程丢弃的帧数
+	Streams         []StreamHealth   `json:"streams,omitempty"`   // 推流健康状态
+	DiskSpace       *DiskSpaceStatus `json:"diskSpace,omitempty"` // 输出卷的剩余空间（录制中且启用保护时）
 }
 
 // NewRecorder 创建录制管理器
@@ -43,18 +85,110 @@ func NewRecorder(ffmpegManager *ffmpeg.FFmpegManager, mouseHook *hook.MouseHook,
 		fileWriter:    io.NewFileWriter(),
 		ctx:           ctx,
 		isRecording:   false,
+		webcamConfig:  DefaultWebcamConfig(),
+
+		diskGuardConfig: DefaultDiskGuardConfig(),
 	}
 }
 
+// SetWebcamConfig 设置摄像头录制配置（对之后开始的录制生效）
+func (r *Recorder) SetWebcamConfig(config WebcamConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webcamConfig = config
+}
+
+// GetWebcamConfig 获取摄像头录制配置
+func (r *Recorder) GetWebcamConfig() WebcamConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.webcamConfig
+}
+
+// SetCursorTracker 设置光标形状捕获，录制时光标形状保存到 CursorPathFor(视频路径)
+func (r *Recorder) SetCursorTracker(tracker *hook.CursorTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursorTracker = tracker
+}
+
+// CursorPathFor 返回与录制视频对应的光标形状数据路径
+func CursorPathFor(videoPath string) string {
+	return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "_cursor.json"
+}
+
+// SetStreamOutputs 设置录制时同时推流的输出（对之后开始的录制生效）
+func (r *Recorder) SetStreamOutputs(outputs []StreamOutputConfig) error {
+	normalized := make([]StreamOutputConfig, 0, len(outputs))
+	for _, output := range outputs {
+		output, err := normalizeStreamOutput(output)
+		if err != nil {
+			return err
+		}
+		normalized = append(normalized, output)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streamOutputs = normalized
+	return nil
+}
+
+// GetStreamOutputs 获取推流输出配置
+func (r *Recorder) GetStreamOutputs() []StreamOutputConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.streamOutputs
+}
+
+// SetDiskGuardConfig 设置磁盘空间保护和码率预算（对之后开始的录制生效）
+func (r *Recorder) SetDiskGuardConfig(config DiskGuardConfig) error {
+	config, err := normalizeDiskGuardConfig(config)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diskGuardConfig = config
+	return nil
+}
+
+// GetDiskGuardConfig 获取磁盘空间保护配置
+func (r *Recorder) GetDiskGuardConfig() DiskGuardConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.diskGuardConfig
+}
+
+// SetDiskEventHandler 设置磁盘空间事件回调
+func (r *Recorder) SetDiskEventHandler(handler func(DiskSpaceEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diskEventHandler = handler
+}
+
+// SetAutoStopHandler 设置剩余空间到达临界值时的停止方式
+// 调用方可以借此同时停止音频、键盘等录制；未设置时调用 StopRecording
+func (r *Recorder) SetAutoStopHandler(handler func(DiskSpaceEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.autoStopHandler = handler
+}
+
 // StartRecording 开始录制
 func (r *Recorder) StartRecording(outputPath string) error {
-	if r.isRecording {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if r.IsRecording() {
 		return fmt.Errorf("录制已在进行中")
 	}
+	if r.IsReplaying() {
+		return fmt.Errorf("回放缓冲正在运行，请先停止回放缓冲")
+	}
 
 	// 检查 FFmpeg 是否可用
 	if !r.ffmpegManager.CheckFFmpegAvailable() {
-		return fmt.Errorf("FFmpeg 不可用，请确保 ffmpeg.exe 在正确位置")
+		return fmt.Errorf("FFmpeg 不可用，请在设置中指定 FFmpeg 路径或安装到 PATH")
 	}
 
 	// 获取 FFmpeg 路径
@@ -68,6 +202,26 @@ func (r *Recorder) StartRecording(outputPath string) error {
 		return fmt.Errorf("创建输出目录失败: %w", err)
 	}
 
+	r.mu.Lock()
+	diskGuardConfig := r.diskGuardConfig
+	streamOutputs := r.streamOutputs
+	webcamConfig := r.webcamConfig
+	cursorTracker := r.cursorTracker
+	diskEventHandler := r.diskEventHandler
+	r.mu.Unlock()
+
+	// 剩余空间已低于临界值时不开始录制
+	var diskGuard *DiskGuard
+	if diskGuardConfig.Enabled {
+		diskGuard, err = NewDiskGuard(diskGuardConfig, outputPath, r.capturedBytes, r.handleDiskCritical)
+		if err != nil {
+			return err
+		}
+		if err := diskGuard.CheckStart(); err != nil {
+			return err
+		}
+	}
+
 	// 生成鼠标数据文件路径
 	mouseDataPath := filepath.Join(filepath.Dir(outputPath), "mouse_events.json")
 
@@ -84,75 +238,206 @@ func (r *Recorder) StartRecording(outputPath string) error {
 	config := DefaultCaptureConfig(outputPath)
 	config.Codec = codec
 	config.Preset = preset
+	config.MaxBitrate = diskGuardConfig.MaxBitrateKbps
+
+	// 推流进程先启动并监听本地中继，捕获进程额外编码一路写到中继
+	var streams []*LiveStream
+	for _, output := range streamOutputs {
+		stream, err := NewLiveStream(r.ffmpegManager, output)
+		if err != nil {
+			stopLiveStreams(streams)
+			return fmt.Errorf("创建推流失败: %w", err)
+		}
+		stream.Start()
+		streams = append(streams, stream)
+		config.streams = append(config.streams, stream.relay)
+	}
 
-	// 尝试使用 ddagrap（推荐）
-	var args []string
-	args = BuildDDAGrabCommand(ffmpegPath, config)
-
-	// 创建 FFmpeg 捕获器
-	r.capture = NewFFmpegCapture()
+	// 启动 FFmpeg 捕获
+	if err := r.startCapture(ffmpegPath, config); err != nil {
+		stopLiveStreams(streams)
+		return fmt.Errorf("启动 FFmpeg 捕获失败: %w", err)
+	}
 
-	// 启动 FFmpeg 进程
-	if err := r.capture.Start(ffmpegPath, args); err != nil {
-		// 如果 ddagrap 失败，尝试使用 gdigrab
-		fmt.Println("ddagrap 失败，尝试使用 gdigrab...")
-		args = BuildGDIRABCommand(ffmpegPath, config)
-		if err := r.capture.Start(ffmpegPath, args); err != nil {
-			return fmt.Errorf("启动 FFmpeg 捕获失败: %w", err)
+	// 启动摄像头录制（可选，失败不影响屏幕录制）
+	var webcam *WebcamRecorder
+	if webcamConfig.Enabled {
+		webcam = NewWebcamRecorder(ffmpegPath, webcamConfig)
+		if err := webcam.Start(WebcamPathFor(outputPath)); err != nil {
+			fmt.Printf("警告: %v\n", err)
+			webcam = nil
 		}
 	}
 
-	// 开始录制鼠标数据
+	// 开始录制鼠标数据和光标形状（光标形状不可用时不影响录制）
 	r.mouseHook.StartRecording()
+	if cursorTracker != nil {
+		if err := cursorTracker.StartRecording(); err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+	}
 
 	// 记录开始时间
+	r.mu.Lock()
+	r.streams = streams
+	r.webcam = webcam
 	r.startTime = time.Now()
 	r.isRecording = true
 	r.outputPath = outputPath
 	r.mouseDataPath = mouseDataPath
+	r.frameRate = config.FrameRate
+	r.verification = nil
+	r.captureConfig = config
+	r.isPaused = false
+	r.parts = nil
+	r.pauses = nil
+	r.markers = nil
+	r.diskGuard = diskGuard
+	r.mu.Unlock()
+
+	// 捕获进程启动后开始监控剩余空间
+	if diskGuard != nil {
+		diskGuard.SetEventHandler(diskEventHandler)
+		diskGuard.Start()
+	}
 
 	fmt.Printf("录制已开始: %s\n", outputPath)
 	fmt.Printf("使用编码器: %s\n", codec)
 	return nil
 }
 
+// startCapture 启动屏幕捕获
+func (r *Recorder) startCapture(ffmpegPath string, config CaptureConfig) error {
+	capture, err := startScreenCapture(r.ffmpegManager, ffmpegPath, config)
+	r.mu.Lock()
+	r.capture = capture
+	r.mu.Unlock()
+	return err
+}
+
+// startScreenCapture 启动屏幕捕获
+// 优先使用 ddagrap；ddagrab 在启动后很快退出（设备被占用、系统不支持等）时回退到 gdigrab
+func startScreenCapture(ffmpegManager *ffmpeg.FFmpegManager, ffmpegPath string, config CaptureConfig) (*FFmpegCapture, error) {
+	args, err := BuildDDAGrabCommand(ffmpegPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("构建捕获命令失败: %w", err)
+	}
+	capture := NewFFmpegCapture(ffmpegManager)
+	err = capture.Start(args)
+	if err == nil {
+		select {
+		case <-capture.Done():
+			err = capture.Err()
+			if err == nil {
+				err = errors.New("进程意外退出")
+			}
+		case <-time.After(captureStartupGrace):
+			return capture, nil
+		}
+	}
+
+	fmt.Printf("ddagrap 失败 (%v)，尝试使用 gdigrab...\n", err)
+	args, err = BuildGDIRABCommand(ffmpegPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("构建捕获命令失败: %w", err)
+	}
+	capture = NewFFmpegCapture(ffmpegManager)
+	return capture, capture.Start(args)
+}
+
 // StopRecording 停止录制
+// 可以重复调用：录制已经停止（包括另一个调用刚刚完成停止）时返回 ErrNotRecording
 func (r *Recorder) StopRecording() (string, string, error) {
-	if !r.isRecording {
-		return "", "", fmt.Errorf("没有正在进行的录制")
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if !r.IsRecording() {
+		return "", "", ErrNotRecording
 	}
 
-	// 停止 FFmpeg 捕获
-	if r.capture != nil {
+	// 磁盘监控退出前可能还在读取 capturedBytes，不能持有 mu 等待
+	r.mu.Lock()
+	diskGuard := r.diskGuard
+	r.diskGuard = nil
+	r.mu.Unlock()
+	if diskGuard != nil {
+		diskGuard.Stop()
+	}
+
+	// 停止 FFmpeg 捕获（暂停中时进程已结束）
+	if r.capture != nil && !r.isPaused {
+		// 停止前记录视频流的开始时间，供音视频对齐使用（有暂停时以第一段为准）
+		if len(r.parts) == 0 {
+			start, measured := r.capture.MediaStart()
+			r.mu.Lock()
+			r.videoTiming = newStreamTiming(r.outputPath, StreamVideo, start, measured)
+			r.mu.Unlock()
+		}
+
 		fmt.Println("正在停止 FFmpeg 捕获...")
 		if err := r.capture.Stop(); err != nil {
 			// 打印错误但不返回，继续处理鼠标数据
 			fmt.Printf("停止 FFmpeg 捕获失败: %v\n", err)
 			// 检查是否有错误日志
-			if ffmpegErr := r.capture.GetError(); ffmpegErr != "" {
-				fmt.Printf("FFmpeg 错误日志: %s\n", ffmpegErr)
+			if ffmpegLog := r.capture.GetOutput(); ffmpegLog != "" {
+				fmt.Printf("FFmpeg 输出日志:\n%s\n", ffmpegLog)
 			}
 		} else {
 			fmt.Println("FFmpeg 捕获已停止")
 		}
 	}
+	r.stopStreams()
 
-	// 等待 FFmpeg 完成文件写入（最多等待 3 秒）
-	fmt.Println("等待 FFmpeg 完成文件写入...")
-	for i := 0; i < 30; i++ {
-		if _, err := os.Stat(r.outputPath); err == nil {
-			// 文件存在，检查文件大小
-			if info, err := os.Stat(r.outputPath); err == nil && info.Size() > 1024 {
-				// 文件大小大于 1KB，认为已写入完成
-				fmt.Printf("视频文件已写入: %s (大小: %d 字节)\n", r.outputPath, info.Size())
-				break
-			}
+	// 视频已结束，立即停止鼠标和光标记录（之后的拼接和校验不计入录制时长）
+	r.mouseHook.StopRecording()
+	r.saveCursorTrack()
+	stoppedAt := time.Now()
+	if r.isPaused {
+		r.mu.Lock()
+		r.pauses[len(r.pauses)-1].End = stoppedAt
+		r.isPaused = false
+		r.mu.Unlock()
+	}
+	duration := stoppedAt.Sub(r.startTime) - pausedDuration(r.pauses, stoppedAt)
+
+	// 暂停过的录制由多段拼接
+	if len(r.parts) > 0 {
+		if err := r.joinParts(); err != nil {
+			fmt.Printf("✗ %v\n", err)
 		}
-		time.Sleep(100 * time.Millisecond)
 	}
 
-	// 停止鼠标录制
-	r.mouseHook.StopRecording()
+	// FFmpeg 已退出，使用 ffprobe 校验输出文件
+	// 录制时长包含捕获启动的延迟（暂停的录制每段都有），因此容差放宽到 2 秒或 10%
+	elapsed := duration.Seconds()
+	report, verifyErr := VerifyOutput(r.ffmpegManager, r.outputPath, r.outputPath, ffmpeg.ProbeExpectation{
+		Duration:          elapsed,
+		DurationTolerance: math.Max(2.0, elapsed*0.1),
+		VideoStreams:      1,
+		FPS:               float64(r.frameRate),
+	})
+	r.mu.Lock()
+	r.verification = report
+	r.mu.Unlock()
+	if r.capture != nil {
+		if err := r.recordStreams(); err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+	}
+	if errors.Is(verifyErr, ffmpeg.ErrOutputMismatch) {
+		// 参数不符不影响文件可用性，只记录警告
+		fmt.Printf("警告: %v\n", verifyErr)
+		verifyErr = nil
+	}
+	if errors.Is(verifyErr, ffmpeg.ErrProbeUnavailable) {
+		// 没有 ffprobe 时跳过校验（VerifyOutput 已打印警告）
+		verifyErr = nil
+	}
+
+	// 更新状态（之后保存鼠标数据失败也不再重复停止）
+	r.mu.Lock()
+	r.isRecording = false
+	r.mu.Unlock()
 
 	// 保存鼠标数据到文件
 	mouseData := r.mouseHook.GetMouseData()
@@ -170,42 +455,118 @@ func (r *Recorder) StopRecording() (string, string, error) {
 	}
 	r.fileWriter.Close()
 
-	// 更新状态
-	r.isRecording = false
-	duration := time.Since(r.startTime).Milliseconds()
-
 	fmt.Printf("录制已停止: %s\n", r.outputPath)
 	fmt.Printf("鼠标数据已保存: %s\n", r.mouseDataPath)
-	fmt.Printf("录制时长: %d ms\n", duration)
+	fmt.Printf("录制时长: %d ms\n", duration.Milliseconds())
 	fmt.Printf("鼠标事件数量: %d\n", len(mouseData))
 
+	if verifyErr != nil {
+		return r.outputPath, r.mouseDataPath, fmt.Errorf("录制输出校验失败: %w", verifyErr)
+	}
+
 	// 返回原始视频路径和鼠标数据路径
 	return r.outputPath, r.mouseDataPath, nil
 }
 
+// saveCursorTrack 停止光标形状捕获并保存（没有记录到变化时不写文件）
+func (r *Recorder) saveCursorTrack() {
+	r.mu.Lock()
+	cursorTracker := r.cursorTracker
+	r.mu.Unlock()
+	if cursorTracker == nil {
+		return
+	}
+	cursorTracker.StopRecording()
+	track := cursorTracker.GetTrack()
+	if len(track.Events) == 0 {
+		return
+	}
+	path := CursorPathFor(r.outputPath)
+	if err := hook.SaveCursorTrack(path, track); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return
+	}
+	fmt.Printf("✓ 光标形状已保存: %s\n", path)
+}
+
 // GetStatus 获取录制状态
 func (r *Recorder) GetStatus() RecorderStatus {
+	r.mu.Lock()
 	status := RecorderStatus{
 		IsRecording: r.isRecording,
+		IsPaused:    r.isPaused,
 		OutputPath:  r.outputPath,
 	}
-
 	if r.isRecording {
-		status.Duration = time.Since(r.startTime).Milliseconds()
+		status.Duration = (time.Since(r.startTime) - pausedDuration(r.pauses, time.Now())).Milliseconds()
 	}
+	webcam, capture, replay, diskGuard := r.webcam, r.capture, r.replay, r.diskGuard
+	streams := r.streams
+	r.mu.Unlock()
 
 	if r.mouseHook != nil {
 		mouseData := r.mouseHook.GetMouseData()
 		status.MouseEventCount = len(mouseData)
 	}
 
-	if r.capture != nil {
-		status.FFmpegPID = r.capture.GetPID()
+	if webcam != nil {
+		status.WebcamRecording = webcam.IsRecording()
+	}
+	if capture != nil {
+		status.FFmpegPID = capture.GetPID()
+		status.DroppedFrames = capture.GetProgress().Dropped
+	}
+	for _, stream := range streams {
+		status.Streams = append(status.Streams, stream.Health())
+	}
+	if replay != nil {
+		replayStatus := replay.GetStatus()
+		status.Replay = &replayStatus
+	}
+	if diskGuard != nil {
+		diskStatus := diskGuard.GetStatus()
+		status.DiskSpace = &diskStatus
 	}
 
 	return status
 }
 
+// capturedBytes 返回捕获进程已写入的字节数（进度中没有大小时读取输出文件大小）
+func (r *Recorder) capturedBytes() int64 {
+	r.mu.Lock()
+	capture, outputPath := r.capture, r.outputPath
+	r.mu.Unlock()
+
+	if capture != nil {
+		if size := capture.GetProgress().TotalSize; size > 0 {
+			return size
+		}
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// handleDiskCritical 剩余空间到达临界值时停止录制
+// 通过 q 让 FFmpeg 正常结束并写入文件尾，避免磁盘写满后进程崩溃留下损坏的文件
+// 在新的 goroutine 中停止，StopRecording 会等待磁盘监控退出
+func (r *Recorder) handleDiskCritical(event DiskSpaceEvent) {
+	r.mu.Lock()
+	autoStopHandler := r.autoStopHandler
+	r.mu.Unlock()
+
+	go func() {
+		if autoStopHandler != nil {
+			autoStopHandler(event)
+			return
+		}
+		if _, _, err := r.StopRecording(); err != nil && !errors.Is(err, ErrNotRecording) {
+			fmt.Printf("✗ 自动停止录制失败: %v\n", err)
+		}
+	}()
+}
+
 // GetMouseData 获取鼠标数据
 func (r *Recorder) GetMouseData() []hook.MouseEvent {
 	if r.mouseHook == nil {
@@ -255,15 +616,146 @@ func (r *Recorder) SaveMouseData(filePath string) error {
 
 // GetOutputPath 获取输出视频路径
 func (r *Recorder) GetOutputPath() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.outputPath
 }
 
 // GetMouseDataPath 获取鼠标数据路径
 func (r *Recorder) GetMouseDataPath() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.mouseDataPath
 }
 
+// GetVerification 获取最近一次录制的输出校验报告（未校验时为 nil）
+func (r *Recorder) GetVerification() *ffmpeg.ProbeReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.verification
+}
+
+// recordStreams 停止摄像头录制，把视频和摄像头的流时间写入会话
+func (r *Recorder) recordStreams() error {
+	if r.webcam == nil {
+		return recordStreamTiming(r.videoTiming)
+	}
+
+	webcamTiming, err := r.webcam.Stop()
+	r.mu.Lock()
+	r.webcam = nil
+	r.mu.Unlock()
+	if err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+	if !fileExists(webcamTiming.Path) {
+		return recordStreamTiming(r.videoTiming)
+	}
+	return RecordAVSync(r.videoTiming, webcamTiming)
+}
+
+// stopStreams 停止所有推流
+func (r *Recorder) stopStreams() {
+	r.mu.Lock()
+	streams := r.streams
+	r.streams = nil
+	r.mu.Unlock()
+	stopLiveStreams(streams)
+}
+
+// stopLiveStreams 停止推流
+func stopLiveStreams(streams []*LiveStream) {
+	for _, stream := range streams {
+		stream.Stop()
+	}
+}
+
+// GetVideoTiming 获取最近一次录制的视频流开始时间
+func (r *Recorder) GetVideoTiming() StreamTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.videoTiming
+}
+
+// StartReplay 启动回放缓冲，keyboardHook 为空时不记录键盘事件
+func (r *Recorder) StartReplay(config ReplayConfig, keyboardHook *hook.KeyboardHook) error {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if r.IsRecording() {
+		return fmt.Errorf("录制正在进行中，无法启动回放缓冲")
+	}
+	if r.IsReplaying() {
+		return fmt.Errorf("回放缓冲已在运行")
+	}
+
+	replay := NewReplayBuffer(r.ffmpegManager, r.mouseHook, keyboardHook, config)
+	if err := replay.Start(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.replay = replay
+	r.mu.Unlock()
+	return nil
+}
+
+// StopReplay 停止回放缓冲并丢弃缓冲的内容
+func (r *Recorder) StopReplay() error {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if r.replay == nil {
+		return fmt.Errorf("回放缓冲未运行")
+	}
+	err := r.replay.Stop()
+	r.mu.Lock()
+	r.replay = nil
+	r.mu.Unlock()
+	return err
+}
+
+// SaveReplay 把回放缓冲的最近窗口保存为录制会话，保存后继续缓冲
+func (r *Recorder) SaveReplay(videoPath string) (*ReplayBundle, error) {
+	r.opMu.Lock()
+	defer r.opMu.Unlock()
+
+	if r.replay == nil {
+		return nil, fmt.Errorf("回放缓冲未运行")
+	}
+	bundle, err := r.replay.SaveReplay(videoPath)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bundle != nil {
+		r.verification = bundle.Verification
+		r.videoTiming = bundle.Timing
+	}
+	if !r.replay.IsRunning() {
+		// 保存后无法继续缓冲
+		r.replay = nil
+	}
+	return bundle, err
+}
+
+// LastInputTime 获取最近一次鼠标或键盘输入的时间（用于空闲检测）
+func (r *Recorder) LastInputTime() time.Time {
+	if r.mouseHook == nil {
+		return time.Time{}
+	}
+	return r.mouseHook.LastInputTime()
+}
+
+// IsReplaying 检查回放缓冲是否在运行
+func (r *Recorder) IsReplaying() bool {
+	r.mu.Lock()
+	replay := r.replay
+	r.mu.Unlock()
+	return replay != nil && replay.IsRunning()
+}
+
 // IsRecording 检查是否正在录制
 func (r *Recorder) IsRecording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.isRecording
 }
@@ -0,0 +1,542 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"SmoothScreen/pkg/hook"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replaySegmentPattern 回放分段文件名模板
+const replaySegmentPattern = "seg_%06d.ts"
+
+// ReplayConfig 回放缓冲配置
+type ReplayConfig struct {
+	Duration       int    `json:"duration"`       // 保留最近多少秒（默认 120）
+	SegmentSeconds int    `json:"segmentSeconds"` // 每段时长（秒，默认 2），保存时窗口按段对齐
+	MaxDiskMB      int    `json:"maxDiskMB"`      // 分段占用磁盘上限（默认 2048），超出时丢弃最旧的分段
+	MaxEvents      int    `json:"maxEvents"`      // 鼠标、键盘事件各自最多保留的数量（默认 200000）
+	Dir            string `json:"dir"`            // 分段目录（默认系统临时目录下的 silkrec-replay）
+}
+
+// DefaultReplayConfig 默认回放缓冲配置
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{
+		Duration:       120,
+		SegmentSeconds: 2,
+		MaxDiskMB:      2048,
+		MaxEvents:      200000,
+		Dir:            filepath.Join(os.TempDir(), "silkrec-replay"),
+	}
+}
+
+// ReplayStatus 回放缓冲状态
+type ReplayStatus struct {
+	Running   bool    `json:"running"`
+	Buffered  float64 `json:"buffered"` // 已完成分段的总时长（秒）
+	Segments  int     `json:"segments"`
+	DiskBytes int64   `json:"diskBytes"`
+}
+
+// ReplayBundle SaveReplay 写出的会话文件
+type ReplayBundle struct {
+	VideoPath        string              `json:"videoPath"`
+	MouseDataPath    string              `json:"mouseDataPath"`
+	KeyboardDataPath string              `json:"keyboardDataPath,omitempty"`
+	Duration         float64             `json:"duration"` // 秒
+	MouseEvents      int                 `json:"mouseEvents"`
+	KeyboardEvents   int                 `json:"keyboardEvents"`
+	Verification     *ffmpeg.ProbeReport `json:"verification,omitempty"`
+	Segments         int                 `json:"segments"`
+	Timing           StreamTiming        `json:"timing"`
+}
+
+// replaySegment 分段列表中的一段
+type replaySegment struct {
+	Path  string
+	Index int
+	Start float64 // 相对捕获输出时间戳 0（秒）
+	End   float64
+	Size  int64
+}
+
+// replayFrozenGeneration 保存时冻结的一代分段和事件
+type replayFrozenGeneration struct {
+	dir        string
+	segments   []replaySegment
+	mediaStart time.Time
+	measured   bool
+	mouse      []hook.MouseEvent
+	mouseStart time.Time
+	keyboard   []hook.KeyboardEvent
+	keyStart   time.Time
+	hasKeys    bool
+}
+
+// ReplayBuffer 回放缓冲（"即时回放"）
+// 屏幕捕获持续写入固定时长的 MPEG-TS 分段，只保留最近 Duration 秒；鼠标和键盘钩子按相同窗口保留事件。
+// SaveReplay 冻结当前窗口，拼接为普通录制会话（视频 + 鼠标数据 + 键盘数据 + 会话清单），随后在新目录继续缓冲。
+type ReplayBuffer struct {
+	ffmpegManager *ffmpeg.FFmpegManager
+	mouseHook     *hook.MouseHook
+	keyboardHook  *hook.KeyboardHook
+	config        ReplayConfig
+
+	mu            sync.Mutex
+	ffmpegPath    string
+	captureConfig CaptureConfig
+	capture       replayCapture
+	dir           string // 当前一代分段目录
+	generation    int
+	ownsKeyboard  bool // 键盘钩子由回放缓冲启动，停止时一并停止
+	stop          chan struct{}
+	done          chan struct{}
+
+	// 以下在 NewReplayBuffer 中设置，测试中替换
+	startCapture   func(config CaptureConfig) (replayCapture, error)
+	mouseEvents    func() ([]hook.MouseEvent, time.Time)
+	keyboardEvents func() ([]hook.KeyboardEvent, time.Time, bool)
+}
+
+// replayCapture 回放缓冲使用的分段捕获进程
+type replayCapture interface {
+	MediaStart() (time.Time, bool)
+	Stop() error
+}
+
+// NewReplayBuffer 创建回放缓冲，keyboardHook 为空时不记录键盘事件
+func NewReplayBuffer(ffmpegManager *ffmpeg.FFmpegManager, mouseHook *hook.MouseHook, keyboardHook *hook.KeyboardHook, config ReplayConfig) *ReplayBuffer {
+	defaults := DefaultReplayConfig()
+	if config.Duration <= 0 {
+		config.Duration = defaults.Duration
+	}
+	if config.SegmentSeconds <= 0 {
+		config.SegmentSeconds = defaults.SegmentSeconds
+	}
+	if config.MaxDiskMB <= 0 {
+		config.MaxDiskMB = defaults.MaxDiskMB
+	}
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = defaults.MaxEvents
+	}
+	if config.Dir == "" {
+		config.Dir = defaults.Dir
+	}
+	b := &ReplayBuffer{
+		ffmpegManager: ffmpegManager,
+		mouseHook:     mouseHook,
+		keyboardHook:  keyboardHook,
+		config:        config,
+	}
+	b.startCapture = func(config CaptureConfig) (replayCapture, error) {
+		capture, err := startScreenCapture(b.ffmpegManager, b.ffmpegPath, config)
+		if err != nil {
+			return nil, err
+		}
+		return capture, nil
+	}
+	b.mouseEvents = func() ([]hook.MouseEvent, time.Time) {
+		if b.mouseHook == nil {
+			return nil, time.Time{}
+		}
+		return append([]hook.MouseEvent(nil), b.mouseHook.GetMouseData()...), b.mouseHook.GetStartTime()
+	}
+	b.keyboardEvents = func() ([]hook.KeyboardEvent, time.Time, bool) {
+		if b.keyboardHook == nil || !b.keyboardHook.IsRecording() {
+			return nil, time.Time{}, false
+		}
+		return b.keyboardHook.GetEvents(), b.keyboardHook.GetStartTime(), true
+	}
+	return b
+}
+
+// Start 开始缓冲
+func (b *ReplayBuffer) Start() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.capture != nil {
+		return fmt.Errorf("回放缓冲已在运行")
+	}
+	if !b.ffmpegManager.CheckFFmpegAvailable() {
+		return fmt.Errorf("FFmpeg 不可用，请在设置中指定 FFmpeg 路径或安装到 PATH")
+	}
+	ffmpegPath, err := b.ffmpegManager.GetFFmpegPath()
+	if err != nil {
+		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+	}
+	codec, err := b.ffmpegManager.GetBestEncoder()
+	if err != nil {
+		return fmt.Errorf("检测编码器失败: %w", err)
+	}
+
+	config := DefaultCaptureConfig("")
+	config.Codec = codec
+	config.Preset = b.ffmpegManager.GetBestPreset(codec)
+	config.SegmentSeconds = b.config.SegmentSeconds
+	// 列表多保留几段，清理协程按时长和磁盘上限再筛选
+	config.SegmentListSize = b.config.Duration/b.config.SegmentSeconds + 4
+
+	b.ffmpegPath = ffmpegPath
+	b.captureConfig = config
+	if err := b.startGenerationLocked(); err != nil {
+		return err
+	}
+
+	// 事件多保留两段，保证最旧的分段也有完整的事件
+	window := time.Duration(b.config.Duration+2*b.config.SegmentSeconds) * time.Second
+	if b.mouseHook != nil {
+		b.mouseHook.SetRetention(window, b.config.MaxEvents)
+		b.mouseHook.StartRecording()
+	}
+	b.ownsKeyboard = false
+	if b.keyboardHook != nil {
+		b.keyboardHook.SetRetention(window, b.config.MaxEvents)
+		if !b.keyboardHook.IsRecording() {
+			if err := b.keyboardHook.StartRecording(); err != nil {
+				fmt.Printf("警告: 键盘录制启动失败: %v\n", err)
+			} else {
+				b.ownsKeyboard = true
+			}
+		}
+	}
+
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.prune(b.stop, b.done)
+
+	fmt.Printf("✓ 回放缓冲已启动: 保留最近 %d 秒 (%s)\n", b.config.Duration, codec)
+	return nil
+}
+
+// startGenerationLocked 在新目录启动一代分段捕获（需持有 b.mu）
+func (b *ReplayBuffer) startGenerationLocked() error {
+	b.generation++
+	dir := filepath.Join(b.config.Dir, fmt.Sprintf("%s_%d", time.Now().Format("20060102_150405"), b.generation))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建回放缓冲目录失败: %w", err)
+	}
+
+	config := b.captureConfig
+	config.OutputPath = filepath.Join(dir, replaySegmentPattern)
+	config.SegmentList = filepath.Join(dir, "segments.csv")
+	capture, err := b.startCapture(config)
+	if err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("启动回放缓冲捕获失败: %w", err)
+	}
+
+	b.capture = capture
+	b.dir = dir
+	return nil
+}
+
+// Stop 停止缓冲并删除分段
+func (b *ReplayBuffer) Stop() error {
+	b.mu.Lock()
+	if b.capture == nil {
+		b.mu.Unlock()
+		return fmt.Errorf("回放缓冲未运行")
+	}
+	stop, done := b.stop, b.done
+	b.mu.Unlock()
+
+	// 清理协程会获取 b.mu，先在锁外等待它退出
+	close(stop)
+	<-done
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.capture.Stop()
+	os.RemoveAll(b.dir)
+	b.capture = nil
+	b.dir = ""
+	b.stopHooksLocked()
+
+	fmt.Println("✓ 回放缓冲已停止")
+	return err
+}
+
+// stopHooksLocked 停止事件录制并取消保留窗口（需持有 b.mu）
+func (b *ReplayBuffer) stopHooksLocked() {
+	if b.mouseHook != nil {
+		b.mouseHook.StopRecording()
+		b.mouseHook.SetRetention(0, 0)
+	}
+	if b.keyboardHook != nil {
+		if b.ownsKeyboard {
+			b.keyboardHook.StopRecording()
+		}
+		b.keyboardHook.SetRetention(0, 0)
+	}
+}
+
+// IsRunning 检查是否正在缓冲
+func (b *ReplayBuffer) IsRunning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.capture != nil
+}
+
+// GetConfig 获取回放缓冲配置
+func (b *ReplayBuffer) GetConfig() ReplayConfig {
+	return b.config
+}
+
+// GetStatus 获取回放缓冲状态
+func (b *ReplayBuffer) GetStatus() ReplayStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := ReplayStatus{Running: b.capture != nil}
+	if b.capture == nil {
+		return status
+	}
+	segments, _ := readReplaySegments(b.dir)
+	for _, segment := range b.selectWindow(segments) {
+		status.Buffered += segment.End - segment.Start
+		status.Segments++
+		status.DiskBytes += segment.Size
+	}
+	return status
+}
+
+// prune 定期删除窗口以外的分段
+func (b *ReplayBuffer) prune(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(b.config.SegmentSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			if b.capture != nil {
+				b.pruneLocked()
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// pruneLocked 删除当前目录中比窗口最旧分段更早的分段（需持有 b.mu）
+// 正在写入的分段不在列表中，编号总是大于列表中的分段，不会被删除
+func (b *ReplayBuffer) pruneLocked() {
+	segments, err := readReplaySegments(b.dir)
+	if err != nil {
+		return
+	}
+	window := b.selectWindow(segments)
+	if len(window) == 0 {
+		return
+	}
+
+	oldest := window[0].Index
+	files, _ := filepath.Glob(filepath.Join(b.dir, "seg_*.ts"))
+	for _, file := range files {
+		var index int
+		if _, err := fmt.Sscanf(filepath.Base(file), replaySegmentPattern, &index); err == nil && index < oldest {
+			os.Remove(file)
+		}
+	}
+}
+
+// selectWindow 从最新的分段往前选取，直到覆盖配置的时长或达到磁盘上限（至少保留最新一段）
+func (b *ReplayBuffer) selectWindow(segments []replaySegment) []replaySegment {
+	maxBytes := int64(b.config.MaxDiskMB) * 1024 * 1024
+	covered := 0.0
+	var bytes int64
+	first := len(segments)
+	for i := len(segments) - 1; i >= 0; i-- {
+		if first < len(segments) && (covered >= float64(b.config.Duration) || bytes+segments[i].Size > maxBytes) {
+			break
+		}
+		covered += segments[i].End - segments[i].Start
+		bytes += segments[i].Size
+		first = i
+	}
+	return segments[first:]
+}
+
+// readReplaySegments 读取分段列表（CSV：文件名,开始时间,结束时间），跳过已删除的分段
+func readReplaySegments(dir string) ([]replaySegment, error) {
+	file, err := os.Open(filepath.Join(dir, "segments.csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析分段列表失败: %w", err)
+	}
+
+	segments := []replaySegment{}
+	for _, record := range records {
+		if len(record) < 3 {
+			continue
+		}
+		start, err1 := strconv.ParseFloat(record[1], 64)
+		end, err2 := strconv.ParseFloat(record[2], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		path := filepath.Join(dir, filepath.Base(record[0]))
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		var index int
+		fmt.Sscanf(filepath.Base(path), replaySegmentPattern, &index)
+		segments = append(segments, replaySegment{Path: path, Index: index, Start: start, End: end, Size: info.Size()})
+	}
+	return segments, nil
+}
+
+// SaveReplay 冻结当前窗口并写出为普通录制会话
+// videoPath 为输出视频路径，鼠标和键盘数据按普通录制的命名放在同一目录
+func (b *ReplayBuffer) SaveReplay(videoPath string) (*ReplayBundle, error) {
+	frozen, err := b.freeze()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(frozen.dir)
+
+	if len(frozen.segments) == 0 {
+		return nil, fmt.Errorf("回放缓冲中还没有完整的分段")
+	}
+	if err := os.MkdirAll(filepath.Dir(videoPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+
+	// 拼接分段（每段以关键帧开始，直接复制）
+	listPath := filepath.Join(frozen.dir, "replay_concat.txt")
+	segmentPaths := make([]string, len(frozen.segments))
+	for i, segment := range frozen.segments {
+		segmentPaths[i] = segment.Path
+	}
+	if err := concatCopy(b.ffmpegManager, "保存回放", listPath, segmentPaths, videoPath, []ffmpeg.Option{ffmpeg.Opt("movflags", "+faststart")}); err != nil {
+		return nil, err
+	}
+
+	// 视频从第一个分段开始，事件时间戳换算到同一起点
+	first, last := frozen.segments[0], frozen.segments[len(frozen.segments)-1]
+	start := frozen.mediaStart.Add(time.Duration(first.Start * float64(time.Second)))
+	duration := time.Duration((last.End - first.Start) * float64(time.Second))
+
+	bundle := &ReplayBundle{
+		VideoPath:     videoPath,
+		MouseDataPath: filepath.Join(filepath.Dir(videoPath), "mouse_events.json"),
+		Duration:      duration.Seconds(),
+		Segments:      len(frozen.segments),
+		Timing:        newStreamTiming(videoPath, StreamVideo, start, frozen.measured),
+	}
+
+	mouse := replayEvents(frozen.mouse, frozen.mouseStart, start, duration, func(e *hook.MouseEvent) *int64 { return &e.Timestamp })
+	if err := writeJSONFile(bundle.MouseDataPath, mouse); err != nil {
+		return nil, fmt.Errorf("写入鼠标数据失败: %w", err)
+	}
+	bundle.MouseEvents = len(mouse)
+
+	if frozen.hasKeys {
+		keyboard := replayEvents(frozen.keyboard, frozen.keyStart, start, duration, func(e *hook.KeyboardEvent) *int64 { return &e.Timestamp })
+		bundle.KeyboardDataPath = strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "_keyboard.json"
+		if err := writeJSONFile(bundle.KeyboardDataPath, keyboard); err != nil {
+			return nil, fmt.Errorf("写入键盘数据失败: %w", err)
+		}
+		bundle.KeyboardEvents = len(keyboard)
+	}
+
+	if err := recordStreamTiming(bundle.Timing); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+	report, err := VerifyOutput(b.ffmpegManager, videoPath, videoPath, ffmpeg.ProbeExpectation{
+		Duration:          bundle.Duration,
+		DurationTolerance: math.Max(1.0, bundle.Duration*0.05),
+		VideoStreams:      1,
+		FPS:               float64(b.captureConfig.FrameRate),
+	})
+	bundle.Verification = report
+	if errors.Is(err, ffmpeg.ErrOutputMismatch) {
+		fmt.Printf("警告: %v\n", err)
+	} else if err != nil && !errors.Is(err, ffmpeg.ErrProbeUnavailable) {
+		return bundle, fmt.Errorf("回放输出校验失败: %w", err)
+	}
+
+	fmt.Printf("✓ 回放已保存: %s (%.1f 秒, %d 段, %d 个鼠标事件)\n", videoPath, bundle.Duration, bundle.Segments, bundle.MouseEvents)
+	return bundle, nil
+}
+
+// freeze 停止当前一代捕获，取出窗口内的分段和事件，并在新目录继续缓冲
+func (b *ReplayBuffer) freeze() (replayFrozenGeneration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.capture == nil {
+		return replayFrozenGeneration{}, fmt.Errorf("回放缓冲未运行")
+	}
+
+	frozen := replayFrozenGeneration{dir: b.dir}
+	frozen.mediaStart, frozen.measured = b.capture.MediaStart()
+	// 停止进程，正在写入的分段写完后出现在列表中
+	if err := b.capture.Stop(); err != nil {
+		fmt.Printf("警告: 停止回放捕获失败: %v\n", err)
+	}
+
+	// 停止捕获可能需要几秒，钩子期间仍在记录，停止后再取事件才能覆盖到最后一个分段的结尾
+	frozen.mouse, frozen.mouseStart = b.mouseEvents()
+	frozen.keyboard, frozen.keyStart, frozen.hasKeys = b.keyboardEvents()
+
+	segments, err := readReplaySegments(b.dir)
+	if err != nil {
+		fmt.Printf("警告: 读取分段列表失败: %v\n", err)
+	}
+	frozen.segments = b.selectWindow(segments)
+
+	if err := b.startGenerationLocked(); err != nil {
+		// 无法继续缓冲时仍然保存已冻结的窗口
+		fmt.Printf("警告: %v\n", err)
+		b.capture = nil
+		b.dir = ""
+		b.stopHooksLocked()
+		close(b.stop)
+	}
+	return frozen, nil
+}
+
+// replayEvents 把事件时间戳从 eventStart 换算到 start，只保留 [0, duration] 内的事件
+func replayEvents[T any](events []T, eventStart, start time.Time, duration time.Duration, timestamp func(*T) *int64) []T {
+	shift := start.Sub(eventStart).Milliseconds()
+	result := make([]T, 0, len(events))
+	for _, event := range events {
+		t := timestamp(&event)
+		*t -= shift
+		if *t >= 0 && *t <= duration.Milliseconds() {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// writeJSONFile 以缩进格式写入 JSON 文件
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,192 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/hook"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeReplaySegments 写出分段文件和分段列表，sizes 为各段大小（-1 表示分段已被删除）
+func writeReplaySegments(t *testing.T, dir string, sizes ...int) {
+	t.Helper()
+	list := ""
+	for i, size := range sizes {
+		name := fmt.Sprintf(replaySegmentPattern, i)
+		list += fmt.Sprintf("%s,%d.000000,%d.000000\n", name, 2*i, 2*i+2)
+		if size < 0 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "segments.csv"), []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadReplaySegments(t *testing.T) {
+	dir := t.TempDir()
+	writeReplaySegments(t, dir, -1, 100, 200)
+
+	// 追加无法解析的行：字段不足、时间不是数字
+	list, _ := os.ReadFile(filepath.Join(dir, "segments.csv"))
+	list = append(list, "seg_000003.ts,6.0\nseg_000004.ts,abc,10.0\n"...)
+	os.WriteFile(filepath.Join(dir, "segments.csv"), list, 0644)
+
+	segments, err := readReplaySegments(dir)
+	if err != nil {
+		t.Fatalf("readReplaySegments: %v", err)
+	}
+	// 已删除的分段和无效的行被跳过
+	want := []replaySegment{
+		{Path: filepath.Join(dir, "seg_000001.ts"), Index: 1, Start: 2, End: 4, Size: 100},
+		{Path: filepath.Join(dir, "seg_000002.ts"), Index: 2, Start: 4, End: 6, Size: 200},
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Errorf("readReplaySegments =\n%+v\nwant\n%+v", segments, want)
+	}
+
+	if _, err := readReplaySegments(t.TempDir()); err == nil {
+		t.Error("没有分段列表时应返回错误")
+	}
+}
+
+func TestSelectWindow(t *testing.T) {
+	const mb = 1024 * 1024
+	segments := []replaySegment{}
+	for i := range 6 {
+		segments = append(segments, replaySegment{Index: i, Start: float64(2 * i), End: float64(2*i + 2), Size: mb / 2})
+	}
+
+	tests := []struct {
+		name      string
+		duration  int
+		maxDiskMB int
+		first     int // 窗口中最旧分段的编号
+	}{
+		{"按段对齐", 6, 100, 3},
+		// 窗口起点落在分段中间时保留整段
+		{"跨越分段边界", 5, 100, 3},
+		{"时长超过缓冲", 60, 100, 0},
+		{"磁盘上限", 60, 1, 4},
+		// 单段就超过磁盘上限时也保留最新一段
+		{"至少保留一段", 1, 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewReplayBuffer(nil, nil, nil, ReplayConfig{Duration: tt.duration})
+			b.config.MaxDiskMB = tt.maxDiskMB // 构造函数把 0 替换为默认值
+			window := b.selectWindow(segments)
+			if len(window) == 0 || window[0].Index != tt.first || window[len(window)-1].Index != 5 {
+				t.Errorf("selectWindow = %+v, want 从 #%d 到 #5", window, tt.first)
+			}
+		})
+	}
+
+	b := NewReplayBuffer(nil, nil, nil, ReplayConfig{})
+	if window := b.selectWindow(nil); len(window) != 0 {
+		t.Errorf("没有分段时 = %+v", window)
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	eventStart := time.Now()
+	// 视频从事件起点之后 10 秒开始，时长 4 秒
+	start := eventStart.Add(10 * time.Second)
+	events := []hook.MouseEvent{
+		{X: 1, Timestamp: 9999},  // 窗口之前
+		{X: 2, Timestamp: 10000}, // 窗口起点
+		{X: 3, Timestamp: 12500},
+		{X: 4, Timestamp: 14000}, // 窗口终点
+		{X: 5, Timestamp: 14001}, // 窗口之后
+	}
+
+	got := replayEvents(events, eventStart, start, 4*time.Second, func(e *hook.MouseEvent) *int64 { return &e.Timestamp })
+	want := []hook.MouseEvent{{X: 2, Timestamp: 0}, {X: 3, Timestamp: 2500}, {X: 4, Timestamp: 4000}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayEvents = %+v, want %+v", got, want)
+	}
+	// 冻结的事件不被修改
+	if events[1].Timestamp != 10000 {
+		t.Errorf("原事件被修改: %+v", events[1])
+	}
+
+	// 键盘钩子比视频晚开始时时间戳向后平移
+	keys := []hook.KeyboardEvent{{Key: "A", Timestamp: 500}}
+	shifted := replayEvents(keys, start.Add(time.Second), start, 4*time.Second, func(e *hook.KeyboardEvent) *int64 { return &e.Timestamp })
+	if len(shifted) != 1 || shifted[0].Timestamp != 1500 {
+		t.Errorf("键盘事件 = %+v, want 时间戳 1500", shifted)
+	}
+}
+
+// fakeReplayCapture 假的分段捕获，Stop 时执行 onStop（模拟写完最后一个分段）
+type fakeReplayCapture struct {
+	mediaStart time.Time
+	onStop     func()
+	stopped    bool
+}
+
+func (c *fakeReplayCapture) MediaStart() (time.Time, bool) { return c.mediaStart, true }
+
+func (c *fakeReplayCapture) Stop() error {
+	c.stopped = true
+	if c.onStop != nil {
+		c.onStop()
+	}
+	return nil
+}
+
+func TestReplayFreezeIncludesEventsDuringStop(t *testing.T) {
+	mediaStart := time.Now()
+	b := NewReplayBuffer(nil, nil, nil, ReplayConfig{Duration: 60, Dir: t.TempDir()})
+	b.startCapture = func(CaptureConfig) (replayCapture, error) {
+		return &fakeReplayCapture{mediaStart: time.Now()}, nil
+	}
+	mouse := []hook.MouseEvent{{X: 1, Timestamp: 1000}, {X: 2, Timestamp: 3500}}
+	keyboard := []hook.KeyboardEvent{{Key: "A", Timestamp: 3900}}
+	b.mouseEvents = func() ([]hook.MouseEvent, time.Time) { return append([]hook.MouseEvent(nil), mouse...), mediaStart }
+	b.keyboardEvents = func() ([]hook.KeyboardEvent, time.Time, bool) {
+		return append([]hook.KeyboardEvent(nil), keyboard...), mediaStart, true
+	}
+
+	// 调用时已完成两段（0-4 秒），停止时写完第三段（4-6 秒），期间钩子继续记录
+	dir := filepath.Join(b.config.Dir, "gen")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReplaySegments(t, dir, 100, 100)
+	capture := &fakeReplayCapture{mediaStart: mediaStart, onStop: func() {
+		writeReplaySegments(t, dir, 100, 100, 100)
+		mouse = append(mouse, hook.MouseEvent{X: 3, Timestamp: 5200})
+		keyboard = append(keyboard, hook.KeyboardEvent{Key: "B", Timestamp: 5800})
+	}}
+	b.capture, b.dir, b.stop = capture, dir, make(chan struct{})
+
+	frozen, err := b.freeze()
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if !capture.stopped || b.capture == capture || b.dir == dir {
+		t.Errorf("冻结后没有开始新一代缓冲")
+	}
+	if len(frozen.segments) != 3 || frozen.segments[2].End != 6 {
+		t.Fatalf("冻结的分段 = %+v，期望三段到 6 秒", frozen.segments)
+	}
+
+	// 最后一段在取事件之前才写完，段内的输入也在事件文件中
+	duration := 6 * time.Second
+	gotMouse := replayEvents(frozen.mouse, frozen.mouseStart, frozen.mediaStart, duration, func(e *hook.MouseEvent) *int64 { return &e.Timestamp })
+	if len(gotMouse) != 3 || gotMouse[2].X != 3 {
+		t.Errorf("鼠标事件 = %+v，期望包含停止期间的事件", gotMouse)
+	}
+	gotKeys := replayEvents(frozen.keyboard, frozen.keyStart, frozen.mediaStart, duration, func(e *hook.KeyboardEvent) *int64 { return &e.Timestamp })
+	if !frozen.hasKeys || len(gotKeys) != 2 || gotKeys[1].Key != "B" {
+		t.Errorf("键盘事件 = %+v，期望包含停止期间的事件", gotKeys)
+	}
+}
@@ -0,0 +1,532 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exportSegment 分段导出中的一段
+type exportSegment struct {
+	Index      int    // 段序号
+	StartFrame int    // 起始相机帧（包含）
+	EndFrame   int    // 结束相机帧（不包含）
+	Hash       string // 段参数哈希，用于检查点复用
+	Path       string // 段输出路径
+}
+
+// ExportWithSegments 分段导出（更精确的相机控制）
+// 将视频按关键帧切成多个段，由工作池并发编码，失败的段会重试，
+// 最后用 concat demuxer 无损合并。调用 Stop 会终止所有工作进程。
+// 每完成一段都会写入会话检查点，重新运行相同导出时跳过已完成的段；
+// 参数变化时只有受影响的段会重新编码。取消 ctx 同样会终止所有工作进程。
+func (e *GPUExporter) ExportWithSegments(parent context.Context) error {
+	if len(e.cameraFrames) == 0 {
+		return fmt.Errorf("没有相机帧数据，请先调用 PrepareExport")
+	}
+	if !e.isExporting.CompareAndSwap(false, true) {
+		return fmt.Errorf("导出已在进行中")
+	}
+	defer e.isExporting.Store(false)
+	defer e.closeCursor()
+
+	// 获取 FFmpeg 路径
+	ffmpegPath, err := e.ffmpegManager.GetFFmpegPath()
+	if err != nil {
+		return fmt.Errorf("获取 FFmpeg 路径失败: %w", err)
+	}
+
+	// 获取编码器
+	codec, err := e.ffmpegManager.GetBestEncoder()
+	if err != nil {
+		codec = "libx264"
+	}
+	preset := e.ffmpegManager.GetBestPreset(codec)
+
+	// 加载会话检查点，段文件保存在会话目录中，失败后保留以便续传
+	session, err := LoadSession(SessionDirFor(e.config.VideoPath))
+	if err != nil {
+		return err
+	}
+	outputName := strings.TrimSuffix(filepath.Base(e.config.OutputPath), filepath.Ext(e.config.OutputPath))
+	segmentDir := filepath.Join(session.Dir(), "export_segments", outputName)
+	if err := os.MkdirAll(segmentDir, 0755); err != nil {
+		return fmt.Errorf("创建段目录失败: %w", err)
+	}
+
+	paramsHash, err := e.exportParamsHash(codec, preset)
+	if err != nil {
+		return err
+	}
+	checkpoint := session.BeginExport(e.config.OutputPath, paramsHash, segmentDir)
+
+	segments := e.planSegments(segmentDir, paramsHash)
+
+	// 清理参数变化后失效的段，复用仍然有效的段
+	keep := make(map[string]bool, len(segments))
+	for _, seg := range segments {
+		keep[seg.Hash] = true
+	}
+	if err := session.PruneSegments(checkpoint, keep); err != nil {
+		return fmt.Errorf("更新导出检查点失败: %w", err)
+	}
+
+	pending := []exportSegment{}
+	segmentDone := make([]int, len(segments))
+	for i, seg := range segments {
+		if done, ok := session.FindSegment(checkpoint, seg.Hash); ok {
+			segments[i].Path = done.Path
+			segmentDone[i] = seg.EndFrame - seg.StartFrame
+			continue
+		}
+		pending = append(pending, seg)
+	}
+	if skipped := len(segments) - len(pending); skipped > 0 {
+		fmt.Printf("✓ 从检查点恢复 %d/%d 个已完成的段\n", skipped, len(segments))
+	}
+
+	workers := e.segmentParallelism(codec, len(pending))
+	threads := runtime.NumCPU() / workers
+	if threads < 1 {
+		threads = 1
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	e.log.Reset()
+	e.mu.Lock()
+	e.cancel = cancel
+	e.segmentDone = segmentDone
+	e.totalFrames = len(e.cameraFrames)
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.cancel = nil
+		e.mu.Unlock()
+	}()
+
+	fmt.Printf("开始分段导出 (共 %d 帧, %d 段, %d 个并发工作进程)...\n", len(e.cameraFrames), len(segments), workers)
+	startTime := time.Now()
+
+	// 工作池：任意一段最终失败时取消上下文，终止其余工作进程
+	jobs := make(chan exportSegment)
+	errCh := make(chan error, len(pending))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range jobs {
+				if err := e.exportSegmentWithRetry(ctx, ffmpegPath, codec, preset, threads, seg); err != nil {
+					errCh <- err
+					cancel()
+					continue
+				}
+				if err := e.recordSegment(session, checkpoint, seg); err != nil {
+					fmt.Printf("警告: 写入段 %d 检查点失败: %v\n", seg.Index, err)
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, seg := range pending {
+		select {
+		case jobs <- seg:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("导出已取消")
+	}
+
+	// 合并所有段
+	fmt.Println("合并视频段...")
+	paths := make([]string, len(segments))
+	for i, seg := range segments {
+		paths[i] = seg.Path
+	}
+	if err := e.concatenateSegments(ctx, ffmpegPath, paths); err != nil {
+		return fmt.Errorf("合并段失败: %w", err)
+	}
+
+	// 导出成功后才清理段文件
+	if err := session.CompleteExport(checkpoint); err != nil {
+		fmt.Printf("警告: 更新导出检查点失败: %v\n", err)
+	}
+	os.RemoveAll(segmentDir)
+
+	fmt.Printf("✓ 分段导出完成: %s (耗时: %.2f 秒)\n", e.config.OutputPath, time.Since(startTime).Seconds())
+	return nil
+}
+
+// planSegments 规划分段边界
+// 边界尽量对齐源视频的关键帧，使每段的输入定位精确且合并无缝；
+// 无法读取关键帧时退回固定帧数分段
+func (e *GPUExporter) planSegments(segmentDir, paramsHash string) []exportSegment {
+	segmentSize := e.config.SegmentFrames
+	if segmentSize <= 0 {
+		segmentSize = 300 // 每段 300 帧 (10秒@30fps)
+	}
+
+	keyframes, err := e.ffmpegManager.GetKeyframeTimes(e.config.VideoPath)
+	if err != nil {
+		fmt.Printf("警告: 无法读取关键帧，使用固定分段: %v\n", err)
+		keyframes = nil
+	}
+
+	boundaries := []int{0}
+	for target := segmentSize; target < len(e.cameraFrames); target += segmentSize {
+		boundary := e.snapToKeyframe(target, segmentSize, keyframes)
+		if boundary > boundaries[len(boundaries)-1] && boundary < len(e.cameraFrames) {
+			boundaries = append(boundaries, boundary)
+		}
+	}
+	boundaries = append(boundaries, len(e.cameraFrames))
+
+	segments := make([]exportSegment, 0, len(boundaries)-1)
+	for i := 0; i < len(boundaries)-1; i++ {
+		start, end := boundaries[i], boundaries[i+1]
+		// 段哈希包含逐帧的裁剪路径，相机路径变化的段才会重新编码
+		hash := hashParams(paramsHash, start, end, e.cameraFrames[start].Timestamp, e.segmentCropPath(start, end))
+		segments = append(segments, exportSegment{
+			Index:      i,
+			StartFrame: start,
+			EndFrame:   end,
+			Hash:       hash,
+			Path:       filepath.Join(segmentDir, fmt.Sprintf("segment_%04d_%s.mp4", i, hash[:8])),
+		})
+	}
+	return segments
+}
+
+// exportParamsHash 计算影响所有段的导出参数哈希
+// 源视频以路径、大小和修改时间标识，重新录制后旧段全部失效；是否叠加光标同样影响所有段
+func (e *GPUExporter) exportParamsHash(codec, preset string) (string, error) {
+	info, err := os.Stat(e.config.VideoPath)
+	if err != nil {
+		return "", fmt.Errorf("读取源视频信息失败: %w", err)
+	}
+
+	return hashParams(
+		e.config.VideoPath, info.Size(), info.ModTime().UnixNano(),
+		codec, preset, e.config.FPS, e.config.ScreenWidth, e.config.ScreenHeight,
+		e.cursor != nil, e.config.CursorSize,
+	), nil
+}
+
+// recordSegment 将完成的段写入会话检查点
+func (e *GPUExporter) recordSegment(session *Session, checkpoint *ExportCheckpoint, seg exportSegment) error {
+	info, err := os.Stat(seg.Path)
+	if err != nil {
+		return err
+	}
+	checksum, err := ffmpeg.FileChecksum(seg.Path)
+	if err != nil {
+		return err
+	}
+
+	return session.RecordSegment(checkpoint, SegmentCheckpoint{
+		Index:      seg.Index,
+		StartFrame: seg.StartFrame,
+		EndFrame:   seg.EndFrame,
+		Hash:       seg.Hash,
+		Path:       seg.Path,
+		Checksum:   checksum,
+		Size:       info.Size(),
+	})
+}
+
+// snapToKeyframe 将目标边界帧移动到最近的关键帧（最多偏移半段）
+func (e *GPUExporter) snapToKeyframe(target, segmentSize int, keyframes []float64) int {
+	if len(keyframes) == 0 {
+		return target
+	}
+
+	targetTime := float64(e.cameraFrames[target].Timestamp) / 1000.0
+	maxShift := float64(segmentSize) / 2 / float64(e.config.FPS)
+
+	// 二分查找最近的关键帧
+	i := sort.SearchFloat64s(keyframes, targetTime)
+	best := -1.0
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(keyframes) {
+			continue
+		}
+		if best < 0 || abs(keyframes[j]-targetTime) < abs(best-targetTime) {
+			best = keyframes[j]
+		}
+	}
+	if best < 0 || abs(best-targetTime) > maxShift {
+		return target
+	}
+
+	// 找到时间不早于该关键帧的第一个相机帧
+	bestMs := int64(best * 1000)
+	return sort.Search(len(e.cameraFrames), func(k int) bool {
+		return e.cameraFrames[k].Timestamp >= bestMs
+	})
+}
+
+// segmentParallelism 计算并发工作进程数
+func (e *GPUExporter) segmentParallelism(codec string, segmentCount int) int {
+	workers := e.config.SegmentParallelism
+	if workers <= 0 {
+		if codec == "libx264" {
+			workers = runtime.NumCPU() / 2
+		} else {
+			// 消费级显卡的硬件编码会话数有限
+			workers = 2
+		}
+	}
+	if workers > segmentCount {
+		workers = segmentCount
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// exportSegmentWithRetry 导出单个段，失败时重试
+// 段先写入 .part 文件，成功后再重命名，避免留下不完整的段
+func (e *GPUExporter) exportSegmentWithRetry(ctx context.Context, ffmpegPath, codec, preset string, threads int, seg exportSegment) error {
+	var lastErr error
+	for attempt := 0; attempt <= e.config.SegmentRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt > 0 {
+			fmt.Printf("重试段 %d (第 %d 次)...\n", seg.Index, attempt)
+			e.setSegmentProgress(seg.Index, 0)
+		}
+
+		partPath := strings.TrimSuffix(seg.Path, ".mp4") + ".part.mp4"
+		lastErr = e.exportSegment(ctx, ffmpegPath, codec, preset, threads, seg, partPath)
+		if lastErr == nil {
+			if err := os.Rename(partPath, seg.Path); err != nil {
+				lastErr = err
+				continue
+			}
+			e.setSegmentProgress(seg.Index, seg.EndFrame-seg.StartFrame)
+			return nil
+		}
+		os.Remove(partPath)
+
+		// 滤镜图错误、编码器不可用、磁盘已满等问题重试也不会成功
+		if isPermanentFFmpegError(lastErr) {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("导出段 %d (帧 %d-%d) 失败: %w", seg.Index, seg.StartFrame, seg.EndFrame, lastErr)
+}
+
+// exportSegment 导出单个视频段
+func (e *GPUExporter) exportSegment(ctx context.Context, ffmpegPath, codec, preset string, threads int, seg exportSegment, outputPath string) error {
+	// 计算时间范围
+	startTime := float64(e.cameraFrames[seg.StartFrame].Timestamp) / 1000.0
+	frameCount := seg.EndFrame - seg.StartFrame
+
+	// 裁剪区域逐帧跟随相机路径：sendcmd 在每帧调整 crop 的尺寸和位置，scale 统一缩放到输出分辨率
+	crops := e.segmentCropPath(seg.StartFrame, seg.EndFrame)
+	commandPath := strings.TrimSuffix(outputPath, ".mp4") + ".camera.txt"
+	if err := os.WriteFile(commandPath, []byte(e.cropCommands(seg.StartFrame, crops)), 0644); err != nil {
+		return fmt.Errorf("写入相机路径失败: %w", err)
+	}
+	defer os.Remove(commandPath)
+
+	// 构建命令
+	// -ss 放在 -i 之前：边界对齐关键帧时定位又快又准
+	// -frames:v 精确控制帧数，保证相邻段之间没有重叠或空隙
+	command := ffmpeg.NewCommand().Overwrite().
+		Global(ffmpeg.HWDeviceArgs(codec)...).
+		Global(ffmpeg.Opt("progress", "pipe:1"), ffmpeg.Flag("nostats"))
+	input := command.Input(e.config.VideoPath, ffmpeg.Opt("ss", fmt.Sprintf("%.3f", startTime)))
+
+	first := crops[0]
+	filters := []*ffmpeg.Filter{
+		ffmpeg.NewFilter("sendcmd").Set("f", commandPath),
+		ffmpeg.NewFilter("crop@camera", first.W, first.H, first.X, first.Y),
+		ffmpeg.NewFilter("scale", e.config.ScreenWidth, e.config.ScreenHeight),
+	}
+	upload := ffmpeg.HWUploadFilters(codec)
+	filters = append(filters, upload...)
+
+	// 光标按这一段的录制时间叠加在裁剪之前
+	video := input.Video()
+	if e.cursor != nil {
+		endMs := e.cameraFrames[seg.EndFrame-1].Timestamp
+		var err error
+		if video, err = e.cursor.apply(command, video, e.cameraFrames[seg.StartFrame].Timestamp, endMs); err != nil {
+			return err
+		}
+	}
+	video = command.FilterGraph().Chain([]ffmpeg.Pad{video}, filters...)
+
+	output := command.Output(outputPath).
+		Map(video).
+		With(ffmpeg.Opt("frames:v", frameCount)).
+		VideoCodec(codec)
+
+	// 编码器设置
+	if preset != "" {
+		output.With(ffmpeg.Opt("preset", preset))
+	}
+	if !ffmpeg.IsHardwareEncoder(codec) {
+		output.With(softwareQualityOptions(codec)...)
+		output.With(ffmpeg.Opt("threads", threads))
+	}
+	output.With(ffmpeg.Opt("r", e.config.FPS))
+	if len(upload) == 0 {
+		output.With(ffmpeg.Opt("pix_fmt", "yuv420p"))
+	}
+	output.With(ffmpeg.Flag("an")) // 音频在导出后单独合并
+
+	args, err := command.Args()
+	if err != nil {
+		return fmt.Errorf("构建 FFmpeg 命令失败: %w", err)
+	}
+
+	// 执行，取消上下文时进程会被停止
+	process := ffmpeg.NewProcess(ffmpegPath, args, ffmpeg.ProcessOptions{
+		Name:  fmt.Sprintf("导出段 %d", seg.Index),
+		Quiet: true,
+		OnProgress: func(progress ffmpeg.Progress) {
+			frames := progress.Frame
+			if frames > frameCount {
+				frames = frameCount
+			}
+			e.setSegmentProgress(seg.Index, frames)
+		},
+	})
+	if err := process.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Fprintf(e.log, "[段 %d] %s\n", seg.Index, process.Stderr())
+		return err
+	}
+	return nil
+}
+
+// isPermanentFFmpegError 检查 FFmpeg 失败是否与输入无关、重试也不会成功
+func isPermanentFFmpegError(err error) bool {
+	return errors.Is(err, ffmpeg.ErrInvalidFilterGraph) ||
+		errors.Is(err, ffmpeg.ErrEncoderUnavailable) ||
+		errors.Is(err, ffmpeg.ErrDiskFull)
+}
+
+// cameraCrop 一个相机帧对应的裁剪区域
+type cameraCrop struct {
+	W, H, X, Y int
+}
+
+// frameCrop 计算相机帧的裁剪区域（尺寸取偶数以满足 yuv420p 的色度采样）
+func (e *GPUExporter) frameCrop(frame CameraFrame) cameraCrop {
+	zoom := math.Max(frame.Zoom, 1.0)
+	crop := cameraCrop{
+		W: int(float64(e.config.ScreenWidth)/zoom) &^ 1,
+		H: int(float64(e.config.ScreenHeight)/zoom) &^ 1,
+	}
+	crop.X = int(frame.X - float64(crop.W)/2)
+	crop.Y = int(frame.Y - float64(crop.H)/2)
+
+	// 限制裁剪区域
+	crop.X = max(0, min(crop.X, e.config.ScreenWidth-crop.W))
+	crop.Y = max(0, min(crop.Y, e.config.ScreenHeight-crop.H))
+	return crop
+}
+
+// segmentCropPath 计算一段中每一帧的裁剪区域
+func (e *GPUExporter) segmentCropPath(startFrame, endFrame int) []cameraCrop {
+	crops := make([]cameraCrop, 0, endFrame-startFrame)
+	for i := startFrame; i < endFrame; i++ {
+		crops = append(crops, e.frameCrop(e.cameraFrames[i]))
+	}
+	return crops
+}
+
+// cropCommands 生成逐帧调整 crop@camera 的 sendcmd 命令（只在裁剪区域变化时发送）
+// 时间相对于这一段的第一帧
+func (e *GPUExporter) cropCommands(startFrame int, crops []cameraCrop) string {
+	var b strings.Builder
+	startMs := e.cameraFrames[startFrame].Timestamp
+	for i, crop := range crops {
+		if i > 0 && crop == crops[i-1] {
+			continue
+		}
+		fmt.Fprintf(&b, "%.3f crop@camera w %d, crop@camera h %d, crop@camera x %d, crop@camera y %d;\n",
+			float64(e.cameraFrames[startFrame+i].Timestamp-startMs)/1000, crop.W, crop.H, crop.X, crop.Y)
+	}
+	return b.String()
+}
+
+// setSegmentProgress 更新某一段的进度
+func (e *GPUExporter) setSegmentProgress(index, frames int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if index < len(e.segmentDone) {
+		e.segmentDone[index] = frames
+	}
+}
+
+// concatenateSegments 合并视频段
+func (e *GPUExporter) concatenateSegments(ctx context.Context, ffmpegPath string, segments []string) error {
+	if len(segments) == 0 {
+		return errors.New("没有可合并的视频段")
+	}
+
+	// 创建合并列表文件
+	listPath := filepath.Join(filepath.Dir(segments[0]), "concat_list.txt")
+	listContent := ""
+	for _, seg := range segments {
+		listContent += fmt.Sprintf("file '%s'\n", filepath.Base(seg))
+	}
+
+	if err := os.WriteFile(listPath, []byte(listContent), 0644); err != nil {
+		return err
+	}
+
+	// 使用 concat demuxer 合并
+	command := ffmpeg.NewCommand().Overwrite()
+	command.Input(listPath, ffmpeg.Opt("safe", 0)).Format("concat")
+	command.Output(e.config.OutputPath).Codec("copy") // 直接复制，不重新编码
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+
+	process := ffmpeg.NewProcess(ffmpegPath, args, ffmpeg.ProcessOptions{Name: "合并视频段"})
+	return process.Run(ctx)
+}
+
+// abs 浮点数绝对值
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
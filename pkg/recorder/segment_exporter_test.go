@@ -0,0 +1,201 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSegment 写入段文件并记录到检查点
+func writeSegment(t *testing.T, session *Session, cp *ExportCheckpoint, seg exportSegment, content string) {
+	t.Helper()
+	if err := os.WriteFile(seg.Path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := &GPUExporter{}
+	if err := e.recordSegment(session, cp, seg); err != nil {
+		t.Fatalf("recordSegment(%d): %v", seg.Index, err)
+	}
+}
+
+func TestSegmentCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	segmentDir := filepath.Join(dir, "export_segments", "out")
+	if err := os.MkdirAll(segmentDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.mp4")
+
+	session, err := readSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := session.BeginExport(output, "params", segmentDir)
+	segments := make([]exportSegment, 3)
+	for i := range segments {
+		segments[i] = exportSegment{
+			Index: i, StartFrame: i * 300, EndFrame: (i + 1) * 300,
+			Hash: fmt.Sprintf("hash-%d", i),
+			Path: filepath.Join(segmentDir, fmt.Sprintf("segment_%04d.mp4", i)),
+		}
+	}
+	// 第三段编码中途被中断：文件写了一半但没有记录到检查点
+	writeSegment(t, session, cp, segments[0], "segment 0")
+	writeSegment(t, session, cp, segments[1], "segment 1")
+	if err := os.WriteFile(segments[2].Path, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新启动后从磁盘读取检查点，已完成的段可以复用，中断的段需要重新编码
+	resumed, err := readSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp = resumed.BeginExport(output, "params", segmentDir)
+	for i, want := range []bool{true, true, false} {
+		done, ok := resumed.FindSegment(cp, segments[i].Hash)
+		if ok != want {
+			t.Errorf("FindSegment(段 %d) = %v, want %v", i, ok, want)
+		}
+		if ok && (done.Path != segments[i].Path || done.StartFrame != segments[i].StartFrame) {
+			t.Errorf("段 %d 检查点 = %+v", i, done)
+		}
+	}
+
+	// 段文件内容被改动（大小不变）后校验和不匹配，不能复用
+	if err := os.WriteFile(segments[1].Path, []byte("segment X"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resumed.FindSegment(cp, segments[1].Hash); ok {
+		t.Error("校验和不匹配的段不应复用")
+	}
+	// 段文件丢失同样需要重新编码
+	os.Remove(segments[0].Path)
+	if _, ok := resumed.FindSegment(cp, segments[0].Hash); ok {
+		t.Error("文件丢失的段不应复用")
+	}
+
+	// 导出完成后清空段记录
+	if err := resumed.CompleteExport(cp); err != nil {
+		t.Fatal(err)
+	}
+	completed, err := readSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp := completed.Exports[output]; cp == nil || !cp.Completed || len(cp.Segments) != 0 {
+		t.Errorf("完成后的检查点 = %+v", cp)
+	}
+}
+
+// newSegmentTestExporter 创建不依赖 FFmpeg 的分段导出器（读取不到关键帧时使用固定分段）
+func newSegmentTestExporter(t *testing.T, frames int) *GPUExporter {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv(ffmpeg.EnvFFmpegPath, "")
+
+	videoPath := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(videoPath, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewGPUExporter(ffmpeg.NewFFmpegManager(context.Background()))
+	e.config = DefaultExportConfig()
+	e.config.VideoPath = videoPath
+	e.config.ScreenWidth, e.config.ScreenHeight = 1920, 1080
+	e.config.SegmentFrames = 300
+	for i := range frames {
+		e.cameraFrames = append(e.cameraFrames, CameraFrame{Timestamp: int64(i) * 33, X: 960, Y: 540, Zoom: 1})
+	}
+	return e
+}
+
+func TestSegmentHashInvalidation(t *testing.T) {
+	e := newSegmentTestExporter(t, 900)
+	segmentDir := t.TempDir()
+
+	paramsHash, err := e.exportParamsHash("libx264", "medium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := e.planSegments(segmentDir, paramsHash)
+	if len(before) != 3 {
+		t.Fatalf("段数 = %d, want 3", len(before))
+	}
+
+	// 只改动第二段的相机路径时只有第二段失效
+	for i := 300; i < 600; i++ {
+		e.cameraFrames[i].Zoom = 1.5
+	}
+	after := e.planSegments(segmentDir, paramsHash)
+	for i, want := range []bool{true, false, true} {
+		if same := before[i].Hash == after[i].Hash; same != want {
+			t.Errorf("相机路径变化后段 %d 哈希不变 = %v, want %v", i, same, want)
+		}
+	}
+
+	// 影响所有段的导出参数变化时全部失效
+	baseline := e.config
+	for _, tt := range []struct {
+		name   string
+		codec  string
+		change func(*ExportConfig)
+	}{
+		{"编码器", "h264_nvenc", func(*ExportConfig) {}},
+		{"帧率", "libx264", func(c *ExportConfig) { c.FPS = 60 }},
+		{"分辨率", "libx264", func(c *ExportConfig) { c.ScreenWidth = 2560 }},
+		{"光标大小", "libx264", func(c *ExportConfig) { c.CursorSize = 48 }},
+		{"源视频", "libx264", func(c *ExportConfig) {
+			// 重新录制：大小和修改时间都变化
+			if err := os.WriteFile(c.VideoPath, []byte("re-recorded"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	} {
+		e.config = baseline
+		tt.change(&e.config)
+		changed, err := e.exportParamsHash(tt.codec, "medium")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed == paramsHash {
+			t.Errorf("%s变化后导出参数哈希不变", tt.name)
+		}
+		for i, seg := range e.planSegments(segmentDir, changed) {
+			if seg.Hash == after[i].Hash {
+				t.Errorf("%s变化后段 %d 仍然有效", tt.name, i)
+			}
+		}
+	}
+}
+
+func TestPruneSegmentsRemovesInvalidated(t *testing.T) {
+	dir := t.TempDir()
+	session, err := readSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := session.BeginExport(filepath.Join(dir, "out.mp4"), "params", dir)
+	keep := exportSegment{Index: 0, Hash: "keep", Path: filepath.Join(dir, "keep.mp4")}
+	stale := exportSegment{Index: 1, Hash: "stale", Path: filepath.Join(dir, "stale.mp4")}
+	writeSegment(t, session, cp, keep, "keep")
+	writeSegment(t, session, cp, stale, "stale")
+
+	// 失效的段记录和文件都被删除，有效的段保留
+	if err := session.PruneSegments(cp, map[string]bool{"keep": true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale.Path); !os.IsNotExist(err) {
+		t.Errorf("失效的段文件未删除: %v", err)
+	}
+	if _, ok := session.FindSegment(cp, "keep"); !ok {
+		t.Error("有效的段应保留")
+	}
+	if _, ok := session.FindSegment(cp, "stale"); ok {
+		t.Error("失效的段记录应删除")
+	}
+}
@@ -0,0 +1,341 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SessionFileName 会话清单文件名（位于录制目录）
+const SessionFileName = "session.json"
+
+// sessionVersion 会话清单格式版本
+const sessionVersion = 1
+
+// Session 录制会话清单
+// 与录制的视频、鼠标数据放在同一目录，记录导出检查点等跨次运行需要保留的状态
+type Session struct {
+	Version   int                          `json:"version"`
+	VideoPath string                       `json:"videoPath,omitempty"`
+	Exports   map[string]*ExportCheckpoint `json:"exports,omitempty"` // 按输出路径索引
+	// 录制与导出产物的 ffprobe 校验报告，按文件路径索引
+	Verifications map[string]*ffmpeg.ProbeReport `json:"verifications,omitempty"`
+	// 采集流的开始时间和相对视频的偏移，按文件路径索引（见 avsync.go）
+	Streams map[string]*StreamTiming `json:"streams,omitempty"`
+	// 字幕稿，按视频路径索引（见 transcriber.go）
+	Transcripts map[string]*Transcript `json:"transcripts,omitempty"`
+	// 自动录制触发器的配置和最终状态，按录制文件路径索引（见 triggers.go）
+	Triggers byPath[[]TriggerStatus] `json:"triggers,omitempty"`
+	// 录制时添加的章节标记，按录制文件路径索引，各自按时间排序（见 markers.go）
+	Markers   byPath[[]Marker] `json:"markers,omitempty"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// byPath 按录制文件路径索引的会话数据
+// 同一目录下的多个录制共用一个 session.json，录制各自的数据必须按路径区分。
+// 早期版本把这类数据存为目录级的列表，无法判断属于哪个录制，读取时丢弃
+type byPath[T any] map[string]T
+
+// UnmarshalJSON 读取按路径索引的数据，忽略早期版本的列表格式
+func (m *byPath[T]) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		*m = nil
+		return nil
+	}
+	var values map[string]T
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*m = values
+	return nil
+}
+
+// ExportCheckpoint 分段导出的检查点
+type ExportCheckpoint struct {
+	OutputPath string              `json:"outputPath"`
+	ParamsHash string              `json:"paramsHash"` // 导出参数哈希（编码器、帧率、分辨率、源视频）
+	SegmentDir string              `json:"segmentDir"`
+	Segments   []SegmentCheckpoint `json:"segments"`
+	Completed  bool                `json:"completed"`
+	UpdatedAt  time.Time           `json:"updatedAt"`
+}
+
+// SegmentCheckpoint 已完成的导出段
+type SegmentCheckpoint struct {
+	Index      int    `json:"index"`
+	StartFrame int    `json:"startFrame"`
+	EndFrame   int    `json:"endFrame"`
+	Hash       string `json:"hash"` // 段参数哈希（导出参数 + 帧范围 + 裁剪区域）
+	Path       string `json:"path"`
+	Checksum   string `json:"checksum"` // 段文件 SHA-256
+	Size       int64  `json:"size"`
+}
+
+// SessionDirFor 返回视频所属的会话目录
+func SessionDirFor(videoPath string) string {
+	return filepath.Dir(videoPath)
+}
+
+// sessions 进程内每个会话目录只有一个 Session 实例
+// 导出、触发器、标记、字幕、音视频同步和校验都写同一个 session.json，
+// 共用一个实例和它的锁，才不会用各自过期的副本互相覆盖
+var sessions = struct {
+	sync.Mutex
+	byDir map[string]*Session
+}{byDir: make(map[string]*Session)}
+
+// sessionKey 会话目录的规范路径
+func sessionKey(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return filepath.Clean(dir)
+}
+
+// LoadSession 获取目录的共享会话清单，首次访问时从磁盘读取，不存在时返回一个新的空会话
+// 读写字段必须持有会话锁（或使用 Session 的方法），修改后用 saveLocked 保存
+func LoadSession(dir string) (*Session, error) {
+	key := sessionKey(dir)
+
+	sessions.Lock()
+	defer sessions.Unlock()
+	if session, ok := sessions.byDir[key]; ok {
+		return session, nil
+	}
+
+	session, err := readSession(dir)
+	if err != nil {
+		return nil, err
+	}
+	sessions.byDir[key] = session
+	return session, nil
+}
+
+// readSession 从磁盘读取会话清单
+func readSession(dir string) (*Session, error) {
+	session := &Session{
+		Version:       sessionVersion,
+		Exports:       make(map[string]*ExportCheckpoint),
+		Verifications: make(map[string]*ffmpeg.ProbeReport),
+		Streams:       make(map[string]*StreamTiming),
+		Transcripts:   make(map[string]*Transcript),
+		Triggers:      make(byPath[[]TriggerStatus]),
+		Markers:       make(byPath[[]Marker]),
+		dir:           dir,
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, SessionFileName))
+	if os.IsNotExist(err) {
+		return session, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取会话清单失败: %w", err)
+	}
+
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("解析会话清单失败: %w", err)
+	}
+	if session.Exports == nil {
+		session.Exports = make(map[string]*ExportCheckpoint)
+	}
+	if session.Verifications == nil {
+		session.Verifications = make(map[string]*ffmpeg.ProbeReport)
+	}
+	if session.Streams == nil {
+		session.Streams = make(map[string]*StreamTiming)
+	}
+	if session.Transcripts == nil {
+		session.Transcripts = make(map[string]*Transcript)
+	}
+	if session.Triggers == nil {
+		session.Triggers = make(byPath[[]TriggerStatus])
+	}
+	if session.Markers == nil {
+		session.Markers = make(byPath[[]Marker])
+	}
+	return session, nil
+}
+
+// Dir 返回会话目录
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Save 保存会话清单（先写临时文件再重命名，避免中途失败损坏清单）
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+// saveLocked 在已持有锁时保存
+func (s *Session) saveLocked() error {
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化会话清单失败: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建会话目录失败: %w", err)
+	}
+
+	path := filepath.Join(s.dir, SessionFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入会话清单失败: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// BeginExport 获取输出路径对应的检查点
+// 参数哈希变化时保留已有段记录，由调用方按段哈希逐段判断是否失效
+func (s *Session) BeginExport(outputPath, paramsHash, segmentDir string) *ExportCheckpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, ok := s.Exports[outputPath]
+	if !ok {
+		cp = &ExportCheckpoint{OutputPath: outputPath}
+		s.Exports[outputPath] = cp
+	}
+	cp.ParamsHash = paramsHash
+	cp.SegmentDir = segmentDir
+	cp.Completed = false
+	return cp
+}
+
+// FindSegment 查找哈希匹配且文件完好的已完成段
+func (s *Session) FindSegment(cp *ExportCheckpoint, hash string) (SegmentCheckpoint, bool) {
+	s.mu.Lock()
+	var found *SegmentCheckpoint
+	for i := range cp.Segments {
+		if cp.Segments[i].Hash == hash {
+			seg := cp.Segments[i]
+			found = &seg
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		return SegmentCheckpoint{}, false
+	}
+
+	// 校验文件仍然存在且内容未被改动
+	info, err := os.Stat(found.Path)
+	if err != nil || info.Size() != found.Size {
+		return SegmentCheckpoint{}, false
+	}
+	checksum, err := ffmpeg.FileChecksum(found.Path)
+	if err != nil || checksum != found.Checksum {
+		return SegmentCheckpoint{}, false
+	}
+	return *found, true
+}
+
+// RecordSegment 记录一个已完成的段并立即保存
+func (s *Session) RecordSegment(cp *ExportCheckpoint, seg SegmentCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replaced := false
+	for i := range cp.Segments {
+		if cp.Segments[i].Hash == seg.Hash {
+			cp.Segments[i] = seg
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		cp.Segments = append(cp.Segments, seg)
+	}
+	cp.UpdatedAt = time.Now()
+	return s.saveLocked()
+}
+
+// PruneSegments 删除不在 keep 中的段记录及其文件（参数变化后失效的段）
+func (s *Session) PruneSegments(cp *ExportCheckpoint, keep map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := cp.Segments[:0]
+	for _, seg := range cp.Segments {
+		if keep[seg.Hash] {
+			kept = append(kept, seg)
+			continue
+		}
+		os.Remove(seg.Path)
+	}
+	cp.Segments = kept
+	return s.saveLocked()
+}
+
+// CompleteExport 标记导出完成，清空段记录
+func (s *Session) CompleteExport(cp *ExportCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp.Segments = nil
+	cp.Completed = true
+	cp.UpdatedAt = time.Now()
+	return s.saveLocked()
+}
+
+// RecordVerification 记录输出文件的校验报告并立即保存
+func (s *Session) RecordVerification(report *ffmpeg.ProbeReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Verifications[report.Path] = report
+	return s.saveLocked()
+}
+
+// VerifyOutput 使用 ffprobe 校验输出文件，并把报告写入 sessionVideoPath 所属的会话
+// 校验失败时仍返回报告（如果有），错误可用 errors.Is 判断类型；
+// 没有 ffprobe 时返回 ffmpeg.ErrProbeUnavailable，调用方应视为跳过校验而不是失败
+func VerifyOutput(ffmpegManager *ffmpeg.FFmpegManager, sessionVideoPath, outputPath string, expect ffmpeg.ProbeExpectation) (*ffmpeg.ProbeReport, error) {
+	report, err := ffmpegManager.ProbeOutput(outputPath, expect)
+	if errors.Is(err, ffmpeg.ErrProbeUnavailable) {
+		fmt.Printf("警告: %v，跳过输出校验: %s\n", err, outputPath)
+		return nil, err
+	}
+	if report == nil {
+		return nil, err
+	}
+
+	session, loadErr := LoadSession(SessionDirFor(sessionVideoPath))
+	if loadErr == nil {
+		loadErr = session.RecordVerification(report)
+	}
+	if loadErr != nil {
+		fmt.Printf("警告: 保存校验报告失败: %v\n", loadErr)
+	}
+
+	if err != nil {
+		fmt.Printf("✗ 输出校验失败: %s: %v\n", outputPath, err)
+	} else {
+		fmt.Printf("✓ 输出校验通过: %s (%.2f 秒, %dx%d @ %.2f fps)\n", outputPath, report.Duration, report.Width, report.Height, report.FPS)
+	}
+	return report, err
+}
+
+// hashParams 计算参数的短哈希
+func hashParams(values ...interface{}) string {
+	hasher := sha256.New()
+	for _, v := range values {
+		fmt.Fprintf(hasher, "%v|", v)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:16]
+}
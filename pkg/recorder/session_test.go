@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionTriggersKeyedByRecording(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.mp4")
+	second := filepath.Join(dir, "second.mp4")
+
+	session, err := LoadSession(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	countdown := TriggerStatus{TriggerConfig: TriggerConfig{ID: "a", Kind: "countdown", Seconds: 3}}
+	process := TriggerStatus{TriggerConfig: TriggerConfig{ID: "b", Kind: "process_launch", Process: "obs"}}
+	if err := session.RecordTriggers(first, []TriggerStatus{countdown}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RecordTriggers(second, []TriggerStatus{process}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一目录的第二个录制不覆盖第一个录制的触发器
+	for path, want := range map[string]string{first: "a", second: "b"} {
+		configs, err := LoadTriggers(path)
+		if err != nil {
+			t.Fatalf("LoadTriggers(%s): %v", filepath.Base(path), err)
+		}
+		if len(configs) != 1 || configs[0].ID != want {
+			t.Errorf("LoadTriggers(%s) = %+v, want ID %s", filepath.Base(path), configs, want)
+		}
+	}
+	if _, err := LoadTriggers(filepath.Join(dir, "other.mp4")); err == nil {
+		t.Error("没有触发器的录制应返回错误")
+	}
+}
+
+func TestSessionMarkersKeyedByRecording(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.mp4")
+	second := filepath.Join(dir, "second.mp4")
+
+	if err := SaveMarkers(first, []Marker{{ID: "marker-2", Time: 8}, {ID: "marker-1", Time: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveMarkers(second, []Marker{{ID: "marker-1", Time: 5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二个录制的标记不覆盖第一个录制的标记，各自按时间排序
+	markers, err := LoadMarkers(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(markers) != 2 || markers[0].Time != 3 || markers[1].Time != 8 {
+		t.Errorf("LoadMarkers(first) = %+v", markers)
+	}
+	markers, err = LoadMarkers(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(markers) != 1 || markers[0].Time != 5 {
+		t.Errorf("LoadMarkers(second) = %+v", markers)
+	}
+	if markers, _ := LoadMarkers(filepath.Join(dir, "other.mp4")); len(markers) != 0 {
+		t.Errorf("没有标记的录制应返回空列表: %+v", markers)
+	}
+}
+
+func TestSessionIgnoresLegacyLists(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"version":1,"triggers":[{"id":"a","kind":"countdown","state":"fired","fired":1}],` +
+		`"markers":[{"id":"marker-1","time":3,"label":"章节 1"}],` +
+		`"streams":{"x.mp4":{"path":"x.mp4","kind":"video","start":1,"measured":true,"offset":0}}}`
+	if err := os.WriteFile(filepath.Join(dir, SessionFileName), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 早期的目录级列表无法判断属于哪个录制，丢弃后其余数据照常读取
+	session, err := readSession(dir)
+	if err != nil {
+		t.Fatalf("readSession: %v", err)
+	}
+	if len(session.Triggers) != 0 {
+		t.Errorf("Triggers = %+v, want empty", session.Triggers)
+	}
+	if len(session.Markers) != 0 {
+		t.Errorf("Markers = %+v, want empty", session.Markers)
+	}
+	if _, ok := session.Streams["x.mp4"]; !ok {
+		t.Error("其他会话数据应正常读取")
+	}
+	if err := session.RecordTriggers("x.mp4", nil); err != nil {
+		t.Fatalf("RecordTriggers: %v", err)
+	}
+	session.Markers["x.mp4"] = []Marker{{ID: "marker-1"}}
+}
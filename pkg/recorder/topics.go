@@ -0,0 +1,59 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/events"
+	"SmoothScreen/pkg/ffmpeg"
+)
+
+// 录制状态
+const (
+	RecorderStarted            = "started"
+	RecorderStopped            = "stopped"
+	RecorderPaused             = "paused"
+	RecorderResumed            = "resumed"
+	RecorderAutoStopped        = "auto-stopped"
+	RecorderVerificationFailed = "verification-failed"
+)
+
+// RecorderStateEvent 录制状态变化事件
+type RecorderStateEvent struct {
+	State string                 `json:"state"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// HotkeyActionEvent 全局快捷键执行的动作及结果
+type HotkeyActionEvent struct {
+	Action string `json:"action"`
+	Keys   string `json:"keys"`
+	Error  string `json:"error,omitempty"` // 动作执行失败时的错误
+}
+
+// 事件总线上的录制相关主题
+var (
+	// RecorderStateTopic 录制开始、停止、暂停、恢复等状态变化
+	RecorderStateTopic = events.NewTopic[RecorderStateEvent]("recorder-state")
+	// ExportJobTopic 导出任务的状态和进度
+	ExportJobTopic = events.NewTopic[ExportJobEvent]("export-job")
+	// AudioLevelTopic 实时音频电平（订阅者可按需限速合并）
+	AudioLevelTopic = events.NewTopic[AudioLevel]("audio-level")
+	// AudioSignalTopic 音频设备无信号警告和恢复
+	AudioSignalTopic = events.NewTopic[AudioSignalEvent]("audio-signal")
+	// DiskSpaceTopic 输出磁盘空间警告和临界事件
+	DiskSpaceTopic = events.NewTopic[DiskSpaceEvent]("disk-space")
+	// MarkerAddedTopic 录制中添加的章节标记
+	MarkerAddedTopic = events.NewTopic[Marker]("marker-added")
+	// TriggerStatusTopic 录制触发器的状态变化
+	TriggerStatusTopic = events.NewTopic[TriggerEvent]("trigger-status")
+	// HotkeyTopic 全局快捷键触发的动作
+	HotkeyTopic = events.NewTopic[HotkeyActionEvent]("hotkey")
+	// ReplayStartedTopic 回放缓冲开始（负载为使用的配置）
+	ReplayStartedTopic = events.NewTopic[ReplayConfig]("replay-started")
+	// ReplayStoppedTopic 回放缓冲停止并丢弃缓冲的内容（负载为停止的缓冲的配置）
+	ReplayStoppedTopic = events.NewTopic[ReplayConfig]("replay-stopped")
+	// ReplaySavedTopic 回放缓冲保存为录制会话
+	ReplaySavedTopic = events.NewTopic[ReplayBundle]("replay-saved")
+	// FFmpegProcessTopic FFmpeg 进程的启动、退出等生命周期事件
+	FFmpegProcessTopic = events.NewTopic[ffmpeg.ProcessEvent]("ffmpeg-process")
+	// FFmpegErrorTopic FFmpeg 不可用等错误（负载为错误说明）
+	FFmpegErrorTopic = events.NewTopic[string]("ffmpeg-error")
+)
@@ -0,0 +1,187 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TranscriptSegment 一段带时间的识别文本
+type TranscriptSegment struct {
+	Start float64 `json:"start"` // 开始时间（秒）
+	End   float64 `json:"end"`   // 结束时间（秒）
+	Text  string  `json:"text"`
+}
+
+// TranscribeOptions 识别参数
+type TranscribeOptions struct {
+	Language string // 语言代码（如 "zh"、"en"），空字符串表示自动检测
+}
+
+// Transcriber 语音识别器
+// 输入音频文件，返回以音频开头为 0 点的分段文本
+type Transcriber interface {
+	// Name 返回识别器名称
+	Name() string
+	// Transcribe 识别音频文件
+	Transcribe(ctx context.Context, audioPath string, options TranscribeOptions) ([]TranscriptSegment, error)
+}
+
+// Transcript 录制的字幕稿，保存在会话中，可编辑后重新导出
+// Segments 的时间已经换算到视频时间轴
+type Transcript struct {
+	VideoPath string              `json:"videoPath"`
+	AudioPath string              `json:"audioPath"`
+	Offset    float64             `json:"offset"` // 识别时应用的音频相对视频的偏移（秒）
+	Language  string              `json:"language,omitempty"`
+	Engine    string              `json:"engine"`
+	Edited    bool                `json:"edited"` // 用户修改过
+	Segments  []TranscriptSegment `json:"segments"`
+	CreatedAt time.Time           `json:"createdAt"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+}
+
+// SaveTranscript 保存字幕稿并立即写入会话
+func (s *Session) SaveTranscript(transcript *Transcript) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transcript.UpdatedAt = time.Now()
+	s.Transcripts[transcript.VideoPath] = transcript
+	return s.saveLocked()
+}
+
+// GetTranscript 获取视频的字幕稿
+func (s *Session) GetTranscript(videoPath string) (*Transcript, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transcript, ok := s.Transcripts[videoPath]
+	return transcript, ok
+}
+
+// speechTrackKinds 选择识别音频的优先级：麦克风 > 合并音频 > 系统音频
+var speechTrackKinds = []string{StreamMicAudio, StreamAudio, StreamSystemAudio}
+
+// SessionSpeechTrack 从会话中找到与视频一起录制的麦克风音频
+// 没有单独的麦克风文件时退回到合并音频或系统音频
+func SessionSpeechTrack(videoPath string) (AudioTrack, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return AudioTrack{}, err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for _, kind := range speechTrackKinds {
+		for _, timing := range session.Streams {
+			if timing.Kind == kind && timing.Reference == videoPath && fileExists(timing.Path) {
+				return AudioTrack{Path: timing.Path, Kind: timing.Kind, Offset: timing.Offset}, nil
+			}
+		}
+	}
+	return AudioTrack{}, fmt.Errorf("会话中没有与 %s 一起录制的音频", videoPath)
+}
+
+// TranscribeRecording 识别录制音频并把字幕稿保存到视频的会话
+// track.Path 为空时使用会话中的麦克风音频
+func TranscribeRecording(ctx context.Context, transcriber Transcriber, videoPath string, track AudioTrack, options TranscribeOptions) (*Transcript, error) {
+	if track.Path == "" {
+		var err error
+		track, err = SessionSpeechTrack(videoPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("正在识别字幕 (%s): %s\n", transcriber.Name(), track.Path)
+	segments, err := transcriber.Transcribe(ctx, track.Path, options)
+	if err != nil {
+		return nil, fmt.Errorf("语音识别失败: %w", err)
+	}
+
+	now := time.Now()
+	transcript := &Transcript{
+		VideoPath: videoPath,
+		AudioPath: track.Path,
+		Offset:    track.Offset,
+		Language:  options.Language,
+		Engine:    transcriber.Name(),
+		Segments:  shiftSegments(segments, track.Offset),
+		CreatedAt: now,
+	}
+
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err == nil {
+		err = session.SaveTranscript(transcript)
+	}
+	if err != nil {
+		return transcript, fmt.Errorf("保存字幕稿失败: %w", err)
+	}
+
+	fmt.Printf("✓ 字幕识别完成: %d 段\n", len(transcript.Segments))
+	return transcript, nil
+}
+
+// UpdateTranscript 用编辑后的分段替换会话中的字幕稿
+func UpdateTranscript(videoPath string, segments []TranscriptSegment) (*Transcript, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, ok := session.GetTranscript(videoPath)
+	if !ok {
+		transcript = &Transcript{VideoPath: videoPath, Engine: "manual", CreatedAt: time.Now()}
+	}
+	transcript.Segments = normalizeSegments(segments)
+	transcript.Edited = true
+
+	if err := session.SaveTranscript(transcript); err != nil {
+		return nil, fmt.Errorf("保存字幕稿失败: %w", err)
+	}
+	return transcript, nil
+}
+
+// LoadTranscript 读取视频会话中的字幕稿
+func LoadTranscript(videoPath string) (*Transcript, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return nil, err
+	}
+	transcript, ok := session.GetTranscript(videoPath)
+	if !ok {
+		return nil, fmt.Errorf("%s 还没有字幕稿", videoPath)
+	}
+	return transcript, nil
+}
+
+// shiftSegments 把音频时间换算到视频时间轴，丢弃视频开始前的部分
+func shiftSegments(segments []TranscriptSegment, offset float64) []TranscriptSegment {
+	shifted := make([]TranscriptSegment, 0, len(segments))
+	for _, segment := range segments {
+		segment.Start += offset
+		segment.End += offset
+		shifted = append(shifted, segment)
+	}
+	return normalizeSegments(shifted)
+}
+
+// normalizeSegments 清理分段：去掉空白文本和视频开始前的部分，保证时间不为负且不倒置
+func normalizeSegments(segments []TranscriptSegment) []TranscriptSegment {
+	normalized := []TranscriptSegment{}
+	for _, segment := range segments {
+		segment.Text = strings.TrimSpace(segment.Text)
+		if segment.Text == "" || segment.End <= 0 {
+			continue
+		}
+		if segment.Start < 0 {
+			segment.Start = 0
+		}
+		if segment.End < segment.Start {
+			segment.End = segment.Start
+		}
+		normalized = append(normalized, segment)
+	}
+	return normalized
+}
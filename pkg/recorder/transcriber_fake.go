@@ -0,0 +1,31 @@
+package recorder
+
+import (
+	"context"
+)
+
+// FakeTranscriber 返回固定结果的识别器
+// 用于测试和没有安装 whisper.cpp 时的界面调试
+type FakeTranscriber struct {
+	Segments []TranscriptSegment
+	Err      error
+}
+
+// Name 返回识别器名称
+func (f *FakeTranscriber) Name() string {
+	return "fake"
+}
+
+// Transcribe 返回预设的分段或错误
+func (f *FakeTranscriber) Transcribe(ctx context.Context, audioPath string, options TranscribeOptions) ([]TranscriptSegment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	segments := make([]TranscriptSegment, len(f.Segments))
+	copy(segments, f.Segments)
+	return segments, nil
+}
@@ -0,0 +1,162 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// whisper.cpp 相关环境变量
+const (
+	EnvWhisperPath  = "SILKREC_WHISPER"       // whisper.cpp 可执行文件路径
+	EnvWhisperModel = "SILKREC_WHISPER_MODEL" // ggml 模型文件路径
+)
+
+// whisperBinaryNames PATH 中查找的 whisper.cpp 可执行文件名
+// 旧版本的可执行文件名为通用的 main，不在 PATH 中查找，需要通过 SILKREC_WHISPER 指定路径
+var whisperBinaryNames = []string{"whisper-cli", "whisper-cpp", "whisper"}
+
+// whisperSampleRate whisper.cpp 要求 16 kHz 单声道 WAV 输入
+const whisperSampleRate = 16000
+
+// WhisperTranscriber 调用本地安装的 whisper.cpp 命令行工具识别语音
+type WhisperTranscriber struct {
+	ffmpegPath string
+	binaryPath string
+	modelPath  string
+	threads    int
+}
+
+// whisperOutput whisper.cpp -oj 输出的 JSON
+type whisperOutput struct {
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // 毫秒
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// NewWhisperTranscriber 创建 whisper.cpp 识别器
+// binaryPath/modelPath 为空时依次使用环境变量和 PATH 查找
+func NewWhisperTranscriber(ffmpegPath, binaryPath, modelPath string) (*WhisperTranscriber, error) {
+	if binaryPath == "" {
+		binaryPath = os.Getenv(EnvWhisperPath)
+	}
+	if binaryPath == "" {
+		for _, name := range whisperBinaryNames {
+			if path, err := exec.LookPath(name); err == nil {
+				binaryPath = path
+				break
+			}
+		}
+	}
+	if binaryPath == "" {
+		return nil, fmt.Errorf("未找到 whisper.cpp，请安装到 PATH 或设置 %s 环境变量", EnvWhisperPath)
+	}
+
+	if modelPath == "" {
+		modelPath = os.Getenv(EnvWhisperModel)
+	}
+	if modelPath == "" {
+		return nil, fmt.Errorf("未指定 whisper 模型，请设置 %s 环境变量", EnvWhisperModel)
+	}
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, fmt.Errorf("whisper 模型不可用: %w", err)
+	}
+
+	return &WhisperTranscriber{
+		ffmpegPath: ffmpegPath,
+		binaryPath: binaryPath,
+		modelPath:  modelPath,
+		threads:    runtime.NumCPU(),
+	}, nil
+}
+
+// Name 返回识别器名称
+func (w *WhisperTranscriber) Name() string {
+	return "whisper.cpp"
+}
+
+// Transcribe 先用 FFmpeg 转换为 16 kHz 单声道 WAV，再调用 whisper.cpp 输出 JSON
+func (w *WhisperTranscriber) Transcribe(ctx context.Context, audioPath string, options TranscribeOptions) ([]TranscriptSegment, error) {
+	workDir, err := os.MkdirTemp("", "silkrec-whisper-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	wavPath := filepath.Join(workDir, "input.wav")
+	command := ffmpeg.NewCommand().Overwrite()
+	input := command.Input(audioPath)
+	command.Output(wavPath).
+		Map(input.Audio()).
+		AudioCodec("pcm_s16le").
+		With(ffmpeg.Opt("ar", whisperSampleRate), ffmpeg.Opt("ac", 1))
+	args, err := command.Args()
+	if err != nil {
+		return nil, err
+	}
+	process := ffmpeg.NewProcess(w.ffmpegPath, args, ffmpeg.ProcessOptions{Name: "字幕音频转换", Quiet: true})
+	if err := process.Run(ctx); err != nil {
+		return nil, fmt.Errorf("转换音频失败: %w", err)
+	}
+
+	language := options.Language
+	if language == "" {
+		language = "auto"
+	}
+	outputBase := filepath.Join(workDir, "transcript")
+	cmd := exec.CommandContext(ctx, w.binaryPath,
+		"-m", w.modelPath,
+		"-f", wavPath,
+		"-l", language,
+		"-t", fmt.Sprint(w.threads),
+		"-oj",
+		"-of", outputBase,
+		"-np",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("运行 whisper.cpp 失败: %w: %s", err, lastLines(string(output), 5))
+	}
+
+	data, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("读取 whisper.cpp 输出失败: %w", err)
+	}
+	return parseWhisperJSON(data)
+}
+
+// parseWhisperJSON 解析 whisper.cpp 的 JSON 输出
+func parseWhisperJSON(data []byte) ([]TranscriptSegment, error) {
+	var output whisperOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("解析 whisper.cpp 输出失败: %w", err)
+	}
+
+	segments := make([]TranscriptSegment, 0, len(output.Transcription))
+	for _, item := range output.Transcription {
+		segments = append(segments, TranscriptSegment{
+			Start: float64(item.Offsets.From) / 1000,
+			End:   float64(item.Offsets.To) / 1000,
+			Text:  strings.TrimSpace(item.Text),
+		})
+	}
+	return segments, nil
+}
+
+// lastLines 返回文本的最后 n 行（用于错误信息）
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
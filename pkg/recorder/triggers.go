@@ -0,0 +1,559 @@
+package recorder
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 触发器类型
+const (
+	TriggerAtTime       = "at_time"        // 到达指定时间（开始或停止）
+	TriggerCountdown    = "countdown"      // 开始前倒计时
+	TriggerMaxDuration  = "max_duration"   // 录制时长达到上限后停止
+	TriggerMaxSize      = "max_size"       // 输出文件达到大小上限后停止
+	TriggerProcessStart = "process_launch" // 指定进程启动时（开始或停止）
+	TriggerProcessExit  = "process_exit"   // 指定进程退出时（开始或停止）
+	TriggerIdle         = "idle"           // 一段时间没有鼠标和键盘输入后停止
+)
+
+// 触发器动作
+const (
+	TriggerActionStart = "start"
+	TriggerActionStop  = "stop"
+)
+
+// 触发器状态
+const (
+	TriggerStateArmed    = "armed"    // 等待条件满足
+	TriggerStateCounting = "counting" // 倒计时中
+	TriggerStateFired    = "fired"    // 已触发（一次性触发器不再生效）
+	TriggerStateError    = "error"    // 触发的动作失败
+)
+
+const (
+	triggerPollInterval = 250 * time.Millisecond
+	processPollInterval = time.Second
+)
+
+// TriggerConfig 触发器配置（可序列化到会话清单）
+type TriggerConfig struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Action  string    `json:"action"`            // start 或 stop（at_time、process_* 可选，默认 at_time/process_launch 开始、process_exit 停止）
+	At      time.Time `json:"at,omitzero"`       // at_time
+	Seconds float64   `json:"seconds,omitempty"` // countdown、max_duration、idle
+	MaxMB   int64     `json:"maxMB,omitempty"`   // max_size
+	Process string    `json:"process,omitempty"` // process_*：进程名（不区分大小写，可省略 .exe）
+}
+
+// TriggerStatus 触发器状态
+type TriggerStatus struct {
+	TriggerConfig
+	State     string    `json:"state"`
+	Remaining float64   `json:"remaining,omitempty"` // 倒计时、时长、空闲触发器的剩余秒数
+	Fired     int       `json:"fired"`               // 触发次数
+	FiredAt   time.Time `json:"firedAt,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// TriggerEvent 触发器状态变化事件
+type TriggerEvent struct {
+	Type    string        `json:"type"` // armed、countdown、fired、error、disarmed
+	Trigger TriggerStatus `json:"trigger"`
+	Message string        `json:"message,omitempty"`
+	Time    time.Time     `json:"time"`
+}
+
+// TriggerActions 触发器执行的动作（由调用方决定如何开始和停止录制，如同时录制音频和键盘）
+type TriggerActions struct {
+	Start func() error
+	Stop  func() error
+}
+
+// normalizeTrigger 校验触发器并补全固定的动作
+func normalizeTrigger(config TriggerConfig, index int) (TriggerConfig, error) {
+	if config.ID == "" {
+		config.ID = fmt.Sprintf("%s-%d", config.Kind, index+1)
+	}
+
+	switch config.Kind {
+	case TriggerAtTime:
+		if config.Action == "" {
+			config.Action = TriggerActionStart
+		}
+		if config.At.IsZero() {
+			return config, fmt.Errorf("触发器 %s 缺少时间", config.ID)
+		}
+	case TriggerCountdown:
+		config.Action = TriggerActionStart
+		if config.Seconds <= 0 {
+			return config, fmt.Errorf("触发器 %s 的倒计时必须大于 0", config.ID)
+		}
+	case TriggerMaxDuration, TriggerIdle:
+		config.Action = TriggerActionStop
+		if config.Seconds <= 0 {
+			return config, fmt.Errorf("触发器 %s 的时长必须大于 0", config.ID)
+		}
+	case TriggerMaxSize:
+		config.Action = TriggerActionStop
+		if config.MaxMB <= 0 {
+			return config, fmt.Errorf("触发器 %s 的大小上限必须大于 0", config.ID)
+		}
+	case TriggerProcessStart, TriggerProcessExit:
+		// 默认进程启动时开始、退出时停止
+		if config.Action == "" && config.Kind == TriggerProcessStart {
+			config.Action = TriggerActionStart
+		} else if config.Action == "" {
+			config.Action = TriggerActionStop
+		}
+		if normalizeProcessName(config.Process) == "" {
+			return config, fmt.Errorf("触发器 %s 缺少进程名", config.ID)
+		}
+	default:
+		return config, fmt.Errorf("未知的触发器类型: %s", config.Kind)
+	}
+
+	if config.Action != TriggerActionStart && config.Action != TriggerActionStop {
+		return config, fmt.Errorf("触发器 %s 的动作必须是 start 或 stop", config.ID)
+	}
+	return config, nil
+}
+
+// TriggerManager 自动录制触发器
+// 每 250ms 检查一次条件：未录制时检查开始触发器，录制中检查停止触发器。
+// 开始触发器触发时若配置了倒计时，先倒计时再开始。一次录制结束后触发器重新进入等待状态，
+// 到点触发器和单独使用的倒计时只触发一次。已在运行的进程不会触发 process_launch。
+type TriggerManager struct {
+	recorder *Recorder
+	actions  TriggerActions
+
+	// 时钟、进程列表和最近输入时间（测试时替换）
+	now         func() time.Time
+	processList func() (map[string]bool, error)
+	lastInput   func() time.Time
+
+	mu              sync.Mutex
+	triggers        []*TriggerStatus
+	countdown       *TriggerStatus // 倒计时触发器（可选）
+	countdownUntil  time.Time      // 倒计时结束时刻（零值表示未在倒计时）
+	wasRecording    bool
+	recordingStart  time.Time
+	processes       map[string]bool // 上一次轮询到的进程
+	lastProcessPoll time.Time
+	eventHandler    func(TriggerEvent)
+	stop            chan struct{}
+	done            chan struct{}
+}
+
+// NewTriggerManager 创建触发器管理器
+func NewTriggerManager(recorder *Recorder, actions TriggerActions) *TriggerManager {
+	return &TriggerManager{
+		recorder:    recorder,
+		actions:     actions,
+		now:         time.Now,
+		processList: listProcesses,
+		lastInput:   recorder.LastInputTime,
+	}
+}
+
+// SetEventHandler 设置状态变化事件处理器
+func (m *TriggerManager) SetEventHandler(handler func(TriggerEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.eventHandler = handler
+}
+
+// Arm 启用一组触发器（替换之前的触发器）
+func (m *TriggerManager) Arm(configs []TriggerConfig) error {
+	triggers, countdown, err := newTriggerStatuses(configs)
+	if err != nil {
+		return err
+	}
+
+	m.Disarm()
+
+	m.mu.Lock()
+	m.resetLocked(triggers, countdown)
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.stop, m.done)
+	m.mu.Unlock()
+
+	fmt.Printf("✓ 已启用 %d 个录制触发器\n", len(triggers))
+	return nil
+}
+
+// newTriggerStatuses 校验触发器配置，返回等待中的触发器和其中的倒计时触发器
+func newTriggerStatuses(configs []TriggerConfig) ([]*TriggerStatus, *TriggerStatus, error) {
+	triggers := make([]*TriggerStatus, 0, len(configs))
+	var countdown *TriggerStatus
+	for i, config := range configs {
+		config, err := normalizeTrigger(config, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		trigger := &TriggerStatus{TriggerConfig: config, State: TriggerStateArmed}
+		if config.Kind == TriggerCountdown {
+			if countdown != nil {
+				return nil, nil, fmt.Errorf("只能配置一个倒计时触发器")
+			}
+			countdown = trigger
+		}
+		triggers = append(triggers, trigger)
+	}
+	if len(triggers) == 0 {
+		return nil, nil, fmt.Errorf("没有触发器")
+	}
+	return triggers, countdown, nil
+}
+
+// resetLocked 替换触发器并重新开始等待（需持有 m.mu）
+func (m *TriggerManager) resetLocked(triggers []*TriggerStatus, countdown *TriggerStatus) {
+	now := m.now()
+	m.triggers = triggers
+	m.countdown = countdown
+	m.countdownUntil = time.Time{}
+	m.wasRecording = m.recorder.IsRecording()
+	m.recordingStart = now
+	m.processes = nil
+	m.lastProcessPoll = time.Time{}
+	if m.needsProcessesLocked() {
+		// 记录启用时已在运行的进程，只响应之后的变化
+		m.processes, _ = m.processList()
+		m.lastProcessPoll = now
+	}
+	for _, trigger := range m.triggers {
+		m.emitLocked("armed", trigger, "")
+	}
+	// 只有倒计时没有其他开始触发器时，启用后立即开始倒计时
+	if countdown != nil && !m.hasStartTriggerLocked() && !m.wasRecording {
+		m.beginCountdownLocked(now)
+	}
+}
+
+// Disarm 停用所有触发器（不影响正在进行的录制）
+func (m *TriggerManager) Disarm() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop = nil
+	m.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	m.mu.Lock()
+	for _, trigger := range m.triggers {
+		m.emitLocked("disarmed", trigger, "")
+	}
+	m.mu.Unlock()
+	fmt.Println("✓ 录制触发器已停用")
+}
+
+// IsArmed 检查触发器是否启用
+func (m *TriggerManager) IsArmed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop != nil
+}
+
+// GetStatus 获取所有触发器的状态
+func (m *TriggerManager) GetStatus() []TriggerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshotLocked()
+}
+
+// snapshotLocked 复制触发器状态（需持有 m.mu）
+func (m *TriggerManager) snapshotLocked() []TriggerStatus {
+	statuses := make([]TriggerStatus, 0, len(m.triggers))
+	for _, trigger := range m.triggers {
+		statuses = append(statuses, *trigger)
+	}
+	return statuses
+}
+
+// run 定期检查触发条件
+func (m *TriggerManager) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(triggerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.evaluate(m.now())
+		}
+	}
+}
+
+// evaluate 检查一次所有触发器，满足条件时执行动作
+func (m *TriggerManager) evaluate(now time.Time) {
+	m.mu.Lock()
+	recording := m.recorder.IsRecording()
+	if recording && !m.wasRecording {
+		m.recordingStart = now
+	}
+	if !recording && m.wasRecording {
+		m.rearmLocked()
+	}
+	m.wasRecording = recording
+
+	launched, exited := m.pollProcessesLocked(now)
+
+	var action string
+	var source *TriggerStatus
+	for _, trigger := range m.triggers {
+		// 到点触发器只触发一次；动作失败的触发器等重新启用后再生效
+		if trigger.State == TriggerStateError || (trigger.State == TriggerStateFired && trigger.Kind == TriggerAtTime) {
+			continue
+		}
+		// 未录制时只检查开始触发器，录制中只检查停止触发器
+		if (trigger.Action == TriggerActionStart) == recording || trigger.Kind == TriggerCountdown {
+			continue
+		}
+		if m.conditionMetLocked(trigger, now, launched, exited) && action == "" {
+			action, source = trigger.Action, trigger
+		}
+	}
+
+	// 开始触发器先进入倒计时
+	if action == TriggerActionStart && m.countdown != nil {
+		m.markFiredLocked(source, now)
+		if m.countdownUntil.IsZero() {
+			m.beginCountdownLocked(now)
+		}
+		action = ""
+	}
+	if !recording && !m.countdownUntil.IsZero() {
+		remaining := m.countdownUntil.Sub(now).Seconds()
+		if remaining <= 0 {
+			m.countdownUntil = time.Time{}
+			action, source = TriggerActionStart, m.countdown
+		} else {
+			// 每秒发送一次倒计时事件
+			previous := math.Ceil(m.countdown.Remaining)
+			m.countdown.Remaining = remaining
+			if math.Ceil(remaining) != previous {
+				m.emitLocked("countdown", m.countdown, fmt.Sprintf("%.0f 秒后开始录制", math.Ceil(remaining)))
+			}
+		}
+	}
+	actions := m.actions
+	m.mu.Unlock()
+
+	if action == "" {
+		return
+	}
+
+	var err error
+	if action == TriggerActionStart {
+		fmt.Printf("触发器 %s: 开始录制\n", source.ID)
+		err = actions.Start()
+	} else {
+		fmt.Printf("触发器 %s: 停止录制\n", source.ID)
+		err = actions.Stop()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		source.State = TriggerStateError
+		source.LastError = err.Error()
+		m.emitLocked("error", source, err.Error())
+		fmt.Printf("✗ 触发器 %s 执行失败: %v\n", source.ID, err)
+		return
+	}
+	now = m.now()
+	m.markFiredLocked(source, now)
+	if action == TriggerActionStart {
+		m.wasRecording = true
+		m.recordingStart = now
+	}
+	m.saveToSessionLocked()
+}
+
+// conditionMetLocked 检查单个触发器的条件并更新剩余时间（需持有 m.mu）
+func (m *TriggerManager) conditionMetLocked(trigger *TriggerStatus, now time.Time, launched, exited map[string]bool) bool {
+	switch trigger.Kind {
+	case TriggerAtTime:
+		trigger.Remaining = trigger.At.Sub(now).Seconds()
+		return !now.Before(trigger.At)
+	case TriggerMaxDuration:
+		elapsed := now.Sub(m.recordingStart).Seconds()
+		trigger.Remaining = trigger.Seconds - elapsed
+		return elapsed >= trigger.Seconds
+	case TriggerMaxSize:
+		info, err := os.Stat(m.recorder.GetOutputPath())
+		return err == nil && info.Size() >= trigger.MaxMB*1024*1024
+	case TriggerIdle:
+		// 录制开始前的空闲时间不计入
+		last := m.lastInput()
+		if last.Before(m.recordingStart) {
+			last = m.recordingStart
+		}
+		idle := now.Sub(last).Seconds()
+		trigger.Remaining = trigger.Seconds - idle
+		return idle >= trigger.Seconds
+	case TriggerProcessStart:
+		return launched[normalizeProcessName(trigger.Process)]
+	case TriggerProcessExit:
+		return exited[normalizeProcessName(trigger.Process)]
+	}
+	return false
+}
+
+// beginCountdownLocked 开始倒计时（需持有 m.mu）
+func (m *TriggerManager) beginCountdownLocked(now time.Time) {
+	m.countdownUntil = now.Add(time.Duration(m.countdown.Seconds * float64(time.Second)))
+	m.countdown.State = TriggerStateCounting
+	m.countdown.Remaining = m.countdown.Seconds
+	m.emitLocked("countdown", m.countdown, fmt.Sprintf("%.0f 秒后开始录制", math.Ceil(m.countdown.Seconds)))
+}
+
+// markFiredLocked 标记触发器已触发（需持有 m.mu）
+func (m *TriggerManager) markFiredLocked(trigger *TriggerStatus, now time.Time) {
+	trigger.State = TriggerStateFired
+	trigger.Fired++
+	trigger.FiredAt = now
+	trigger.Remaining = 0
+	trigger.LastError = ""
+	m.emitLocked("fired", trigger, "")
+}
+
+// rearmLocked 一次录制结束后重新等待（到点触发器和单独的倒计时除外）（需持有 m.mu）
+func (m *TriggerManager) rearmLocked() {
+	m.saveToSessionLocked()
+	hasStart := m.hasStartTriggerLocked()
+	for _, trigger := range m.triggers {
+		if trigger.Kind == TriggerAtTime && trigger.Fired > 0 {
+			continue
+		}
+		if trigger.Kind == TriggerCountdown && !hasStart {
+			continue
+		}
+		if trigger.State != TriggerStateArmed {
+			trigger.State = TriggerStateArmed
+			trigger.Remaining = 0
+			m.emitLocked("armed", trigger, "")
+		}
+	}
+}
+
+// hasStartTriggerLocked 检查是否有倒计时以外的开始触发器（需持有 m.mu）
+func (m *TriggerManager) hasStartTriggerLocked() bool {
+	for _, trigger := range m.triggers {
+		if trigger.Action == TriggerActionStart && trigger.Kind != TriggerCountdown {
+			return true
+		}
+	}
+	return false
+}
+
+// needsProcessesLocked 检查是否有进程触发器（需持有 m.mu）
+func (m *TriggerManager) needsProcessesLocked() bool {
+	for _, trigger := range m.triggers {
+		if trigger.Kind == TriggerProcessStart || trigger.Kind == TriggerProcessExit {
+			return true
+		}
+	}
+	return false
+}
+
+// pollProcessesLocked 每秒轮询一次进程列表，返回新启动和已退出的进程（需持有 m.mu）
+func (m *TriggerManager) pollProcessesLocked(now time.Time) (launched, exited map[string]bool) {
+	if !m.needsProcessesLocked() || now.Sub(m.lastProcessPoll) < processPollInterval {
+		return nil, nil
+	}
+	m.lastProcessPoll = now
+
+	current, err := m.processList()
+	if err != nil {
+		fmt.Printf("警告: 获取进程列表失败: %v\n", err)
+		return nil, nil
+	}
+	launched = make(map[string]bool)
+	exited = make(map[string]bool)
+	if m.processes != nil {
+		for name := range current {
+			if !m.processes[name] {
+				launched[name] = true
+			}
+		}
+		for name := range m.processes {
+			if !current[name] {
+				exited[name] = true
+			}
+		}
+	}
+	m.processes = current
+	return launched, exited
+}
+
+// saveToSessionLocked 把触发器状态写入当前录制的会话（需持有 m.mu）
+func (m *TriggerManager) saveToSessionLocked() {
+	outputPath := m.recorder.GetOutputPath()
+	if outputPath == "" {
+		return
+	}
+	session, err := LoadSession(SessionDirFor(outputPath))
+	if err == nil {
+		err = session.RecordTriggers(outputPath, m.snapshotLocked())
+	}
+	if err != nil {
+		fmt.Printf("警告: 保存触发器状态失败: %v\n", err)
+	}
+}
+
+// emitLocked 发送状态变化事件（需持有 m.mu）
+func (m *TriggerManager) emitLocked(eventType string, trigger *TriggerStatus, message string) {
+	if m.eventHandler == nil {
+		return
+	}
+	m.eventHandler(TriggerEvent{Type: eventType, Trigger: *trigger, Message: message, Time: m.now()})
+}
+
+// RecordTriggers 记录录制 videoPath 的触发器状态并立即保存
+func (s *Session) RecordTriggers(videoPath string, triggers []TriggerStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Triggers[videoPath] = triggers
+	return s.saveLocked()
+}
+
+// LoadTriggers 读取录制会话中保存的触发器配置，用于再次启用相同的触发器
+func LoadTriggers(videoPath string) ([]TriggerConfig, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return nil, err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	triggers := session.Triggers[videoPath]
+	configs := make([]TriggerConfig, 0, len(triggers))
+	for _, trigger := range triggers {
+		configs = append(configs, trigger.TriggerConfig)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("会话中没有 %s 的触发器配置", videoPath)
+	}
+	return configs, nil
+}
+
+// normalizeProcessName 统一进程名：小写、去掉路径和 .exe 后缀
+func normalizeProcessName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
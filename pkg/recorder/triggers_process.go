@@ -0,0 +1,94 @@
+package recorder
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"time"
+)
+
+// processListTimeout 列出进程的超时时间（tasklist、ps 卡住时不阻塞触发器轮询）
+const processListTimeout = 5 * time.Second
+
+// listProcesses 列出正在运行的进程名（经 normalizeProcessName 统一）
+func listProcesses() (map[string]bool, error) {
+	switch goruntime.GOOS {
+	case "windows":
+		return listWindowsProcesses()
+	case "linux":
+		return listProcProcesses()
+	default:
+		return listPSProcesses()
+	}
+}
+
+// listWindowsProcesses 通过 tasklist 列出进程（CSV 输出，第一列为映像名称）
+func listWindowsProcesses() (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), processListTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "tasklist", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return nil, fmt.Errorf("运行 tasklist 失败: %w", err)
+	}
+	reader := csv.NewReader(strings.NewReader(string(output)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 tasklist 输出失败: %w", err)
+	}
+
+	processes := make(map[string]bool)
+	for _, record := range records {
+		if len(record) > 0 {
+			processes[normalizeProcessName(record[0])] = true
+		}
+	}
+	return processes, nil
+}
+
+// listProcProcesses 通过 /proc/<pid>/comm 列出进程
+// comm 最长 15 个字符，同时读取 cmdline 的第一个参数以匹配完整的可执行文件名
+func listProcProcesses() (map[string]bool, error) {
+	entries, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := make(map[string]bool)
+	for _, entry := range entries {
+		if comm, err := os.ReadFile(entry); err == nil {
+			processes[normalizeProcessName(string(comm))] = true
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(filepath.Dir(entry), "cmdline")); err == nil {
+			if argv0, _, _ := strings.Cut(string(cmdline), "\x00"); argv0 != "" {
+				processes[normalizeProcessName(argv0)] = true
+			}
+		}
+	}
+	return processes, nil
+}
+
+// listPSProcesses 通过 ps 列出进程（macOS 等）
+func listPSProcesses() (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), processListTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "ps", "-axo", "comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("运行 ps 失败: %w", err)
+	}
+
+	processes := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := normalizeProcessName(line); name != "" {
+			processes[name] = true
+		}
+	}
+	return processes, nil
+}
@@ -0,0 +1,281 @@
+package recorder
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+// triggerHarness 使用假时钟、假进程列表驱动触发器，不启动轮询协程
+type triggerHarness struct {
+	t         *testing.T
+	manager   *TriggerManager
+	recorder  *Recorder
+	now       time.Time
+	processes map[string]bool
+	lastInput time.Time
+	startErr  error
+	actions   []string // 依次执行的动作
+	events    []string // 依次发出的事件（类型:触发器 ID）
+}
+
+func newTriggerHarness(t *testing.T, processes map[string]bool, configs ...TriggerConfig) *triggerHarness {
+	t.Helper()
+	h := &triggerHarness{
+		t:         t,
+		recorder:  &Recorder{},
+		now:       time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+		processes: processes,
+	}
+	h.manager = NewTriggerManager(h.recorder, TriggerActions{
+		Start: func() error {
+			if h.startErr != nil {
+				return h.startErr
+			}
+			h.actions = append(h.actions, TriggerActionStart)
+			h.setRecording(true)
+			return nil
+		},
+		Stop: func() error {
+			h.actions = append(h.actions, TriggerActionStop)
+			h.setRecording(false)
+			return nil
+		},
+	})
+	h.manager.now = func() time.Time { return h.now }
+	h.manager.processList = func() (map[string]bool, error) { return maps.Clone(h.processes), nil }
+	h.manager.lastInput = func() time.Time { return h.lastInput }
+	h.manager.SetEventHandler(func(ev TriggerEvent) {
+		h.events = append(h.events, ev.Type+":"+ev.Trigger.ID)
+	})
+
+	triggers, countdown, err := newTriggerStatuses(configs)
+	if err != nil {
+		t.Fatalf("newTriggerStatuses: %v", err)
+	}
+	h.manager.mu.Lock()
+	h.manager.resetLocked(triggers, countdown)
+	h.manager.mu.Unlock()
+	return h
+}
+
+// setRecording 模拟录制开始或停止（如用户手动操作）
+func (h *triggerHarness) setRecording(recording bool) {
+	h.recorder.mu.Lock()
+	h.recorder.isRecording = recording
+	h.recorder.mu.Unlock()
+}
+
+// advance 拨快假时钟并检查一次触发器
+func (h *triggerHarness) advance(d time.Duration) {
+	h.now = h.now.Add(d)
+	h.manager.evaluate(h.now)
+}
+
+// expectActions 检查到目前为止执行的动作
+func (h *triggerHarness) expectActions(step string, want ...string) {
+	h.t.Helper()
+	if !slices.Equal(h.actions, want) {
+		h.t.Fatalf("%s: 动作 = %v，期望 %v", step, h.actions, want)
+	}
+}
+
+// status 获取指定触发器的状态
+func (h *triggerHarness) status(id string) TriggerStatus {
+	h.t.Helper()
+	for _, status := range h.manager.GetStatus() {
+		if status.ID == id {
+			return status
+		}
+	}
+	h.t.Fatalf("没有触发器 %s", id)
+	return TriggerStatus{}
+}
+
+func TestTriggerCountdownGatesStart(t *testing.T) {
+	h := newTriggerHarness(t, nil,
+		TriggerConfig{ID: "at", Kind: TriggerAtTime, At: time.Date(2026, 1, 1, 9, 0, 2, 0, time.UTC)},
+		TriggerConfig{ID: "cd", Kind: TriggerCountdown, Seconds: 3},
+	)
+	// 有其他开始触发器时，倒计时等它触发后才开始
+	if state := h.status("cd").State; state != TriggerStateArmed {
+		t.Fatalf("启用后倒计时状态 = %s", state)
+	}
+
+	h.advance(time.Second)
+	h.expectActions("到点前")
+	h.advance(time.Second)
+	h.expectActions("到点后进入倒计时")
+	if h.status("at").State != TriggerStateFired || h.status("cd").State != TriggerStateCounting {
+		t.Fatalf("到点后状态 = %s / %s", h.status("at").State, h.status("cd").State)
+	}
+	h.advance(time.Second)
+	h.advance(time.Second)
+	h.expectActions("倒计时中")
+	if remaining := h.status("cd").Remaining; remaining != 1 {
+		t.Errorf("倒计时剩余 = %v", remaining)
+	}
+	h.advance(time.Second)
+	h.expectActions("倒计时结束", TriggerActionStart)
+	if status := h.status("cd"); status.State != TriggerStateFired || status.Fired != 1 {
+		t.Errorf("倒计时状态 = %s，触发 %d 次", status.State, status.Fired)
+	}
+
+	want := []string{"armed:at", "armed:cd", "fired:at", "countdown:cd", "countdown:cd", "countdown:cd", "fired:cd"}
+	if !slices.Equal(h.events, want) {
+		t.Errorf("事件 = %v\n期望 %v", h.events, want)
+	}
+}
+
+func TestTriggerStandaloneCountdownFiresOnce(t *testing.T) {
+	h := newTriggerHarness(t, nil, TriggerConfig{ID: "cd", Kind: TriggerCountdown, Seconds: 2})
+	// 单独的倒计时在启用时立即开始
+	if state := h.status("cd").State; state != TriggerStateCounting {
+		t.Fatalf("启用后倒计时状态 = %s", state)
+	}
+	h.advance(time.Second)
+	h.expectActions("倒计时中")
+	h.advance(time.Second)
+	h.expectActions("倒计时结束", TriggerActionStart)
+
+	// 录制结束后不再倒计时
+	h.setRecording(false)
+	h.advance(time.Second)
+	h.advance(5 * time.Second)
+	h.expectActions("停止后", TriggerActionStart)
+	if state := h.status("cd").State; state != TriggerStateFired {
+		t.Errorf("停止后倒计时状态 = %s", state)
+	}
+}
+
+func TestTriggerAtTimeFiresOnce(t *testing.T) {
+	h := newTriggerHarness(t, nil,
+		TriggerConfig{ID: "at", Kind: TriggerAtTime, At: time.Date(2026, 1, 1, 9, 0, 1, 0, time.UTC)},
+		TriggerConfig{ID: "max", Kind: TriggerMaxDuration, Seconds: 2},
+	)
+	h.advance(500 * time.Millisecond)
+	if remaining := h.status("at").Remaining; remaining != 0.5 {
+		t.Errorf("到点剩余 = %v", remaining)
+	}
+	h.advance(500 * time.Millisecond)
+	h.expectActions("到点", TriggerActionStart)
+
+	// 录制时长从开始录制时计算
+	h.advance(time.Second)
+	h.expectActions("时长未到", TriggerActionStart)
+	if remaining := h.status("max").Remaining; remaining != 1 {
+		t.Errorf("时长剩余 = %v", remaining)
+	}
+	h.advance(time.Second)
+	h.expectActions("时长达到上限", TriggerActionStart, TriggerActionStop)
+
+	// 停止后时长触发器重新等待，到点触发器不再触发
+	h.advance(time.Second)
+	h.advance(time.Minute)
+	h.expectActions("停止后", TriggerActionStart, TriggerActionStop)
+	if status := h.status("at"); status.State != TriggerStateFired || status.Fired != 1 {
+		t.Errorf("到点触发器状态 = %s，触发 %d 次", status.State, status.Fired)
+	}
+	if state := h.status("max").State; state != TriggerStateArmed {
+		t.Errorf("时长触发器状态 = %s", state)
+	}
+}
+
+func TestTriggerProcessLaunchAndExit(t *testing.T) {
+	h := newTriggerHarness(t, map[string]bool{"explorer": true, "notepad": true},
+		TriggerConfig{ID: "launch", Kind: TriggerProcessStart, Process: `C:\Games\Game.exe`},
+		TriggerConfig{ID: "exit", Kind: TriggerProcessExit, Process: "game"},
+		TriggerConfig{ID: "notepad", Kind: TriggerProcessStart, Process: "Notepad.exe"},
+	)
+	// 启用时已在运行的进程不触发
+	h.advance(time.Second)
+	h.expectActions("已在运行的进程")
+
+	h.processes["game"] = true
+	h.advance(500 * time.Millisecond)
+	h.expectActions("距上次轮询不到 1 秒")
+	h.advance(500 * time.Millisecond)
+	h.expectActions("进程启动", TriggerActionStart)
+
+	delete(h.processes, "game")
+	h.advance(time.Second)
+	h.expectActions("进程退出", TriggerActionStart, TriggerActionStop)
+
+	// 停止后重新等待，进程再次启动时再次开始录制
+	h.advance(time.Second)
+	for _, id := range []string{"launch", "exit"} {
+		if state := h.status(id).State; state != TriggerStateArmed {
+			t.Errorf("停止后 %s 状态 = %s", id, state)
+		}
+	}
+	h.processes["game"] = true
+	h.advance(time.Second)
+	h.expectActions("再次启动", TriggerActionStart, TriggerActionStop, TriggerActionStart)
+	if fired := h.status("launch").Fired; fired != 2 {
+		t.Errorf("启动触发器触发 %d 次", fired)
+	}
+}
+
+func TestTriggerRearmAfterManualStop(t *testing.T) {
+	h := newTriggerHarness(t, map[string]bool{},
+		TriggerConfig{ID: "launch", Kind: TriggerProcessStart, Process: "game"},
+		TriggerConfig{ID: "max", Kind: TriggerMaxDuration, Seconds: 10},
+	)
+	h.processes["game"] = true
+	h.advance(time.Second)
+	h.expectActions("进程启动", TriggerActionStart)
+
+	// 用户手动停止录制后重新等待，时长从下一次录制开始计算
+	h.setRecording(false)
+	h.advance(5 * time.Second)
+	h.setRecording(true)
+	h.advance(time.Second)
+	h.advance(9 * time.Second)
+	h.expectActions("手动开始后时长未到", TriggerActionStart)
+	h.advance(time.Second)
+	h.expectActions("时长达到上限", TriggerActionStart, TriggerActionStop)
+}
+
+func TestTriggerIdle(t *testing.T) {
+	h := newTriggerHarness(t, nil, TriggerConfig{ID: "idle", Kind: TriggerIdle, Seconds: 10})
+	h.lastInput = h.now.Add(-time.Hour)
+
+	// 未录制时不检查停止触发器
+	h.advance(time.Minute)
+	h.expectActions("未录制")
+
+	// 录制开始前的空闲时间不计入
+	h.setRecording(true)
+	h.advance(time.Second)
+	h.advance(5 * time.Second)
+	h.lastInput = h.now
+	h.advance(9 * time.Second)
+	h.expectActions("输入后空闲不到 10 秒")
+	if remaining := h.status("idle").Remaining; remaining != 1 {
+		t.Errorf("空闲剩余 = %v", remaining)
+	}
+	h.advance(time.Second)
+	h.expectActions("空闲 10 秒", TriggerActionStop)
+}
+
+func TestTriggerActionError(t *testing.T) {
+	h := newTriggerHarness(t, map[string]bool{},
+		TriggerConfig{ID: "launch", Kind: TriggerProcessStart, Process: "game"},
+	)
+	h.startErr = errors.New("没有可用的屏幕")
+	h.processes["game"] = true
+	h.advance(time.Second)
+	if status := h.status("launch"); status.State != TriggerStateError || status.LastError != "没有可用的屏幕" {
+		t.Fatalf("失败后状态 = %s (%s)", status.State, status.LastError)
+	}
+
+	// 失败的触发器不再重试
+	h.startErr = nil
+	delete(h.processes, "game")
+	h.advance(time.Second)
+	h.processes["game"] = true
+	h.advance(time.Second)
+	h.expectActions("失败后")
+}
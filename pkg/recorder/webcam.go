@@ -0,0 +1,207 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WebcamDevice 摄像头设备
+type WebcamDevice struct {
+	ID      string `json:"id"`      // 直接作为 FFmpeg 的 -i 参数（/dev/video0、video=Name）
+	Name    string `json:"name"`    // 显示名称
+	Backend string `json:"backend"` // v4l2 或 dshow
+}
+
+// WebcamConfig 摄像头录制配置
+type WebcamConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Device    string `json:"device"`    // WebcamDevice.ID，空字符串表示第一个设备
+	Width     int    `json:"width"`     // 采集分辨率（默认 1280x720）
+	Height    int    `json:"height"`    //
+	FrameRate int    `json:"frameRate"` // 采集帧率（默认 30）
+}
+
+// DefaultWebcamConfig 默认摄像头配置（不启用）
+func DefaultWebcamConfig() WebcamConfig {
+	return WebcamConfig{
+		Width:     1280,
+		Height:    720,
+		FrameRate: 30,
+	}
+}
+
+// ListWebcams 列出可用的摄像头
+func ListWebcams(ffmpegPath string) ([]WebcamDevice, error) {
+	switch goruntime.GOOS {
+	case "windows":
+		devices := []WebcamDevice{}
+		for _, entry := range parseDshowEntries(listDshowDevices(ffmpegPath)) {
+			if entry.deviceType == "video" {
+				devices = append(devices, WebcamDevice{ID: "video=" + entry.name, Name: entry.name, Backend: "dshow"})
+			}
+		}
+		return devices, nil
+	case "linux":
+		return listV4L2Devices(), nil
+	default:
+		return nil, fmt.Errorf("不支持在 %s 上录制摄像头", goruntime.GOOS)
+	}
+}
+
+// listV4L2Devices 通过 sysfs 枚举 V4L2 采集设备
+// 一个摄像头通常对应多个 /dev/video 节点（采集 + 元数据），只保留 index 为 0 的采集节点
+func listV4L2Devices() []WebcamDevice {
+	devices := []WebcamDevice{}
+	nodes, _ := filepath.Glob("/sys/class/video4linux/video*")
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if index, err := os.ReadFile(filepath.Join(node, "index")); err == nil && strings.TrimSpace(string(index)) != "0" {
+			continue
+		}
+		id := "/dev/" + filepath.Base(node)
+		name := id
+		if data, err := os.ReadFile(filepath.Join(node, "name")); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+		devices = append(devices, WebcamDevice{ID: id, Name: name, Backend: "v4l2"})
+	}
+	return devices
+}
+
+// resolveWebcam 按 ID 查找摄像头，ID 为空时返回第一个
+func resolveWebcam(ffmpegPath, id string) (WebcamDevice, error) {
+	devices, err := ListWebcams(ffmpegPath)
+	if err != nil {
+		return WebcamDevice{}, err
+	}
+	for _, device := range devices {
+		if id == "" || device.ID == id {
+			return device, nil
+		}
+	}
+	if id != "" {
+		return WebcamDevice{}, fmt.Errorf("未找到摄像头: %s", id)
+	}
+	return WebcamDevice{}, fmt.Errorf("没有可用的摄像头")
+}
+
+// WebcamPathFor 返回录制视频对应的摄像头视频路径
+func WebcamPathFor(videoPath string) string {
+	return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "_webcam.mp4"
+}
+
+// WebcamRecorder 摄像头录制器
+// 与屏幕录制并行运行，输出为单独的视频文件，开始时间记录在会话中用于导出时对齐
+type WebcamRecorder struct {
+	ffmpegPath string
+	config     WebcamConfig
+
+	mu         sync.Mutex
+	process    *ffmpeg.Process
+	device     WebcamDevice
+	outputPath string
+	timing     StreamTiming
+}
+
+// NewWebcamRecorder 创建摄像头录制器
+func NewWebcamRecorder(ffmpegPath string, config WebcamConfig) *WebcamRecorder {
+	defaults := DefaultWebcamConfig()
+	if config.Width <= 0 || config.Height <= 0 {
+		config.Width, config.Height = defaults.Width, defaults.Height
+	}
+	if config.FrameRate <= 0 {
+		config.FrameRate = defaults.FrameRate
+	}
+	return &WebcamRecorder{ffmpegPath: ffmpegPath, config: config}
+}
+
+// Start 开始录制摄像头
+func (w *WebcamRecorder) Start(outputPath string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.process != nil && w.process.Running() {
+		return fmt.Errorf("摄像头录制已在进行中")
+	}
+
+	device, err := resolveWebcam(w.ffmpegPath, w.config.Device)
+	if err != nil {
+		return err
+	}
+
+	command := ffmpeg.NewCommand().Overwrite()
+	input := command.Input(device.ID,
+		ffmpeg.Opt("framerate", w.config.FrameRate),
+		ffmpeg.Opt("video_size", fmt.Sprintf("%dx%d", w.config.Width, w.config.Height)),
+	).Format(device.Backend)
+	if device.Backend == "dshow" {
+		// 默认的实时缓冲太小，高分辨率摄像头容易丢帧
+		input.With(ffmpeg.Opt("rtbufsize", "100M"))
+	}
+	command.Output(outputPath).
+		Map(input.Video()).
+		VideoCodec("libx264").
+		With(
+			ffmpeg.Opt("preset", "ultrafast"),
+			ffmpeg.Opt("crf", 23),
+			ffmpeg.Opt("pix_fmt", "yuv420p"),
+		)
+
+	args, err := command.Args()
+	if err != nil {
+		return err
+	}
+
+	process := ffmpeg.NewProcess(w.ffmpegPath, args, ffmpeg.ProcessOptions{Name: "摄像头录制"})
+	if err := process.Start(context.Background()); err != nil {
+		return fmt.Errorf("启动摄像头录制失败: %w", err)
+	}
+
+	w.process = process
+	w.device = device
+	w.outputPath = outputPath
+	fmt.Printf("✓ 摄像头录制已启动: %s (%s)\n", device.Name, device.Backend)
+	return nil
+}
+
+// Stop 停止录制并返回摄像头视频的流时间
+func (w *WebcamRecorder) Stop() (StreamTiming, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.process == nil {
+		return StreamTiming{}, fmt.Errorf("摄像头录制未在进行中")
+	}
+
+	w.timing = processTiming(w.outputPath, StreamWebcam, w.process)
+	w.process.Stop()
+	err := w.process.Wait()
+	w.process = nil
+	if err != nil {
+		return w.timing, fmt.Errorf("摄像头录制异常结束: %w", err)
+	}
+
+	fmt.Printf("✓ 摄像头录制已停止: %s\n", w.outputPath)
+	return w.timing, nil
+}
+
+// IsRecording 检查是否正在录制
+func (w *WebcamRecorder) IsRecording() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.process != nil && w.process.Running()
+}
+
+// GetDevice 获取实际使用的摄像头
+func (w *WebcamRecorder) GetDevice() WebcamDevice {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.device
+}
@@ -0,0 +1,443 @@
+package recorder
+
+import (
+	"SmoothScreen/pkg/ffmpeg"
+	"SmoothScreen/pkg/hook"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 摄像头气泡形状
+const (
+	WebcamShapeCircle  = "circle"
+	WebcamShapeRounded = "rounded"
+)
+
+// 摄像头气泡位置
+const (
+	WebcamTopLeft     = "top-left"
+	WebcamTopRight    = "top-right"
+	WebcamBottomLeft  = "bottom-left"
+	WebcamBottomRight = "bottom-right"
+)
+
+// 摄像头时间段模式
+const (
+	WebcamModeFullscreen = "fullscreen" // 摄像头全屏
+	WebcamModeHidden     = "hidden"     // 隐藏摄像头
+)
+
+const (
+	webcamMoveDuration = 0.3 // 气泡换角的动画时长（秒）
+	webcamMinDwell     = 1.0 // 换角后至少停留的时间（秒），避免来回跳动
+)
+
+// WebcamRange 摄像头全屏或隐藏的时间段（秒，导出视频时间轴）
+type WebcamRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Mode  string  `json:"mode"` // fullscreen 或 hidden
+}
+
+// WebcamOverlayConfig 摄像头画中画配置
+type WebcamOverlayConfig struct {
+	Enabled      bool          `json:"enabled"`
+	Shape        string        `json:"shape"`        // circle 或 rounded
+	Corner       string        `json:"corner"`       // 默认位置
+	Size         float64       `json:"size"`         // 气泡边长占输出高度的比例
+	Margin       int           `json:"margin"`       // 距画面边缘的距离（像素）
+	CornerRadius float64       `json:"cornerRadius"` // 圆角矩形的圆角半径占边长的比例
+	BorderWidth  int           `json:"borderWidth"`  // 边框宽度（像素，0 表示无边框）
+	BorderColor  string        `json:"borderColor"`  // 边框颜色 #RRGGBB
+	AvoidCursor  bool          `json:"avoidCursor"`  // 镜头焦点接近时移到其他角落
+	Ranges       []WebcamRange `json:"ranges"`
+}
+
+// DefaultWebcamOverlayConfig 默认画中画配置（不启用）
+func DefaultWebcamOverlayConfig() WebcamOverlayConfig {
+	return WebcamOverlayConfig{
+		Shape:        WebcamShapeCircle,
+		Corner:       WebcamBottomRight,
+		Size:         0.22,
+		Margin:       32,
+		CornerRadius: 0.2,
+		BorderWidth:  4,
+		BorderColor:  "#FFFFFF",
+		AvoidCursor:  true,
+	}
+}
+
+// webcamCornerSpan 气泡从 Start 开始停在 Corner
+type webcamCornerSpan struct {
+	Start  float64
+	Corner string
+}
+
+// focusSample 某一时刻镜头焦点（光标）在输出画面中的位置
+type focusSample struct {
+	Time float64
+	X, Y float64
+}
+
+// webcamLayout 气泡在输出画面中的尺寸
+type webcamLayout struct {
+	width, height int // 输出画面
+	size          int // 摄像头画面边长
+	border        int
+	margin        int
+}
+
+// total 含边框的气泡边长
+func (l webcamLayout) total() int {
+	return l.size + 2*l.border
+}
+
+// cornerPosition 气泡（含边框）左上角的位置
+func (l webcamLayout) cornerPosition(corner string) (int, int) {
+	x, y := l.margin, l.margin
+	if strings.HasSuffix(corner, "right") {
+		x = l.width - l.total() - l.margin
+	}
+	if strings.HasPrefix(corner, "bottom") {
+		y = l.height - l.total() - l.margin
+	}
+	return x, y
+}
+
+// near 检查焦点是否落在气泡附近（气泡外扩半个边长）
+func (l webcamLayout) near(corner string, x, y float64) bool {
+	cx, cy := l.cornerPosition(corner)
+	pad := float64(l.total()) / 2
+	return x >= float64(cx)-pad && x <= float64(cx+l.total())+pad &&
+		y >= float64(cy)-pad && y <= float64(cy+l.total())+pad
+}
+
+// cornerPreference 避让时的候选顺序：默认位置、水平相邻、垂直相邻、对角
+func cornerPreference(corner string) []string {
+	vertical, horizontal, _ := strings.Cut(corner, "-")
+	flipV := map[string]string{"top": "bottom", "bottom": "top"}[vertical]
+	flipH := map[string]string{"left": "right", "right": "left"}[horizontal]
+	return []string{
+		corner,
+		vertical + "-" + flipH,
+		flipV + "-" + horizontal,
+		flipV + "-" + flipH,
+	}
+}
+
+// planWebcamCorners 根据焦点轨迹规划气泡位置
+// 焦点接近当前位置时换到第一个空闲的候选位置；离开默认位置附近后回到默认位置
+func planWebcamCorners(layout webcamLayout, corner string, samples []focusSample) []webcamCornerSpan {
+	spans := []webcamCornerSpan{{Start: 0, Corner: corner}}
+	preference := cornerPreference(corner)
+	current := corner
+	lastMove := math.Inf(-1)
+
+	for _, sample := range samples {
+		if sample.Time-lastMove < webcamMinDwell {
+			continue
+		}
+
+		target := current
+		if layout.near(current, sample.X, sample.Y) || current != corner {
+			for _, candidate := range preference {
+				if !layout.near(candidate, sample.X, sample.Y) {
+					target = candidate
+					break
+				}
+			}
+		}
+		if target != current {
+			current = target
+			lastMove = sample.Time
+			spans = append(spans, webcamCornerSpan{Start: sample.Time, Corner: current})
+		}
+	}
+	return spans
+}
+
+// positionExpression 生成气泡坐标的 FFmpeg 表达式（换角时线性移动）
+// 每次换角是一项从 0 到位移的斜坡，各项相加：嵌套深度与换角次数无关
+// （libavutil 的表达式解析限制嵌套深度，逐段嵌套 if 在长录制中会解析失败）
+func positionExpression(spans []webcamCornerSpan, position func(corner string) int) string {
+	var expr strings.Builder
+	expr.WriteString(strconv.Itoa(position(spans[0].Corner)))
+	for i := 1; i < len(spans); i++ {
+		delta := position(spans[i].Corner) - position(spans[i-1].Corner)
+		if delta == 0 {
+			continue
+		}
+		fmt.Fprintf(&expr, "+(%d)*clip((t-%.3f)/%.3f,0,1)", delta, spans[i].Start, webcamMoveDuration)
+	}
+	return expr.String()
+}
+
+// rangeExpression 生成 "处于某种模式的时间段内" 的表达式，没有该模式时返回空字符串
+func rangeExpression(ranges []WebcamRange, mode string) string {
+	terms := []string{}
+	for _, r := range ranges {
+		if r.Mode == mode && r.End > r.Start {
+			terms = append(terms, fmt.Sprintf("between(t,%.3f,%.3f)", r.Start, r.End))
+		}
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	return "gt(" + strings.Join(terms, "+") + ",0)"
+}
+
+// sessionWebcamTrack 从会话中找到与视频一起录制的摄像头视频
+func sessionWebcamTrack(videoPath string) (StreamTiming, error) {
+	session, err := LoadSession(SessionDirFor(videoPath))
+	if err != nil {
+		return StreamTiming{}, err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for _, timing := range session.Streams {
+		if timing.Kind == StreamWebcam && timing.Reference == videoPath && fileExists(timing.Path) {
+			return *timing, nil
+		}
+	}
+	return StreamTiming{}, fmt.Errorf("会话中没有与 %s 一起录制的摄像头视频", videoPath)
+}
+
+// loadFocusSamples 由鼠标数据和相机路径计算光标在输出画面中的位置
+func loadFocusSamples(config ExportConfig, width, height int) ([]focusSample, error) {
+	if config.MouseDataPath == "" || config.ScreenWidth <= 0 || config.ScreenHeight <= 0 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(config.MouseDataPath)
+	if err != nil {
+		return nil, err
+	}
+	var mouseEvents []hook.MouseEvent
+	if err := json.Unmarshal(data, &mouseEvents); err != nil {
+		return nil, err
+	}
+	keyboardEvents, err := LoadKeyboardEvents(config)
+	if err != nil {
+		return nil, err
+	}
+
+	fps := config.FPS
+	if fps <= 0 {
+		fps = 30
+	}
+	frames := GenerateCameraPathWithKeyboard(mouseEvents, keyboardEvents, NewCameraControllerForConfig(config), fps)
+	if len(frames) == 0 {
+		return nil, nil
+	}
+
+	// 相机中心映射到画面中心，光标按缩放倍数偏离中心
+	scaleX := float64(width) / float64(config.ScreenWidth)
+	scaleY := float64(height) / float64(config.ScreenHeight)
+	samples := make([]focusSample, 0, len(frames))
+	for _, frame := range frames {
+		zoom := math.Max(frame.Zoom, 1)
+		samples = append(samples, focusSample{
+			Time: float64(frame.Timestamp-frames[0].Timestamp) / 1000,
+			X:    ((float64(frame.MouseX)-frame.X)*zoom + float64(config.ScreenWidth)/2) * scaleX,
+			Y:    ((float64(frame.MouseY)-frame.Y)*zoom + float64(config.ScreenHeight)/2) * scaleY,
+		})
+	}
+	return samples, nil
+}
+
+// webcamStage 后处理阶段：把会话中的摄像头视频以画中画气泡叠加到 width x height 的导出画面
+// 返回的 cleanup 删除遮罩等临时文件（不为 nil 时调用方在后处理结束后调用）
+func webcamStage(config ExportConfig, width, height int) (postProcessStage, func(), error) {
+	overlay := config.Webcam
+	webcam, err := sessionWebcamTrack(config.VideoPath)
+	if err != nil {
+		return postProcessStage{}, nil, err
+	}
+
+	layout := webcamLayout{
+		width:  width,
+		height: height,
+		size:   int(math.Round(float64(height)*overlay.Size/2)) * 2,
+		border: overlay.BorderWidth,
+		margin: overlay.Margin,
+	}
+	if layout.size <= 0 {
+		return postProcessStage{}, nil, fmt.Errorf("摄像头气泡尺寸无效: %.2f", overlay.Size)
+	}
+
+	corner := overlay.Corner
+	if corner == "" {
+		corner = WebcamBottomRight
+	}
+	spans := []webcamCornerSpan{{Start: 0, Corner: corner}}
+	if overlay.AvoidCursor {
+		samples, err := loadFocusSamples(config, layout.width, layout.height)
+		if err != nil {
+			fmt.Printf("警告: 读取光标轨迹失败，气泡不避让: %v\n", err)
+		}
+		spans = planWebcamCorners(layout, corner, samples)
+	}
+
+	// 遮罩和边框渲染为 PNG，作为循环图片输入
+	workDir, err := os.MkdirTemp("", "silkrec-webcam-")
+	if err != nil {
+		return postProcessStage{}, nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(workDir) }
+
+	radius := overlay.CornerRadius
+	if overlay.Shape != WebcamShapeRounded {
+		radius = 0.5 // 圆形即圆角半径为边长一半
+	}
+	maskPath := filepath.Join(workDir, "mask.png")
+	if err := writeShapePNG(maskPath, layout.size, radius, color.White); err != nil {
+		return postProcessStage{}, cleanup, err
+	}
+	borderPath := filepath.Join(workDir, "border.png")
+	if layout.border > 0 {
+		borderColor, err := parseHexColor(overlay.BorderColor)
+		if err != nil {
+			return postProcessStage{}, cleanup, err
+		}
+		// 边框图形外扩 border 像素，圆角半径按比例保持同心
+		outerRadius := (radius*float64(layout.size) + float64(layout.border)) / float64(layout.total())
+		if err := writeShapePNG(borderPath, layout.total(), outerRadius, borderColor); err != nil {
+			return postProcessStage{}, cleanup, err
+		}
+	}
+	fmt.Printf("摄像头画中画: %d 次换位\n", len(spans)-1)
+
+	build := func(command *ffmpeg.Command, video ffmpeg.Pad) (ffmpeg.Pad, error) {
+		graph := command.FilterGraph()
+		camera := alignVideo(graph, command.Input(webcam.Path).Video(), webcam.Offset)
+		mask := command.Input(maskPath, ffmpeg.Opt("loop", 1)).Video()
+
+		fullscreen := rangeExpression(overlay.Ranges, WebcamModeFullscreen)
+		hidden := rangeExpression(overlay.Ranges, WebcamModeHidden)
+		enable := "1"
+		if hidden != "" || fullscreen != "" {
+			enable = "not(" + strings.Join(nonEmpty(hidden, fullscreen), "+") + ")"
+		}
+
+		var full ffmpeg.Pad
+		if fullscreen != "" {
+			split := graph.ChainN(2, []ffmpeg.Pad{camera}, ffmpeg.NewFilter("split"))
+			camera = split[0]
+			full = graph.Chain([]ffmpeg.Pad{split[1]},
+				ffmpeg.NewFilter("scale").
+					Set("w", layout.width).
+					Set("h", layout.height).
+					Set("force_original_aspect_ratio", "increase"),
+				ffmpeg.NewFilter("crop", layout.width, layout.height),
+			)
+		}
+
+		// 裁成正方形并缩放，用遮罩生成透明圆形/圆角矩形
+		camera = graph.Chain([]ffmpeg.Pad{camera},
+			ffmpeg.NewFilter("crop", "min(iw,ih)", "min(iw,ih)"),
+			ffmpeg.NewFilter("scale", layout.size, layout.size),
+			ffmpeg.NewFilter("format", "yuva420p"),
+		)
+		mask = graph.Apply(ffmpeg.NewFilter("format", "gray"), mask)
+		bubble := graph.Apply(ffmpeg.NewFilter("alphamerge"), camera, mask)
+
+		x := positionExpression(spans, func(corner string) int { x, _ := layout.cornerPosition(corner); return x })
+		y := positionExpression(spans, func(corner string) int { _, y := layout.cornerPosition(corner); return y })
+
+		if layout.border > 0 {
+			border := command.Input(borderPath, ffmpeg.Opt("loop", 1)).Video()
+			video = graph.Apply(overlayFilter(x, y, enable), video, border)
+		}
+		video = graph.Apply(overlayFilter(
+			fmt.Sprintf("%s+%d", x, layout.border),
+			fmt.Sprintf("%s+%d", y, layout.border),
+			enable), video, bubble)
+		if fullscreen != "" {
+			video = graph.Apply(overlayFilter("0", "0", fullscreen), video, full)
+		}
+		return video, nil
+	}
+	return postProcessStage{name: "摄像头画中画", video: build}, cleanup, nil
+}
+
+// overlayFilter 创建叠加滤镜（坐标逐帧计算，叠加源结束后保留主画面）
+func overlayFilter(x, y, enable string) *ffmpeg.Filter {
+	return ffmpeg.NewFilter("overlay").
+		Set("x", x).
+		Set("y", y).
+		Set("enable", enable).
+		Set("eval", "frame").
+		Set("eof_action", "pass")
+}
+
+// alignVideo 按偏移对齐视频：晚于主视频开始时推迟时间戳，早于主视频开始时裁掉多录的部分
+func alignVideo(graph *ffmpeg.FilterGraph, video ffmpeg.Pad, offset float64) ffmpeg.Pad {
+	if math.Abs(offset) < minAlignOffset {
+		return video
+	}
+	if offset > 0 {
+		return graph.Apply(ffmpeg.NewFilter("setpts", fmt.Sprintf("PTS-STARTPTS+%.3f/TB", offset)), video)
+	}
+	return graph.Chain([]ffmpeg.Pad{video},
+		ffmpeg.NewFilter("trim").Set("start", fmt.Sprintf("%.3f", -offset)),
+		ffmpeg.NewFilter("setpts", "PTS-STARTPTS"),
+	)
+}
+
+// nonEmpty 返回非空字符串
+func nonEmpty(values ...string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// writeShapePNG 渲染边长为 size 的圆角矩形（radius 为圆角半径占边长的比例，0.5 即圆形），边缘抗锯齿
+func writeShapePNG(path string, size int, radius float64, fill color.Color) error {
+	r, g, b, _ := fill.RGBA()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	half := float64(size) / 2
+	cornerRadius := radius * float64(size)
+
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			// 像素中心到圆角矩形边界的有符号距离
+			dx := math.Abs(float64(px)+0.5-half) - (half - cornerRadius)
+			dy := math.Abs(float64(py)+0.5-half) - (half - cornerRadius)
+			outside := math.Hypot(math.Max(dx, 0), math.Max(dy, 0)) + math.Min(math.Max(dx, dy), 0) - cornerRadius
+			alpha := math.Max(0, math.Min(1, 0.5-outside))
+			img.SetNRGBA(px, py, color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(alpha * 255)})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建遮罩图片失败: %w", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		return fmt.Errorf("写入遮罩图片失败: %w", err)
+	}
+	return nil
+}
+
+// parseHexColor 解析 #RRGGBB 颜色
+func parseHexColor(value string) (color.Color, error) {
+	hex := strings.TrimPrefix(value, "#")
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("无效的颜色: %s", value)
+	}
+	return color.NRGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}, nil
+}
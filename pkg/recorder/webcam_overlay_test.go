@@ -0,0 +1,155 @@
+package recorder
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testWebcamLayout 1920x1080 画面右下角的气泡：左上角 (1680, 840)，含边框边长 208
+var testWebcamLayout = webcamLayout{width: 1920, height: 1080, size: 200, border: 4, margin: 32}
+
+func TestPlanWebcamCorners(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  webcamLayout
+		samples []focusSample
+		want    []webcamCornerSpan
+	}{
+		{
+			name:    "焦点远离气泡",
+			layout:  testWebcamLayout,
+			samples: []focusSample{{Time: 1, X: 500, Y: 300}, {Time: 5, X: 1200, Y: 600}},
+			want:    []webcamCornerSpan{{0, WebcamBottomRight}},
+		},
+		{
+			name:    "接近时换到水平相邻的角",
+			layout:  testWebcamLayout,
+			samples: []focusSample{{Time: 1, X: 500, Y: 300}, {Time: 2, X: 1700, Y: 900}},
+			want:    []webcamCornerSpan{{0, WebcamBottomRight}, {2, WebcamBottomLeft}},
+		},
+		{
+			name:   "停留时间内不换角，焦点离开后回到默认位置",
+			layout: testWebcamLayout,
+			samples: []focusSample{
+				{Time: 2, X: 1700, Y: 900},
+				{Time: 2.5, X: 100, Y: 900}, // 接近新位置，但换角不到 1 秒
+				{Time: 2.9, X: 800, Y: 400},
+				{Time: 3.5, X: 800, Y: 400},
+			},
+			want: []webcamCornerSpan{{0, WebcamBottomRight}, {2, WebcamBottomLeft}, {3.5, WebcamBottomRight}},
+		},
+		{
+			name:   "停留期满后焦点追上新位置时再次避让",
+			layout: testWebcamLayout,
+			samples: []focusSample{
+				{Time: 2, X: 1700, Y: 900},
+				{Time: 3, X: 100, Y: 900},
+			},
+			want: []webcamCornerSpan{{0, WebcamBottomRight}, {2, WebcamBottomLeft}, {3, WebcamBottomRight}},
+		},
+		{
+			name:    "默认位置和水平相邻都被挡住时换到垂直相邻",
+			layout:  webcamLayout{width: 600, height: 1080, size: 200, border: 4, margin: 32},
+			samples: []focusSample{{Time: 1, X: 300, Y: 900}},
+			want:    []webcamCornerSpan{{0, WebcamBottomRight}, {1, WebcamTopRight}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planWebcamCorners(tt.layout, WebcamBottomRight, tt.samples)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planWebcamCorners = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebcamPositionExpression(t *testing.T) {
+	spans := []webcamCornerSpan{{0, WebcamBottomRight}, {2, WebcamBottomLeft}, {3.5, WebcamBottomRight}}
+	x := func(corner string) int { x, _ := testWebcamLayout.cornerPosition(corner); return x }
+	y := func(corner string) int { _, y := testWebcamLayout.cornerPosition(corner); return y }
+
+	tests := []struct {
+		name  string
+		spans []webcamCornerSpan
+		axis  func(string) int
+		want  string
+	}{
+		{"不换角", spans[:1], x, "1680"},
+		{"水平换角", spans, x, "1680+(-1648)*clip((t-2.000)/0.300,0,1)+(1648)*clip((t-3.500)/0.300,0,1)"},
+		{"垂直坐标不变", spans, y, "840"},
+	}
+	for _, tt := range tests {
+		if got := positionExpression(tt.spans, tt.axis); got != tt.want {
+			t.Errorf("%s: positionExpression = %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebcamPositionExpressionIsFlat(t *testing.T) {
+	// 长录制中每秒换一次角，表达式的嵌套深度不随换角次数增长
+	spans := []webcamCornerSpan{{0, WebcamBottomRight}}
+	for i := 1; i <= 500; i++ {
+		corner := WebcamBottomLeft
+		if i%2 == 0 {
+			corner = WebcamBottomRight
+		}
+		spans = append(spans, webcamCornerSpan{Start: float64(i), Corner: corner})
+	}
+	expr := positionExpression(spans, func(corner string) int { x, _ := testWebcamLayout.cornerPosition(corner); return x })
+
+	depth, maxDepth := 0, 0
+	for _, c := range expr {
+		switch c {
+		case '(':
+			depth++
+			maxDepth = max(maxDepth, depth)
+		case ')':
+			depth--
+		}
+	}
+	if maxDepth > 2 {
+		t.Errorf("嵌套深度 = %d", maxDepth)
+	}
+	if n := strings.Count(expr, "clip("); n != 500 {
+		t.Errorf("换角项 = %d, want 500", n)
+	}
+
+	// 有 FFmpeg 时确认表达式可以解析
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("没有安装 FFmpeg")
+	}
+	output, err := exec.Command(ffmpegPath, "-hide_banner", "-nostdin",
+		"-f", "lavfi", "-i", "color=c=black:s=1920x1080:d=0.2",
+		"-f", "lavfi", "-i", "color=c=red:s=208x208:d=0.2",
+		"-filter_complex", "[0:v][1:v]overlay=x='"+expr+"':y=840:eval=frame",
+		"-f", "null", "-").CombinedOutput()
+	if err != nil {
+		t.Fatalf("FFmpeg 无法解析位置表达式: %v\n%s", err, output)
+	}
+}
+
+func TestWebcamRangeExpression(t *testing.T) {
+	ranges := []WebcamRange{
+		{Start: 1, End: 2, Mode: WebcamModeFullscreen},
+		{Start: 5, End: 4, Mode: WebcamModeFullscreen}, // 无效区间忽略
+		{Start: 3, End: 4.5, Mode: WebcamModeHidden},
+		{Start: 8, End: 9.25, Mode: WebcamModeFullscreen},
+	}
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{WebcamModeFullscreen, "gt(between(t,1.000,2.000)+between(t,8.000,9.250),0)"},
+		{WebcamModeHidden, "gt(between(t,3.000,4.500),0)"},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := rangeExpression(ranges, tt.mode); got != tt.want {
+			t.Errorf("rangeExpression(%s) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -16,6 +16,7 @@ type FileServer struct {
 	port      int
 	rootDir   string
 	isRunning bool
+	handlers  map[string]http.Handler // 额外的路由（在 Start 之前注册）
 	mu        sync.Mutex
 }
 
@@ -29,6 +30,16 @@ func NewFileServer(rootDir string, port int) *FileServer {
 	}
 }
 
+// Handle 注册额外的路由（如事件推送端点），需在 Start 之前调用
+func (s *FileServer) Handle(pattern string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]http.Handler)
+	}
+	s.handlers[pattern] = handler
+}
+
 // Start 启动文件服务器
 func (s *FileServer) Start() error {
 	s.mu.Lock()
@@ -48,6 +59,9 @@ func (s *FileServer) Start() error {
 
 	// 处理静态文件请求
 	mux.HandleFunc("/", s.handleFileRequest)
+	for pattern, handler := range s.handlers {
+		mux.Handle(pattern, handler)
+	}
 
 	// 创建服务器
 	s.server = &http.Server{